@@ -13,22 +13,28 @@ import (
 	"adstxt-api/internal/cache"
 	"adstxt-api/internal/config"
 	"adstxt-api/internal/ratelimit"
+	"adstxt-api/internal/tracing"
 )
 
 func main() {
-	// Initialize structured logger
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+	// Initialize structured logger; request-scoped lines gain trace_id/span_id when TRACING_ENABLED and a tracer provider is installed
+	logger := slog.New(tracing.NewLogHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
-	}))
+	})))
 	slog.SetDefault(logger)
 
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Error("failed to load configuration", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
 
 	logger.Info("loading configuration",
 		slog.String("port", cfg.Port),
 		slog.String("cache_type", cfg.CacheType),
 		slog.Duration("cache_ttl", cfg.CacheTTL),
 		slog.Int("rate_limit", cfg.RateLimitPerSecond),
+		slog.Int("rate_limit_burst", cfg.RateLimitBurst),
 	)
 
 	cacheStore, err := cache.NewCache(cfg.CacheType, cfg)
@@ -38,10 +44,26 @@ func main() {
 	}
 	defer cacheStore.Close()
 
-	rateLimiter := ratelimit.NewRateLimiter(cfg.RateLimitPerSecond)
+	rateLimiter := ratelimit.NewRateLimiterWithCleanup(cfg.RateLimitPerSecond, cfg.RateLimitCleanupInterval, cfg.RateLimitClientTTL)
 	defer rateLimiter.Stop()
+	rateLimiter.SetMaxClients(cfg.RateLimitMaxClients)
+	rateLimiter.SetBurst(cfg.RateLimitBurst)
 
-	handler := api.NewHandler(cacheStore, cfg, logger)
+	handler, err := api.NewHandler(cacheStore, cfg, logger)
+	if err != nil {
+		logger.Error("failed to initialize handler", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	defer handler.Close()
+	handler.AddCleanupMonitor("rate_limiter", rateLimiter)
+	if cfg.SeedDir != "" {
+		seeded, err := handler.SeedCache(cfg.SeedDir)
+		if err != nil {
+			logger.Error("failed to seed cache", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		logger.Info("cache seeded", slog.String("dir", cfg.SeedDir), slog.Int("domains", seeded))
+	}
 	router := api.NewRouter(handler, rateLimiter)
 
 	server := &http.Server{
@@ -64,6 +86,29 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	// Fail readiness first so the load balancer stops routing here, then keep serving
+	// until it has noticed. A second signal skips the wait.
+	handler.BeginShutdown()
+	if cfg.ShutdownDrainDelay > 0 {
+		logger.Info("draining before shutdown", slog.Duration("delay", cfg.ShutdownDrainDelay))
+		select {
+		case <-time.After(cfg.ShutdownDrainDelay):
+		case <-quit:
+		}
+	}
+
+	// Flush before Shutdown, which may be cut short by its deadline; requests still
+	// being drained after this point are not counted in the snapshot
+	if cfg.MetricsFlushOnShutdown {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := handler.FlushMetrics(flushCtx, cfg.MetricsFlushDestination); err != nil {
+			logger.Error("failed to flush metrics", slog.String("error", err.Error()))
+		} else {
+			logger.Info("metrics flushed")
+		}
+		cancel()
+	}
+
 	logger.Info("shutting down server...")
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
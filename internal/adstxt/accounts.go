@@ -0,0 +1,53 @@
+package adstxt
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Account groups for IDs that no configured prefix matches and that have no leading
+// non-digit prefix of their own.
+const (
+	AccountGroupNumeric = "numeric" // All digits, e.g. AppNexus member IDs
+	AccountGroupOther   = "other"   // Starts with a digit but is not all digits, or has no digits at all
+)
+
+// AccountGroup returns the group of an advertiser account ID: the longest of prefixes it
+// starts with (compared case-insensitively and returned as configured), otherwise its own
+// lowercased prefix before the first digit, so pub-1234 and pub-5678 fall under "pub-".
+// IDs without such a prefix are AccountGroupNumeric or AccountGroupOther.
+func AccountGroup(accountID string, prefixes []string) string {
+	lower := strings.ToLower(accountID)
+	best := ""
+	for _, prefix := range prefixes {
+		if len(prefix) > len(best) && strings.HasPrefix(lower, strings.ToLower(prefix)) {
+			best = prefix
+		}
+	}
+	if best != "" {
+		return best
+	}
+
+	digit := strings.IndexFunc(lower, unicode.IsDigit)
+	switch {
+	case digit > 0:
+		return lower[:digit]
+	case digit == 0 && strings.IndexFunc(lower, func(r rune) bool { return !unicode.IsDigit(r) }) < 0:
+		return AccountGroupNumeric
+	default:
+		return AccountGroupOther
+	}
+}
+
+// GroupAccounts sums the record counts of accountIDs (as in RelationshipCounts.AccountIDs)
+// per AccountGroup. Returns nil if there are none.
+func GroupAccounts(accountIDs map[string]int, prefixes []string) map[string]int {
+	if len(accountIDs) == 0 {
+		return nil
+	}
+	groups := make(map[string]int)
+	for id, count := range accountIDs {
+		groups[AccountGroup(id, prefixes)] += count
+	}
+	return groups
+}
@@ -0,0 +1,52 @@
+package adstxt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAccountGroup(t *testing.T) {
+	prefixes := []string{"pub-", "ca-pub-", "CA-"}
+
+	tests := []struct {
+		accountID string
+		prefixes  []string
+		want      string
+	}{
+		{"pub-1234567890", nil, "pub-"},
+		{"PUB-1234", nil, "pub-"},
+		{"ca-pub-1234", nil, "ca-pub-"},
+		{"12345", nil, AccountGroupNumeric},
+		{"1a2b", nil, AccountGroupOther},
+		{"abcdef", nil, AccountGroupOther},
+		{"ca-pub-1234", prefixes, "ca-pub-"}, // Longest match wins
+		{"ca-video-1", prefixes, "CA-"},      // Returned as configured
+		{"pub-1", prefixes, "pub-"},
+		{"sovrn-77", prefixes, "sovrn-"}, // Falls back to its own prefix
+		{"42", prefixes, AccountGroupNumeric},
+	}
+
+	for _, tt := range tests {
+		if got := AccountGroup(tt.accountID, tt.prefixes); got != tt.want {
+			t.Errorf("AccountGroup(%q, %v) = %q, want %q", tt.accountID, tt.prefixes, got, tt.want)
+		}
+	}
+}
+
+func TestGroupAccounts(t *testing.T) {
+	accountIDs := map[string]int{"pub-1": 2, "pub-2": 1, "ca-pub-3": 1, "550": 3}
+
+	want := map[string]int{"pub-": 3, "ca-pub-": 1, AccountGroupNumeric: 3}
+	if got := GroupAccounts(accountIDs, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupAccounts = %v, want %v", got, want)
+	}
+
+	want = map[string]int{"pub-": 3, "ca-": 1, AccountGroupNumeric: 3}
+	if got := GroupAccounts(accountIDs, []string{"ca-", "pub-"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupAccounts with prefixes = %v, want %v", got, want)
+	}
+
+	if got := GroupAccounts(nil, nil); got != nil {
+		t.Errorf("Expected nil for no account IDs, got %v", got)
+	}
+}
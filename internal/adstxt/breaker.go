@@ -0,0 +1,147 @@
+package adstxt
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned, wrapped with the domain, when fetches to a publisher are
+// short-circuited because its recent fetches kept failing (see FetcherOptions.CircuitFailureThreshold).
+var ErrCircuitOpen = errors.New("circuit open")
+
+// Default circuit breaker timing, used when the breaker is enabled without explicit values.
+const (
+	DefaultCircuitWindow   = 1 * time.Minute
+	DefaultCircuitCooldown = 30 * time.Second
+)
+
+// maxBreakerHosts bounds the breaker's host map; when full, hosts whose failures have
+// aged out of the window are dropped, and if that frees nothing the map starts over.
+const maxBreakerHosts = 10000
+
+// circuitBreaker tracks consecutive fetch failures per publisher. After threshold failures
+// within window the host's circuit opens and fetches fail immediately for cooldown. Then a
+// single probe is let through (half-open): success closes the circuit, failure reopens it.
+type circuitBreaker struct {
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu    sync.Mutex
+	hosts map[string]*circuit
+}
+
+// circuit is one host's state. Hosts without recent failures have no entry.
+type circuit struct {
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time // Zero while closed
+	probing      bool      // A half-open probe is in flight
+}
+
+func newCircuitBreaker(threshold int, window, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		now:       time.Now,
+		hosts:     make(map[string]*circuit),
+	}
+}
+
+// allow returns an error wrapping ErrCircuitOpen if host's circuit is open. Once the
+// cooldown has passed it admits exactly one caller as the half-open probe; that caller
+// must report the outcome with record.
+func (b *circuitBreaker) allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.hosts[host]
+	if !ok || c.openedAt.IsZero() {
+		return nil
+	}
+	if retryIn := c.openedAt.Add(b.cooldown).Sub(b.now()); retryIn > 0 {
+		return fmt.Errorf("%w for %s after %d consecutive failures, retrying in %s", ErrCircuitOpen, host, c.failures, retryIn.Round(time.Second))
+	}
+	if c.probing {
+		return fmt.Errorf("%w for %s, recovery probe in progress", ErrCircuitOpen, host)
+	}
+	c.probing = true
+	return nil
+}
+
+// record reports the outcome of a fetch admitted by allow. A success forgets the host;
+// a failure counts towards the threshold, or reopens the circuit after a failed probe.
+func (b *circuitBreaker) record(host string, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		delete(b.hosts, host)
+		return
+	}
+
+	now := b.now()
+	c, ok := b.hosts[host]
+	if !ok {
+		b.prune(now)
+		c = &circuit{}
+		b.hosts[host] = c
+	}
+	if c.probing || !c.openedAt.IsZero() {
+		c.failures++
+		c.openedAt = now
+		c.probing = false
+		return
+	}
+	if c.failures == 0 || now.Sub(c.firstFailure) > b.window {
+		c.failures = 0
+		c.firstFailure = now
+	}
+	c.failures++
+	if c.failures >= b.threshold {
+		c.openedAt = now
+	}
+}
+
+// release ends a half-open probe without a verdict, e.g. because the caller gave up,
+// so the next caller can probe instead.
+func (b *circuitBreaker) release(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c, ok := b.hosts[host]; ok {
+		c.probing = false
+	}
+}
+
+// prune makes room for a new host once the map is full. Called with b.mu held.
+func (b *circuitBreaker) prune(now time.Time) {
+	if len(b.hosts) < maxBreakerHosts {
+		return
+	}
+	for host, c := range b.hosts {
+		if c.openedAt.IsZero() && now.Sub(c.firstFailure) > b.window {
+			delete(b.hosts, host)
+		}
+	}
+	if len(b.hosts) >= maxBreakerHosts {
+		b.hosts = make(map[string]*circuit)
+	}
+}
+
+// isOutage reports whether a fetch error suggests the publisher's server is unavailable:
+// connection failures, timeouts, 5xx responses and bodies cut off mid-read. Any other
+// status (such as 404) and rejected redirects show the server answering, so they never
+// trip the breaker.
+func isOutage(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= 500
+	}
+	var redirectErr *RedirectError
+	return !errors.As(err, &redirectErr)
+}
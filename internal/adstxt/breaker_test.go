@@ -0,0 +1,123 @@
+package adstxt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker(3, time.Minute, 30*time.Second)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	// Failures spread beyond the window never add up to the threshold
+	for i := 0; i < 3; i++ {
+		b.record("example.com", true)
+		now = now.Add(45 * time.Second)
+	}
+	if err := b.allow("example.com"); err != nil {
+		t.Fatalf("Expected the circuit to stay closed when failures are outside the window, got %v", err)
+	}
+
+	b.record("example.com", false)
+	for i := 0; i < 3; i++ {
+		b.record("example.com", true)
+	}
+	if err := b.allow("example.com"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen after 3 consecutive failures, got %v", err)
+	}
+	if err := b.allow("other.com"); err != nil {
+		t.Errorf("Expected other hosts to be unaffected, got %v", err)
+	}
+
+	// After the cooldown exactly one probe is let through
+	now = now.Add(30 * time.Second)
+	if err := b.allow("example.com"); err != nil {
+		t.Fatalf("Expected a half-open probe after the cooldown, got %v", err)
+	}
+	if err := b.allow("example.com"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected other callers to fail fast during the probe, got %v", err)
+	}
+
+	// A failed probe reopens the circuit for another cooldown
+	b.record("example.com", true)
+	now = now.Add(10 * time.Second)
+	if err := b.allow("example.com"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected the circuit to reopen after a failed probe, got %v", err)
+	}
+
+	// An abandoned probe frees the slot; a successful one closes the circuit
+	now = now.Add(30 * time.Second)
+	if err := b.allow("example.com"); err != nil {
+		t.Fatal(err)
+	}
+	b.release("example.com")
+	if err := b.allow("example.com"); err != nil {
+		t.Fatalf("Expected a new probe after the last one was abandoned, got %v", err)
+	}
+	b.record("example.com", false)
+	if err := b.allow("example.com"); err != nil {
+		t.Errorf("Expected the circuit to close after a successful probe, got %v", err)
+	}
+}
+
+func TestIsOutage(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("wrapped: %w", &StatusError{Code: http.StatusServiceUnavailable}), true},
+		{fmt.Errorf("wrapped: %w", &StatusError{Code: http.StatusNotFound}), false},
+		{fmt.Errorf("wrapped: %w", &RedirectError{From: "a.com", To: "b.com"}), false},
+		{errors.New("connection refused"), true},
+	}
+
+	for _, tt := range tests {
+		if got := isOutage(tt.err); got != tt.want {
+			t.Errorf("isOutage(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestFetchAdsTxt_CircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	status := atomic.Int32{}
+	status.Store(http.StatusServiceUnavailable)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	fetcher := NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, CircuitFailureThreshold: 2})
+	for i := 0; i < 2; i++ {
+		if _, err := fetcher.FetchAdsTxt(context.Background(), host); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Expected fetch %d to reach the failing server, got %v", i+1, err)
+		}
+	}
+
+	before := requests.Load()
+	if _, err := fetcher.FetchAdsTxt(context.Background(), host); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen once the threshold is reached, got %v", err)
+	}
+	if requests.Load() != before {
+		t.Error("Expected an open circuit to fail without contacting the server")
+	}
+
+	// A publisher answering 404 is up, so it never trips the breaker
+	status.Store(http.StatusNotFound)
+	fetcher = NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, CircuitFailureThreshold: 2})
+	for i := 0; i < 3; i++ {
+		if _, err := fetcher.FetchAdsTxt(context.Background(), host); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Expected 404s not to open the circuit, got %v on fetch %d", err, i+1)
+		}
+	}
+}
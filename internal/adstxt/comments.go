@@ -0,0 +1,86 @@
+package adstxt
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CommentDirective extracts one named value from comment lines. The value is the
+// pattern's first capture group, or the whole match if it has none.
+type CommentDirective struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// DefaultCommentDirectives recognize conventions commonly emitted by ads.txt tooling:
+// a contact comment ("# Contact: ads@example.com"), a generation or update date
+// ("# Generated on 2025-11-20"), and a file version ("# ads.txt version 1.1").
+var DefaultCommentDirectives = []CommentDirective{
+	{Name: "contact", Pattern: regexp.MustCompile(`(?i)^(?:contact|e-?mail)\s*[:=]?\s*(\S.*)$`)},
+	{Name: "generated", Pattern: regexp.MustCompile(`(?i)\b(?:generated|created|updated|modified|date)\b.*?(\d{4}-\d{2}-\d{2}(?:[T ]\d{2}:\d{2}(?::\d{2})?(?:Z|[+-]\d{2}:?\d{2})?)?)`)},
+	{Name: "version", Pattern: regexp.MustCompile(`(?i)^ads\.txt\s+version\s*[:=]?\s*(\S+)`)},
+}
+
+// ParseCommentDirectives parses "name=regexp" entries into directives.
+// Malformed entries and invalid patterns are skipped and reported together.
+func ParseCommentDirectives(entries []string) ([]CommentDirective, error) {
+	directives := make([]CommentDirective, 0, len(entries))
+	var invalid []string
+	for _, entry := range entries {
+		name, expr, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || expr == "" {
+			invalid = append(invalid, fmt.Sprintf("%q: expected name=regexp", entry))
+			continue
+		}
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%q: %v", entry, err))
+			continue
+		}
+		directives = append(directives, CommentDirective{Name: name, Pattern: pattern})
+	}
+
+	if len(invalid) > 0 {
+		return directives, fmt.Errorf("invalid comment directives %s", strings.Join(invalid, "; "))
+	}
+	return directives, nil
+}
+
+// ExtractCommentMetadata applies directives to the comment block at the top of content,
+// i.e. the comment and blank lines before the first record or variable. Each directive
+// takes its value from the first comment it matches. Returns nil if nothing matched.
+func ExtractCommentMetadata(content string, directives []CommentDirective) map[string]string {
+	var metadata map[string]string
+	content = strings.TrimPrefix(content, "\ufeff")
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+
+		text := strings.TrimSpace(strings.TrimLeft(line, "#"))
+		for _, directive := range directives {
+			if _, found := metadata[directive.Name]; found {
+				continue
+			}
+			match := directive.Pattern.FindStringSubmatch(text)
+			if match == nil {
+				continue
+			}
+			value := match[0]
+			if len(match) > 1 {
+				value = match[1]
+			}
+			if metadata == nil {
+				metadata = make(map[string]string)
+			}
+			metadata[directive.Name] = strings.TrimSpace(value)
+		}
+	}
+	return metadata
+}
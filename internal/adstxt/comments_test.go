@@ -0,0 +1,74 @@
+package adstxt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractCommentMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+	}{
+		{
+			name: "defaults",
+			content: "\ufeff# ads.txt version 1.1\r\n" +
+				"# Contact: ads@example.com\n" +
+				"\n" +
+				"# Generated by AdsTool on 2025-11-20T10:30:00Z\n" +
+				"# contact: second@example.com\n" +
+				"google.com, pub-1, DIRECT\n",
+			want: map[string]string{
+				"version":   "1.1",
+				"contact":   "ads@example.com",
+				"generated": "2025-11-20T10:30:00Z",
+			},
+		},
+		{
+			name:    "only the leading block",
+			content: "google.com, pub-1, DIRECT\n# Contact: ads@example.com\n",
+			want:    nil,
+		},
+		{
+			name:    "variable ends the block",
+			content: "# Last updated: 2024-01-05\ncontact=ads@example.com\n# Contact: late@example.com\n",
+			want:    map[string]string{"generated": "2024-01-05"},
+		},
+		{
+			name:    "generic comments",
+			content: "# Welcome to our ads.txt\n# Questions? See our website\n",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExtractCommentMetadata(tt.content, DefaultCommentDirectives)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractCommentMetadata() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCommentDirectives(t *testing.T) {
+	directives, err := ParseCommentDirectives([]string{
+		`owner=^Owner:\s*(.+)`,
+		`team=Team \w+`,
+		`missing-pattern=`,
+		`broken=(`,
+	})
+	if err == nil {
+		t.Error("Expected an error for the invalid entries")
+	}
+	if len(directives) != 2 {
+		t.Fatalf("Expected 2 valid directives, got %d", len(directives))
+	}
+
+	got := ExtractCommentMetadata("# Owner: Example Media\n# Team Yield\n", directives)
+	want := map[string]string{"owner": "Example Media", "team": "Team Yield"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractCommentMetadata() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package adstxt
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxDNSCacheEntries bounds the resolver cache; when full, expired entries are dropped
+// and, if that frees nothing, the cache starts over.
+const maxDNSCacheEntries = 10000
+
+// hostResolver is the subset of *net.Resolver used by dnsCache, so tests can count lookups.
+type hostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// dnsCache remembers successful host lookups for a fixed TTL, so repeated fetches to the same
+// publisher skip redundant DNS round trips. Failed lookups are never cached.
+type dnsCache struct {
+	resolver hostResolver
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+func newDNSCache(resolver hostResolver, ttl time.Duration) *dnsCache {
+	return &dnsCache{
+		resolver: resolver,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[string]dnsEntry),
+	}
+}
+
+// lookup returns host's addresses, from the cache while the entry is fresh.
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	now := c.now()
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := c.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxDNSCacheEntries {
+		for h, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, h)
+			}
+		}
+		if len(c.entries) >= maxDNSCacheEntries {
+			c.entries = make(map[string]dnsEntry)
+		}
+	}
+	c.entries[host] = dnsEntry{addrs: addrs, expires: now.Add(c.ttl)}
+	return addrs, nil
+}
+
+// dialContext wraps dial so hostnames are resolved through the cache. Each cached address is
+// tried in turn until one connects. Addresses that are already IPs are dialed directly.
+// Any check on the resolved addresses belongs here, so it also covers cached resolutions.
+func (c *dnsCache) dialContext(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+
+		addrs, err := c.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error
+		for _, ip := range addrs {
+			conn, err := dial(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, lastErr
+	}
+}
@@ -0,0 +1,129 @@
+package adstxt
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type countingResolver struct {
+	mu    sync.Mutex
+	addrs map[string][]string
+	calls map[string]int
+}
+
+func newCountingResolver(addrs map[string][]string) *countingResolver {
+	return &countingResolver{addrs: addrs, calls: make(map[string]int)}
+}
+
+func (r *countingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls[host]++
+	addrs, ok := r.addrs[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+func TestDNSCache_Lookup(t *testing.T) {
+	resolver := newCountingResolver(map[string][]string{"example.com": {"192.0.2.1"}})
+	c := newDNSCache(resolver, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		addrs, err := c.lookup(context.Background(), "example.com")
+		if err != nil || !reflect.DeepEqual(addrs, []string{"192.0.2.1"}) {
+			t.Fatalf("lookup = %v, %v", addrs, err)
+		}
+	}
+	if resolver.calls["example.com"] != 1 {
+		t.Errorf("Expected 1 resolution within the TTL, got %d", resolver.calls["example.com"])
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := c.lookup(context.Background(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if resolver.calls["example.com"] != 2 {
+		t.Errorf("Expected a fresh resolution after the TTL, got %d", resolver.calls["example.com"])
+	}
+
+	// Failures are not cached
+	for i := 0; i < 2; i++ {
+		if _, err := c.lookup(context.Background(), "missing.com"); err == nil {
+			t.Fatal("Expected an error for an unknown host")
+		}
+	}
+	if resolver.calls["missing.com"] != 2 {
+		t.Errorf("Expected failed lookups to be retried, got %d resolutions", resolver.calls["missing.com"])
+	}
+}
+
+func TestDNSCache_DialContext(t *testing.T) {
+	resolver := newCountingResolver(map[string][]string{"example.com": {"192.0.2.1", "192.0.2.2"}})
+	c := newDNSCache(resolver, time.Minute)
+
+	var dialed []string
+	dial := c.dialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		if addr == "192.0.2.2:443" || addr == "198.51.100.1:80" {
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		}
+		return nil, errors.New("connection refused")
+	})
+
+	conn, err := dial(context.Background(), "tcp", "example.com:443")
+	if err != nil {
+		t.Fatalf("Expected the second address to connect, got %v", err)
+	}
+	conn.Close()
+	if want := []string{"192.0.2.1:443", "192.0.2.2:443"}; !reflect.DeepEqual(dialed, want) {
+		t.Errorf("dialed %v, want %v", dialed, want)
+	}
+
+	// IP literals bypass the resolver
+	dialed = nil
+	conn, err = dial(context.Background(), "tcp", "198.51.100.1:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if resolver.calls["198.51.100.1"] != 0 || !reflect.DeepEqual(dialed, []string{"198.51.100.1:80"}) {
+		t.Errorf("Expected an IP literal to be dialed directly, dialed %v", dialed)
+	}
+
+	if _, err := dial(context.Background(), "tcp", "missing.com:443"); err == nil {
+		t.Error("Expected a resolution error for an unknown host")
+	}
+}
+
+func TestFetchAdsTxt_DNSCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("google.com, pub-1, DIRECT"))
+	}))
+	defer server.Close()
+
+	// Address the server by name so the dialer resolves it through the cache
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	fetcher := NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, DNSCacheTTL: time.Minute})
+	for i := 0; i < 2; i++ {
+		content, err := fetcher.FetchAdsTxt(context.Background(), net.JoinHostPort("localhost", port))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if content != "google.com, pub-1, DIRECT" {
+			t.Errorf("Unexpected content %q", content)
+		}
+	}
+}
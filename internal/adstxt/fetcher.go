@@ -2,53 +2,353 @@ package adstxt
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
 	"time"
+
+	"adstxt-api/internal/tracing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
 )
 
 const maxResponseSize = 10 << 20 // 10MB max size for ads.txt files
 
+// StatusError is returned when a publisher responds with a non-200 status code.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status code: %d", e.Code)
+}
+
+// Is makes a 404 match ErrNotFound.
+func (e *StatusError) Is(target error) bool {
+	return target == ErrNotFound && e.Code == http.StatusNotFound
+}
+
+// Failure classes of a fetch in which every URL pattern failed, matched with errors.Is on
+// the error from FetchAdsTxt or StreamAdsTxt. Failures that fit none of them (a 5xx, a
+// timeout, a TLS error) match none.
+var (
+	// ErrDNSFailure: no attempted host resolved, so the domain has no web presence at all.
+	ErrDNSFailure = errors.New("domain does not resolve")
+	// ErrConnectionRefused: the domain resolves, but every host that resolved refused the
+	// connection, i.e. nothing listens on the web ports.
+	ErrConnectionRefused = errors.New("connection refused")
+	// ErrNotFound: a web server answered 404 for ads.txt.
+	ErrNotFound = errors.New("ads.txt not found")
+)
+
+// ErrWWWOnly is returned, wrapped with the apex's own failure, when the www fallback is
+// disabled and the file could only have been fetched from https://www.domain/ads.txt.
+var ErrWWWOnly = errors.New("ads.txt is only served on the www host")
+
+// RedirectError is returned when a redirect leaves the publisher's domain while
+// same-domain redirects are enforced (see FetcherOptions.SameDomainRedirectsOnly).
+type RedirectError struct {
+	From string // Host originally requested
+	To   string // Host the redirect pointed at
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("cross-domain redirect from %s to %s", e.From, e.To)
+}
+
+// ErrMalformedDomain is returned, wrapped with the reason, for domains the fetcher refuses to
+// request: too many labels, an empty label, or an ads.txt URL over the length limit.
+var ErrMalformedDomain = errors.New("malformed domain")
+
+// errTooManyRedirects stops a redirect chain longer than 10 hops.
+var errTooManyRedirects = errors.New("too many redirects")
+
+// HTTPFallback controls when a failed https://domain/ads.txt is retried over plain http.
+type HTTPFallback string
+
+const (
+	// HTTPFallbackTransport retries over http only when https got no response at all:
+	// a TLS handshake or certificate failure, a refused or reset connection, a DNS
+	// failure or a timeout. A status such as 404 or 503, a rejected redirect or a body
+	// that is not an ads.txt is the https answer, so http is not tried. The zero value.
+	HTTPFallbackTransport HTTPFallback = "transport"
+	HTTPFallbackAlways    HTTPFallback = "always" // Retry over http after any https failure
+	HTTPFallbackNever     HTTPFallback = "never"  // Never fetch over plain http
+)
+
 // Fetcher handles HTTP requests to retrieve ads.txt files from domains.
 // It tries multiple URL patterns (https, http, www prefix) to maximize success.
 type Fetcher struct {
-	client  *http.Client
-	timeout time.Duration
+	client        *http.Client
+	timeout       time.Duration
+	sem           chan struct{}          // Global outbound request slots; nil means unlimited
+	credentials   map[string]Credentials // Basic auth per lowercased domain; never logged
+	maxLabels     int
+	maxURLLength  int
+	maxAttempts   int
+	breaker       *circuitBreaker // Per-publisher circuit breaker; nil when disabled
+	httpFallback  HTTPFallback
+	noWWWFallback bool
+	race          bool
+	raceStagger   time.Duration
+	onTLSVersion  func(url string) // FetcherOptions.OnTLSVersionRejected
+}
+
+// Default connection pool sizing, based on testing with 50 concurrent requests.
+const (
+	DefaultMaxIdleConns        = 100 // Prevents "too many open files" errors
+	DefaultMaxIdleConnsPerHost = 10  // Sweet spot - tested 5/10/20, 10 performed best
+	DefaultIdleConnTimeout     = 90 * time.Second
+)
+
+const tlsHandshakeTimeout = 5 * time.Second
+
+// dialFunc matches net.Dialer.DialContext and http.Transport's dial hooks.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Default limits on the domains and URLs a Fetcher will request.
+const (
+	DefaultMaxDomainLabels = 10   // Real publisher domains rarely exceed 4-5 labels
+	DefaultMaxURLLength    = 2048 // Widely supported URL length
+	DefaultMaxAttempts     = 3    // Every URL pattern: https apex, http apex, https www
+)
+
+// FetcherOptions configures a Fetcher. Zero values fall back to the defaults noted per field.
+type FetcherOptions struct {
+	Timeout       time.Duration          // Overall timeout for one FetchAdsTxt call
+	MaxConcurrent int                    // Max outbound requests in flight across all callers (0 = unlimited)
+	Credentials   map[string]Credentials // HTTP Basic Auth keyed by domain, sent over https only (nil = no auth)
+	DNSCacheTTL   time.Duration          // How long resolved publisher addresses are reused (0 = no caching)
+	TLSConfig     *tls.Config            // Client certificate and roots for https attempts (nil = system defaults)
+
+	// Hosts, with their subdomains, that TLSConfig's client certificates are presented to;
+	// every other host never sees them. Connections tunnelled through ProxyURL present none.
+	ClientCertHosts []string // (default: none, certificates presented to every host)
+	MinTLSVersion   uint16   // Lowest TLS version for https attempts, e.g. tls.VersionTLS12 (0 = DefaultMinTLSVersion)
+
+	// Called when an https attempt fails because the publisher supports no TLS version at
+	// or above MinTLSVersion, so callers can log it apart from other TLS failures.
+	OnTLSVersionRejected func(url string) // (default: nil)
+
+	// Hosts whose TLS certificates are accepted without verification, e.g. an internal test
+	// server with a self-signed certificate. Matched exactly, so subdomains and redirect
+	// targets are still verified. Meant for testing only; OnSkipVerify is called on every
+	// connection that goes unverified so callers can log it.
+	InsecureSkipVerifyHosts []string          // (default: none, all certificates verified)
+	OnSkipVerify            func(host string) // (default: nil)
+
+	// Egress proxy for every fetch, typically from ParseProxyURL. Userinfo in the URL is
+	// sent as Proxy-Authorization. https targets are tunnelled with CONNECT and verified
+	// against TLSConfig; InsecureSkipVerifyHosts does not apply to tunnelled connections.
+	ProxyURL *url.URL // (default: nil, direct connections)
+
+	// Connection pool sizing. Larger pools let high-concurrency deployments reuse
+	// connections instead of paying TCP/TLS setup per fetch, at the cost of more open
+	// file descriptors and memory held by idle sockets; small deployments can shrink them.
+	// A longer idle timeout improves reuse for domains polled repeatedly but keeps
+	// sockets to one-off publishers open longer.
+	MaxIdleConns        int           // Idle connections kept across all hosts (default: DefaultMaxIdleConns)
+	MaxIdleConnsPerHost int           // Idle connections kept per host (default: DefaultMaxIdleConnsPerHost)
+	IdleConnTimeout     time.Duration // How long an idle connection is kept (default: DefaultIdleConnTimeout)
+
+	// Redirect policy. When SameDomainRedirectsOnly is set, a redirect must stay on the
+	// publisher's registrable domain (its parent, www and other subdomains included) or on
+	// one of RedirectAllowedDomains; anything else fails the attempt with a *RedirectError.
+	SameDomainRedirectsOnly bool     // Reject cross-domain redirects (default: false)
+	RedirectAllowedDomains  []string // Extra redirect targets, subdomains included (default: none)
+
+	// When https://domain/ads.txt fails, whether http://domain/ads.txt is tried before
+	// https://www.domain/ads.txt.
+	HTTPFallback HTTPFallback // (default: HTTPFallbackTransport)
+
+	// Whether https://www.domain/ads.txt is left out, limiting fetches to the exact host
+	// requested. The www variant is then still requested, but only to report ErrWWWOnly if it
+	// would have served the file. Overridden per fetch by WithWWWFallback.
+	NoWWWFallback bool // (default: false)
+
+	// URLs requested per fetch, in pattern order. A pattern skipped by HTTPFallback does not
+	// count, so with 2 a definitive https answer is followed by the www variant only.
+	MaxAttempts int // (default: DefaultMaxAttempts)
+
+	// Whether the URL patterns are requested concurrently rather than one after another, for
+	// publishers whose https hangs until the timeout. Each pattern starts RaceStagger after the
+	// previous one, or as soon as that one fails; the first 200 response wins and the other
+	// requests are cancelled. This costs extra outbound requests. Overridden per fetch by
+	// WithFetchRace.
+	Race        bool          // (default: false)
+	RaceStagger time.Duration // (default: DefaultRaceStagger)
+
+	// Input limits. Domains or ads.txt URLs beyond them fail with ErrMalformedDomain
+	// before any request is made.
+	MaxDomainLabels int // Max dot-separated labels in a domain (default: DefaultMaxDomainLabels)
+	MaxURLLength    int // Max length of a constructed ads.txt URL (default: DefaultMaxURLLength)
+
+	// Circuit breaker. After CircuitFailureThreshold consecutive outage-like failures for a
+	// publisher within CircuitWindow, its fetches fail immediately with ErrCircuitOpen for
+	// CircuitCooldown, after which one probe fetch decides whether to close the circuit.
+	CircuitFailureThreshold int           // Failures that open a circuit (default: 0, disabled)
+	CircuitWindow           time.Duration // How long failures count as consecutive (default: DefaultCircuitWindow)
+	CircuitCooldown         time.Duration // How long an open circuit fails fast (default: DefaultCircuitCooldown)
+}
+
+// Credentials holds HTTP Basic Auth credentials for a protected ads.txt.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// ParseCredentials parses "domain=user:pass" entries into a map keyed by lowercased domain.
+// Malformed entries are skipped and reported by position only, so secrets never end up in errors or logs.
+func ParseCredentials(entries []string) (map[string]Credentials, error) {
+	credentials := make(map[string]Credentials, len(entries))
+	var invalid []string
+	for i, entry := range entries {
+		domain, userinfo, ok := strings.Cut(entry, "=")
+		username, password, hasPassword := strings.Cut(userinfo, ":")
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if !ok || !hasPassword || domain == "" || username == "" {
+			invalid = append(invalid, fmt.Sprintf("#%d", i+1))
+			continue
+		}
+		credentials[domain] = Credentials{Username: username, Password: password}
+	}
+
+	if len(invalid) > 0 {
+		return credentials, fmt.Errorf("malformed credential entries %s, expected domain=user:pass", strings.Join(invalid, ", "))
+	}
+	return credentials, nil
+}
+
+// NewFetcher creates a new Fetcher with the specified timeout and no concurrency cap.
+func NewFetcher(timeout time.Duration) *Fetcher {
+	return NewFetcherWithOptions(FetcherOptions{Timeout: timeout})
 }
 
-// NewFetcher creates a new Fetcher with the specified timeout.
+// NewFetcherWithOptions creates a new Fetcher from opts.
 // Limits redirects to 10 to prevent infinite loops.
 // Connection pooling significantly improves performance for batch requests.
-func NewFetcher(timeout time.Duration) *Fetcher {
-	return &Fetcher{
+func NewFetcherWithOptions(opts FetcherOptions) *Fetcher {
+	if opts.MaxIdleConns <= 0 {
+		opts.MaxIdleConns = DefaultMaxIdleConns
+	}
+	if opts.MaxIdleConnsPerHost <= 0 {
+		opts.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if opts.IdleConnTimeout <= 0 {
+		opts.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	if opts.MaxDomainLabels <= 0 {
+		opts.MaxDomainLabels = DefaultMaxDomainLabels
+	}
+	if opts.MaxURLLength <= 0 {
+		opts.MaxURLLength = DefaultMaxURLLength
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.RaceStagger <= 0 {
+		opts.RaceStagger = DefaultRaceStagger
+	}
+	if opts.CircuitWindow <= 0 {
+		opts.CircuitWindow = DefaultCircuitWindow
+	}
+	if opts.CircuitCooldown <= 0 {
+		opts.CircuitCooldown = DefaultCircuitCooldown
+	}
+	if opts.MinTLSVersion == 0 {
+		opts.MinTLSVersion = DefaultMinTLSVersion
+	}
+	tlsConfig := &tls.Config{}
+	if opts.TLSConfig != nil {
+		tlsConfig = opts.TLSConfig.Clone()
+	}
+	tlsConfig.MinVersion = opts.MinTLSVersion
+
+	dialContext := (&net.Dialer{
+		Timeout:   5 * time.Second, // Protects against slow DNS/connection
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	if opts.DNSCacheTTL > 0 {
+		dialContext = newDNSCache(net.DefaultResolver, opts.DNSCacheTTL).dialContext(dialContext)
+	}
+
+	transport := &http.Transport{
+		DialContext:           dialContext,
+		TLSClientConfig:       tlsConfig,           // Only consulted for https URLs
+		TLSHandshakeTimeout:   tlsHandshakeTimeout, // Prevents slowloris TLS attacks
+		ResponseHeaderTimeout: 5 * time.Second,     // Headers must arrive quickly
+		ExpectContinueTimeout: 1 * time.Second,
+		MaxIdleConns:          opts.MaxIdleConns,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+	}
+	if opts.ProxyURL != nil {
+		transport.Proxy = http.ProxyURL(opts.ProxyURL)
+	}
+	if len(opts.ClientCertHosts) > 0 {
+		// Tunnelled connections use TLSClientConfig, which must not carry the scoped certificates
+		scoped := tlsConfig
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.Certificates = nil
+		transport.TLSClientConfig = tlsConfig
+		transport.DialTLSContext = tlsDialer(dialContext, scoped, opts.InsecureSkipVerifyHosts, opts.ClientCertHosts, opts.OnSkipVerify)
+	} else if len(opts.InsecureSkipVerifyHosts) > 0 {
+		transport.DialTLSContext = tlsDialer(dialContext, tlsConfig, opts.InsecureSkipVerifyHosts, nil, opts.OnSkipVerify)
+	}
+
+	f := &Fetcher{
+		// No client-wide timeout: fetch bounds each call with f.timeout or a WithFetchTimeout override
 		client: &http.Client{
-			Timeout: timeout,
-			Transport: &http.Transport{
-				DialContext: (&net.Dialer{
-					Timeout:   5 * time.Second, // Protects against slow DNS/connection
-					KeepAlive: 30 * time.Second,
-				}).DialContext,
-				TLSHandshakeTimeout:   5 * time.Second, // Prevents slowloris TLS attacks
-				ResponseHeaderTimeout: 5 * time.Second, // Headers must arrive quickly
-				ExpectContinueTimeout: 1 * time.Second,
-				// Connection pool settings based on testing with 50 concurrent requests
-				// MaxIdleConns=100 prevents "too many open files" error
-				// MaxIdleConnsPerHost=10 is sweet spot - tested 5/10/20, 10 performed best
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     90 * time.Second,
-			},
+			Transport: transport,
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				if len(via) >= 10 {
-					return fmt.Errorf("too many redirects")
+					return errTooManyRedirects
+				}
+				// Go keeps Authorization on a same-host redirect, even one down to plain http
+				if req.URL.Scheme != "https" {
+					req.Header.Del("Authorization")
+				}
+				if opts.SameDomainRedirectsOnly {
+					return checkRedirectDomain(via[0].URL.Hostname(), req.URL.Hostname(), opts.RedirectAllowedDomains)
 				}
 				return nil
 			},
 		},
-		timeout: timeout,
+		timeout:       opts.Timeout,
+		credentials:   make(map[string]Credentials, len(opts.Credentials)),
+		maxLabels:     opts.MaxDomainLabels,
+		maxURLLength:  opts.MaxURLLength,
+		httpFallback:  opts.HTTPFallback,
+		noWWWFallback: opts.NoWWWFallback,
+		race:          opts.Race,
+		raceStagger:   opts.RaceStagger,
+		maxAttempts:   opts.MaxAttempts,
+		onTLSVersion:  opts.OnTLSVersionRejected,
+	}
+
+	for domain, creds := range opts.Credentials {
+		f.credentials[strings.ToLower(domain)] = creds
+	}
+
+	if opts.MaxConcurrent > 0 {
+		f.sem = make(chan struct{}, opts.MaxConcurrent)
 	}
+
+	if opts.CircuitFailureThreshold > 0 {
+		f.breaker = newCircuitBreaker(opts.CircuitFailureThreshold, opts.CircuitWindow, opts.CircuitCooldown)
+	}
+
+	return f
 }
 
 // FetchAdsTxt retrieves the ads.txt file content for the given domain.
@@ -57,46 +357,467 @@ func NewFetcher(timeout time.Duration) *Fetcher {
 //  2. http://domain/ads.txt
 //  3. https://www.domain/ads.txt
 //
-// Returns the content of the first successful response, or an error if all attempts fail.
-func (f *Fetcher) FetchAdsTxt(domain string) (string, error) {
+// With the www fallback disabled (see FetcherOptions.NoWWWFallback) the third pattern is
+// only probed, and the fetch fails with ErrWWWOnly if the probe succeeds.
+// In race mode (see FetcherOptions.Race) the patterns are requested concurrently instead.
+// Returns the content of the first successful response transcoded to UTF-8 according to its
+// declared charset, or an error if all attempts fail.
+// If credentials are configured for domain, the https attempts carry a Basic Authorization
+// header; plain http attempts, and redirects to http, never do.
+// Cancelling ctx aborts the in-flight request and any remaining attempts.
+func (f *Fetcher) FetchAdsTxt(ctx context.Context, domain string) (string, error) {
+	var content string
+	err := f.fetch(ctx, domain, func(body io.Reader, contentType string) error {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		content = decodeBody(data, contentType)
+		return nil
+	})
+	return content, err
+}
+
+// StreamAdsTxt fetches domain's ads.txt like FetchAdsTxt but hands the response body to consume
+// as it arrives instead of buffering it, so large files never sit in memory as a whole.
+// The reader is already transcoded to UTF-8 and capped at 10MB. If consume returns an error the
+// next URL pattern is tried, so consume may be called more than once and must start fresh each time.
+func (f *Fetcher) StreamAdsTxt(ctx context.Context, domain string, consume func(io.Reader) error) error {
+	return f.fetch(ctx, domain, func(body io.Reader, contentType string) error {
+		return consume(decodeReader(body, contentType))
+	})
+}
+
+// fetchTimeoutKey is the context key for a WithFetchTimeout override.
+type fetchTimeoutKey struct{}
+
+// WithFetchTimeout returns a context under which fetches are bounded by timeout instead of
+// the fetcher's configured timeout, longer or shorter. ctx's own deadline still applies.
+func WithFetchTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, fetchTimeoutKey{}, timeout)
+}
+
+// FetchTimeoutFromContext returns the WithFetchTimeout override in ctx, if any.
+func FetchTimeoutFromContext(ctx context.Context) (timeout time.Duration, ok bool) {
+	timeout, ok = ctx.Value(fetchTimeoutKey{}).(time.Duration)
+	return timeout, ok
+}
+
+// FetchAttempt is one URL requested by a fetch, as recorded in a FetchTrace.
+type FetchAttempt struct {
+	URL             string  `json:"url"`
+	StatusCode      int     `json:"status,omitempty"` // Status of the final response; 0 if none was received
+	Error           string  `json:"error,omitempty"`  // Why the attempt failed; empty for the one that succeeded
+	DurationSeconds float64 `json:"duration_seconds"`
+	Probe           bool    `json:"probe,omitempty"` // Only checked whether the www variant serves a file, see ErrWWWOnly
+}
+
+// FetchTrace collects the URLs a fetch requested, in order. URL patterns a fetch skipped
+// (see HTTPFallback and MaxAttempts) are not listed.
+type FetchTrace struct {
+	Attempts []FetchAttempt
+}
+
+// fetchTraceKey is the context key for a WithFetchTrace destination.
+type fetchTraceKey struct{}
+
+// WithFetchTrace returns a context under which FetchAdsTxt and StreamAdsTxt append every
+// attempt to trace, whether the fetch succeeds or not. A trace must not be shared between
+// concurrent fetches.
+func WithFetchTrace(ctx context.Context, trace *FetchTrace) context.Context {
+	return context.WithValue(ctx, fetchTraceKey{}, trace)
+}
+
+// traceAttempt records an attempt at url that started at start and ended with err in ctx's
+// FetchTrace, if any.
+func traceAttempt(ctx context.Context, url string, start time.Time, err error, probe bool) {
+	trace, ok := ctx.Value(fetchTraceKey{}).(*FetchTrace)
+	if !ok || trace == nil {
+		return
+	}
+	attempt := FetchAttempt{URL: url, DurationSeconds: time.Since(start).Seconds(), Probe: probe}
+	var statusErr *StatusError
+	switch {
+	case err == nil:
+		attempt.StatusCode = http.StatusOK
+	case errors.As(err, &statusErr):
+		attempt.StatusCode = statusErr.Code
+		attempt.Error = err.Error()
+	default:
+		attempt.Error = err.Error()
+	}
+	trace.Attempts = append(trace.Attempts, attempt)
+}
+
+// wwwFallbackKey is the context key for a WithWWWFallback override.
+type wwwFallbackKey struct{}
+
+// WithWWWFallback returns a context under which fetches fall back to the www variant if
+// enabled is true, whatever the fetcher's NoWWWFallback option says.
+func WithWWWFallback(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, wwwFallbackKey{}, enabled)
+}
+
+// WWWFallbackFromContext returns the WithWWWFallback override in ctx, if any.
+func WWWFallbackFromContext(ctx context.Context) (enabled, ok bool) {
+	enabled, ok = ctx.Value(wwwFallbackKey{}).(bool)
+	return enabled, ok
+}
+
+// FetchInfo describes the response a successful fetch was served from.
+type FetchInfo struct {
+	URL         string // URL pattern that answered, before any redirects
+	StatusCode  int    // Status of the final response
+	Redirects   int    // Redirects followed to reach the final response
+	WWWFallback bool   // Only the www variant answered; the apex patterns failed
+	BodySHA256  string // Hex SHA-256 of the body as received, before charset transcoding
+}
+
+// fetchInfoKey is the context key for a WithFetchInfo destination.
+type fetchInfoKey struct{}
+
+// WithFetchInfo returns a context under which a successful FetchAdsTxt or StreamAdsTxt fills
+// in info. Failed fetches leave it untouched.
+func WithFetchInfo(ctx context.Context, info *FetchInfo) context.Context {
+	return context.WithValue(ctx, fetchInfoKey{}, info)
+}
+
+// fetch tries each URL pattern for domain in order until consume succeeds on a 200 response.
+// The whole attempt is bounded by the fetcher timeout (or a WithFetchTimeout override) and is
+// abandoned early if ctx is cancelled. With the circuit breaker enabled, a publisher whose
+// circuit is open fails immediately. Other outcomes are reported to the breaker, a failure
+// counting only if no URL pattern got an answer (see isOutage); fetches the caller
+// abandoned say nothing about the publisher and are not counted.
+func (f *Fetcher) fetch(ctx context.Context, domain string, consume func(body io.Reader, contentType string) error) (err error) {
+	domain = strings.TrimSuffix(domain, ".") // example.com. names the same host, but not in a URL
+	if err := CheckDomainLabels(domain, f.maxLabels); err != nil {
+		return err
+	}
+	wwwURL := fmt.Sprintf("https://www.%s/ads.txt", domain)
 	urls := []string{
 		fmt.Sprintf("https://%s/ads.txt", domain),
 		fmt.Sprintf("http://%s/ads.txt", domain),
-		fmt.Sprintf("https://www.%s/ads.txt", domain),
+		wwwURL,
+	}
+	for _, url := range urls {
+		if len(url) > f.maxURLLength {
+			return fmt.Errorf("%w: ads.txt URL is %d characters, over the maximum of %d", ErrMalformedDomain, len(url), f.maxURLLength)
+		}
+	}
+
+	answered := false // Some URL pattern got a response showing the publisher is up
+	if f.breaker != nil {
+		host := strings.ToLower(domain)
+		if err := f.breaker.allow(host); err != nil {
+			return err
+		}
+		parent := ctx
+		defer func() {
+			if err != nil && parent.Err() != nil {
+				f.breaker.release(host)
+				return
+			}
+			f.breaker.record(host, err != nil && !answered)
+		}()
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	timeout := f.timeout
+	if override, ok := FetchTimeoutFromContext(ctx); ok && override > 0 {
+		timeout = override
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	var creds *Credentials
+	if c, ok := f.credentials[strings.ToLower(domain)]; ok {
+		creds = &c
+	}
+	wwwFallback := !f.noWWWFallback
+	if enabled, ok := WWWFallbackFromContext(ctx); ok {
+		wwwFallback = enabled
+	}
+	race := f.race
+	if enabled, ok := FetchRaceFromContext(ctx); ok {
+		race = enabled
+	}
+	if race {
+		return f.raceFetch(ctx, domain, urls, wwwFallback, creds, consume, &answered)
+	}
+
 	var lastErr error
+	var errs []error // One per attempt, for classifyFailure
+	var redirectErr *RedirectError
+	attempts := 0
 	for _, url := range urls {
-		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if strings.HasPrefix(url, "http://") && !f.allowHTTPFallback(lastErr) {
+			continue // Same host as the https attempt, which already gave a definitive answer
+		}
+		if attempts == f.maxAttempts {
+			break
+		}
+		attempts++
+		if url == wwwURL && !wwwFallback {
+			start := time.Now()
+			err := f.probeURL(ctx, url, creds)
+			traceAttempt(ctx, url, start, err, true)
+			if err == nil {
+				answered = true
+				return fmt.Errorf("failed to fetch ads.txt for %s: %w: %w", domain, ErrWWWOnly, lastErr)
+			}
+			errs = append(errs, err) // lastErr stays the apex's, the failure being reported
+			answered = answered || !isOutage(err)
+			break
+		}
+		start := time.Now()
+		err := f.fetchURL(ctx, url, creds, consume)
+		traceAttempt(ctx, url, start, err, false)
 		if err != nil {
 			lastErr = err
+			errs = append(errs, err)
+			if f.onTLSVersion != nil && IsTLSVersionError(err) {
+				f.onTLSVersion(url)
+			}
+			answered = answered || !isOutage(err)
+			var re *RedirectError
+			if errors.As(err, &re) {
+				redirectErr = re
+			}
+			if ctx.Err() != nil {
+				// Out of time; the remaining URLs would fail the same way
+				break
+			}
 			continue
 		}
+		if info, ok := ctx.Value(fetchInfoKey{}).(*FetchInfo); ok && info != nil {
+			info.WWWFallback = url == wwwURL
+		}
+		return nil
+	}
 
-		req.Header.Set("User-Agent", "AdsTxtBot/1.0")
+	return fetchFailure(domain, errs, lastErr, redirectErr)
+}
 
-		resp, err := f.client.Do(req)
-		if err != nil {
-			lastErr = err
-			continue
+// fetchFailure returns the error of a fetch of domain whose attempts all failed with errs,
+// lastErr being the one reported.
+func fetchFailure(domain string, errs []error, lastErr error, redirectErr *RedirectError) error {
+	if redirectErr != nil {
+		// A hijacked or misconfigured redirect is the finding worth reporting,
+		// even if a later URL pattern failed for a more mundane reason
+		return fmt.Errorf("failed to fetch ads.txt for %s: %w", domain, redirectErr)
+	}
+	if class := classifyFailure(errs); class != nil {
+		return fmt.Errorf("failed to fetch ads.txt for %s: %w: %w", domain, class, lastErr)
+	}
+	return fmt.Errorf("failed to fetch ads.txt for %s: %w", domain, lastErr)
+}
+
+// probeURL requests url like fetchURL but discards the body and leaves any FetchInfo in ctx
+// untouched, for checking whether a URL the fetch may not use would have served the file.
+func (f *Fetcher) probeURL(ctx context.Context, url string, creds *Credentials) error {
+	ctx = context.WithValue(ctx, fetchInfoKey{}, (*FetchInfo)(nil))
+	return f.fetchURL(ctx, url, creds, func(body io.Reader, _ string) error {
+		_, err := io.Copy(io.Discard, body)
+		return err
+	})
+}
+
+// classifyFailure returns the failure class of a fetch whose attempts all failed with errs:
+// ErrNotFound if any server answered 404, ErrDNSFailure if no host resolved, and
+// ErrConnectionRefused if every host that resolved refused the connection. It returns nil
+// for anything else.
+func classifyFailure(errs []error) error {
+	dnsFailures, refused := 0, 0
+	for _, err := range errs {
+		var dnsErr *net.DNSError
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return ErrNotFound
+		case errors.As(err, &dnsErr):
+			dnsFailures++
+		case errors.Is(err, syscall.ECONNREFUSED):
+			refused++
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode == http.StatusOK {
-			// Limit response size to prevent DoS attacks
-			limitedReader := io.LimitReader(resp.Body, maxResponseSize)
-			body, err := io.ReadAll(limitedReader)
-			if err != nil {
-				lastErr = err
-				continue
-			}
-			return string(body), nil
+	}
+
+	switch {
+	case len(errs) == 0:
+		return nil
+	case dnsFailures == len(errs):
+		return ErrDNSFailure
+	case refused > 0 && dnsFailures+refused == len(errs):
+		return ErrConnectionRefused
+	}
+	return nil
+}
+
+// allowHTTPFallback reports whether http:// should be tried after https failed with err.
+func (f *Fetcher) allowHTTPFallback(err error) bool {
+	switch f.httpFallback {
+	case HTTPFallbackAlways:
+		return true
+	case HTTPFallbackNever:
+		return false
+	default:
+		return isTransportError(err)
+	}
+}
+
+// isTransportError reports whether err means the request got no HTTP response: a TLS or
+// connection failure, a DNS failure or a timeout. Anything else is a definitive answer from
+// the host (a 404 or 503, a rejected redirect, a body that is not an ads.txt), which asking
+// again over another scheme would not change. The client wraps every transport failure in a
+// *url.Error; rejected or endless redirects are wrapped too, but those show the server answering.
+func isTransportError(err error) bool {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return false
+	}
+	var redirectErr *RedirectError
+	return !errors.As(err, &redirectErr) && !errors.Is(err, errTooManyRedirects)
+}
+
+// CheckDomainLabels returns an error wrapping ErrMalformedDomain if domain has an empty
+// label or more than max dot-separated labels. A single trailing dot (the DNS root) is allowed.
+func CheckDomainLabels(domain string, max int) error {
+	labels := strings.Split(strings.TrimSuffix(domain, "."), ".")
+	if len(labels) > max {
+		return fmt.Errorf("%w: %d labels, over the maximum of %d", ErrMalformedDomain, len(labels), max)
+	}
+	for _, label := range labels {
+		if label == "" {
+			return fmt.Errorf("%w: empty label", ErrMalformedDomain)
 		}
-		lastErr = fmt.Errorf("status code: %d", resp.StatusCode)
 	}
+	return nil
+}
 
-	return "", fmt.Errorf("failed to fetch ads.txt for %s: %v", domain, lastErr)
+// checkRedirectDomain returns a *RedirectError unless to shares origin's registrable domain
+// (see RegistrableDomain) or is within allowed. So ads.example.com may redirect to example.com
+// or www.example.com, and example.co.uk to cdn.example.co.uk, but not to another.co.uk.
+// IP addresses only match themselves.
+func checkRedirectDomain(origin, to string, allowed []string) error {
+	origin = strings.ToLower(origin)
+	to = strings.ToLower(to)
+
+	if origin == to || (net.ParseIP(origin) == nil && net.ParseIP(to) == nil && RegistrableDomain(origin) == RegistrableDomain(to)) {
+		return nil
+	}
+	for _, domain := range allowed {
+		if withinDomain(to, strings.ToLower(domain)) {
+			return nil
+		}
+	}
+	return &RedirectError{From: origin, To: to}
+}
+
+// withinDomain reports whether host is domain or one of its subdomains.
+func withinDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// fetchURL performs a single GET request while holding a slot of the global semaphore,
+// passing a 200 response body (limited to maxResponseSize) to consume.
+// Waiting for a slot respects ctx, so callers never block past their deadline.
+// Credentials travel only in the Authorization header, never in the URL, so they cannot leak into
+// errors, and only over https, so they are never sent in cleartext.
+func (f *Fetcher) fetchURL(ctx context.Context, url string, creds *Credentials, consume func(body io.Reader, contentType string) error) (err error) {
+	if f.sem != nil {
+		select {
+		case f.sem <- struct{}{}:
+			defer func() { <-f.sem }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	ctx, span := tracing.StartClient(ctx, url)
+	status := 0
+	defer func() { tracing.End(span, status, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("User-Agent", "AdsTxtBot/1.0")
+	tracing.Inject(ctx, req.Header)
+	if creds != nil && req.URL.Scheme == "https" {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	status = resp.StatusCode
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{Code: resp.StatusCode}
+	}
+
+	// Limit response size to prevent DoS attacks
+	body := io.LimitReader(resp.Body, maxResponseSize)
+	info, _ := ctx.Value(fetchInfoKey{}).(*FetchInfo)
+	hash := sha256.New()
+	if info != nil {
+		body = io.TeeReader(body, hash) // Before consume transcodes it
+	}
+	if err := consume(body, resp.Header.Get("Content-Type")); err != nil {
+		return err
+	}
+	if info != nil {
+		*info = FetchInfo{URL: url, StatusCode: resp.StatusCode, Redirects: redirectCount(resp), BodySHA256: hex.EncodeToString(hash.Sum(nil))}
+	}
+	return nil
+}
+
+// redirectCount returns how many redirects the client followed to get resp. Each request
+// created by a redirect links back to the response that caused it.
+func redirectCount(resp *http.Response) int {
+	n := 0
+	for req := resp.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		n++
+	}
+	return n
+}
+
+// charsetEncoding returns the encoding for the charset declared in contentType,
+// or nil if it is missing, unknown, or already UTF-8.
+func charsetEncoding(contentType string) encoding.Encoding {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil
+	}
+	name := params["charset"]
+	if name == "" || strings.EqualFold(name, "utf-8") {
+		return nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil
+	}
+	return enc
+}
+
+// decodeBody transcodes body to UTF-8 using the charset declared in contentType.
+// Unknown or missing charsets are treated as UTF-8. Any remaining invalid byte
+// sequences are replaced with U+FFFD so downstream JSON output is always valid.
+func decodeBody(body []byte, contentType string) string {
+	if enc := charsetEncoding(contentType); enc != nil {
+		if decoded, err := enc.NewDecoder().Bytes(body); err == nil {
+			body = decoded
+		}
+	}
+
+	return strings.ToValidUTF8(string(body), "\uFFFD")
+}
+
+// decodeReader is the streaming counterpart of decodeBody. Invalid UTF-8 is passed through;
+// the parser only extracts ASCII domain names, so it never reaches JSON output.
+func decodeReader(body io.Reader, contentType string) io.Reader {
+	if enc := charsetEncoding(contentType); enc != nil {
+		return enc.NewDecoder().Reader(body)
+	}
+	return body
 }
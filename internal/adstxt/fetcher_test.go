@@ -1,11 +1,29 @@
 package adstxt
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
+
+	"adstxt-api/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func TestFetchAdsTxt_Success(t *testing.T) {
@@ -26,7 +44,7 @@ func TestFetchAdsTxt_Success(t *testing.T) {
 	// Extract host from server.URL (remove http://)
 	host := strings.TrimPrefix(server.URL, "http://")
 
-	result, err := fetcher.FetchAdsTxt(host)
+	result, err := fetcher.FetchAdsTxt(context.Background(), host)
 	if err != nil {
 		t.Fatalf("FetchAdsTxt() error = %v", err)
 	}
@@ -45,9 +63,60 @@ func TestFetchAdsTxt_NotFound(t *testing.T) {
 	fetcher := NewFetcher(5 * time.Second)
 	host := strings.TrimPrefix(server.URL, "http://")
 
-	_, err := fetcher.FetchAdsTxt(host)
+	_, err := fetcher.FetchAdsTxt(context.Background(), host)
 	if err == nil {
-		t.Error("FetchAdsTxt() expected error for 404, got nil")
+		t.Fatal("FetchAdsTxt() expected error for 404, got nil")
+	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("FetchAdsTxt() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFetchAdsTxt_ConnectionRefused(t *testing.T) {
+	// Take a free port and close it, so nothing listens there
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := listener.Addr().String()
+	listener.Close()
+
+	_, err = NewFetcher(5*time.Second).FetchAdsTxt(context.Background(), host)
+	if !errors.Is(err, ErrConnectionRefused) {
+		t.Errorf("FetchAdsTxt() error = %v, want ErrConnectionRefused", err)
+	}
+	if errors.Is(err, ErrDNSFailure) || errors.Is(err, ErrNotFound) {
+		t.Errorf("FetchAdsTxt() error = %v matches another failure class", err)
+	}
+}
+
+func TestClassifyFailure(t *testing.T) {
+	dnsErr := &url.Error{Op: "Get", URL: "https://nowhere.invalid/ads.txt", Err: &net.DNSError{Err: "no such host", Name: "nowhere.invalid", IsNotFound: true}}
+	refused := &url.Error{Op: "Get", URL: "https://example.com/ads.txt", Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}
+	notFound := &StatusError{Code: http.StatusNotFound}
+	unavailable := &StatusError{Code: http.StatusServiceUnavailable}
+
+	tests := []struct {
+		name string
+		errs []error
+		want error
+	}{
+		{"nothing resolves", []error{dnsErr, dnsErr, dnsErr}, ErrDNSFailure},
+		{"apex refuses, www does not resolve", []error{refused, refused, dnsErr}, ErrConnectionRefused},
+		{"every host refuses", []error{refused, refused, refused}, ErrConnectionRefused},
+		{"server without ads.txt", []error{notFound, dnsErr}, ErrNotFound},
+		{"404 after a refused https", []error{refused, notFound, dnsErr}, ErrNotFound},
+		{"server error", []error{unavailable, dnsErr}, nil},
+		{"refused and timed out", []error{refused, context.DeadlineExceeded}, nil},
+		{"no attempts", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyFailure(tt.errs); got != tt.want {
+				t.Errorf("classifyFailure() = %v, want %v", got, tt.want)
+			}
+		})
 	}
 }
 
@@ -61,12 +130,57 @@ func TestFetchAdsTxt_Timeout(t *testing.T) {
 	fetcher := NewFetcher(100 * time.Millisecond)
 	host := strings.TrimPrefix(server.URL, "http://")
 
-	_, err := fetcher.FetchAdsTxt(host)
+	_, err := fetcher.FetchAdsTxt(context.Background(), host)
 	if err == nil {
 		t.Error("FetchAdsTxt() expected timeout error, got nil")
 	}
 }
 
+func TestFetchAdsTxt_WithFetchTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte("google.com, pub-1, DIRECT"))
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(50 * time.Millisecond)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	// A longer override lets a known-slow host finish
+	if _, err := fetcher.FetchAdsTxt(WithFetchTimeout(context.Background(), 2*time.Second), host); err != nil {
+		t.Errorf("FetchAdsTxt() with a longer timeout failed: %v", err)
+	}
+
+	fetcher = NewFetcher(2 * time.Second)
+	if _, err := fetcher.FetchAdsTxt(WithFetchTimeout(context.Background(), 50*time.Millisecond), host); err == nil {
+		t.Error("FetchAdsTxt() with a shorter timeout expected an error, got nil")
+	}
+}
+
+func TestFetchAdsTxt_ContextCancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	fetcher := NewFetcher(5 * time.Second)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := fetcher.FetchAdsTxt(ctx, host)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("FetchAdsTxt() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("FetchAdsTxt() took %v, expected to stop when ctx was cancelled", elapsed)
+	}
+}
+
 // TestFetchAdsTxt_Redirect tests that the fetcher follows HTTP redirects.
 // Note: This test is skipped because httptest.Server with localhost doesn't work
 // well with the "www." prefix added by the fetcher. In production, this works correctly
@@ -92,7 +206,7 @@ func TestFetchAdsTxt_Redirect(t *testing.T) {
 	fetcher := NewFetcher(5 * time.Second)
 	host := strings.TrimPrefix(server.URL, "http://") + "/redirect"
 
-	result, err := fetcher.FetchAdsTxt(host)
+	result, err := fetcher.FetchAdsTxt(context.Background(), host)
 	if err != nil {
 		t.Fatalf("FetchAdsTxt() error = %v", err)
 	}
@@ -102,6 +216,112 @@ func TestFetchAdsTxt_Redirect(t *testing.T) {
 	}
 }
 
+func TestFetchAdsTxt_PropagatesTrace(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(tracing.Header)
+		_, _ = w.Write([]byte("google.com, pub-123, DIRECT"))
+	}))
+	defer server.Close()
+	domain := strings.TrimPrefix(server.URL, "http://")
+
+	// Without a tracer provider the caller's trace stays in this service
+	caller := httptest.NewRequest("GET", "/api/analyze", nil)
+	caller.Header.Set(tracing.Header, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, span := tracing.StartServer(caller)
+	if _, err := NewFetcher(5*time.Second).FetchAdsTxt(ctx, domain); err != nil {
+		t.Fatalf("FetchAdsTxt() error = %v", err)
+	}
+	span.End()
+	if got != "" {
+		t.Errorf("traceparent = %q without a tracer provider, want none", got)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+	ctx, span = tracing.StartServer(caller)
+	if _, err := NewFetcher(5*time.Second).FetchAdsTxt(ctx, domain); err != nil {
+		t.Fatalf("FetchAdsTxt() error = %v", err)
+	}
+	span.End()
+
+	// Each URL tried gets its own span, a child of the request's, passed on as the parent
+	ended := recorder.Ended()
+	if len(ended) != 3 {
+		t.Fatalf("%d spans ended, want https, the http fallback and the request", len(ended))
+	}
+	for _, fetch := range ended[:2] {
+		if fetch.Parent().SpanID() != span.SpanContext().SpanID() {
+			t.Errorf("fetch span parent = %s, want the request span %s", fetch.Parent().SpanID(), span.SpanContext().SpanID())
+		}
+	}
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + ended[1].SpanContext().SpanID().String() + "-01"
+	if got != want {
+		t.Errorf("traceparent = %q, want %q", got, want)
+	}
+}
+
+func TestFetchAdsTxt_FetchInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ads.txt":
+			http.Redirect(w, r, "/hop1", http.StatusMovedPermanently)
+		case "/hop1":
+			http.Redirect(w, r, "/hop2", http.StatusFound)
+		case "/hop2":
+			http.Redirect(w, r, "/final.txt", http.StatusTemporaryRedirect)
+		case "/final.txt":
+			_, _ = w.Write([]byte("google.com, pub-123, DIRECT"))
+		case "/clean/ads.txt":
+			w.Header().Set("Content-Type", "text/plain; charset=ISO-8859-1")
+			_, _ = w.Write([]byte("ex\xe4mple.com, pub-123, DIRECT"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(5 * time.Second)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	var info FetchInfo
+	if _, err := fetcher.FetchAdsTxt(WithFetchInfo(context.Background(), &info), host); err != nil {
+		t.Fatalf("FetchAdsTxt() error = %v", err)
+	}
+	sum := sha256.Sum256([]byte("google.com, pub-123, DIRECT"))
+	want := FetchInfo{URL: "http://" + host + "/ads.txt", StatusCode: http.StatusOK, Redirects: 3, BodySHA256: hex.EncodeToString(sum[:])}
+	if info != want {
+		t.Errorf("FetchInfo = %+v, want %+v", info, want)
+	}
+
+	// Streaming fills it in too, and a direct answer has no redirects
+	info = FetchInfo{}
+	err := fetcher.StreamAdsTxt(WithFetchInfo(context.Background(), &info), host+"/clean", func(body io.Reader) error {
+		_, err := io.Copy(io.Discard, body)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("StreamAdsTxt() error = %v", err)
+	}
+	if info.StatusCode != http.StatusOK || info.Redirects != 0 {
+		t.Errorf("FetchInfo = %+v, want a 200 without redirects", info)
+	}
+	// The hash is of the ISO-8859-1 bytes served, not of the UTF-8 the consumer read
+	if sum = sha256.Sum256([]byte("ex\xe4mple.com, pub-123, DIRECT")); info.BodySHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("BodySHA256 = %s, want the hash of the bytes served", info.BodySHA256)
+	}
+
+	// A failed fetch leaves it untouched
+	info = FetchInfo{}
+	if _, err := fetcher.FetchAdsTxt(WithFetchInfo(context.Background(), &info), host+"/missing"); err == nil {
+		t.Fatal("FetchAdsTxt() expected error for a missing file")
+	}
+	if info != (FetchInfo{}) {
+		t.Errorf("FetchInfo = %+v after a failed fetch, want zero", info)
+	}
+}
+
 func TestFetchAdsTxt_TooManyRedirects(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Infinite redirect loop
@@ -112,12 +332,389 @@ func TestFetchAdsTxt_TooManyRedirects(t *testing.T) {
 	fetcher := NewFetcher(5 * time.Second)
 	host := strings.TrimPrefix(server.URL, "http://")
 
-	_, err := fetcher.FetchAdsTxt(host)
+	_, err := fetcher.FetchAdsTxt(context.Background(), host)
 	if err == nil {
 		t.Error("FetchAdsTxt() expected error for too many redirects, got nil")
 	}
 }
 
+// schemeRouter sends https requests to one test server and http requests to another,
+// whatever host they name, so a single domain can answer differently per scheme.
+type schemeRouter struct {
+	https, http *httptest.Server
+}
+
+func (s schemeRouter) RoundTrip(req *http.Request) (*http.Response, error) {
+	server := s.http
+	if req.URL.Scheme == "https" {
+		server = s.https
+	}
+	req = req.Clone(req.Context())
+	req.URL.Host = server.Listener.Addr().String()
+	return server.Client().Transport.RoundTrip(req)
+}
+
+func TestFetchAdsTxt_HTTPFallback(t *testing.T) {
+	content := "google.com, pub-123, DIRECT"
+
+	tests := []struct {
+		name        string
+		mode        HTTPFallback
+		httpsStatus int // 0 leaves https unreachable
+		wantHTTP    bool
+	}{
+		{name: "unreachable https falls back", httpsStatus: 0, wantHTTP: true},
+		{name: "https 404 does not fall back", httpsStatus: http.StatusNotFound},
+		{name: "https 503 does not fall back", httpsStatus: http.StatusServiceUnavailable},
+		{name: "always falls back on status", mode: HTTPFallbackAlways, httpsStatus: http.StatusNotFound, wantHTTP: true},
+		{name: "never falls back", mode: HTTPFallbackNever, httpsStatus: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.httpsStatus)
+			}))
+			defer httpsServer.Close()
+			if tt.httpsStatus == 0 {
+				httpsServer.Close()
+			}
+
+			var httpCalls atomic.Int32
+			httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				httpCalls.Add(1)
+				_, _ = w.Write([]byte(content))
+			}))
+			defer httpServer.Close()
+
+			fetcher := NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, HTTPFallback: tt.mode})
+			fetcher.client.Transport = schemeRouter{https: httpsServer, http: httpServer}
+
+			got, err := fetcher.FetchAdsTxt(context.Background(), "publisher.test")
+			if tt.wantHTTP {
+				if err != nil {
+					t.Fatalf("FetchAdsTxt() error = %v", err)
+				}
+				if got != content {
+					t.Errorf("FetchAdsTxt() = %q, want %q", got, content)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("FetchAdsTxt() error = nil, want the https failure")
+			}
+			if n := httpCalls.Load(); n != 0 {
+				t.Errorf("http:// fetched %d times, want 0", n)
+			}
+			var statusErr *StatusError
+			if tt.httpsStatus != 0 && (!errors.As(err, &statusErr) || statusErr.Code != tt.httpsStatus) {
+				t.Errorf("FetchAdsTxt() error = %v, want status %d", err, tt.httpsStatus)
+			}
+		})
+	}
+}
+
+func TestFetchAdsTxt_MaxAttempts(t *testing.T) {
+	content := "google.com, pub-123, DIRECT"
+
+	tests := []struct {
+		name        string
+		maxAttempts int
+		wantErr     bool
+		wantHosts   []string // https hosts requested, in order
+	}{
+		{name: "404 on apex still tries www", wantHosts: []string{"publisher.test", "www.publisher.test"}},
+		{name: "single attempt", maxAttempts: 1, wantErr: true, wantHosts: []string{"publisher.test"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var hosts []string
+			httpsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				hosts = append(hosts, r.Host)
+				mu.Unlock()
+				if !strings.HasPrefix(r.Host, "www.") {
+					http.NotFound(w, r)
+					return
+				}
+				_, _ = w.Write([]byte(content))
+			}))
+			defer httpsServer.Close()
+
+			var httpCalls atomic.Int32
+			httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				httpCalls.Add(1)
+				_, _ = w.Write([]byte(content))
+			}))
+			defer httpServer.Close()
+
+			fetcher := NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, MaxAttempts: tt.maxAttempts})
+			fetcher.client.Transport = schemeRouter{https: httpsServer, http: httpServer}
+
+			got, err := fetcher.FetchAdsTxt(context.Background(), "publisher.test")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FetchAdsTxt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != content {
+				t.Errorf("FetchAdsTxt() = %q, want %q", got, content)
+			}
+			if n := httpCalls.Load(); n != 0 {
+				t.Errorf("http:// fetched %d times after a definitive 404, want 0", n)
+			}
+			if strings.Join(hosts, ",") != strings.Join(tt.wantHosts, ",") {
+				t.Errorf("https hosts requested = %v, want %v", hosts, tt.wantHosts)
+			}
+		})
+	}
+}
+
+func TestFetchAdsTxt_NoWWWFallback(t *testing.T) {
+	content := "google.com, pub-123, DIRECT"
+	enabled, disabled := true, false
+
+	tests := []struct {
+		name          string
+		noWWWFallback bool
+		override      *bool // WithWWWFallback value, if any
+		apexServes    bool
+		wwwServes     bool
+		wantErr       error // nil for success
+		wantFallback  bool  // FetchInfo.WWWFallback on success
+	}{
+		{name: "www fallback by default", wwwServes: true, wantFallback: true},
+		{name: "apex served", noWWWFallback: true, apexServes: true, wwwServes: true},
+		{name: "only www serves", noWWWFallback: true, wwwServes: true, wantErr: ErrWWWOnly},
+		{name: "neither serves", noWWWFallback: true, wantErr: ErrNotFound},
+		{name: "disabled per fetch", override: &disabled, wwwServes: true, wantErr: ErrWWWOnly},
+		{name: "enabled per fetch", noWWWFallback: true, override: &enabled, wwwServes: true, wantFallback: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hosts []string
+			var mu sync.Mutex
+			httpsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				hosts = append(hosts, r.Host)
+				mu.Unlock()
+				if strings.HasPrefix(r.Host, "www.") && tt.wwwServes || !strings.HasPrefix(r.Host, "www.") && tt.apexServes {
+					_, _ = w.Write([]byte(content))
+					return
+				}
+				http.NotFound(w, r)
+			}))
+			defer httpsServer.Close()
+
+			fetcher := NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, NoWWWFallback: tt.noWWWFallback})
+			fetcher.client.Transport = schemeRouter{https: httpsServer, http: httpsServer}
+
+			ctx := context.Background()
+			if tt.override != nil {
+				ctx = WithWWWFallback(ctx, *tt.override)
+			}
+			var info FetchInfo
+			got, err := fetcher.FetchAdsTxt(WithFetchInfo(ctx, &info), "publisher.test")
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("FetchAdsTxt() error = %v, want %v", err, tt.wantErr)
+				}
+				if tt.wantErr == ErrWWWOnly && !errors.Is(err, ErrNotFound) {
+					t.Errorf("Expected the apex's own 404 to be kept, got %v", err)
+				}
+				if info != (FetchInfo{}) {
+					t.Errorf("Expected FetchInfo untouched by a failed fetch, got %+v", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchAdsTxt() error = %v", err)
+			}
+			if got != content {
+				t.Errorf("FetchAdsTxt() = %q, want %q", got, content)
+			}
+			if info.WWWFallback != tt.wantFallback {
+				t.Errorf("FetchInfo.WWWFallback = %v, want %v (hosts %v)", info.WWWFallback, tt.wantFallback, hosts)
+			}
+		})
+	}
+}
+
+func TestFetchAdsTxt_Trace(t *testing.T) {
+	httpsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Host, "www.") {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("google.com, pub-123, DIRECT"))
+	}))
+	defer httpsServer.Close()
+
+	tests := []struct {
+		name          string
+		noWWWFallback bool
+		wantProbe     bool
+	}{
+		{name: "www fallback"},
+		{name: "www probe", noWWWFallback: true, wantProbe: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, NoWWWFallback: tt.noWWWFallback})
+			fetcher.client.Transport = schemeRouter{https: httpsServer, http: httpsServer}
+
+			var trace FetchTrace
+			_, err := fetcher.FetchAdsTxt(WithFetchTrace(context.Background(), &trace), "publisher.test")
+			if (err != nil) != tt.noWWWFallback {
+				t.Fatalf("FetchAdsTxt() error = %v", err)
+			}
+
+			// The apex 404 is definitive, so http:// is skipped and not listed
+			if len(trace.Attempts) != 2 {
+				t.Fatalf("Expected 2 attempts, got %+v", trace.Attempts)
+			}
+			apex, www := trace.Attempts[0], trace.Attempts[1]
+			if apex.URL != "https://publisher.test/ads.txt" || apex.StatusCode != http.StatusNotFound || apex.Error == "" || apex.Probe {
+				t.Errorf("Unexpected apex attempt %+v", apex)
+			}
+			if www.URL != "https://www.publisher.test/ads.txt" || www.StatusCode != http.StatusOK || www.Error != "" || www.Probe != tt.wantProbe {
+				t.Errorf("Unexpected www attempt %+v", www)
+			}
+			if apex.DurationSeconds <= 0 || www.DurationSeconds <= 0 {
+				t.Errorf("Expected attempt durations, got %v and %v", apex.DurationSeconds, www.DurationSeconds)
+			}
+		})
+	}
+}
+
+func TestFetchAdsTxt_SameDomainRedirectsOnly(t *testing.T) {
+	content := "google.com, pub-123, DIRECT"
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(content))
+	}))
+	defer target.Close()
+
+	// The publisher lives on 127.0.0.1 and redirects to the same server under "localhost"
+	elsewhere := strings.Replace(target.URL, "127.0.0.1", "localhost", 1) + "/ads.txt"
+	publisher := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, elsewhere, http.StatusFound)
+	}))
+	defer publisher.Close()
+	host := strings.TrimPrefix(publisher.URL, "http://")
+
+	// Without the policy the redirect is followed
+	if result, err := NewFetcher(5*time.Second).FetchAdsTxt(context.Background(), host); err != nil || result != content {
+		t.Fatalf("FetchAdsTxt() = %q, %v; want the redirected content", result, err)
+	}
+
+	fetcher := NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, SameDomainRedirectsOnly: true})
+	_, err := fetcher.FetchAdsTxt(context.Background(), host)
+	var redirectErr *RedirectError
+	if !errors.As(err, &redirectErr) {
+		t.Fatalf("FetchAdsTxt() error = %v, want a *RedirectError", err)
+	}
+	if redirectErr.From != "127.0.0.1" || redirectErr.To != "localhost" {
+		t.Errorf("RedirectError = %+v, want 127.0.0.1 -> localhost", redirectErr)
+	}
+
+	// An allowlisted target is followed even in same-domain mode
+	fetcher = NewFetcherWithOptions(FetcherOptions{
+		Timeout:                 5 * time.Second,
+		SameDomainRedirectsOnly: true,
+		RedirectAllowedDomains:  []string{"localhost"},
+	})
+	if result, err := fetcher.FetchAdsTxt(context.Background(), host); err != nil || result != content {
+		t.Errorf("FetchAdsTxt() = %q, %v; want the allowlisted redirect to be followed", result, err)
+	}
+}
+
+func TestCheckRedirectDomain(t *testing.T) {
+	tests := []struct {
+		origin, to string
+		allowed    []string
+		wantErr    bool
+	}{
+		{"example.com", "example.com", nil, false},
+		{"example.com", "www.example.com", nil, false},
+		{"www.example.com", "example.com", nil, false},
+		{"example.com", "cdn.example.com", nil, false},
+		{"example.com", "EXAMPLE.com", nil, false},
+		{"example.com", "evil.com", nil, true},
+		{"example.com", "notexample.com", nil, true},
+		{"example.com", "example.com.evil.com", nil, true},
+		{"ads.example.com", "example.com", nil, false},
+		{"ads.example.com", "cdn.example.com", nil, false},
+		{"example.co.uk", "cdn.example.co.uk", nil, false},
+		{"example.co.uk", "another.co.uk", nil, true},
+		{"alice.github.io", "bob.github.io", nil, true},
+		{"127.0.0.1", "127.0.0.1", nil, false},
+		{"127.0.0.1", "10.0.0.1", nil, true},
+		{"example.com", "ads.crawler.net", []string{"crawler.net"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin+"->"+tt.to, func(t *testing.T) {
+			err := checkRedirectDomain(tt.origin, tt.to, tt.allowed)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkRedirectDomain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckDomainLabels(t *testing.T) {
+	tests := []struct {
+		domain  string
+		wantErr bool
+	}{
+		{"example.com", false},
+		{"example.com.", false},
+		{"a.b.c.d.e.f.g.h.i.com", false},
+		{"a.b.c.d.e.f.g.h.i.j.com", true},
+		{"example..com", true},
+		{".example.com", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			err := CheckDomainLabels(tt.domain, DefaultMaxDomainLabels)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckDomainLabels() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrMalformedDomain) {
+				t.Errorf("Expected ErrMalformedDomain, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFetchAdsTxt_MalformedDomain(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	fetcher := NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, MaxURLLength: len("https://www." + host)})
+	if _, err := fetcher.FetchAdsTxt(context.Background(), host); !errors.Is(err, ErrMalformedDomain) {
+		t.Errorf("Expected ErrMalformedDomain for an over-long URL, got %v", err)
+	}
+
+	fetcher = NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, MaxDomainLabels: 3})
+	if _, err := fetcher.FetchAdsTxt(context.Background(), "a.b.c.example.com"); !errors.Is(err, ErrMalformedDomain) {
+		t.Errorf("Expected ErrMalformedDomain for too many labels, got %v", err)
+	}
+
+	if requests != 0 {
+		t.Errorf("Expected no requests for malformed domains, got %d", requests)
+	}
+}
+
 func TestFetchAdsTxt_EmptyContent(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -128,7 +725,7 @@ func TestFetchAdsTxt_EmptyContent(t *testing.T) {
 	fetcher := NewFetcher(5 * time.Second)
 	host := strings.TrimPrefix(server.URL, "http://")
 
-	result, err := fetcher.FetchAdsTxt(host)
+	result, err := fetcher.FetchAdsTxt(context.Background(), host)
 	if err != nil {
 		t.Fatalf("FetchAdsTxt() error = %v", err)
 	}
@@ -154,3 +751,314 @@ func TestNewFetcher(t *testing.T) {
 		t.Error("NewFetcher() client is nil")
 	}
 }
+
+func TestNewFetcherWithOptions_PoolSizing(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        FetcherOptions
+		wantIdle    int
+		wantPerHost int
+		wantTimeout time.Duration
+	}{
+		{"defaults", FetcherOptions{}, DefaultMaxIdleConns, DefaultMaxIdleConnsPerHost, DefaultIdleConnTimeout},
+		{"custom", FetcherOptions{MaxIdleConns: 500, MaxIdleConnsPerHost: 50, IdleConnTimeout: 30 * time.Second}, 500, 50, 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := NewFetcherWithOptions(tt.opts).client.Transport.(*http.Transport)
+
+			if transport.MaxIdleConns != tt.wantIdle {
+				t.Errorf("MaxIdleConns = %d, want %d", transport.MaxIdleConns, tt.wantIdle)
+			}
+			if transport.MaxIdleConnsPerHost != tt.wantPerHost {
+				t.Errorf("MaxIdleConnsPerHost = %d, want %d", transport.MaxIdleConnsPerHost, tt.wantPerHost)
+			}
+			if transport.IdleConnTimeout != tt.wantTimeout {
+				t.Errorf("IdleConnTimeout = %v, want %v", transport.IdleConnTimeout, tt.wantTimeout)
+			}
+		})
+	}
+}
+
+func TestFetchAdsTxt_MaxConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		_, _ = w.Write([]byte("google.com, pub-1, DIRECT"))
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, MaxConcurrent: 2})
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := fetcher.FetchAdsTxt(context.Background(), host); err != nil {
+				t.Errorf("FetchAdsTxt() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("Expected at most 2 concurrent requests, observed %d", peak)
+	}
+}
+
+func TestFetchAdsTxt_MaxConcurrentRespectsTimeout(t *testing.T) {
+	fetcher := NewFetcherWithOptions(FetcherOptions{Timeout: 50 * time.Millisecond, MaxConcurrent: 1})
+
+	// Occupy the only slot so the fetch has to wait
+	fetcher.sem <- struct{}{}
+	defer func() { <-fetcher.sem }()
+
+	start := time.Now()
+	_, err := fetcher.FetchAdsTxt(context.Background(), "example.com")
+	if err == nil {
+		t.Fatal("FetchAdsTxt() expected error while semaphore is full, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("FetchAdsTxt() waited %v, expected to give up at the timeout", elapsed)
+	}
+}
+
+func TestFetchAdsTxt_Latin1(t *testing.T) {
+	// "exämple.com" encoded as ISO-8859-1: ä is the single byte 0xE4
+	body := []byte("ex\xe4mple.com, pub-1, DIRECT\n# caf\xe9")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=ISO-8859-1")
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(5 * time.Second)
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	result, err := fetcher.FetchAdsTxt(context.Background(), host)
+	if err != nil {
+		t.Fatalf("FetchAdsTxt() error = %v", err)
+	}
+
+	want := "exämple.com, pub-1, DIRECT\n# café"
+	if result != want {
+		t.Errorf("FetchAdsTxt() = %q, want %q", result, want)
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{"utf-8 declared", []byte("café"), "text/plain; charset=utf-8", "café"},
+		{"no charset", []byte("café"), "text/plain", "café"},
+		{"latin-1", []byte("caf\xe9"), "text/plain; charset=latin1", "café"},
+		{"unknown charset", []byte("cafe"), "text/plain; charset=bogus", "cafe"},
+		{"invalid utf-8", []byte("caf\xe9"), "", "caf�"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeBody(tt.body, tt.contentType); got != tt.want {
+				t.Errorf("decodeBody() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchAdsTxt_BasicAuth(t *testing.T) {
+	httpsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "partner" || pass != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("google.com, pub-1, DIRECT"))
+	}))
+	defer httpsServer.Close()
+
+	// Unconfigured domains are fetched without credentials, as before
+	plain := NewFetcher(5 * time.Second)
+	plain.client.Transport = schemeRouter{https: httpsServer, http: httpsServer}
+	if _, err := plain.FetchAdsTxt(context.Background(), "publisher.test"); err == nil {
+		t.Fatal("FetchAdsTxt() without credentials expected error, got nil")
+	}
+
+	fetcher := NewFetcherWithOptions(FetcherOptions{
+		Timeout:     5 * time.Second,
+		Credentials: map[string]Credentials{"PUBLISHER.test": {Username: "partner", Password: "s3cret"}},
+	})
+	fetcher.client.Transport = schemeRouter{https: httpsServer, http: httpsServer}
+	result, err := fetcher.FetchAdsTxt(context.Background(), "publisher.test")
+	if err != nil {
+		t.Fatalf("FetchAdsTxt() error = %v", err)
+	}
+	if result != "google.com, pub-1, DIRECT" {
+		t.Errorf("FetchAdsTxt() = %q, want the protected content", result)
+	}
+}
+
+func TestFetchAdsTxt_BasicAuthNotOverHTTP(t *testing.T) {
+	tests := []struct {
+		name  string
+		https http.HandlerFunc
+	}{
+		{
+			name: "http fallback",
+			https: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+		},
+		{
+			name: "redirect to http",
+			https: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "http://"+r.Host+"/ads.txt", http.StatusFound)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpsServer := httptest.NewTLSServer(tt.https)
+			defer httpsServer.Close()
+
+			var mu sync.Mutex
+			var authHeaders []string
+			httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+				mu.Unlock()
+				_, _ = w.Write([]byte("google.com, pub-1, DIRECT"))
+			}))
+			defer httpServer.Close()
+
+			fetcher := NewFetcherWithOptions(FetcherOptions{
+				Timeout:      5 * time.Second,
+				HTTPFallback: HTTPFallbackAlways,
+				Credentials:  map[string]Credentials{"publisher.test": {Username: "partner", Password: "s3cret"}},
+			})
+			fetcher.client.Transport = schemeRouter{https: httpsServer, http: httpServer}
+
+			if _, err := fetcher.FetchAdsTxt(context.Background(), "publisher.test"); err != nil {
+				t.Fatalf("FetchAdsTxt() error = %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(authHeaders) == 0 {
+				t.Fatal("Expected an http:// request")
+			}
+			for _, auth := range authHeaders {
+				if auth != "" {
+					t.Errorf("http:// request carried Authorization %q, want none", auth)
+				}
+			}
+		})
+	}
+}
+
+func TestFetchAdsTxt_BasicAuthNotInErrors(t *testing.T) {
+	httpsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer httpsServer.Close()
+
+	fetcher := NewFetcherWithOptions(FetcherOptions{
+		Timeout:     5 * time.Second,
+		Credentials: map[string]Credentials{"publisher.test": {Username: "partner", Password: "s3cret"}},
+	})
+	fetcher.client.Transport = schemeRouter{https: httpsServer, http: httpsServer}
+	_, err := fetcher.FetchAdsTxt(context.Background(), "publisher.test")
+	if err == nil {
+		t.Fatal("FetchAdsTxt() expected error, got nil")
+	}
+	if strings.Contains(err.Error(), "partner") || strings.Contains(err.Error(), "s3cret") {
+		t.Errorf("FetchAdsTxt() error leaks credentials: %v", err)
+	}
+}
+
+func TestParseCredentials(t *testing.T) {
+	creds, err := ParseCredentials([]string{"Staging.Example.com=user:pa:ss", "bad-entry", "nopass.com=alice", "other.com=u:p"})
+	if err == nil {
+		t.Fatal("ParseCredentials() expected error for malformed entries, got nil")
+	}
+	if strings.Contains(err.Error(), "alice") || strings.Contains(err.Error(), "nopass.com") {
+		t.Errorf("ParseCredentials() error leaks entry contents: %v", err)
+	}
+
+	want := map[string]Credentials{
+		"staging.example.com": {Username: "user", Password: "pa:ss"},
+		"other.com":           {Username: "u", Password: "p"},
+	}
+	if len(creds) != len(want) {
+		t.Fatalf("ParseCredentials() returned %d entries, want %d", len(creds), len(want))
+	}
+	for domain, c := range want {
+		if creds[domain] != c {
+			t.Errorf("ParseCredentials()[%q] = %+v, want %+v", domain, creds[domain], c)
+		}
+	}
+}
+
+func TestStreamAdsTxt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=ISO-8859-1")
+		_, _ = w.Write([]byte("google.com, pub-1, DIRECT\n# caf\xe9\nappnexus.com, 1, RESELLER"))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	var advertisers map[string]int
+	err := NewFetcher(5*time.Second).StreamAdsTxt(context.Background(), host, func(body io.Reader) error {
+		var err error
+		advertisers, err = ParseAdsTxtReader(body)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("StreamAdsTxt() error = %v", err)
+	}
+	if len(advertisers) != 2 || advertisers["google.com"] != 1 || advertisers["appnexus.com"] != 1 {
+		t.Errorf("StreamAdsTxt() parsed %v, want google.com and appnexus.com", advertisers)
+	}
+}
+
+func TestStreamAdsTxt_ConsumeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("google.com, pub-1, DIRECT"))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	// A consume failure counts as a failed attempt, so the remaining URL patterns are still tried
+	calls := 0
+	err := NewFetcher(5*time.Second).StreamAdsTxt(context.Background(), host, func(body io.Reader) error {
+		calls++
+		return errors.New("parse failed")
+	})
+	if err == nil {
+		t.Fatal("StreamAdsTxt() expected error when consume fails, got nil")
+	}
+	if calls != 1 {
+		t.Errorf("Expected consume to be called once for the one reachable URL, got %d", calls)
+	}
+}
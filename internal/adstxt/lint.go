@@ -0,0 +1,83 @@
+package adstxt
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Line kinds reported by Lint.
+const (
+	LineRecord    = "record"
+	LineComment   = "comment"
+	LineVariable  = "variable"
+	LineMalformed = "malformed"
+)
+
+// LineReport describes one non-blank line of an ads.txt file.
+type LineReport struct {
+	Line   int    `json:"line"` // 1-based line number
+	Raw    string `json:"raw"`
+	Kind   string `json:"kind"`
+	Reason string `json:"reason,omitempty"` // Why the line is malformed
+}
+
+// domainPattern matches a whole advertiser domain field, using the same rules as linePattern.
+var domainPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9.-]*\.[a-zA-Z0-9][a-zA-Z0-9-]*$`)
+
+// variablePattern matches a variable line such as "contact=ads@example.com".
+var variablePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*=`)
+
+// Lint classifies every non-blank line of content and gives the reason for each
+// malformed one. It is stricter than the parser, which only needs a leading domain:
+// records must have a valid advertiser domain, an account ID, and a DIRECT or RESELLER relationship.
+func Lint(content string) []LineReport {
+	content = strings.TrimPrefix(content, "\ufeff")
+
+	reports := make([]LineReport, 0)
+	for i, raw := range strings.Split(content, "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+
+		report := LineReport{Line: i + 1, Raw: raw}
+		line := stripComment(raw)
+		switch {
+		case line == "":
+			report.Kind = LineComment
+		case variablePattern.MatchString(line):
+			report.Kind = LineVariable
+		default:
+			if reason := lintRecord(line); reason != "" {
+				report.Kind = LineMalformed
+				report.Reason = reason
+			} else {
+				report.Kind = LineRecord
+			}
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// lintRecord returns why line is not a valid ads.txt record, or "" if it is.
+func lintRecord(line string) string {
+	fields := recordFields(line)
+	if len(fields) < 3 {
+		return fmt.Sprintf("too few fields: expected domain, account ID and relationship, got %d", len(fields))
+	}
+	if len(fields) > 4 {
+		return fmt.Sprintf("too many fields: expected at most 4, got %d (extensions must follow a semicolon)", len(fields))
+	}
+	if !domainPattern.MatchString(fields[0]) {
+		return fmt.Sprintf("invalid advertiser domain %q", fields[0])
+	}
+	if fields[1] == "" {
+		return "missing account ID"
+	}
+	if rel := strings.ToUpper(fields[2]); rel != "DIRECT" && rel != "RESELLER" {
+		return fmt.Sprintf("invalid relationship %q: must be DIRECT or RESELLER", fields[2])
+	}
+	return ""
+}
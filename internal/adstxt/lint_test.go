@@ -0,0 +1,63 @@
+package adstxt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	content := "\ufeff# ads.txt for example.com\r\n" +
+		"google.com, pub-1, DIRECT, f08c47fec0942fa0\r\n" +
+		"\r\n" +
+		"appnexus.com, 1, reseller # inline comment\n" +
+		"openx.com, 2, DIRECT;extension=1\n" +
+		"contact=ads@example.com\n" +
+		"rubicon.com, 3\n" +
+		"not_a_domain, 4, DIRECT\n" +
+		"pubmatic.com, , DIRECT\n" +
+		"indexexchange.com, 5, PARTNER\n" +
+		"a.com, 1, DIRECT, cert, extra\n" +
+		"just some text"
+
+	reports := Lint(content)
+
+	want := []struct {
+		line   int
+		kind   string
+		reason string
+	}{
+		{1, LineComment, ""},
+		{2, LineRecord, ""},
+		{4, LineRecord, ""},
+		{5, LineRecord, ""},
+		{6, LineVariable, ""},
+		{7, LineMalformed, "too few fields"},
+		{8, LineMalformed, "invalid advertiser domain"},
+		{9, LineMalformed, "missing account ID"},
+		{10, LineMalformed, "invalid relationship"},
+		{11, LineMalformed, "too many fields"},
+		{12, LineMalformed, "too few fields"},
+	}
+	if len(reports) != len(want) {
+		t.Fatalf("Expected %d reports, got %d: %+v", len(want), len(reports), reports)
+	}
+	for i, w := range want {
+		r := reports[i]
+		if r.Line != w.line || r.Kind != w.kind || !strings.HasPrefix(r.Reason, w.reason) {
+			t.Errorf("report %d = %+v, want line %d kind %s reason %q...", i, r, w.line, w.kind, w.reason)
+		}
+		if w.reason == "" && r.Reason != "" {
+			t.Errorf("line %d: expected no reason, got %q", r.Line, r.Reason)
+		}
+	}
+
+	if reports[1].Raw != "google.com, pub-1, DIRECT, f08c47fec0942fa0" {
+		t.Errorf("Expected raw line without CR, got %q", reports[1].Raw)
+	}
+}
+
+func TestLint_Empty(t *testing.T) {
+	if reports := Lint(""); reports == nil || len(reports) != 0 {
+		t.Errorf("Expected an empty non-nil report, got %#v", reports)
+	}
+}
@@ -3,42 +3,463 @@
 package adstxt
 
 import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
 	"regexp"
+	"sort"
 	"strings"
+	"unicode"
 )
 
+// DefaultMaxLineLength bounds a single line when ParseOptions.MaxLineLength is unset. Real
+// ads.txt records are well under 1KB, so anything longer is malformed content rather than a
+// record, and is skipped rather than parsed.
+const DefaultMaxLineLength = 8 << 10 // 8KB
+
+// DefaultMaxAccountIDs bounds the distinct account IDs counted per advertiser when
+// ParseOptions.MaxAccountIDs is unset. Even the largest exchanges list a few hundred, so the
+// cap only keeps a hostile file from growing RelationshipCounts.AccountIDs without bound.
+const DefaultMaxAccountIDs = 1000
+
 // AdvertiserCount represents an advertiser domain and the number of times it appears in an ads.txt file.
+// Direct and Reseller break Count down by the relationship field; lines with neither count only in Count.
 type AdvertiserCount struct {
-	Domain string `json:"domain"`
-	Count  int    `json:"count"`
+	Domain          string   `json:"domain"`
+	Count           int      `json:"count"`
+	Direct          int      `json:"direct,omitempty"`
+	Reseller        int      `json:"reseller,omitempty"`
+	CertAuthorities []string `json:"cert_authorities,omitempty"` // Distinct certification authority IDs, sorted
+	Percentage      float64  `json:"percentage,omitempty"`       // Share of all entries, only set on request
+
+	// AccountGroups holds the record count per group of account IDs, see GroupAccounts.
+	// Not filled in by RelationshipsToSlice, which knows no grouping prefixes.
+	AccountGroups map[string]int `json:"account_groups,omitempty"`
+
+	// Subdomains holds the count per listed domain when advertisers were normalized to
+	// their registrable domain and more than one domain, or a subdomain, was merged.
+	Subdomains map[string]int `json:"subdomains,omitempty"`
+}
+
+// RelationshipCounts holds how many times an advertiser appears in total and per relationship.
+type RelationshipCounts struct {
+	Total    int
+	Direct   int
+	Reseller int
+
+	// CertAuthorities is the set of distinct certification authority IDs (the optional
+	// 4th field) seen on the advertiser's records, lower-cased. Nil if none had one.
+	CertAuthorities map[string]bool
+
+	// AccountIDs counts the advertiser's records per account ID (the 2nd field), as written.
+	// Nil if no record had one. At most ParseOptions.MaxAccountIDs distinct IDs are counted;
+	// records with any further ID only count towards the totals.
+	AccountIDs map[string]int
+}
+
+// Entry is one parsed ads.txt record. Fields missing from the record are empty;
+// the domain and relationship are normalized like the counts (lower- and upper-cased).
+type Entry struct {
+	Domain        string `json:"domain"`
+	AccountID     string `json:"account_id"`
+	Relationship  string `json:"relationship,omitempty"`
+	CertAuthority string `json:"cert_authority,omitempty"`
+	Line          int    `json:"line"`              // 1-based line number in the file
+	Lenient       bool   `json:"lenient,omitempty"` // Only accepted by lenient parsing
+}
+
+// entryList collects parsed records up to max (0 means no limit).
+type entryList struct {
+	entries   []Entry
+	max       int
+	truncated bool
+}
+
+// add records one entry from fields, or marks the list truncated once it is full.
+func (l *entryList) add(domain string, fields []string, line int, lenient bool) {
+	if l.max > 0 && len(l.entries) >= l.max {
+		l.truncated = true
+		return
+	}
+	entry := Entry{Domain: domain, Line: line, Lenient: lenient}
+	if len(fields) >= 2 {
+		entry.AccountID = fields[1]
+	}
+	if len(fields) >= 3 {
+		entry.Relationship = strings.ToUpper(fields[2])
+	}
+	if len(fields) >= 4 {
+		entry.CertAuthority = strings.ToLower(fields[3])
+	}
+	l.entries = append(l.entries, entry)
 }
 
 // linePattern matches valid ads.txt lines that start with a domain name.
 // Format: domain.com,publisher_id,relationship,certification_authority_id
 var linePattern = regexp.MustCompile(`^([a-zA-Z0-9][a-zA-Z0-9.-]*\.[a-zA-Z0-9][a-zA-Z0-9-]*),`)
 
+// lenientPattern matches records that linePattern rejects because their fields are
+// separated by whitespace or semicolons (or have spaces before the comma), as some
+// publishers write them. The first token is still taken as the advertiser domain.
+var lenientPattern = regexp.MustCompile(`^([a-zA-Z0-9][a-zA-Z0-9.-]*\.[a-zA-Z0-9][a-zA-Z0-9-]*)\s*[\s;,]\s*[^\s;,#]`)
+
 // ParseAdsTxt parses the content of an ads.txt file and returns a map of advertiser domains to their counts.
-// It ignores empty lines and comments (lines starting with #).
+// It ignores empty lines and comments, both whole-line and inline after a record.
 // Domain names are normalized to lowercase for case-insensitive counting.
 func ParseAdsTxt(content string) map[string]int {
-	advertisers := make(map[string]int)
-	lines := strings.Split(content, "\n")
+	advertisers, _ := ParseAdsTxtWithLimit(content, 0)
+	return advertisers
+}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
+// ParseAdsTxtWithLimit behaves like ParseAdsTxt but tracks at most maxAdvertisers distinct domains.
+// Once the cap is reached, new domains are ignored while existing ones keep being counted.
+// The returned bool reports whether any domain was dropped. A maxAdvertisers of 0 means no limit.
+func ParseAdsTxtWithLimit(content string, maxAdvertisers int) (map[string]int, bool) {
+	counts, truncated := ParseRelationships(content, maxAdvertisers)
+	advertisers := make(map[string]int, len(counts))
+	for domain, c := range counts {
+		advertisers[domain] = c.Total
+	}
+	return advertisers, truncated
+}
 
-		if line == "" || strings.HasPrefix(line, "#") {
+// ParseRelationships behaves like ParseAdsTxtWithLimit but also breaks each advertiser's
+// count down by the relationship field (DIRECT or RESELLER, case-insensitive).
+func ParseRelationships(content string, maxAdvertisers int) (map[string]RelationshipCounts, bool) {
+	result := parseContent(content, ParseOptions{MaxAdvertisers: maxAdvertisers}, false)
+	return result.Advertisers, result.Truncated
+}
+
+// ParseRelationshipsLenient behaves like ParseRelationships but also recovers records whose
+// fields are separated by whitespace or semicolons instead of commas. Recovered records are
+// returned separately from the spec-compliant ones so callers can decide whether to count them.
+// maxAdvertisers applies to each map on its own.
+func ParseRelationshipsLenient(content string, maxAdvertisers int) (advertisers, recovered map[string]RelationshipCounts, truncated bool) {
+	result := ParseLenient(content, ParseOptions{MaxAdvertisers: maxAdvertisers})
+	return result.Advertisers, result.Recovered, result.Truncated
+}
+
+// ParseRelationshipsLenientWithEntries behaves like ParseRelationshipsLenient but also
+// returns every record as an Entry, in file order, including records dropped from the
+// counts by maxAdvertisers. At most maxEntries are kept (0 means no limit), and
+// entriesTruncated reports whether any were left out.
+func ParseRelationshipsLenientWithEntries(content string, maxAdvertisers, maxEntries int) (advertisers, recovered map[string]RelationshipCounts, entries []Entry, truncated, entriesTruncated bool) {
+	result := ParseLenient(content, ParseOptions{MaxAdvertisers: maxAdvertisers, WithEntries: true, MaxEntries: maxEntries})
+	return result.Advertisers, result.Recovered, result.Entries, result.Truncated, result.EntriesTruncated
+}
+
+// ParseOptions configures ParseLenient and ParseLenientReader.
+type ParseOptions struct {
+	MaxAdvertisers int  // Distinct domains tracked in each map, 0 means no limit
+	WithEntries    bool // Also collect every record as an Entry
+	MaxEntries     int  // Entries kept with WithEntries, 0 means no limit
+	MaxLineLength  int  // Longer lines are skipped as malformed (default: DefaultMaxLineLength)
+	MaxAccountIDs  int  // Distinct account IDs counted per advertiser (default: DefaultMaxAccountIDs)
+}
+
+// ParseResult is the outcome of ParseLenient or ParseLenientReader.
+type ParseResult struct {
+	Advertisers      map[string]RelationshipCounts
+	Recovered        map[string]RelationshipCounts // Records only lenient parsing accepts
+	Entries          []Entry                       // Only with ParseOptions.WithEntries
+	Truncated        bool                          // A domain was dropped by MaxAdvertisers
+	EntriesTruncated bool                          // An entry was dropped by MaxEntries
+	SkippedLines     int                           // Lines over MaxLineLength, skipped as malformed
+}
+
+// ParseLenient parses content like ParseRelationshipsLenient, with the limits and extras in opts.
+func ParseLenient(content string, opts ParseOptions) *ParseResult {
+	return parseContent(content, opts, true)
+}
+
+// ParseLenientReader is the streaming counterpart of ParseLenient. Over-length lines are
+// discarded as they are read, so a huge line without newlines never sits in memory.
+// Returns an error only if reading fails.
+func ParseLenientReader(r io.Reader, opts ParseOptions) (*ParseResult, error) {
+	return parseReader(r, opts, true)
+}
+
+func parseContent(content string, opts ParseOptions, lenient bool) *ParseResult {
+	p := newLineParser(opts, lenient)
+	for i, line := range strings.Split(content, "\n") {
+		if len(strings.TrimSuffix(line, "\r")) > p.maxLineLength {
+			p.result.SkippedLines++
 			continue
 		}
+		p.countLine(line, i+1)
+	}
+	return p.finish()
+}
+
+// ParseAdsTxtReader is the streaming counterpart of ParseAdsTxt. It reads r line by line,
+// so a large file is never held in memory as a whole. Lines over DefaultMaxLineLength are
+// skipped. Returns an error if reading fails.
+func ParseAdsTxtReader(r io.Reader) (map[string]int, error) {
+	counts, _, err := ParseRelationshipsReader(r, 0)
+	if err != nil {
+		return nil, err
+	}
 
-		matches := linePattern.FindStringSubmatch(line)
-		if len(matches) >= 2 {
-			domain := strings.ToLower(matches[1])
-			advertisers[domain]++
+	advertisers := make(map[string]int, len(counts))
+	for domain, c := range counts {
+		advertisers[domain] = c.Total
+	}
+	return advertisers, nil
+}
+
+// ParseRelationshipsReader is the streaming counterpart of ParseRelationships.
+func ParseRelationshipsReader(r io.Reader, maxAdvertisers int) (map[string]RelationshipCounts, bool, error) {
+	result, err := parseReader(r, ParseOptions{MaxAdvertisers: maxAdvertisers}, false)
+	if err != nil {
+		return nil, false, err
+	}
+	return result.Advertisers, result.Truncated, nil
+}
+
+// ParseRelationshipsLenientReader is the streaming counterpart of ParseRelationshipsLenient.
+func ParseRelationshipsLenientReader(r io.Reader, maxAdvertisers int) (advertisers, recovered map[string]RelationshipCounts, truncated bool, err error) {
+	result, err := ParseLenientReader(r, ParseOptions{MaxAdvertisers: maxAdvertisers})
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return result.Advertisers, result.Recovered, result.Truncated, nil
+}
+
+// ParseRelationshipsLenientWithEntriesReader is the streaming counterpart of ParseRelationshipsLenientWithEntries.
+func ParseRelationshipsLenientWithEntriesReader(r io.Reader, maxAdvertisers, maxEntries int) (advertisers, recovered map[string]RelationshipCounts, entries []Entry, truncated, entriesTruncated bool, err error) {
+	result, err := ParseLenientReader(r, ParseOptions{MaxAdvertisers: maxAdvertisers, WithEntries: true, MaxEntries: maxEntries})
+	if err != nil {
+		return nil, nil, nil, false, false, err
+	}
+	return result.Advertisers, result.Recovered, result.Entries, result.Truncated, result.EntriesTruncated, nil
+}
+
+func parseReader(r io.Reader, opts ParseOptions, lenient bool) (*ParseResult, error) {
+	p := newLineParser(opts, lenient)
+	br := bufio.NewReader(r)
+	var buf []byte
+	for lineNo := 1; ; lineNo++ {
+		line, tooLong, err := readLine(br, p.maxLineLength, buf[:0])
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("reading ads.txt: %w", err)
+		}
+		if err == io.EOF && len(line) == 0 && !tooLong {
+			break // Nothing after the final newline
+		}
+		if tooLong {
+			p.result.SkippedLines++
+		} else {
+			p.countLine(string(line), lineNo)
+		}
+		if err == io.EOF {
+			break
 		}
+		buf = line
 	}
+	return p.finish(), nil
+}
 
-	return advertisers
+// readLine reads the next line from br into buf without its line ending. A line longer than
+// max is read to its end but not kept: tooLong is set and line is empty. err is io.EOF when
+// the input ended, possibly after a final line without a newline.
+func readLine(br *bufio.Reader, max int, buf []byte) (line []byte, tooLong bool, err error) {
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if !tooLong {
+			if len(buf)+len(chunk) > max+2 { // Room for the "\r\n" trimmed below
+				tooLong, buf = true, buf[:0]
+			} else {
+				buf = append(buf, chunk...)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		buf = bytes.TrimSuffix(bytes.TrimSuffix(buf, []byte("\n")), []byte("\r"))
+		if len(buf) > max {
+			tooLong, buf = true, buf[:0]
+		}
+		return buf, tooLong, err
+	}
+}
+
+// lineParser accumulates a ParseResult one line at a time.
+type lineParser struct {
+	result         ParseResult
+	entries        *entryList
+	maxAdvertisers int
+	maxLineLength  int
+	maxAccountIDs  int
+}
+
+func newLineParser(opts ParseOptions, lenient bool) *lineParser {
+	p := &lineParser{
+		result:         ParseResult{Advertisers: make(map[string]RelationshipCounts)},
+		maxAdvertisers: opts.MaxAdvertisers,
+		maxLineLength:  opts.MaxLineLength,
+		maxAccountIDs:  opts.MaxAccountIDs,
+	}
+	if lenient {
+		p.result.Recovered = make(map[string]RelationshipCounts)
+	}
+	if opts.WithEntries {
+		p.entries = &entryList{max: opts.MaxEntries}
+	}
+	if p.maxLineLength <= 0 {
+		p.maxLineLength = DefaultMaxLineLength
+	}
+	if p.maxAccountIDs <= 0 {
+		p.maxAccountIDs = DefaultMaxAccountIDs
+	}
+	return p
+}
+
+func (p *lineParser) countLine(line string, lineNo int) {
+	if !countLine(p.result.Advertisers, p.result.Recovered, p.entries, line, lineNo, p.maxAdvertisers, p.maxAccountIDs) {
+		p.result.Truncated = true
+	}
+}
+
+func (p *lineParser) finish() *ParseResult {
+	if p.entries != nil {
+		p.result.Entries, p.result.EntriesTruncated = p.entries.entries, p.entries.truncated
+	}
+	return &p.result
+}
+
+// countLine adds one ads.txt line to advertisers. Empty lines, comments, and lines that
+// are not records are ignored; inline comments are stripped before any field is read.
+// If recovered is non-nil, records that only lenientPattern accepts are added to it.
+// If entries is non-nil, each record is also collected there, numbered lineNo. Returns
+// false if the record was dropped because maxAdvertisers distinct domains are already
+// tracked. maxAccountIDs is passed on to addRecord.
+func countLine(advertisers, recovered map[string]RelationshipCounts, entries *entryList, line string, lineNo, maxAdvertisers, maxAccountIDs int) bool {
+	line = stripComment(line)
+	if line == "" {
+		return true
+	}
+
+	if matches := linePattern.FindStringSubmatch(line); len(matches) >= 2 {
+		domain, fields := strings.ToLower(matches[1]), recordFields(line)
+		if entries != nil {
+			entries.add(domain, fields, lineNo, false)
+		}
+		return addRecord(advertisers, domain, fields, maxAdvertisers, maxAccountIDs)
+	}
+	if recovered != nil {
+		if matches := lenientPattern.FindStringSubmatch(line); len(matches) >= 2 {
+			domain, fields := strings.ToLower(matches[1]), lenientRecordFields(line)
+			if entries != nil {
+				entries.add(domain, fields, lineNo, true)
+			}
+			return addRecord(recovered, domain, fields, maxAdvertisers, maxAccountIDs)
+		}
+	}
+	return true
+}
+
+// addRecord counts one record for domain given its fields. Returns false if it was
+// dropped because maxAdvertisers distinct domains are already tracked. Its account ID is
+// only counted if the domain has fewer than maxAccountIDs distinct ones or already has it.
+func addRecord(advertisers map[string]RelationshipCounts, domain string, fields []string, maxAdvertisers, maxAccountIDs int) bool {
+	if _, seen := advertisers[domain]; !seen && maxAdvertisers > 0 && len(advertisers) >= maxAdvertisers {
+		return false
+	}
+
+	c := advertisers[domain]
+	c.Total++
+	if len(fields) >= 3 {
+		switch strings.ToUpper(fields[2]) {
+		case "DIRECT":
+			c.Direct++
+		case "RESELLER":
+			c.Reseller++
+		}
+	}
+	if len(fields) >= 2 && fields[1] != "" {
+		if c.AccountIDs == nil {
+			c.AccountIDs = make(map[string]int)
+		}
+		if _, seen := c.AccountIDs[fields[1]]; seen || len(c.AccountIDs) < maxAccountIDs {
+			c.AccountIDs[fields[1]]++
+		}
+	}
+	if len(fields) >= 4 && fields[3] != "" {
+		if c.CertAuthorities == nil {
+			c.CertAuthorities = make(map[string]bool)
+		}
+		c.CertAuthorities[strings.ToLower(fields[3])] = true
+	}
+	advertisers[domain] = c
+	return true
+}
+
+// stripComment removes a comment from line: everything from the first # that is not
+// inside double quotes to the end of the line. The rest is trimmed of surrounding whitespace,
+// so a line holding only a comment becomes empty.
+func stripComment(line string) string {
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case '#':
+			if !quoted {
+				return strings.TrimSpace(line[:i])
+			}
+		}
+	}
+	return strings.TrimSpace(line)
+}
+
+// recordFields returns the trimmed comma-separated fields of an ads.txt record whose
+// comment has already been stripped, ignoring any extension fields after a semicolon.
+func recordFields(line string) []string {
+	if i := strings.Index(line, ";"); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Split(line, ",")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields
+}
+
+// lenientRecordFields is recordFields for records whose fields may be separated by
+// commas, semicolons, or whitespace in any mix.
+func lenientRecordFields(line string) []string {
+	return strings.FieldsFunc(line, func(r rune) bool {
+		return r == ',' || r == ';' || unicode.IsSpace(r)
+	})
+}
+
+// RelationshipsToSlice converts parsed relationship counts to a slice of AdvertiserCount structs.
+func RelationshipsToSlice(advertisers map[string]RelationshipCounts) []AdvertiserCount {
+	result := make([]AdvertiserCount, 0, len(advertisers))
+	for domain, c := range advertisers {
+		result = append(result, AdvertiserCount{
+			Domain:          domain,
+			Count:           c.Total,
+			Direct:          c.Direct,
+			Reseller:        c.Reseller,
+			CertAuthorities: sortedKeys(c.CertAuthorities),
+		})
+	}
+	return result
+}
+
+// sortedKeys returns the keys of set in ascending order, or nil if set is empty.
+func sortedKeys(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 // MapToSlice converts a map of advertiser domains and counts to a slice of AdvertiserCount structs.
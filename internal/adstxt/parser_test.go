@@ -1,7 +1,10 @@
 package adstxt
 
 import (
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestParseAdsTxt_ValidContent(t *testing.T) {
@@ -84,3 +87,300 @@ func TestMapToSlice(t *testing.T) {
 		}
 	}
 }
+
+func TestParseAdsTxtWithLimit(t *testing.T) {
+	content := `google.com, pub-1, DIRECT
+appnexus.com, 1, RESELLER
+rubiconproject.com, 2, RESELLER
+google.com, pub-2, DIRECT`
+
+	advertisers, truncated := ParseAdsTxtWithLimit(content, 2)
+
+	if !truncated {
+		t.Error("Expected truncated to be true when the cap is exceeded")
+	}
+	if len(advertisers) != 2 {
+		t.Errorf("Expected 2 tracked advertisers, got %d", len(advertisers))
+	}
+	if advertisers["google.com"] != 2 {
+		t.Errorf("Expected existing domain to keep counting, got %d", advertisers["google.com"])
+	}
+	if _, ok := advertisers["rubiconproject.com"]; ok {
+		t.Error("Expected new domain past the cap to be dropped")
+	}
+
+	_, truncated = ParseAdsTxtWithLimit(content, 0)
+	if truncated {
+		t.Error("Expected no truncation with a limit of 0")
+	}
+}
+
+func TestParseRelationships(t *testing.T) {
+	content := `google.com, pub-1, DIRECT, f08c47fec0942fa0
+google.com, pub-2, reseller # lowercase is accepted
+google.com, pub-3, DIRECT;extension=1
+appnexus.com, 1, RESELLER
+openx.com, 2`
+
+	advertisers, _ := ParseRelationships(content, 0)
+
+	want := map[string]RelationshipCounts{
+		"google.com": {Total: 3, Direct: 2, Reseller: 1, CertAuthorities: map[string]bool{"f08c47fec0942fa0": true},
+			AccountIDs: map[string]int{"pub-1": 1, "pub-2": 1, "pub-3": 1}},
+		"appnexus.com": {Total: 1, Reseller: 1, AccountIDs: map[string]int{"1": 1}},
+		"openx.com":    {Total: 1, AccountIDs: map[string]int{"2": 1}},
+	}
+	if len(advertisers) != len(want) {
+		t.Fatalf("Expected %d advertisers, got %d", len(want), len(advertisers))
+	}
+	for domain, c := range want {
+		if !reflect.DeepEqual(advertisers[domain], c) {
+			t.Errorf("%s = %+v, want %+v", domain, advertisers[domain], c)
+		}
+	}
+}
+
+func TestParseRelationships_InlineComments(t *testing.T) {
+	content := `# ads.txt for example.com
+google.com, pub-123, DIRECT # our main partner
+appnexus.com, 1, RESELLER, f5ab79cb980f11d1#no space before the comment
+openx.com, "pub#7", DIRECT, 6a698e2ec38604c6 # quoted # is not a comment
+   # indented comment
+rubicon.com # a comment where fields should be`
+
+	advertisers, _ := ParseRelationships(content, 0)
+
+	want := map[string]RelationshipCounts{
+		"google.com":   {Total: 1, Direct: 1, AccountIDs: map[string]int{"pub-123": 1}},
+		"appnexus.com": {Total: 1, Reseller: 1, CertAuthorities: map[string]bool{"f5ab79cb980f11d1": true}, AccountIDs: map[string]int{"1": 1}},
+		"openx.com":    {Total: 1, Direct: 1, CertAuthorities: map[string]bool{"6a698e2ec38604c6": true}, AccountIDs: map[string]int{`"pub#7"`: 1}},
+	}
+	if len(advertisers) != len(want) {
+		t.Fatalf("Expected %d advertisers, got %d: %+v", len(want), len(advertisers), advertisers)
+	}
+	for domain, c := range want {
+		if !reflect.DeepEqual(advertisers[domain], c) {
+			t.Errorf("%s = %+v, want %+v", domain, advertisers[domain], c)
+		}
+	}
+}
+
+func TestStripComment(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"google.com, pub-1, DIRECT # partner", "google.com, pub-1, DIRECT"},
+		{"  # whole line", ""},
+		{"google.com, pub-1, DIRECT", "google.com, pub-1, DIRECT"},
+		{`google.com, "pub#1", DIRECT #x`, `google.com, "pub#1", DIRECT`},
+		{`google.com, "unterminated#, DIRECT`, `google.com, "unterminated#, DIRECT`},
+	}
+
+	for _, tt := range tests {
+		if got := stripComment(tt.line); got != tt.want {
+			t.Errorf("stripComment(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseRelationshipsLenient(t *testing.T) {
+	content := `google.com, pub-1, DIRECT
+google.com	pub-2	DIRECT
+google.com pub-3 reseller # spaces instead of commas
+appnexus.com;1;RESELLER
+openx.com , 2, DIRECT
+contact=ads@example.com
+rubicon.com # no fields
+invalid line`
+
+	advertisers, recovered, _ := ParseRelationshipsLenient(content, 0)
+
+	if want := (RelationshipCounts{Total: 1, Direct: 1, AccountIDs: map[string]int{"pub-1": 1}}); !reflect.DeepEqual(advertisers["google.com"], want) || len(advertisers) != 1 {
+		t.Errorf("Expected only the comma-separated google.com record as strict, got %+v", advertisers)
+	}
+
+	want := map[string]RelationshipCounts{
+		"google.com":   {Total: 2, Direct: 1, Reseller: 1, AccountIDs: map[string]int{"pub-2": 1, "pub-3": 1}},
+		"appnexus.com": {Total: 1, Reseller: 1, AccountIDs: map[string]int{"1": 1}},
+		"openx.com":    {Total: 1, Direct: 1, AccountIDs: map[string]int{"2": 1}},
+	}
+	if len(recovered) != len(want) {
+		t.Fatalf("Expected %d recovered advertisers, got %d: %+v", len(want), len(recovered), recovered)
+	}
+	for domain, c := range want {
+		if !reflect.DeepEqual(recovered[domain], c) {
+			t.Errorf("%s = %+v, want %+v", domain, recovered[domain], c)
+		}
+	}
+
+	// The streaming parser recovers the same records
+	_, streamed, _, err := ParseRelationshipsLenientReader(strings.NewReader(content), 0)
+	if err != nil {
+		t.Fatalf("ParseRelationshipsLenientReader() error = %v", err)
+	}
+	if len(streamed) != len(recovered) {
+		t.Errorf("Expected %d recovered advertisers from the reader, got %d", len(recovered), len(streamed))
+	}
+
+	// Strict parsing ignores the same records
+	if strict, _ := ParseRelationships(content, 0); len(strict) != 1 {
+		t.Errorf("Expected strict parsing to find 1 advertiser, got %d", len(strict))
+	}
+}
+
+func TestParseRelationshipsLenientWithEntries(t *testing.T) {
+	content := `# ads.txt
+Google.com, pub-1, direct, F08C47FEC0942FA0
+appnexus.com;1;RESELLER
+
+openx.com, 2 # no relationship
+contact=ads@example.com`
+
+	advertisers, recovered, entries, truncated, entriesTruncated := ParseRelationshipsLenientWithEntries(content, 0, 0)
+
+	want := []Entry{
+		{Domain: "google.com", AccountID: "pub-1", Relationship: "DIRECT", CertAuthority: "f08c47fec0942fa0", Line: 2},
+		{Domain: "appnexus.com", AccountID: "1", Relationship: "RESELLER", Line: 3, Lenient: true},
+		{Domain: "openx.com", AccountID: "2", Line: 5},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("entries = %+v, want %+v", entries, want)
+	}
+	if truncated || entriesTruncated {
+		t.Errorf("Expected nothing truncated, got truncated=%t entriesTruncated=%t", truncated, entriesTruncated)
+	}
+	if len(advertisers) != 2 || len(recovered) != 1 {
+		t.Errorf("Expected the counts to match ParseRelationshipsLenient, got %+v and %+v", advertisers, recovered)
+	}
+
+	// The streaming parser returns the same entries
+	_, _, streamed, _, _, err := ParseRelationshipsLenientWithEntriesReader(strings.NewReader(content), 0, 0)
+	if err != nil {
+		t.Fatalf("ParseRelationshipsLenientWithEntriesReader() error = %v", err)
+	}
+	if !reflect.DeepEqual(streamed, want) {
+		t.Errorf("streamed entries = %+v, want %+v", streamed, want)
+	}
+
+	// Entries are capped on their own
+	_, _, entries, _, entriesTruncated = ParseRelationshipsLenientWithEntries(content, 0, 2)
+	if len(entries) != 2 || !entriesTruncated {
+		t.Errorf("Expected 2 entries and entriesTruncated, got %d and %t", len(entries), entriesTruncated)
+	}
+}
+
+func TestParseRelationships_CertAuthorities(t *testing.T) {
+	content := `google.com, pub-1, DIRECT, f08c47fec0942fa0
+google.com, pub-2, RESELLER, F08C47FEC0942FA0
+google.com, pub-3, DIRECT, abc123 # second certifier
+google.com, pub-4, DIRECT,
+appnexus.com, 1, RESELLER
+openx.com pub-5 DIRECT 6a698e2ec38604c6`
+
+	advertisers, recovered, _ := ParseRelationshipsLenient(content, 0)
+
+	want := map[string]bool{"f08c47fec0942fa0": true, "abc123": true}
+	if got := advertisers["google.com"].CertAuthorities; !reflect.DeepEqual(got, want) {
+		t.Errorf("google.com cert authorities = %v, want %v", got, want)
+	}
+	if got := advertisers["appnexus.com"].CertAuthorities; got != nil {
+		t.Errorf("Expected no cert authorities for appnexus.com, got %v", got)
+	}
+	if got := recovered["openx.com"].CertAuthorities; !got["6a698e2ec38604c6"] {
+		t.Errorf("Expected lenient record's cert authority to be recovered, got %v", got)
+	}
+
+	slice := RelationshipsToSlice(advertisers)
+	for _, adv := range slice {
+		if adv.Domain == "google.com" && !reflect.DeepEqual(adv.CertAuthorities, []string{"abc123", "f08c47fec0942fa0"}) {
+			t.Errorf("Expected sorted cert authorities, got %v", adv.CertAuthorities)
+		}
+	}
+}
+
+func TestParseAdsTxtReader(t *testing.T) {
+	content := "google.com, pub-1, DIRECT\r\n# comment\r\nappnexus.com, 1, RESELLER\r\ngoogle.com, pub-2, DIRECT\r\ninvalid line"
+
+	advertisers, err := ParseAdsTxtReader(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("ParseAdsTxtReader() error = %v", err)
+	}
+
+	want := ParseAdsTxt(content)
+	if len(advertisers) != len(want) {
+		t.Fatalf("Expected %d advertisers, got %d", len(want), len(advertisers))
+	}
+	for domain, count := range want {
+		if advertisers[domain] != count {
+			t.Errorf("%s = %d, want %d", domain, advertisers[domain], count)
+		}
+	}
+}
+
+func TestParseAdsTxtReader_LineTooLong(t *testing.T) {
+	content := "google.com, pub-1, DIRECT\n" + strings.Repeat("x", DefaultMaxLineLength+1)
+
+	advertisers, err := ParseAdsTxtReader(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("ParseAdsTxtReader() error = %v, want the over-length line skipped", err)
+	}
+	if advertisers["google.com"] != 1 || len(advertisers) != 1 {
+		t.Errorf("Expected only google.com to be counted, got %v", advertisers)
+	}
+}
+
+func TestParseLenient_LineTooLong(t *testing.T) {
+	// A multi-megabyte line without newlines between valid records, and one just at the limit
+	huge := "evil.com, " + strings.Repeat("A", 3<<20)
+	atLimit := "openx.com, 1, DIRECT, " + strings.Repeat("a", 100-len("openx.com, 1, DIRECT, "))
+	content := "google.com, pub-1, DIRECT\r\n" + huge + "\r\n" + atLimit + "\r\n" +
+		strings.Repeat("#", 101) + "\nappnexus.com, 2, RESELLER"
+	opts := ParseOptions{MaxLineLength: 100, WithEntries: true}
+
+	check := func(t *testing.T, result *ParseResult) {
+		t.Helper()
+		if result.SkippedLines != 2 {
+			t.Errorf("SkippedLines = %d, want 2", result.SkippedLines)
+		}
+		for _, domain := range []string{"google.com", "openx.com", "appnexus.com"} {
+			if result.Advertisers[domain].Total != 1 {
+				t.Errorf("%s = %d, want 1", domain, result.Advertisers[domain].Total)
+			}
+		}
+		if _, ok := result.Advertisers["evil.com"]; ok {
+			t.Error("Expected the over-length line not to be counted")
+		}
+		// Skipped lines still advance the line numbers
+		if n := len(result.Entries); n != 3 || result.Entries[2].Line != 5 {
+			t.Errorf("Expected appnexus.com as entry 3 on line 5, got %+v", result.Entries)
+		}
+	}
+
+	t.Run("string", func(t *testing.T) {
+		check(t, ParseLenient(content, opts))
+	})
+	t.Run("reader", func(t *testing.T) {
+		// A small read buffer makes the huge line arrive in many chunks
+		result, err := ParseLenientReader(iotest.OneByteReader(strings.NewReader(content)), opts)
+		if err != nil {
+			t.Fatalf("ParseLenientReader() error = %v", err)
+		}
+		check(t, result)
+	})
+}
+
+func TestParseLenient_MaxAccountIDs(t *testing.T) {
+	content := "google.com, pub-1, DIRECT\ngoogle.com, pub-2, DIRECT\ngoogle.com, pub-3, RESELLER\n" +
+		"google.com, pub-1, RESELLER\nappnexus.com, 1, DIRECT\nappnexus.com, 2, DIRECT"
+	result := ParseLenient(content, ParseOptions{MaxAccountIDs: 2})
+
+	// Further IDs still count towards the totals, and IDs already tracked keep being counted
+	want := map[string]RelationshipCounts{
+		"google.com":   {Total: 4, Direct: 2, Reseller: 2, AccountIDs: map[string]int{"pub-1": 2, "pub-2": 1}},
+		"appnexus.com": {Total: 2, Direct: 2, AccountIDs: map[string]int{"1": 1, "2": 1}},
+	}
+	if !reflect.DeepEqual(result.Advertisers, want) {
+		t.Errorf("Advertisers = %+v, want %+v", result.Advertisers, want)
+	}
+}
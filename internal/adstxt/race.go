@@ -0,0 +1,189 @@
+package adstxt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRaceStagger is how long a raced fetch waits on one URL pattern before also
+// requesting the next, after the Connection Attempt Delay of Happy Eyeballs (RFC 8305).
+const DefaultRaceStagger = 250 * time.Millisecond
+
+// errRaceLost fails a raced attempt whose 200 response arrived after another URL pattern won.
+var errRaceLost = errors.New("another URL answered first")
+
+// fetchRaceKey is the context key for a WithFetchRace override.
+type fetchRaceKey struct{}
+
+// WithFetchRace returns a context under which fetches race their URL patterns if enabled is
+// true, or request them one after another if false, whatever the fetcher's Race option says.
+func WithFetchRace(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, fetchRaceKey{}, enabled)
+}
+
+// FetchRaceFromContext returns the WithFetchRace override in ctx, if any.
+func FetchRaceFromContext(ctx context.Context) (enabled, ok bool) {
+	enabled, ok = ctx.Value(fetchRaceKey{}).(bool)
+	return enabled, ok
+}
+
+// raceAttempt is the outcome of one raced URL.
+type raceAttempt struct {
+	url   string
+	start time.Time
+	err   error
+}
+
+// raceFetch is fetch in race mode (see FetcherOptions.Race): urls, in fetch's pattern order, are
+// requested concurrently with a stagger until one succeeds or all have failed. The rest are then
+// cancelled and waited for, so every attempt is traced and none outlives the fetch.
+// The rules of the ordered fetch still hold: HTTPFallbackNever leaves http out, MaxAttempts caps
+// the requests, and without the www fallback www is only probed once the others failed. Under
+// HTTPFallbackTransport an http response waits for the https outcome and is only used if https
+// failed without an answer; a definitive https answer cancels the http request instead.
+// Response bodies are consumed one at a time, so consume needs no locking.
+func (f *Fetcher) raceFetch(ctx context.Context, domain string, urls []string, wwwFallback bool, creds *Credentials, consume func(body io.Reader, contentType string) error, answered *bool) error {
+	httpsURL, httpURL, wwwURL := urls[0], urls[1], urls[2]
+	candidates := []string{httpsURL}
+	if f.httpFallback != HTTPFallbackNever {
+		candidates = append(candidates, httpURL)
+	}
+	if wwwFallback {
+		candidates = append(candidates, wwwURL)
+	}
+
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	httpCtx, cancelHTTP := context.WithCancel(raceCtx)
+	defer cancelHTTP()
+	httpsSettled := make(chan struct{})               // Closed once the https outcome is known
+	httpGated := f.httpFallback != HTTPFallbackAlways // HTTPFallbackTransport, the default
+
+	var consumeMu sync.Mutex
+	var won atomic.Bool
+	results := make(chan raceAttempt, len(candidates))
+	launch := func(url string) {
+		attemptCtx := raceCtx
+		if url == httpURL {
+			attemptCtx = httpCtx
+		}
+		go func() {
+			start := time.Now()
+			err := f.fetchURL(attemptCtx, url, creds, func(body io.Reader, contentType string) error {
+				if url == httpURL && httpGated {
+					select {
+					case <-httpsSettled:
+					case <-httpCtx.Done():
+					}
+					if err := httpCtx.Err(); err != nil {
+						return err
+					}
+				}
+				consumeMu.Lock()
+				defer consumeMu.Unlock()
+				if won.Load() {
+					return errRaceLost
+				}
+				if err := consume(body, contentType); err != nil {
+					return err
+				}
+				won.Store(true)
+				return nil
+			})
+			results <- raceAttempt{url: url, start: start, err: err}
+		}()
+	}
+
+	var lastErr, httpsErr error
+	var errs []error // One per failed attempt, for classifyFailure
+	var redirectErr *RedirectError
+	winner := ""
+	httpsDone, httpCancelled := false, false
+	attempts, pending, next := 0, 0, 0
+	stagger := time.NewTimer(0)
+	defer stagger.Stop()
+	for next < len(candidates) || pending > 0 {
+		var due <-chan time.Time
+		if next < len(candidates) {
+			due = stagger.C
+		}
+
+		select {
+		case <-due:
+			url := candidates[next]
+			next++
+			switch {
+			case attempts == f.maxAttempts || won.Load() || (attempts > 0 && raceCtx.Err() != nil):
+				next = len(candidates)
+				continue
+			case url == httpURL && httpsDone && !f.allowHTTPFallback(httpsErr):
+				stagger.Reset(0) // Same host as https, which already gave a definitive answer
+				continue
+			}
+			attempts++
+			pending++
+			launch(url)
+			stagger.Reset(f.raceStagger)
+
+		case res := <-results:
+			pending--
+			traceAttempt(ctx, res.url, res.start, res.err, false)
+			if res.url == httpsURL {
+				if res.err != nil && !f.allowHTTPFallback(res.err) {
+					httpCancelled = true // Same host as https, which gave a definitive answer
+					cancelHTTP()
+				}
+				close(httpsSettled)
+			}
+			switch {
+			case res.err == nil:
+				winner = res.url
+				next = len(candidates)
+				cancel()
+				continue
+			case winner != "" || errors.Is(res.err, errRaceLost) || (res.url == httpURL && httpCancelled):
+				continue // Cancelled or beaten, says nothing about the publisher
+			}
+
+			if res.url == httpsURL {
+				httpsDone, httpsErr = true, res.err
+			}
+			lastErr = res.err
+			errs = append(errs, res.err)
+			if f.onTLSVersion != nil && IsTLSVersionError(res.err) {
+				f.onTLSVersion(res.url)
+			}
+			*answered = *answered || !isOutage(res.err)
+			var re *RedirectError
+			if errors.As(res.err, &re) {
+				redirectErr = re
+			}
+			stagger.Reset(0) // A failure starts the next pattern without waiting out the stagger
+		}
+	}
+
+	if winner != "" {
+		if info, ok := ctx.Value(fetchInfoKey{}).(*FetchInfo); ok && info != nil {
+			info.WWWFallback = winner == wwwURL
+		}
+		return nil
+	}
+
+	if !wwwFallback && attempts < f.maxAttempts && ctx.Err() == nil {
+		start := time.Now()
+		err := f.probeURL(ctx, wwwURL, creds)
+		traceAttempt(ctx, wwwURL, start, err, true)
+		if err == nil {
+			*answered = true
+			return fmt.Errorf("failed to fetch ads.txt for %s: %w: %w", domain, ErrWWWOnly, lastErr)
+		}
+		errs = append(errs, err) // lastErr stays the apex's, the failure being reported
+		*answered = *answered || !isOutage(err)
+	}
+	return fetchFailure(domain, errs, lastErr, redirectErr)
+}
@@ -0,0 +1,228 @@
+package adstxt
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchAdsTxt_Race(t *testing.T) {
+	content := "google.com, pub-123, DIRECT"
+
+	// https hangs until its request is cancelled; http answers at once
+	httpsCancelled := make(chan struct{}, 1)
+	httpsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		httpsCancelled <- struct{}{}
+	}))
+	defer httpsServer.Close()
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(content))
+	}))
+	defer httpServer.Close()
+
+	// Under the default HTTPFallbackTransport http would wait for https instead
+	fetcher := NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, Race: true, RaceStagger: 20 * time.Millisecond, HTTPFallback: HTTPFallbackAlways})
+	fetcher.client.Transport = schemeRouter{https: httpsServer, http: httpServer}
+
+	var info FetchInfo
+	var trace FetchTrace
+	ctx := WithFetchTrace(WithFetchInfo(context.Background(), &info), &trace)
+	start := time.Now()
+	got, err := fetcher.FetchAdsTxt(ctx, "publisher.test")
+	if err != nil {
+		t.Fatalf("FetchAdsTxt() error = %v", err)
+	}
+	if got != content {
+		t.Errorf("FetchAdsTxt() = %q, want %q", got, content)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("FetchAdsTxt() took %v, want the http answer without waiting for https", elapsed)
+	}
+	if info.URL != "http://publisher.test/ads.txt" || info.WWWFallback {
+		t.Errorf("FetchInfo = %+v, want the http URL", info)
+	}
+
+	select {
+	case <-httpsCancelled:
+	case <-time.After(2 * time.Second):
+		t.Error("Expected the losing https request to be cancelled")
+	}
+
+	// Both attempts are traced, the loser with its cancellation
+	if len(trace.Attempts) != 2 {
+		t.Fatalf("trace = %+v, want 2 attempts", trace.Attempts)
+	}
+	if winner, loser := trace.Attempts[0], trace.Attempts[1]; winner.StatusCode != http.StatusOK || loser.URL != "https://publisher.test/ads.txt" || loser.Error == "" {
+		t.Errorf("trace = %+v, want the http success then the cancelled https", trace.Attempts)
+	}
+}
+
+func TestFetchAdsTxt_RaceStagger(t *testing.T) {
+	content := "google.com, pub-123, DIRECT"
+
+	tests := []struct {
+		name        string
+		httpsStatus int
+		wantHTTP    int32 // http requests made
+	}{
+		// The stagger outlasts the whole fetch, so only a failure can start the next pattern
+		{name: "fast https wins alone", httpsStatus: http.StatusOK},
+		{name: "definitive https failure skips to www", httpsStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var httpCalls atomic.Int32
+			httpsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasPrefix(r.Host, "www.") || tt.httpsStatus == http.StatusOK {
+					_, _ = w.Write([]byte(content))
+					return
+				}
+				w.WriteHeader(tt.httpsStatus)
+			}))
+			defer httpsServer.Close()
+			httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				httpCalls.Add(1)
+				http.NotFound(w, r)
+			}))
+			defer httpServer.Close()
+
+			fetcher := NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, Race: true, RaceStagger: time.Minute})
+			fetcher.client.Transport = schemeRouter{https: httpsServer, http: httpServer}
+
+			got, err := fetcher.FetchAdsTxt(context.Background(), "publisher.test")
+			if err != nil {
+				t.Fatalf("FetchAdsTxt() error = %v", err)
+			}
+			if got != content {
+				t.Errorf("FetchAdsTxt() = %q, want %q", got, content)
+			}
+			if n := httpCalls.Load(); n != tt.wantHTTP {
+				t.Errorf("http:// fetched %d times, want %d", n, tt.wantHTTP)
+			}
+		})
+	}
+}
+
+func TestFetchAdsTxt_RaceOverride(t *testing.T) {
+	hanging := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hanging.Close()
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("google.com, pub-123, DIRECT"))
+	}))
+	defer httpServer.Close()
+
+	tests := []struct {
+		name     string
+		race     bool
+		override bool
+		wantErr  bool
+	}{
+		{name: "enabled per fetch", override: true},
+		{name: "disabled per fetch", race: true, override: false, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Ordered fetches spend the whole timeout on https, as its stall is a timeout too
+			fetcher := NewFetcherWithOptions(FetcherOptions{Timeout: 300 * time.Millisecond, Race: tt.race, RaceStagger: 20 * time.Millisecond, HTTPFallback: HTTPFallbackAlways})
+			fetcher.client.Transport = schemeRouter{https: hanging, http: httpServer}
+
+			_, err := fetcher.FetchAdsTxt(WithFetchRace(context.Background(), tt.override), "publisher.test")
+			if tt.wantErr && !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("FetchAdsTxt() error = %v, want the https timeout", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("FetchAdsTxt() error = %v, want the http answer", err)
+			}
+		})
+	}
+}
+
+func TestFetchAdsTxt_RaceHTTPFallbackTransport(t *testing.T) {
+	content := "google.com, pub-123, DIRECT"
+
+	tests := []struct {
+		name      string
+		https     http.HandlerFunc // Answers after http has
+		wantHTTP  bool
+		wantHTTPS int // Status of a definitive https answer
+	}{
+		{
+			name: "definitive https answer beats an earlier http 200",
+			https: func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(100 * time.Millisecond)
+				http.NotFound(w, r)
+			},
+			wantHTTPS: http.StatusNotFound,
+		},
+		{
+			name: "https without an answer lets http win",
+			https: func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(100 * time.Millisecond)
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+			},
+			wantHTTP: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpsServer := httptest.NewTLSServer(tt.https)
+			defer httpsServer.Close()
+			httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(content))
+			}))
+			defer httpServer.Close()
+
+			fetcher := NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, Race: true, RaceStagger: 10 * time.Millisecond, MaxAttempts: 2})
+			fetcher.client.Transport = schemeRouter{https: httpsServer, http: httpServer}
+
+			got, err := fetcher.FetchAdsTxt(context.Background(), "publisher.test")
+			if tt.wantHTTP {
+				if err != nil || got != content {
+					t.Fatalf("FetchAdsTxt() = %q, %v, want the http answer", got, err)
+				}
+				return
+			}
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) || statusErr.Code != tt.wantHTTPS {
+				t.Errorf("FetchAdsTxt() = %q, %v, want the https status %d", got, err, tt.wantHTTPS)
+			}
+		})
+	}
+}
+
+func TestFetchAdsTxt_RaceNoWWWFallback(t *testing.T) {
+	httpsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Host, "www.") {
+			_, _ = w.Write([]byte("google.com, pub-123, DIRECT"))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer httpsServer.Close()
+	httpServer := httptest.NewServer(http.NotFoundHandler())
+	defer httpServer.Close()
+
+	fetcher := NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, Race: true, NoWWWFallback: true, HTTPFallback: HTTPFallbackAlways})
+	fetcher.client.Transport = schemeRouter{https: httpsServer, http: httpServer}
+
+	var trace FetchTrace
+	_, err := fetcher.FetchAdsTxt(WithFetchTrace(context.Background(), &trace), "publisher.test")
+	if !errors.Is(err, ErrWWWOnly) || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("FetchAdsTxt() error = %v, want ErrWWWOnly with the apex 404", err)
+	}
+	if n := len(trace.Attempts); n != 3 || !trace.Attempts[2].Probe {
+		t.Errorf("trace = %+v, want both apex attempts then the www probe", trace.Attempts)
+	}
+}
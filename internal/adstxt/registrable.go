@@ -0,0 +1,21 @@
+package adstxt
+
+import (
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// RegistrableDomain returns the domain a vendor registered, i.e. domain stripped of any
+// subdomains such as www or fastlane: fastlane.rubiconproject.com becomes rubiconproject.com
+// and ads.example.co.uk becomes example.co.uk, per the Public Suffix List. Domains that are
+// already registrable, or are themselves public suffixes, are returned lower-cased but
+// otherwise unchanged.
+func RegistrableDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return domain
+	}
+	return registrable
+}
@@ -0,0 +1,29 @@
+package adstxt
+
+import "testing"
+
+func TestRegistrableDomain(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{"rubiconproject.com", "rubiconproject.com"},
+		{"fastlane.rubiconproject.com", "rubiconproject.com"},
+		{"www.Google.com", "google.com"},
+		{"a.b.c.openx.net", "openx.net"},
+		{"example.co.uk", "example.co.uk"},
+		{"ads.example.co.uk", "example.co.uk"},
+		{"ads.example.com.pe", "example.com.pe"},
+		{"co.uk", "co.uk"},
+		{"example.com.", "example.com"},
+		{"localhost", "localhost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			if got := RegistrableDomain(tt.domain); got != tt.want {
+				t.Errorf("RegistrableDomain(%q) = %q, want %q", tt.domain, got, tt.want)
+			}
+		})
+	}
+}
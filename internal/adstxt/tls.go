@@ -0,0 +1,161 @@
+package adstxt
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// DefaultMinTLSVersion is the lowest TLS version https fetches negotiate by default.
+const DefaultMinTLSVersion = tls.VersionTLS12
+
+// tlsVersions maps FETCH_MIN_TLS_VERSION values to crypto/tls versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion converts a version such as "1.2" (optionally prefixed "TLS" or "TLS ")
+// to its crypto/tls constant. An empty string returns DefaultMinTLSVersion.
+func ParseTLSVersion(raw string) (uint16, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return DefaultMinTLSVersion, nil
+	}
+	trimmed := strings.TrimSpace(strings.TrimPrefix(strings.ToUpper(raw), "TLS"))
+	version, ok := tlsVersions[trimmed]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q, expected 1.0, 1.1, 1.2 or 1.3", raw)
+	}
+	return version, nil
+}
+
+// IsTLSVersionError reports whether err is a handshake that failed because the peer
+// and the fetcher share no TLS version, typically a publisher that only offers versions
+// below FetcherOptions.MinTLSVersion. Other TLS failures, such as an untrusted
+// certificate, return false.
+func IsTLSVersionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	// crypto/tls has no typed error for either case: the peer answers with a
+	// protocol_version alert, or picks a version below the minimum itself
+	msg := err.Error()
+	return strings.Contains(msg, "tls: protocol version not supported") ||
+		strings.Contains(msg, "tls: server selected unsupported protocol version")
+}
+
+// LoadClientTLSConfig builds the TLS config for https fetches from PEM files: a client
+// certificate and key for endpoints that require mutual TLS, and an optional CA bundle
+// trusted in addition to the system roots. Returns nil when all paths are empty, which
+// leaves the transport's defaults (no client certificate, system roots) in place.
+func LoadClientTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" && caFile == "" {
+		return nil, nil
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("client certificate and key must be configured together")
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA certificate: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		cfg.RootCAs = roots
+	}
+
+	return cfg, nil
+}
+
+// tlsDialer returns a DialTLSContext that performs the TLS handshake itself, so the TLS
+// settings can differ per host: certificate verification is skipped for skipHosts only, and
+// when certHosts is set, base's client certificates are only presented to those hosts and
+// their subdomains. Every host is otherwise verified by the standard handshake against base's
+// roots. The per-connection decision happens here rather than in a VerifyConnection or
+// GetClientCertificate hook because neither learns which host the connection is for (and
+// the TLS client reports no server name for IP hosts). onSkip, if set, is called for each
+// unverified connection.
+func tlsDialer(dial dialFunc, base *tls.Config, skipHosts, certHosts []string, onSkip func(host string)) dialFunc {
+	skip := make(map[string]bool, len(skipHosts))
+	for _, host := range skipHosts {
+		skip[strings.ToLower(strings.TrimSuffix(host, "."))] = true
+	}
+	var certificates []tls.Certificate
+	if base != nil && len(certHosts) > 0 {
+		certificates = base.Certificates
+		base = base.Clone()
+		base.Certificates = nil
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		host = strings.ToLower(host)
+
+		cfg := &tls.Config{}
+		if base != nil {
+			cfg = base.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = host
+		}
+		if skip[host] {
+			cfg.InsecureSkipVerify = true
+			if onSkip != nil {
+				onSkip(host)
+			}
+		}
+		if certificates != nil && clientCertHost(host, certHosts) {
+			cfg.Certificates = certificates
+		}
+
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		handshakeCtx, cancel := context.WithTimeout(ctx, tlsHandshakeTimeout)
+		defer cancel()
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(handshakeCtx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+}
+
+// clientCertHost reports whether host is one of certHosts or a subdomain of one.
+func clientCertHost(host string, certHosts []string) bool {
+	for _, h := range certHosts {
+		if withinDomain(host, strings.ToLower(strings.TrimSuffix(h, "."))) {
+			return true
+		}
+	}
+	return false
+}
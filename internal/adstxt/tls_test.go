@@ -0,0 +1,371 @@
+package adstxt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeClientCert writes a self-signed client certificate and key to dir and returns
+// their paths along with the parsed certificate.
+func writeClientCert(t *testing.T, dir string) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "adstxt-api client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "client.pem")
+	keyFile = filepath.Join(dir, "client-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile, cert
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadClientTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeClientCert(t, dir)
+	caFile := certFile
+	emptyFile := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(emptyFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		cert, key, ca   string
+		wantNil         bool
+		wantErr         bool
+		wantClientCerts int
+		wantRoots       bool
+	}{
+		{name: "unset keeps defaults", wantNil: true},
+		{name: "client certificate", cert: certFile, key: keyFile, wantClientCerts: 1},
+		{name: "CA only", ca: caFile, wantRoots: true},
+		{name: "certificate and CA", cert: certFile, key: keyFile, ca: caFile, wantClientCerts: 1, wantRoots: true},
+		{name: "certificate without key", cert: certFile, wantErr: true},
+		{name: "key without certificate", key: keyFile, wantErr: true},
+		{name: "missing certificate file", cert: filepath.Join(dir, "missing.pem"), key: keyFile, wantErr: true},
+		{name: "missing CA file", ca: filepath.Join(dir, "missing.pem"), wantErr: true},
+		{name: "CA file without certificates", ca: emptyFile, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadClientTLSConfig(tt.cert, tt.key, tt.ca)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadClientTLSConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (cfg == nil) != tt.wantNil {
+				t.Fatalf("LoadClientTLSConfig() = %v, wantNil %v", cfg, tt.wantNil)
+			}
+			if cfg == nil {
+				return
+			}
+			if len(cfg.Certificates) != tt.wantClientCerts {
+				t.Errorf("Expected %d client certificates, got %d", tt.wantClientCerts, len(cfg.Certificates))
+			}
+			if (cfg.RootCAs != nil) != tt.wantRoots {
+				t.Errorf("RootCAs set = %v, want %v", cfg.RootCAs != nil, tt.wantRoots)
+			}
+		})
+	}
+}
+
+func TestFetchAdsTxt_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, clientCert := writeClientCert(t, dir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("google.com, pub-1, DIRECT"))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "server-ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", server.Certificate().Raw)
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	// Trusting the server alone is not enough: it demands a client certificate
+	tlsConfig, err := LoadClientTLSConfig("", "", caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetcher := NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, TLSConfig: tlsConfig})
+	if _, err := fetcher.FetchAdsTxt(context.Background(), host); err == nil {
+		t.Error("Expected the fetch to fail without a client certificate")
+	}
+
+	tlsConfig, err = LoadClientTLSConfig(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetcher = NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, TLSConfig: tlsConfig})
+	content, err := fetcher.FetchAdsTxt(context.Background(), host)
+	if err != nil {
+		t.Fatalf("Expected the mutual TLS fetch to succeed, got %v", err)
+	}
+	if content != "google.com, pub-1, DIRECT" {
+		t.Errorf("Unexpected content %q", content)
+	}
+}
+
+func TestFetchAdsTxt_ClientCertHosts(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, clientCert := writeClientCert(t, dir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("google.com, pub-1, DIRECT"))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(dir, "server-ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", server.Certificate().Raw)
+	host := strings.TrimPrefix(server.URL, "https://")
+	tlsConfig, err := LoadClientTLSConfig(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		certHosts []string
+		wantErr   bool
+	}{
+		{name: "partner host", certHosts: []string{"127.0.0.1"}},
+		{name: "other host", certHosts: []string{"partner.example"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetcher := NewFetcherWithOptions(FetcherOptions{Timeout: 5 * time.Second, TLSConfig: tlsConfig, ClientCertHosts: tt.certHosts})
+			_, err := fetcher.FetchAdsTxt(context.Background(), host)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FetchAdsTxt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// The shared TLS settings are left untouched for other fetchers
+	if len(tlsConfig.Certificates) != 1 {
+		t.Errorf("Expected the loaded TLS config to keep its certificate, got %d", len(tlsConfig.Certificates))
+	}
+}
+
+func TestClientCertHost(t *testing.T) {
+	certHosts := []string{"partner.example", "Ads.Partner.Test."}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"partner.example", true},
+		{"cdn.partner.example", true},
+		{"ads.partner.test", true},
+		{"notpartner.example", false},
+		{"example.com", false},
+	}
+	for _, tt := range tests {
+		if got := clientCertHost(tt.host, certHosts); got != tt.want {
+			t.Errorf("clientCertHost(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestFetchAdsTxt_InsecureSkipVerifyHosts(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("google.com, pub-1, DIRECT"))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	caFile := filepath.Join(t.TempDir(), "server-ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", server.Certificate().Raw)
+	trusted, err := LoadClientTLSConfig("", "", caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		tlsConfig   *tls.Config
+		skipHosts   []string
+		wantErr     bool
+		wantSkipped bool
+	}{
+		{name: "self-signed certificate rejected by default", wantErr: true},
+		{name: "listed host skips verification", skipHosts: []string{"127.0.0.1"}, wantSkipped: true},
+		{name: "other hosts are still verified", skipHosts: []string{"adstxt.test.internal"}, wantErr: true},
+		{name: "verification of other hosts honors custom roots", tlsConfig: trusted, skipHosts: []string{"adstxt.test.internal"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var skipped []string
+			fetcher := NewFetcherWithOptions(FetcherOptions{
+				Timeout:                 5 * time.Second,
+				TLSConfig:               tt.tlsConfig,
+				InsecureSkipVerifyHosts: tt.skipHosts,
+				OnSkipVerify:            func(host string) { skipped = append(skipped, host) },
+			})
+
+			content, err := fetcher.FetchAdsTxt(context.Background(), host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FetchAdsTxt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && content != "google.com, pub-1, DIRECT" {
+				t.Errorf("Unexpected content %q", content)
+			}
+			if (len(skipped) > 0) != tt.wantSkipped {
+				t.Errorf("Skipped verification for %v, wantSkipped %v", skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    uint16
+		wantErr bool
+	}{
+		{raw: "", want: tls.VersionTLS12},
+		{raw: "1.0", want: tls.VersionTLS10},
+		{raw: "1.2", want: tls.VersionTLS12},
+		{raw: "TLS1.3", want: tls.VersionTLS13},
+		{raw: "tls 1.1", want: tls.VersionTLS11},
+		{raw: "1.4", wantErr: true},
+		{raw: "SSLv3", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTLSVersion(tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTLSVersion(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTLSVersion(%q) = %x, want %x", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestFetchAdsTxt_MinTLSVersion(t *testing.T) {
+	// A legacy publisher that only speaks TLS 1.0 and 1.1
+	legacy := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("google.com, pub-1, DIRECT"))
+	}))
+	legacy.TLS = &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11}
+	legacy.StartTLS()
+	defer legacy.Close()
+	host := strings.TrimPrefix(legacy.URL, "https://")
+
+	caFile := filepath.Join(t.TempDir(), "server-ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", legacy.Certificate().Raw)
+	trusted, err := LoadClientTLSConfig("", "", caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		minVersion   uint16
+		wantErr      bool
+		wantRejected bool
+	}{
+		{name: "default refuses TLS 1.1", wantErr: true, wantRejected: true},
+		{name: "TLS 1.3 minimum refuses TLS 1.1", minVersion: tls.VersionTLS13, wantErr: true, wantRejected: true},
+		{name: "lowered minimum accepts TLS 1.1", minVersion: tls.VersionTLS10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rejected []string
+			fetcher := NewFetcherWithOptions(FetcherOptions{
+				Timeout:              5 * time.Second,
+				TLSConfig:            trusted,
+				MinTLSVersion:        tt.minVersion,
+				HTTPFallback:         HTTPFallbackNever,
+				OnTLSVersionRejected: func(url string) { rejected = append(rejected, url) },
+			})
+
+			_, err := fetcher.FetchAdsTxt(context.Background(), host)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FetchAdsTxt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantRejected && (len(rejected) == 0 || rejected[0] != "https://"+host+"/ads.txt") {
+				t.Errorf("OnTLSVersionRejected calls = %v, want the https attempt", rejected)
+			}
+			if !tt.wantRejected && len(rejected) > 0 {
+				t.Errorf("OnTLSVersionRejected calls = %v, want none", rejected)
+			}
+		})
+	}
+
+	t.Run("other TLS failures are not version rejections", func(t *testing.T) {
+		var rejected []string
+		fetcher := NewFetcherWithOptions(FetcherOptions{
+			Timeout:              5 * time.Second,
+			MinTLSVersion:        tls.VersionTLS10,
+			HTTPFallback:         HTTPFallbackNever,
+			OnTLSVersionRejected: func(url string) { rejected = append(rejected, url) },
+		})
+		if _, err := fetcher.FetchAdsTxt(context.Background(), host); err == nil {
+			t.Fatal("Expected the untrusted certificate to fail the fetch")
+		}
+		if len(rejected) > 0 {
+			t.Errorf("OnTLSVersionRejected calls = %v for a certificate failure, want none", rejected)
+		}
+	})
+}
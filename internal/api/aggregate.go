@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// AggregateAdvertiser is an advertiser domain's combined presence across a batch of publishers.
+type AggregateAdvertiser struct {
+	Domain     string `json:"domain"`
+	Count      int    `json:"count"`      // Total entries summed across all publishers
+	Publishers int    `json:"publishers"` // Number of publishers listing this advertiser
+}
+
+type BatchAggregateResponse struct {
+	TotalPublishers  int                   `json:"total_publishers"`
+	TotalAdvertisers int                   `json:"total_advertisers"`
+	Advertisers      []AggregateAdvertiser `json:"advertisers"`
+	Errors           map[string]string     `json:"errors,omitempty"`
+}
+
+// AnalyzeBatchAggregate analyzes a batch of publishers and merges their advertisers into one ranking.
+// Accepts the same body as AnalyzeBatch. An optional ?top=N limits the ranking to the first N advertisers.
+func (h *Handler) AnalyzeBatchAggregate(w http.ResponseWriter, r *http.Request) {
+	h.metrics.mu.Lock()
+	h.metrics.requestsTotal++
+	h.metrics.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	top := 0
+	if value := r.URL.Query().Get("top"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			h.sendError(w, r, http.StatusBadRequest, CodeInvalidParameter, "top must be a positive integer")
+			return
+		}
+		top = n
+	}
+	lenient, ok := h.boolParam(w, r, "lenient")
+	if !ok {
+		return
+	}
+	if ctx, ok = h.withFastFetch(ctx, w, r); !ok {
+		return
+	}
+	if ctx, ok = h.withNoWWWFallback(ctx, w, r); !ok {
+		return
+	}
+
+	req, ok := h.decodeBatchRequest(w, r, maxBatchDomains)
+	if !ok {
+		return
+	}
+
+	batch := h.processBatch(ctx, req.Domains, req.fetchTimeouts)
+	for i := range batch.Results {
+		applyLenient(&batch.Results[i], lenient)
+	}
+	response := aggregateResults(batch.Results)
+	response.Errors = batch.Errors
+
+	if top > 0 && len(response.Advertisers) > top {
+		response.Advertisers = response.Advertisers[:top]
+	}
+
+	h.respond(w, r, http.StatusOK, response)
+}
+
+// aggregateResults sums advertiser counts across results and ranks them by total count,
+// then by publisher reach, then by domain name for stable output.
+func aggregateResults(results []SingleAnalysisResponse) BatchAggregateResponse {
+	merged := make(map[string]*AggregateAdvertiser)
+	for _, result := range results {
+		for _, adv := range result.Advertisers {
+			entry, exists := merged[adv.Domain]
+			if !exists {
+				entry = &AggregateAdvertiser{Domain: adv.Domain}
+				merged[adv.Domain] = entry
+			}
+			entry.Count += adv.Count
+			entry.Publishers++
+		}
+	}
+
+	advertisers := make([]AggregateAdvertiser, 0, len(merged))
+	for _, entry := range merged {
+		advertisers = append(advertisers, *entry)
+	}
+
+	sort.Slice(advertisers, func(i, j int) bool {
+		if advertisers[i].Count != advertisers[j].Count {
+			return advertisers[i].Count > advertisers[j].Count
+		}
+		if advertisers[i].Publishers != advertisers[j].Publishers {
+			return advertisers[i].Publishers > advertisers[j].Publishers
+		}
+		return advertisers[i].Domain < advertisers[j].Domain
+	})
+
+	return BatchAggregateResponse{
+		TotalPublishers:  len(results),
+		TotalAdvertisers: len(advertisers),
+		Advertisers:      advertisers,
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/adstxt"
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+func TestAggregateResults(t *testing.T) {
+	results := []SingleAnalysisResponse{
+		{
+			Domain: "pub-a.com",
+			Advertisers: []adstxt.AdvertiserCount{
+				{Domain: "google.com", Count: 5},
+				{Domain: "appnexus.com", Count: 2},
+			},
+		},
+		{
+			Domain: "pub-b.com",
+			Advertisers: []adstxt.AdvertiserCount{
+				{Domain: "google.com", Count: 1},
+				{Domain: "rubicon.com", Count: 2},
+			},
+		},
+	}
+
+	response := aggregateResults(results)
+
+	if response.TotalPublishers != 2 {
+		t.Errorf("Expected 2 publishers, got %d", response.TotalPublishers)
+	}
+	if response.TotalAdvertisers != 3 {
+		t.Fatalf("Expected 3 advertisers, got %d", response.TotalAdvertisers)
+	}
+
+	first := response.Advertisers[0]
+	if first.Domain != "google.com" || first.Count != 6 || first.Publishers != 2 {
+		t.Errorf("Expected google.com with count 6 on 2 publishers, got %+v", first)
+	}
+
+	// Ties on count are broken by domain name
+	if response.Advertisers[1].Domain != "appnexus.com" || response.Advertisers[2].Domain != "rubicon.com" {
+		t.Errorf("Unexpected tie ordering: %+v", response.Advertisers[1:])
+	}
+}
+
+func TestHandler_AnalyzeBatchAggregate(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := mustNewHandler(t, cacheStore, cfg, logger)
+
+	// Pre-populate cache so no network fetch is needed
+	for domain, advertisers := range map[string][]adstxt.AdvertiserCount{
+		"pub-a.com": {{Domain: "google.com", Count: 3}, {Domain: "appnexus.com", Count: 1}},
+		"pub-b.com": {{Domain: "google.com", Count: 2}},
+	} {
+		data, _ := json.Marshal(SingleAnalysisResponse{Domain: domain, Advertisers: advertisers})
+		_ = cacheStore.Set("adstxt:"+domain, data, cfg.CacheTTL)
+	}
+
+	body, _ := json.Marshal(BatchAnalysisRequest{Domains: []string{"pub-a.com", "pub-b.com", "http://bad"}})
+	req := httptest.NewRequest(http.MethodPost, "/api/batch-aggregate?top=1", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.AnalyzeBatchAggregate(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response BatchAggregateResponse
+	_ = json.NewDecoder(w.Body).Decode(&response)
+
+	if response.TotalPublishers != 2 {
+		t.Errorf("Expected 2 publishers, got %d", response.TotalPublishers)
+	}
+	if len(response.Advertisers) != 1 || response.Advertisers[0].Count != 5 {
+		t.Errorf("Expected only google.com with count 5, got %+v", response.Advertisers)
+	}
+	if _, ok := response.Errors["http://bad"]; !ok {
+		t.Error("Expected invalid domain to be reported in errors")
+	}
+}
+
+func TestHandler_AnalyzeBatchAggregate_InvalidTop(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := mustNewHandler(t, cacheStore, cfg, logger)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/batch-aggregate?top=0", bytes.NewBufferString(`{"domains":["a.com"]}`))
+	w := httptest.NewRecorder()
+
+	handler.AnalyzeBatchAggregate(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
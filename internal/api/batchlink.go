@@ -0,0 +1,95 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxBatchLinkSize bounds the decompressed domain list of a batch link, so a small
+// parameter cannot expand into a decompression bomb. 50 maximum-length domains fit easily.
+const maxBatchLinkSize = 64 << 10
+
+// AnalyzeBatchLink analyzes the domains packed into ?domains= like AnalyzeBatch, so a fixed
+// batch can be shared and bookmarked as a plain GET link. The parameter is the comma-separated
+// domain list, gzip-compressed and then base64url-encoded (padding optional). It accepts the same
+// query flags as AnalyzeBatch and is subject to the same batch cap.
+func (h *Handler) AnalyzeBatchLink(w http.ResponseWriter, r *http.Request) {
+	h.metrics.mu.Lock()
+	h.metrics.requestsTotal++
+	h.metrics.mu.Unlock()
+
+	if r.Method != http.MethodGet {
+		h.sendError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	opts, ok := h.batchOptions(w, r)
+	if !ok {
+		return
+	}
+	if ctx, ok = h.withFastFetch(ctx, w, r); !ok {
+		return
+	}
+	if ctx, ok = h.withNoWWWFallback(ctx, w, r); !ok {
+		return
+	}
+
+	param := r.URL.Query().Get("domains")
+	if param == "" {
+		h.sendError(w, r, http.StatusBadRequest, CodeInvalidParameter, "domains query parameter is required")
+		return
+	}
+	domains, err := decodeBatchLink(param)
+	if err != nil {
+		h.sendError(w, r, http.StatusBadRequest, CodeInvalidParameter, err.Error())
+		return
+	}
+	if !h.checkBatchSize(w, r, domains, maxBatchDomains) {
+		return
+	}
+
+	response := h.processBatch(ctx, domains, nil)
+	opts.apply(&response)
+	h.respond(w, r, http.StatusOK, response)
+}
+
+// decodeBatchLink unpacks a base64url-encoded, gzip-compressed, comma-separated domain list.
+// Blank entries are dropped; domains are validated later by processBatch.
+func decodeBatchLink(param string) ([]string, error) {
+	compressed, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(param, "="))
+	if err != nil {
+		return nil, fmt.Errorf("domains must be base64url-encoded: %v", err)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("domains must be gzip-compressed: %v", err)
+	}
+	defer zr.Close()
+
+	list, err := io.ReadAll(io.LimitReader(zr, maxBatchLinkSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("domains could not be decompressed: %v", err)
+	}
+	if len(list) > maxBatchLinkSize {
+		return nil, fmt.Errorf("domains decompress to more than %d bytes", maxBatchLinkSize)
+	}
+
+	domains := make([]string, 0)
+	for _, domain := range strings.Split(string(list), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	return domains, nil
+}
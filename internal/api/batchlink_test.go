@@ -0,0 +1,118 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+// encodeBatchLink packs list the way clients build a batch link.
+func encodeBatchLink(t *testing.T, list string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(list)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes())
+}
+
+func newBatchLinkTestHandler(t *testing.T) (*Handler, *fakeFetcher) {
+	t.Helper()
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	t.Cleanup(func() { cacheStore.Close() })
+	fetcher := newFakeFetcher(map[string]string{
+		"one.com": "google.com, pub-1, DIRECT",
+		"two.com": "google.com, pub-1, DIRECT\nappnexus.com, 1, RESELLER",
+	})
+	return NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil))), fetcher
+}
+
+func TestHandler_AnalyzeBatchLink(t *testing.T) {
+	handler, _ := newBatchLinkTestHandler(t)
+
+	link := "/api/batch-link?domains=" + encodeBatchLink(t, "one.com, two.com,,bad_domain")
+	w := httptest.NewRecorder()
+	handler.AnalyzeBatchLink(w, httptest.NewRequest(http.MethodGet, link, nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response BatchAnalysisResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Results) != 2 {
+		t.Errorf("Expected 2 results, got %+v", response.Results)
+	}
+	if _, ok := response.Errors["bad_domain"]; !ok {
+		t.Errorf("Expected an error for bad_domain, got %v", response.Errors)
+	}
+}
+
+func TestHandler_AnalyzeBatchLink_Errors(t *testing.T) {
+	handler, fetcher := newBatchLinkTestHandler(t)
+
+	var tooMany []string
+	for i := 0; i <= maxBatchDomains; i++ {
+		tooMany = append(tooMany, fmt.Sprintf("d%d.com", i))
+	}
+	bomb := encodeBatchLink(t, strings.Repeat("a", maxBatchLinkSize+1))
+
+	tests := []struct {
+		name   string
+		method string
+		param  string
+		status int
+		code   string
+	}{
+		{"wrong method", http.MethodPost, encodeBatchLink(t, "one.com"), http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{"missing", http.MethodGet, "", http.StatusBadRequest, CodeInvalidParameter},
+		{"not base64", http.MethodGet, "!!!", http.StatusBadRequest, CodeInvalidParameter},
+		{"not gzip", http.MethodGet, base64.RawURLEncoding.EncodeToString([]byte("one.com")), http.StatusBadRequest, CodeInvalidParameter},
+		{"decompression bomb", http.MethodGet, bomb, http.StatusBadRequest, CodeInvalidParameter},
+		{"empty list", http.MethodGet, encodeBatchLink(t, " , "), http.StatusBadRequest, CodeEmptyBatch},
+		{"too many domains", http.MethodGet, encodeBatchLink(t, strings.Join(tooMany, ",")), http.StatusBadRequest, CodeBatchTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.AnalyzeBatchLink(w, httptest.NewRequest(tt.method, "/api/batch-link?domains="+url.QueryEscape(tt.param), nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			var response ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Code != tt.code {
+				t.Errorf("Expected code %s, got %s", tt.code, response.Code)
+			}
+		})
+	}
+	if len(fetcher.calls) != 0 {
+		t.Errorf("Expected nothing to be fetched for rejected links, got %v", fetcher.calls)
+	}
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// CacheCodec serializes analyses for the cache, see RegisterCacheCodec.
+type CacheCodec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// cacheFormat is a CACHE_SERIALIZATION value. Entries are stored as tag followed by the
+// codec's output, except for JSON, whose entries are untagged: they always start with '{',
+// which no tag may be, so entries cached before CACHE_SERIALIZATION existed still read as JSON.
+type cacheFormat struct {
+	name  string
+	tag   byte
+	codec CacheCodec
+}
+
+// Built-in CACHE_SERIALIZATION values.
+const (
+	cacheSerializationJSON    = "json"
+	cacheSerializationGob     = "gob"
+	cacheSerializationMsgpack = "msgpack"
+)
+
+// The registered formats by name. Entries of every registered format are readable
+// whichever one new entries are written in.
+var (
+	cacheFormatsMu sync.RWMutex
+	cacheFormats   = map[string]cacheFormat{
+		cacheSerializationJSON:    {name: cacheSerializationJSON, codec: jsonCodec{}},
+		cacheSerializationGob:     {name: cacheSerializationGob, tag: 1, codec: gobCodec{}},
+		cacheSerializationMsgpack: {name: cacheSerializationMsgpack, tag: 2, codec: msgpackCodec{}},
+	}
+)
+
+// RegisterCacheCodec makes codec available as CACHE_SERIALIZATION=name, e.g. a protobuf codec.
+// Its entries are prefixed with tag, which must be neither 0 nor '{' nor another format's tag.
+// Call it at startup, before the handler is created.
+func RegisterCacheCodec(name string, tag byte, codec CacheCodec) error {
+	if tag == 0 || tag == '{' {
+		return fmt.Errorf("cache codec %s: tag %#x is reserved", name, tag)
+	}
+
+	cacheFormatsMu.Lock()
+	defer cacheFormatsMu.Unlock()
+	for _, f := range cacheFormats {
+		if f.tag == tag && f.name != name {
+			return fmt.Errorf("cache codec %s: tag %#x is used by %s", name, tag, f.name)
+		}
+	}
+	cacheFormats[name] = cacheFormat{name: name, tag: tag, codec: codec}
+	return nil
+}
+
+// lookupCacheFormat returns the format registered as name; "" is JSON.
+func lookupCacheFormat(name string) (cacheFormat, bool) {
+	if name == "" {
+		name = cacheSerializationJSON
+	}
+	cacheFormatsMu.RLock()
+	defer cacheFormatsMu.RUnlock()
+	f, ok := cacheFormats[name]
+	return f, ok
+}
+
+// marshal encodes v as a cache entry in format f.
+func (f cacheFormat) marshal(v any) ([]byte, error) {
+	data, err := f.codec.Marshal(v)
+	if err != nil || f.tag == 0 {
+		return data, err
+	}
+	return append([]byte{f.tag}, data...), nil
+}
+
+// unmarshalCacheData decodes a cache entry written in any registered format into v.
+func unmarshalCacheData(data []byte, v any) error {
+	if len(data) == 0 {
+		return errors.New("empty cache entry")
+	}
+	if data[0] == '{' {
+		return json.Unmarshal(data, v)
+	}
+
+	cacheFormatsMu.RLock()
+	var codec CacheCodec
+	for _, f := range cacheFormats {
+		if f.tag == data[0] && f.tag != 0 {
+			codec = f.codec
+			break
+		}
+	}
+	cacheFormatsMu.RUnlock()
+	if codec == nil {
+		return fmt.Errorf("unknown cache serialization tag %#x", data[0])
+	}
+	return codec.Unmarshal(data[1:], v)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// gobCodec trades JSON's readability for smaller entries and faster decoding of long
+// advertiser lists. Gob skips zero values, see restoreZeroValues.
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// msgpackCodec is about as compact as gob but, unlike it, readable from other languages
+// sharing the cache. It follows the json struct tags, so field names match JSON entries.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/adstxt"
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+func TestCacheSerialization_RoundTrip(t *testing.T) {
+	redirects := 0
+	original := &SingleAnalysisResponse{
+		TotalAdvertisers: 1,
+		Advertisers:      []adstxt.AdvertiserCount{{Domain: "google.com", Count: 2, Direct: 1, Reseller: 1, CertAuthorities: []string{"f08c47fec0942fa0"}}},
+		ContentHash:      "3f1c9a0b7e2d4c5a8b6e1f0d2c3b4a59",
+		CommentMetadata:  map[string]string{"contact": "ads@example.com"},
+		Changes:          &AdvertiserChanges{PreviousTimestamp: "2025-01-01T00:00:00Z", Added: []adstxt.AdvertiserCount{}, Removed: []adstxt.AdvertiserCount{}, Changed: []CountChange{}},
+		HTTPStatus:       http.StatusOK,
+		Redirects:        &redirects,
+		Timestamp:        "2025-01-02T00:00:00Z",
+	}
+	empty := &SingleAnalysisResponse{Advertisers: []adstxt.AdvertiserCount{}, Timestamp: "2025-01-02T00:00:00Z"}
+	want, _ := json.Marshal(original)
+	wantEmpty, _ := json.Marshal(empty)
+
+	for _, format := range []string{cacheSerializationJSON, cacheSerializationGob, cacheSerializationMsgpack} {
+		t.Run(format, func(t *testing.T) {
+			cfg := &config.Config{CacheTTL: time.Hour, CacheSerialization: format}
+			cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+			defer cacheStore.Close()
+			handler := NewHandlerWithFetcher(cacheStore, newFakeFetcher(nil), cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+			defer handler.Close()
+
+			for _, tt := range []struct {
+				result *SingleAnalysisResponse
+				want   []byte
+			}{{original, want}, {empty, wantEmpty}} {
+				data, err := handler.encodeCacheEntry(tt.result)
+				if err != nil {
+					t.Fatalf("encodeCacheEntry() error = %v", err)
+				}
+				decoded, err := handler.decodeCacheEntry("example.com", data)
+				if err != nil {
+					t.Fatalf("decodeCacheEntry() error = %v", err)
+				}
+				// Responses render exactly as before caching, [] and zero redirects included
+				if got, _ := json.Marshal(decoded); !bytes.Equal(got, tt.want) {
+					t.Errorf("decoded = %s, want %s", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheSerialization_ReadsEveryFormat(t *testing.T) {
+	const content = "google.com, pub-1, DIRECT\ngoogle.com, pub-2, RESELLER\n"
+	cacheStore := cache.NewMemoryCache(time.Hour)
+	defer cacheStore.Close()
+
+	newHandler := func(format, mode string) *Handler {
+		cfg := &config.Config{CacheTTL: time.Hour, RequestTimeout: 10 * time.Second, CacheSerialization: format, CacheMode: mode}
+		fetcher := newFakeFetcher(map[string]string{
+			"gob.parsed.example": content, "gob.raw.example": content,
+			"msgpack.parsed.example": content, "msgpack.raw.example": content,
+		})
+		return NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	}
+
+	// Entries written in gob or msgpack, in both cache modes, stay readable after switching back to JSON
+	for format, tag := range map[string]byte{cacheSerializationGob: 1, cacheSerializationMsgpack: 2} {
+		for domain, mode := range map[string]string{format + ".parsed.example": cacheModeParsed, format + ".raw.example": cacheModeRaw} {
+			writer := newHandler(format, mode)
+			if _, err := writer.analyzeDomain(context.Background(), domain); err != nil {
+				t.Fatalf("analyzeDomain(%s) error = %v", domain, err)
+			}
+			writer.Close()
+
+			stored, err := cacheStore.Get(cacheKeyFor(domain))
+			if err != nil {
+				t.Fatalf("cache entry for %s missing: %v", domain, err)
+			}
+			if stored[0] != tag {
+				t.Errorf("%s entry starts with %#x, want the %s tag", domain, stored[0], format)
+			}
+
+			reader := newHandler(cacheSerializationJSON, cacheModeParsed)
+			result, err := reader.analyzeDomain(context.Background(), domain)
+			reader.Close()
+			if err != nil {
+				t.Fatalf("analyzeDomain(%s) error = %v", domain, err)
+			}
+			if !result.Cached || result.TotalAdvertisers != 1 || result.Advertisers[0].Count != 2 {
+				t.Errorf("analyzeDomain(%s) = %+v, want the %s entry served from cache", domain, result, format)
+			}
+		}
+	}
+
+	// Untagged JSON entries, e.g. from before CACHE_SERIALIZATION, are read by a gob handler
+	parsed, _ := json.Marshal(SingleAnalysisResponse{TotalAdvertisers: 7, Advertisers: []adstxt.AdvertiserCount{}, Timestamp: time.Now().Format(time.RFC3339)})
+	_ = cacheStore.Set(cacheKeyFor("json.example"), parsed, time.Hour)
+	reader := newHandler(cacheSerializationGob, cacheModeParsed)
+	defer reader.Close()
+	if result, err := reader.analyzeDomain(context.Background(), "json.example"); err != nil || !result.Cached || result.TotalAdvertisers != 7 {
+		t.Errorf("analyzeDomain(json.example) = %+v, %v, want the JSON entry served from cache", result, err)
+	}
+}
+
+func TestRegisterCacheCodec(t *testing.T) {
+	for _, tag := range []byte{0, '{', 1} {
+		if err := RegisterCacheCodec("custom", tag, jsonCodec{}); err == nil {
+			t.Errorf("RegisterCacheCodec(tag %#x) error = nil, want reserved or in use", tag)
+		}
+	}
+
+	if err := RegisterCacheCodec("custom", 0x7f, jsonCodec{}); err != nil {
+		t.Fatalf("RegisterCacheCodec() error = %v", err)
+	}
+	t.Cleanup(func() {
+		cacheFormatsMu.Lock()
+		delete(cacheFormats, "custom")
+		cacheFormatsMu.Unlock()
+	})
+
+	cfg := &config.Config{CacheTTL: time.Hour, CacheSerialization: "custom"}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	handler := NewHandlerWithFetcher(cacheStore, newFakeFetcher(nil), cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	defer handler.Close()
+
+	data, err := handler.encodeCacheEntry(&SingleAnalysisResponse{TotalAdvertisers: 3})
+	if err != nil || data[0] != 0x7f {
+		t.Fatalf("encodeCacheEntry() = %q, %v, want the custom tag", data, err)
+	}
+	if result, err := handler.decodeCacheEntry("example.com", data); err != nil || result.TotalAdvertisers != 3 {
+		t.Errorf("decodeCacheEntry() = %+v, %v, want 3 advertisers", result, err)
+	}
+	if _, err := handler.decodeCacheEntry("example.com", []byte{0x42, '{', '}'}); err == nil {
+		t.Error("decodeCacheEntry() of an unknown tag error = nil, want an error")
+	}
+
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+
+	"adstxt-api/internal/adstxt"
+)
+
+// CACHE_MODE values.
+const (
+	cacheModeParsed = "parsed" // Cache the analysis, trading storage for CPU on every hit
+	cacheModeRaw    = "raw"    // Cache the fetched file and re-parse it on every hit
+)
+
+// rawCacheEntry is an analysis cached in raw mode: the fetched ads.txt plus what a re-parse
+// cannot recover, the fetch metadata (the hash of the bytes served included) and the delta
+// from the previous fetch. Field names match
+// SingleAnalysisResponse, so one decode reads entries of either mode.
+type rawCacheEntry struct {
+	RawContent     string             `json:"raw_content"`
+	RawContentHash string             `json:"raw_content_hash,omitempty"`
+	HTTPStatus     int                `json:"http_status,omitempty"`
+	Redirects      *int               `json:"redirects,omitempty"`
+	WWWFallback    bool               `json:"www_fallback,omitempty"`
+	Changes        *AdvertiserChanges `json:"changes,omitempty"`
+	Timestamp      string             `json:"timestamp"`
+}
+
+// cachedAnalysis is a cache entry of either mode. RawContent is nil for parsed entries,
+// which are used as is.
+type cachedAnalysis struct {
+	SingleAnalysisResponse
+	RawContent *string `json:"raw_content"`
+}
+
+// MarshalJSON writes raw entries as a rawCacheEntry, leaving out the parsed fields that have
+// no omitempty. Gob and other codecs that skip zero values store the struct as is.
+func (c cachedAnalysis) MarshalJSON() ([]byte, error) {
+	if c.RawContent == nil {
+		return json.Marshal(&c.SingleAnalysisResponse)
+	}
+	return json.Marshal(rawCacheEntry{
+		RawContent:     *c.RawContent,
+		RawContentHash: c.RawContentHash,
+		HTTPStatus:     c.HTTPStatus,
+		Redirects:      c.Redirects,
+		WWWFallback:    c.WWWFallback,
+		Changes:        c.Changes,
+		Timestamp:      c.Timestamp,
+	})
+}
+
+// encodeCacheEntry renders result for the cache in the configured mode and CACHE_SERIALIZATION.
+// Parsed entries never carry Entries or a Trace, which are only built on request. A result without
+// its raw content (e.g. from a fetcher that was not asked to keep it) is cached parsed.
+func (h *Handler) encodeCacheEntry(result *SingleAnalysisResponse) ([]byte, error) {
+	if h.rawCache && result.rawContent != nil {
+		return h.cacheFormat.marshal(cachedAnalysis{
+			SingleAnalysisResponse: SingleAnalysisResponse{
+				RawContentHash: result.RawContentHash,
+				HTTPStatus:     result.HTTPStatus,
+				Redirects:      result.Redirects,
+				WWWFallback:    result.WWWFallback,
+				Changes:        result.Changes,
+				Timestamp:      result.Timestamp,
+			},
+			RawContent: result.rawContent,
+		})
+	}
+
+	cached := *result
+	cached.Entries, cached.EntriesTruncated = nil, false
+	cached.Trace = nil
+	return h.cacheFormat.marshal(cachedAnalysis{SingleAnalysisResponse: cached})
+}
+
+// decodeCacheEntry turns a cache entry of either mode and any serialization back into an
+// analysis of domain, so entries written before a CACHE_MODE or CACHE_SERIALIZATION change are
+// still served until they expire. Raw entries are re-parsed with the current parser settings.
+func (h *Handler) decodeCacheEntry(domain string, data []byte) (*SingleAnalysisResponse, error) {
+	var entry cachedAnalysis
+	if err := unmarshalCacheData(data, &entry); err != nil {
+		return nil, err
+	}
+	restoreZeroValues(&entry.SingleAnalysisResponse)
+	if entry.RawContent == nil {
+		return &entry.SingleAnalysisResponse, nil
+	}
+
+	result := h.buildAnalysis(domain, *entry.RawContent)
+	result.HTTPStatus = entry.HTTPStatus
+	result.Redirects = entry.Redirects
+	result.WWWFallback = entry.WWWFallback
+	result.Changes = entry.Changes
+	result.Timestamp = entry.Timestamp
+	if h.cfg.IncludeRawHash {
+		result.RawContentHash = entry.RawContentHash // Of the bytes served, which the transcoded raw content may not match
+		if result.RawContentHash == "" {
+			result.RawContentHash = rawContentHash(*entry.RawContent) // Cached while INCLUDE_RAW_HASH was off
+		}
+	}
+	return result, nil
+}
+
+// restoreZeroValues puts back what gob drops: empty lists, which are sent as [] rather than
+// null, and a zero redirect count, which is always recorded along with the status.
+func restoreZeroValues(result *SingleAnalysisResponse) {
+	if result.Advertisers == nil {
+		result.Advertisers = []adstxt.AdvertiserCount{}
+	}
+	if result.HTTPStatus != 0 && result.Redirects == nil {
+		result.Redirects = new(int)
+	}
+	if c := result.Changes; c != nil {
+		if c.Added == nil {
+			c.Added = []adstxt.AdvertiserCount{}
+		}
+		if c.Removed == nil {
+			c.Removed = []adstxt.AdvertiserCount{}
+		}
+		if c.Changed == nil {
+			c.Changed = []CountChange{}
+		}
+	}
+}
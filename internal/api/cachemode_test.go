@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+// streamingFakeFetcher serves a fakeFetcher's content through StreamAdsTxt.
+type streamingFakeFetcher struct {
+	*fakeFetcher
+}
+
+func (f streamingFakeFetcher) StreamAdsTxt(ctx context.Context, domain string, consume func(io.Reader) error) error {
+	content, err := f.FetchAdsTxt(ctx, domain)
+	if err != nil {
+		return err
+	}
+	return consume(strings.NewReader(content))
+}
+
+func TestHandler_CacheModeRaw(t *testing.T) {
+	const content = "# contact=ads@example.com\ngoogle.com, pub-1, DIRECT\ngoogle.com, pub-2, RESELLER\nappnexus.com, 3, DIRECT\n"
+
+	for _, streaming := range []bool{false, true} {
+		name := "buffered"
+		if streaming {
+			name = "streaming"
+		}
+		t.Run(name, func(t *testing.T) {
+			cfg := &config.Config{CacheTTL: time.Hour, RequestTimeout: 10 * time.Second, CacheMode: "raw"}
+			cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+			defer cacheStore.Close()
+
+			fake := newFakeFetcher(map[string]string{"example.com": content})
+			var fetcher AdsTxtFetcher = fake
+			if streaming {
+				fetcher = streamingFakeFetcher{fake}
+			}
+			handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+			defer handler.Close()
+
+			var responses [2]SingleAnalysisResponse
+			for i := range responses {
+				w := httptest.NewRecorder()
+				handler.AnalyzeSingle(w, httptest.NewRequest("GET", "/api/analyze?domain=example.com", nil))
+				if w.Code != http.StatusOK {
+					t.Fatalf("request %d: status = %d, body: %s", i+1, w.Code, w.Body.String())
+				}
+				if err := json.NewDecoder(w.Body).Decode(&responses[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			stored, err := cacheStore.Get(cacheKeyFor("example.com"))
+			if err != nil {
+				t.Fatalf("cache entry missing: %v", err)
+			}
+			var entry map[string]any
+			if err := json.Unmarshal(stored, &entry); err != nil {
+				t.Fatal(err)
+			}
+			if entry["raw_content"] != content {
+				t.Errorf("cached raw_content = %v, want the fetched file", entry["raw_content"])
+			}
+			if _, ok := entry["advertisers"]; ok {
+				t.Error("raw cache entry also holds the parsed advertisers")
+			}
+
+			fresh, cached := responses[0], responses[1]
+			if fake.calls["example.com"] != 1 {
+				t.Errorf("fetched %d times, want 1", fake.calls["example.com"])
+			}
+			if !cached.Cached || cached.TotalAdvertisers != fresh.TotalAdvertisers || len(cached.Advertisers) != 2 {
+				t.Errorf("cached response = %+v, want the fresh analysis re-parsed", cached)
+			}
+			if cached.Advertisers[0].Domain != "google.com" || cached.Advertisers[0].Count != 2 {
+				t.Errorf("cached advertisers = %+v, want google.com counted twice first", cached.Advertisers)
+			}
+			if cached.Timestamp != fresh.Timestamp {
+				t.Errorf("cached timestamp = %s, want the fetch time %s", cached.Timestamp, fresh.Timestamp)
+			}
+			if cached.ContentHash != fresh.ContentHash {
+				t.Errorf("cached content_hash = %s, want %s", cached.ContentHash, fresh.ContentHash)
+			}
+		})
+	}
+}
+
+func TestHandler_CacheModeRaw_DetectChanges(t *testing.T) {
+	cfg := &config.Config{CacheTTL: time.Hour, RequestTimeout: 10 * time.Second, CacheMode: "raw", ChangeHistoryTTL: 24 * time.Hour}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	fetcher := newFakeFetcher(map[string]string{"example.com": "google.com, pub-1, DIRECT\nappnexus.com, 1, RESELLER\n"})
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	defer handler.Close()
+
+	analyze := func() SingleAnalysisResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.AnalyzeSingle(w, httptest.NewRequest("GET", "/api/analyze?domain=example.com&detect_changes=true", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, body: %s", w.Code, w.Body.String())
+		}
+		var response SingleAnalysisResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	analyze()
+	_ = cacheStore.Delete(cacheKeyFor("example.com"))
+	fetcher.mu.Lock()
+	fetcher.content["example.com"] = "google.com, pub-1, DIRECT\nopenx.com, 1, DIRECT\n"
+	fetcher.mu.Unlock()
+	fresh := analyze()
+
+	// The delta is stored with the raw file, so cache hits report it like the fetch did
+	cached := analyze()
+	if !cached.Cached || cached.Changes == nil {
+		t.Fatalf("cached response = %+v, want the changes from the fetch", cached)
+	}
+	if len(cached.Changes.Added) != 1 || len(cached.Changes.Removed) != 1 || cached.Changes.PreviousTimestamp != fresh.Changes.PreviousTimestamp {
+		t.Errorf("cached changes = %+v, want %+v", cached.Changes, fresh.Changes)
+	}
+}
+
+func TestHandler_CacheMode_ReadsEitherFormat(t *testing.T) {
+	cfg := &config.Config{CacheTTL: time.Hour, RequestTimeout: 10 * time.Second}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	handler := NewHandlerWithFetcher(cacheStore, newFakeFetcher(nil), cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	defer handler.Close()
+
+	// Entries written before a switch from raw to parsed are still served
+	raw, _ := json.Marshal(rawCacheEntry{RawContent: "google.com, pub-1, DIRECT\n", Timestamp: time.Now().Format(time.RFC3339)})
+	_ = cacheStore.Set(cacheKeyFor("raw.example.com"), raw, time.Hour)
+	parsed, _ := json.Marshal(SingleAnalysisResponse{TotalAdvertisers: 7, Timestamp: time.Now().Format(time.RFC3339)})
+	_ = cacheStore.Set(cacheKeyFor("parsed.example.com"), parsed, time.Hour)
+
+	for domain, want := range map[string]int{"raw.example.com": 1, "parsed.example.com": 7} {
+		result, err := handler.analyzeDomain(context.Background(), domain)
+		if err != nil {
+			t.Fatalf("analyzeDomain(%s) error = %v", domain, err)
+		}
+		if !result.Cached || result.TotalAdvertisers != want {
+			t.Errorf("analyzeDomain(%s) = %d advertisers (cached %v), want %d from cache", domain, result.TotalAdvertisers, result.Cached, want)
+		}
+	}
+}
+
+func TestSeedCache_RawMode(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "example.com"), []byte("google.com, pub-1, DIRECT\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{CacheTTL: time.Hour, CacheMode: "raw"}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	handler := NewHandlerWithFetcher(cacheStore, newFakeFetcher(nil), cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	defer handler.Close()
+
+	if _, err := handler.SeedCache(dir); err != nil {
+		t.Fatalf("SeedCache() error = %v", err)
+	}
+	stored, _ := cacheStore.Get(cacheKeyFor("example.com"))
+	if !strings.Contains(string(stored), `"raw_content"`) {
+		t.Errorf("seeded entry = %s, want raw content", stored)
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"adstxt-api/internal/adstxt"
+)
+
+// AdvertiserChanges describes how a domain's advertisers differ from its previous analysis.
+type AdvertiserChanges struct {
+	PreviousTimestamp string                   `json:"previous_timestamp"`
+	Added             []adstxt.AdvertiserCount `json:"added"`
+	Removed           []adstxt.AdvertiserCount `json:"removed"`
+	Changed           []CountChange            `json:"changed"`
+}
+
+// CountChange is an advertiser present in both analyses with a different count.
+type CountChange struct {
+	Domain   string `json:"domain"`
+	Previous int    `json:"previous"`
+	Current  int    `json:"current"`
+}
+
+// previousKeyFor returns the cache key holding the last analysis of a domain for change detection.
+// It outlives the main entry (CHANGE_HISTORY_TTL) so polls spaced wider than CACHE_TTL still get a delta.
+func previousKeyFor(domain string) string {
+	return fmt.Sprintf("adstxt:previous:%s", domain)
+}
+
+// recordChanges sets result.Changes against the previously stored analysis of target,
+// then stores result as the new baseline. It is a no-op when CHANGE_HISTORY_TTL is 0.
+// Changes stay nil on the first analysis of a domain, as there is nothing to compare to.
+func (h *Handler) recordChanges(target string, result *SingleAnalysisResponse) {
+	if h.cfg.ChangeHistoryTTL <= 0 {
+		return
+	}
+
+	if data, err := h.cache.Get(previousKeyFor(target)); err == nil {
+		var previous SingleAnalysisResponse
+		if err := json.Unmarshal(data, &previous); err == nil {
+			result.Changes = diffAdvertisers(&previous, result)
+		} else {
+			h.logger.Warn("failed to unmarshal previous analysis",
+				slog.String("domain", target),
+				slog.String("error", err.Error()))
+		}
+	}
+
+	baseline := *result
+	baseline.Changes = nil
+	if data, err := json.Marshal(baseline); err == nil {
+		if err := h.cache.Set(previousKeyFor(target), data, h.cfg.ChangeHistoryTTL); err != nil {
+			h.logger.Warn("failed to store previous analysis", slog.String("domain", target), slog.String("error", err.Error()))
+		}
+	}
+}
+
+// diffAdvertisers compares two analyses. Each list is sorted by advertiser domain for stable output.
+func diffAdvertisers(previous, current *SingleAnalysisResponse) *AdvertiserChanges {
+	changes := &AdvertiserChanges{
+		PreviousTimestamp: previous.Timestamp,
+		Added:             make([]adstxt.AdvertiserCount, 0),
+		Removed:           make([]adstxt.AdvertiserCount, 0),
+		Changed:           make([]CountChange, 0),
+	}
+
+	before := make(map[string]int, len(previous.Advertisers))
+	for _, adv := range previous.Advertisers {
+		before[adv.Domain] = adv.Count
+	}
+
+	for _, adv := range current.Advertisers {
+		count, existed := before[adv.Domain]
+		switch {
+		case !existed:
+			changes.Added = append(changes.Added, adv)
+		case count != adv.Count:
+			changes.Changed = append(changes.Changed, CountChange{Domain: adv.Domain, Previous: count, Current: adv.Count})
+		}
+		delete(before, adv.Domain)
+	}
+	for domain, count := range before {
+		changes.Removed = append(changes.Removed, adstxt.AdvertiserCount{Domain: domain, Count: count})
+	}
+
+	sort.Slice(changes.Added, func(i, j int) bool { return changes.Added[i].Domain < changes.Added[j].Domain })
+	sort.Slice(changes.Removed, func(i, j int) bool { return changes.Removed[i].Domain < changes.Removed[j].Domain })
+	sort.Slice(changes.Changed, func(i, j int) bool { return changes.Changed[i].Domain < changes.Changed[j].Domain })
+
+	return changes
+}
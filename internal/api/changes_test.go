@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/adstxt"
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+func TestDiffAdvertisers(t *testing.T) {
+	previous := &SingleAnalysisResponse{
+		Timestamp: "2025-01-01T00:00:00Z",
+		Advertisers: []adstxt.AdvertiserCount{
+			{Domain: "google.com", Count: 3},
+			{Domain: "appnexus.com", Count: 1},
+			{Domain: "rubicon.com", Count: 2},
+		},
+	}
+	current := &SingleAnalysisResponse{
+		Advertisers: []adstxt.AdvertiserCount{
+			{Domain: "google.com", Count: 5},
+			{Domain: "rubicon.com", Count: 2},
+			{Domain: "openx.com", Count: 1},
+		},
+	}
+
+	changes := diffAdvertisers(previous, current)
+
+	if changes.PreviousTimestamp != previous.Timestamp {
+		t.Errorf("Expected previous timestamp %s, got %s", previous.Timestamp, changes.PreviousTimestamp)
+	}
+	if len(changes.Added) != 1 || changes.Added[0].Domain != "openx.com" {
+		t.Errorf("Expected openx.com added, got %+v", changes.Added)
+	}
+	if len(changes.Removed) != 1 || !reflect.DeepEqual(changes.Removed[0], adstxt.AdvertiserCount{Domain: "appnexus.com", Count: 1}) {
+		t.Errorf("Expected appnexus.com removed, got %+v", changes.Removed)
+	}
+	if len(changes.Changed) != 1 || changes.Changed[0] != (CountChange{Domain: "google.com", Previous: 3, Current: 5}) {
+		t.Errorf("Expected google.com 3 -> 5, got %+v", changes.Changed)
+	}
+}
+
+func TestHandler_FetchAndStore_RecordsChanges(t *testing.T) {
+	var version int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&version) == 0 {
+			_, _ = w.Write([]byte("google.com, pub-1, DIRECT\nappnexus.com, 1, RESELLER"))
+			return
+		}
+		_, _ = w.Write([]byte("google.com, pub-1, DIRECT\ngoogle.com, pub-2, DIRECT\nopenx.com, 1, DIRECT"))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	cfg := &config.Config{
+		CacheTTL:         1 * time.Hour,
+		RequestTimeout:   5 * time.Second,
+		ChangeHistoryTTL: 24 * time.Hour,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	handler := mustNewHandler(t, cacheStore, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	first, err := handler.fetchAndStore(context.Background(), host, host, false)
+	if err != nil {
+		t.Fatalf("fetchAndStore() error = %v", err)
+	}
+	if first.Changes != nil {
+		t.Errorf("Expected no changes on first analysis, got %+v", first.Changes)
+	}
+
+	// The main entry expiring must not lose the baseline
+	_ = cacheStore.Delete(cacheKeyFor(host))
+	atomic.StoreInt32(&version, 1)
+
+	second, err := handler.fetchAndStore(context.Background(), host, host, false)
+	if err != nil {
+		t.Fatalf("fetchAndStore() error = %v", err)
+	}
+	if second.Changes == nil {
+		t.Fatal("Expected changes on second analysis")
+	}
+	if len(second.Changes.Added) != 1 || len(second.Changes.Removed) != 1 || len(second.Changes.Changed) != 1 {
+		t.Errorf("Expected one added, removed, and changed advertiser, got %+v", second.Changes)
+	}
+}
+
+func TestHandler_AnalyzeSingle_DetectChanges(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	handler := mustNewHandler(t, cacheStore, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	data, _ := json.Marshal(SingleAnalysisResponse{
+		Domain:    "example.com",
+		Timestamp: time.Now().Format(time.RFC3339),
+		Changes:   &AdvertiserChanges{Added: []adstxt.AdvertiserCount{{Domain: "openx.com", Count: 1}}},
+	})
+	_ = cacheStore.Set(cacheKeyFor("example.com"), data, cfg.CacheTTL)
+
+	tests := []struct {
+		name        string
+		query       string
+		wantStatus  int
+		wantChanges bool
+	}{
+		{"omitted by default", "", http.StatusOK, false},
+		{"requested", "&detect_changes=true", http.StatusOK, true},
+		{"invalid value", "&detect_changes=maybe", http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/analyze?domain=example.com"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			handler.AnalyzeSingle(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var response SingleAnalysisResponse
+			_ = json.NewDecoder(w.Body).Decode(&response)
+			if (response.Changes != nil) != tt.wantChanges {
+				t.Errorf("Expected changes present = %v, got %+v", tt.wantChanges, response.Changes)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"adstxt-api/internal/adstxt"
+)
+
+// fetchFlight is one outbound fetch shared by every caller that missed the cache for the
+// same key while it was running.
+type fetchFlight struct {
+	done    chan struct{} // Closed once result and err are set
+	result  *SingleAnalysisResponse
+	err     error
+	waiters int                // Callers still waiting; the fetch is cancelled when it drops to 0
+	cancel  context.CancelFunc // Cancels the shared fetch
+}
+
+// fetchCoalescer deduplicates concurrent fetches of the same domain across all requests,
+// whichever endpoint they came from: single, batch, NDJSON, jobs and background refreshes.
+type fetchCoalescer struct {
+	mu      sync.Mutex
+	flights map[string]*fetchFlight
+}
+
+// coalescedFetchAndStore is fetchAndStore shared between concurrent callers. The first caller
+// for a key starts the fetch; later callers wait for its result instead of fetching again and
+// are counted in fetches_coalesced_total. Requests for parsed entries only share with each
+// other, since the others' analysis is built without them, and so do requests without the
+// www fallback.
+//
+// The fetch runs on a fresh context, not on any single caller's, so a caller that gives up does
+// not fail the others; it is cancelled only once every caller has given up. That context
+// carries only the fetch overrides (www fallback, race and timeout), which are all part of the
+// key, so one caller's override never applies to another; request-scoped values such as the
+// trace stay behind. Each caller gets its own deep copy of the result, with domain set to its
+// own input, so per-request filtering stays private.
+func (h *Handler) coalescedFetchAndStore(ctx context.Context, domain, target string, withEntries bool) (*SingleAnalysisResponse, error) {
+	key := cacheKeyFor(target)
+	if withEntries {
+		key += "+entries"
+	}
+	if !h.wwwFallback(ctx) {
+		key += "+apex" // Fails with ErrWWWOnly where the others succeed
+	}
+	flightCtx := context.Background()
+	if enabled, ok := adstxt.WWWFallbackFromContext(ctx); ok {
+		flightCtx = adstxt.WithWWWFallback(flightCtx, enabled)
+	}
+	if enabled, ok := adstxt.FetchRaceFromContext(ctx); ok {
+		flightCtx = adstxt.WithFetchRace(flightCtx, enabled)
+		key += "+race=" + strconv.FormatBool(enabled)
+	}
+	if timeout, ok := adstxt.FetchTimeoutFromContext(ctx); ok {
+		flightCtx = adstxt.WithFetchTimeout(flightCtx, timeout)
+		key += "+timeout=" + timeout.String()
+	}
+
+	c := &h.coalescer
+	c.mu.Lock()
+	flight, shared := c.flights[key]
+	if !shared {
+		fetchCtx, cancel := context.WithCancel(flightCtx)
+		flight = &fetchFlight{done: make(chan struct{}), cancel: cancel}
+		if c.flights == nil {
+			c.flights = make(map[string]*fetchFlight)
+		}
+		c.flights[key] = flight
+		go h.runFlight(fetchCtx, key, flight, domain, target, withEntries)
+	}
+	flight.waiters++
+	c.mu.Unlock()
+
+	if shared {
+		h.metrics.mu.Lock()
+		h.metrics.coalescedFetches++
+		h.metrics.mu.Unlock()
+	}
+
+	select {
+	case <-flight.done:
+	case <-ctx.Done():
+		c.mu.Lock()
+		if flight.waiters--; flight.waiters == 0 {
+			flight.cancel()
+			if c.flights[key] == flight {
+				delete(c.flights, key) // Later callers start afresh rather than join a cancelled fetch
+			}
+		}
+		c.mu.Unlock()
+		return nil, ctx.Err()
+	}
+
+	if flight.err != nil {
+		return nil, flight.err
+	}
+	result := flight.result.clone()
+	result.Domain = domain
+	return result, nil
+}
+
+// runFlight performs flight's fetch and publishes the outcome to its waiters.
+// It runs on its own goroutine, outside any caller's panic recovery, so a panic in the
+// fetch or parse is recovered here and reported to every waiter as an error.
+func (h *Handler) runFlight(ctx context.Context, key string, flight *fetchFlight, domain, target string, withEntries bool) {
+	defer flight.cancel()
+	defer func() {
+		h.coalescer.mu.Lock()
+		if h.coalescer.flights[key] == flight {
+			delete(h.coalescer.flights, key)
+		}
+		h.coalescer.mu.Unlock()
+		close(flight.done)
+	}()
+	// Deferred after the cleanup above so it runs first, and waiters never see done closed without an error
+	defer func() {
+		if rec := recover(); rec != nil {
+			h.logger.Error("panic in shared fetch",
+				slog.String("domain", target),
+				slog.Any("panic", rec),
+				slog.String("stack", string(debug.Stack())))
+			flight.result, flight.err = nil, errors.New("internal error processing domain")
+		}
+	}()
+
+	start := time.Now()
+	flight.result, flight.err = h.fetchAndStore(ctx, domain, target, withEntries)
+	if !errors.Is(flight.err, adstxt.ErrCircuitOpen) { // Nothing was fetched
+		h.metrics.recordFetchLatency(time.Since(start))
+	}
+}
+
+// clone copies r deeply: every waiter on a flight gets its own copy, and response options such
+// as ?verbose, ?percentages and ?lenient edit the slices and maps in it.
+func (r *SingleAnalysisResponse) clone() *SingleAnalysisResponse {
+	c := *r
+	c.Advertisers = cloneAdvertisers(r.Advertisers)
+	c.Recovered = cloneAdvertisers(r.Recovered)
+	c.CommentMetadata = maps.Clone(r.CommentMetadata)
+	c.Entries = slices.Clone(r.Entries)
+	c.Trace = slices.Clone(r.Trace)
+	if r.Changes != nil {
+		changes := *r.Changes
+		changes.Added = cloneAdvertisers(r.Changes.Added)
+		changes.Removed = cloneAdvertisers(r.Changes.Removed)
+		changes.Changed = slices.Clone(r.Changes.Changed)
+		c.Changes = &changes
+	}
+	if r.Redirects != nil {
+		redirects := *r.Redirects
+		c.Redirects = &redirects
+	}
+	return &c
+}
+
+// cloneAdvertisers copies advertisers along with the slices and maps in each element.
+func cloneAdvertisers(advertisers []adstxt.AdvertiserCount) []adstxt.AdvertiserCount {
+	if advertisers == nil {
+		return nil
+	}
+	c := make([]adstxt.AdvertiserCount, len(advertisers))
+	for i, a := range advertisers {
+		a.CertAuthorities = slices.Clone(a.CertAuthorities)
+		a.AccountGroups = maps.Clone(a.AccountGroups)
+		a.Subdomains = maps.Clone(a.Subdomains)
+		c[i] = a
+	}
+	return c
+}
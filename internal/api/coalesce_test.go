@@ -0,0 +1,267 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/adstxt"
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+// gatedFetcher holds every fetch until release is closed, or until its context ends.
+type gatedFetcher struct {
+	*fakeFetcher
+	release chan struct{}
+}
+
+func (f gatedFetcher) FetchAdsTxt(ctx context.Context, domain string) (string, error) {
+	select {
+	case <-f.release:
+		return f.fakeFetcher.FetchAdsTxt(ctx, domain)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// waitForCoalesced polls until n callers have joined another caller's fetch.
+func waitForCoalesced(t *testing.T, handler *Handler, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for handler.metricsSnapshot().CoalescedTotal < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("fetches_coalesced_total = %d, want %d", handler.metricsSnapshot().CoalescedTotal, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHandler_CoalescesConcurrentFetches(t *testing.T) {
+	cfg := &config.Config{CacheTTL: time.Hour, RequestTimeout: 10 * time.Second}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+
+	fetcher := gatedFetcher{newFakeFetcher(map[string]string{"example.com": "google.com, pub-1, DIRECT\n"}), make(chan struct{})}
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	defer handler.Close()
+
+	// A single analysis and an overlapping batch, each of which misses the cache
+	var wg sync.WaitGroup
+	var single *httptest.ResponseRecorder
+	var batch BatchAnalysisResponse
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		single = httptest.NewRecorder()
+		handler.AnalyzeSingle(single, httptest.NewRequest("GET", "/api/analyze?domain=example.com&verbose=true", nil))
+	}()
+	waitForInflight(t, handler, 1)
+	go func() {
+		defer wg.Done()
+		batch = handler.processBatch(context.Background(), []string{"example.com"}, nil)
+	}()
+	waitForCoalesced(t, handler, 1)
+	close(fetcher.release)
+	wg.Wait()
+
+	if calls := fetcher.calls["example.com"]; calls != 1 {
+		t.Errorf("fetched example.com %d times, want 1", calls)
+	}
+	if single.Code != 200 || !strings.Contains(single.Body.String(), `"domain":"example.com"`) {
+		t.Errorf("single analysis = %d %s", single.Code, single.Body.String())
+	}
+	if len(batch.Errors) != 0 || len(batch.Results) != 1 || batch.Results[0].TotalAdvertisers != 1 {
+		t.Errorf("batch = %+v, want the shared analysis", batch)
+	}
+	var metrics MetricsResponse
+	w := httptest.NewRecorder()
+	handler.Metrics(w, httptest.NewRequest("GET", "/metrics", nil))
+	if err := json.NewDecoder(w.Body).Decode(&metrics); err != nil {
+		t.Fatal(err)
+	}
+	if metrics.CoalescedTotal != 1 || metrics.FetchDuration.Count != 1 {
+		t.Errorf("fetches_coalesced_total = %d, fetch count = %d, want 1 each", metrics.CoalescedTotal, metrics.FetchDuration.Count)
+	}
+}
+
+// waitForInflight polls until n fetches are in flight.
+func waitForInflight(t *testing.T, handler *Handler, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		handler.coalescer.mu.Lock()
+		inflight := len(handler.coalescer.flights)
+		handler.coalescer.mu.Unlock()
+		if inflight >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d fetches in flight, want %d", inflight, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHandler_CoalescedFetch_Cancellation(t *testing.T) {
+	cfg := &config.Config{CacheTTL: time.Hour, RequestTimeout: 10 * time.Second}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+
+	fetcher := gatedFetcher{newFakeFetcher(map[string]string{"example.com": "google.com, pub-1, DIRECT\n"}), make(chan struct{})}
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	defer handler.Close()
+
+	// The caller that started the fetch gives up; the one that joined still gets the result
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := handler.analyzeDomain(leaderCtx, "example.com")
+		leaderErr <- err
+	}()
+	waitForInflight(t, handler, 1)
+	followerResult := make(chan *SingleAnalysisResponse, 1)
+	go func() {
+		result, _ := handler.analyzeDomain(context.Background(), "example.com")
+		followerResult <- result
+	}()
+	waitForCoalesced(t, handler, 1)
+
+	cancelLeader()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller error = %v, want context.Canceled", err)
+	}
+	close(fetcher.release)
+	if result := <-followerResult; result == nil || result.TotalAdvertisers != 1 {
+		t.Errorf("remaining caller result = %+v, want the analysis", result)
+	}
+
+	// Once every caller has given up, the shared fetch is cancelled too
+	blocking := NewHandlerWithFetcher(cacheStore, blockingFetcher{}, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	defer blocking.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := blocking.analyzeDomain(ctx, "slow.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("analyzeDomain() error = %v, want context.DeadlineExceeded", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for blocking.metricsSnapshot().FetchDuration.Count != 1 { // Recorded when the fetch returns
+		if time.Now().After(deadline) {
+			t.Fatal("abandoned fetch kept running after its last caller left")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// panickingFetcher panics inside the fetch, on the shared fetch's own goroutine.
+type panickingFetcher struct{}
+
+func (panickingFetcher) FetchAdsTxt(ctx context.Context, domain string) (string, error) {
+	panic("simulated fetch panic")
+}
+
+func TestHandler_CoalescedFetch_Panic(t *testing.T) {
+	cfg := &config.Config{CacheTTL: time.Hour, RequestTimeout: 10 * time.Second}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+
+	handler := NewHandlerWithFetcher(cacheStore, panickingFetcher{}, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	defer handler.Close()
+
+	// Each domain fails on its own instead of crashing the process
+	response := handler.processBatch(context.Background(), []string{"one.com", "two.com"}, nil)
+	for _, d := range []string{"one.com", "two.com"} {
+		if !strings.Contains(response.Errors[d], "internal error processing domain") {
+			t.Errorf("error for %s = %q, want the internal error", d, response.Errors[d])
+		}
+	}
+
+	// The failed flight is cleared, so a later caller fetches again rather than hanging
+	w := httptest.NewRecorder()
+	handler.AnalyzeSingle(w, httptest.NewRequest("GET", "/api/analyze?domain=one.com", nil))
+	if w.Code == 200 {
+		t.Errorf("status = %d, want an error", w.Code)
+	}
+	handler.coalescer.mu.Lock()
+	inflight := len(handler.coalescer.flights)
+	handler.coalescer.mu.Unlock()
+	if inflight != 0 {
+		t.Errorf("%d fetches still in flight, want 0", inflight)
+	}
+}
+
+func TestHandler_CoalescedFetch_KeepsOverridesApart(t *testing.T) {
+	cfg := &config.Config{CacheTTL: time.Hour, RequestTimeout: 10 * time.Second}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+
+	fetcher := gatedFetcher{newFakeFetcher(map[string]string{"example.com": "google.com, pub-1, DIRECT\n"}), make(chan struct{})}
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	defer handler.Close()
+
+	// A caller's timeout override must not apply to a caller without one, so they fetch apart
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		handler.analyzeDomain(adstxt.WithFetchTimeout(context.Background(), time.Second), "example.com")
+	}()
+	waitForInflight(t, handler, 1)
+	go func() {
+		defer wg.Done()
+		handler.analyzeDomain(context.Background(), "example.com")
+	}()
+	waitForInflight(t, handler, 2)
+	close(fetcher.release)
+	wg.Wait()
+
+	if coalesced := handler.metricsSnapshot().CoalescedTotal; coalesced != 0 {
+		t.Errorf("fetches_coalesced_total = %d, want 0", coalesced)
+	}
+}
+
+func TestSingleAnalysisResponse_Clone(t *testing.T) {
+	redirects := 1
+	original := &SingleAnalysisResponse{
+		Advertisers:     []adstxt.AdvertiserCount{{Domain: "google.com", Count: 2, CertAuthorities: []string{"f08c47fec0942fa0"}, AccountGroups: map[string]int{"pub": 2}}},
+		Recovered:       []adstxt.AdvertiserCount{{Domain: "rubicon.com", Count: 1}},
+		Changes:         &AdvertiserChanges{Added: []adstxt.AdvertiserCount{{Domain: "google.com", Count: 2}}, Changed: []CountChange{{Domain: "google.com", Previous: 1, Current: 2}}},
+		Entries:         []adstxt.Entry{{Domain: "google.com", AccountID: "pub-1"}, {Domain: "rubicon.com", AccountID: "1", Lenient: true}},
+		Redirects:       &redirects,
+		Trace:           []adstxt.FetchAttempt{{URL: "https://example.com/ads.txt"}},
+		CommentMetadata: map[string]string{"contact": "ads@example.com"},
+	}
+
+	// Every change to the copy, including lenient filtering, stays out of the original
+	c := original.clone()
+	c.Advertisers[0].Count = 9
+	c.Advertisers[0].CertAuthorities[0] = "changed"
+	c.Advertisers[0].AccountGroups["pub"] = 9
+	c.Recovered[0].Count = 9
+	c.Changes.Added[0].Count = 9
+	c.Changes.Changed[0].Current = 9
+	*c.Redirects = 9
+	c.Trace[0].URL = "changed"
+	c.CommentMetadata["contact"] = "changed"
+	applyLenient(c, false)
+
+	if a := original.Advertisers[0]; a.Count != 2 || a.CertAuthorities[0] != "f08c47fec0942fa0" || a.AccountGroups["pub"] != 2 {
+		t.Errorf("original advertiser = %+v, want it unchanged", a)
+	}
+	if original.Recovered[0].Count != 1 || original.Changes.Added[0].Count != 2 || original.Changes.Changed[0].Current != 2 {
+		t.Errorf("original recovered = %+v, changes = %+v, want them unchanged", original.Recovered, original.Changes)
+	}
+	if *original.Redirects != 1 || original.Trace[0].URL != "https://example.com/ads.txt" || original.CommentMetadata["contact"] != "ads@example.com" {
+		t.Errorf("original redirects = %d, trace = %+v, metadata = %v, want them unchanged", *original.Redirects, original.Trace, original.CommentMetadata)
+	}
+	if len(original.Entries) != 2 || original.Entries[1].Domain != "rubicon.com" {
+		t.Errorf("original entries = %+v, want both records", original.Entries)
+	}
+}
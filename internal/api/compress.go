@@ -0,0 +1,258 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// CompressionWriter is the stream a CompressionEncoder returns. Flush pushes buffered data to
+// the underlying writer so streamed responses still arrive promptly; Close finishes the stream.
+type CompressionWriter interface {
+	io.Writer
+	Flush() error
+	Close() error
+}
+
+// CompressionEncoder returns a CompressionWriter producing one content coding into w. level is
+// RESPONSE_COMPRESSION_LEVEL clamped to gzip's 1 (fastest) to 9 (smallest); codings with another
+// range map it onto theirs.
+type CompressionEncoder func(w io.Writer, level int) CompressionWriter
+
+// compressionEncoder is a content coding CompressionMiddleware can produce.
+type compressionEncoder struct {
+	name   string
+	encode CompressionEncoder
+}
+
+var (
+	compressionMu sync.Mutex
+	// compressionEncoders lists the registered content codings, most preferred first.
+	compressionEncoders = []compressionEncoder{{name: "br", encode: newBrotliWriter}, {name: "gzip", encode: newGzipWriter}}
+)
+
+// RegisterCompressionEncoder makes CompressionMiddleware offer the content coding name (e.g.
+// "zstd", backed by a third-party module), preferred over the codings registered before it
+// when a client weighs them equally. Registering a name again replaces its encoder. Only
+// middleware created afterwards sees it, so register encoders before building the router.
+func RegisterCompressionEncoder(name string, encoder CompressionEncoder) {
+	name = strings.ToLower(name)
+	compressionMu.Lock()
+	defer compressionMu.Unlock()
+
+	encoders := []compressionEncoder{{name: name, encode: encoder}}
+	for _, e := range compressionEncoders {
+		if e.name != name {
+			encoders = append(encoders, e)
+		}
+	}
+	compressionEncoders = encoders
+}
+
+// registeredEncoders returns a snapshot of compressionEncoders.
+func registeredEncoders() []compressionEncoder {
+	compressionMu.Lock()
+	defer compressionMu.Unlock()
+	return compressionEncoders
+}
+
+// gzipWriterPools and brotliWriterPools reuse writers per level; allocating one costs hundreds of KB.
+var (
+	gzipWriterPools   [gzip.BestCompression + 1]sync.Pool
+	brotliWriterPools [gzip.BestCompression + 1]sync.Pool
+)
+
+// CompressionMiddleware compresses response bodies for clients that accept one of the
+// registered codings: br, gzip, and any added with RegisterCompressionEncoder.
+// level runs from 1 (fastest) to 9 (smallest); 0 disables compression and other values
+// are clamped into range. Responses without a body (HEAD, 204, 304) and responses that
+// already set Content-Encoding are passed through untouched. A strong ETag is weakened
+// on compressed responses, since the bytes on the wire no longer match the hashed body;
+// weak If-None-Match comparison still yields 304 for it.
+func CompressionMiddleware(level int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if level == 0 {
+			return next
+		}
+		level := min(max(level, gzip.BestSpeed), gzip.BestCompression)
+		encoders := registeredEncoders()
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addVary(w.Header(), "Accept-Encoding")
+			encoder, ok := negotiateEncoding(r.Header.Get("Accept-Encoding"), encoders)
+			if !ok || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoder: encoder, level: level}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding picks the most preferred of encoders that acceptEncoding allows, or
+// reports false to send the body uncompressed. Codings are weighed by q-value and ties go to
+// encoders order; "*" covers codings not listed by name and q=0 refuses one.
+func negotiateEncoding(acceptEncoding string, encoders []compressionEncoder) (compressionEncoder, bool) {
+	if acceptEncoding == "" {
+		return compressionEncoder{}, false
+	}
+
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		weight := 1.0
+		if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(key) == "q" {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			weight = q
+		}
+		weights[name] = weight
+	}
+
+	var best compressionEncoder
+	bestWeight := 0.0
+	for _, encoder := range encoders {
+		weight, ok := weights[encoder.name]
+		if !ok {
+			weight, ok = weights["*"]
+		}
+		if ok && weight > bestWeight {
+			best, bestWeight = encoder, weight
+		}
+	}
+	return best, bestWeight > 0
+}
+
+// compressWriter compresses the body written through it with encoder. Whether to compress is
+// decided when the status is written; the encoder's writer is only created once there is a
+// body to compress.
+type compressWriter struct {
+	http.ResponseWriter
+	encoder compressionEncoder
+	level   int
+
+	wroteHeader bool
+	compress    bool
+	zw          CompressionWriter
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	if code < 200 {
+		cw.ResponseWriter.WriteHeader(code) // Informational; the final status is still to come
+		return
+	}
+	cw.wroteHeader = true
+
+	header := cw.Header()
+	cw.compress = bodyAllowed(code) && header.Get("Content-Encoding") == ""
+	if cw.compress {
+		header.Set("Content-Encoding", cw.encoder.name)
+		header.Del("Content-Length")
+		if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			header.Set("ETag", "W/"+etag)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.compress {
+		return cw.ResponseWriter.Write(b)
+	}
+	if cw.zw == nil {
+		cw.zw = cw.encoder.encode(cw.ResponseWriter, cw.level)
+	}
+	return cw.zw.Write(b)
+}
+
+// Flush pushes buffered compressed data to the client, so streamed responses such as
+// NDJSON batches still arrive line by line.
+func (cw *compressWriter) Flush() {
+	if cw.zw != nil {
+		_ = cw.zw.Flush()
+	}
+	_ = http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close finishes the compressed stream.
+func (cw *compressWriter) close() {
+	if cw.zw == nil {
+		return
+	}
+	_ = cw.zw.Close()
+	cw.zw = nil
+}
+
+// pooledGzipWriter returns its gzip.Writer to the pool once closed.
+type pooledGzipWriter struct {
+	*gzip.Writer
+	level int
+}
+
+func (zw pooledGzipWriter) Close() error {
+	err := zw.Writer.Close()
+	gzipWriterPools[zw.level].Put(zw.Writer)
+	return err
+}
+
+// newGzipWriter is the gzip CompressionEncoder.
+func newGzipWriter(w io.Writer, level int) CompressionWriter {
+	if zw, ok := gzipWriterPools[level].Get().(*gzip.Writer); ok {
+		zw.Reset(w)
+		return pooledGzipWriter{Writer: zw, level: level}
+	}
+	zw, _ := gzip.NewWriterLevel(w, level) // level is already clamped into gzip's range
+	return pooledGzipWriter{Writer: zw, level: level}
+}
+
+// pooledBrotliWriter returns its brotli.Writer to the pool once closed.
+type pooledBrotliWriter struct {
+	*brotli.Writer
+	level int
+}
+
+func (bw pooledBrotliWriter) Close() error {
+	err := bw.Writer.Close()
+	brotliWriterPools[bw.level].Put(bw.Writer)
+	return err
+}
+
+// newBrotliWriter is the br CompressionEncoder. Brotli's quality runs from 0 to 11; gzip's
+// 1 to 9 are used as is, which keeps the fast end fast and leaves out the very slow 10 and 11.
+func newBrotliWriter(w io.Writer, level int) CompressionWriter {
+	if bw, ok := brotliWriterPools[level].Get().(*brotli.Writer); ok {
+		bw.Reset(w)
+		return pooledBrotliWriter{Writer: bw, level: level}
+	}
+	return pooledBrotliWriter{Writer: brotli.NewWriterLevel(w, level), level: level}
+}
+
+// bodyAllowed reports whether a response with status code may carry a body.
+func bodyAllowed(code int) bool {
+	return code != http.StatusNoContent && code != http.StatusNotModified
+}
@@ -0,0 +1,275 @@
+package api
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"adstxt-api/internal/adstxt"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"GZIP", "gzip"},
+		{"br", "br"},
+		{"identity", ""},
+		{"*", "br"},
+		{"gzip;q=0", ""},
+		{"gzip;q=0, *", "br"},
+		{"br;q=0, *", "gzip"},
+		{"br;q=1.0, gzip;q=0.5", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"*;q=0", ""},
+		{"gzip;q=bogus", ""},
+	}
+
+	for _, tt := range tests {
+		if got, _ := negotiateEncoding(tt.acceptEncoding, registeredEncoders()); got.name != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.acceptEncoding, got.name, tt.want)
+		}
+	}
+}
+
+// flateWriter is a deflate CompressionWriter for testing registered encoders.
+type flateWriter struct {
+	*flate.Writer
+}
+
+func TestRegisterCompressionEncoder(t *testing.T) {
+	saved := registeredEncoders()
+	t.Cleanup(func() { compressionEncoders = saved })
+
+	var gotLevel int
+	RegisterCompressionEncoder("Deflate", func(w io.Writer, level int) CompressionWriter {
+		gotLevel = level
+		zw, _ := flate.NewWriter(w, level)
+		return flateWriter{zw}
+	})
+	body := strings.Repeat(`{"domain":"google.com","count":1}`, 100)
+	handler := CompressionMiddleware(12)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, body)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/analyze", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Content-Encoding"); got != "deflate" {
+		t.Fatalf("Content-Encoding = %q, want the registered deflate preferred", got)
+	}
+	if gotLevel != gzip.BestCompression {
+		t.Errorf("encoder level = %d, want it clamped to %d", gotLevel, gzip.BestCompression)
+	}
+	decoded, err := io.ReadAll(flate.NewReader(w.Body))
+	if err != nil || string(decoded) != body {
+		t.Errorf("deflate body does not decode to the original: %v", err)
+	}
+
+	// gzip is still offered to clients that only accept it
+	req.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("Content-Encoding = %q, want gzip", got)
+	}
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	body := strings.Repeat(`{"domain":"google.com","count":1}`, 100)
+	handler := CompressionMiddleware(6)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"abc"`)
+		if r.Header.Get("If-None-Match") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = io.WriteString(w, body)
+	}))
+
+	t.Run("gzip accepted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/analyze", nil)
+		req.Header.Set("Accept-Encoding", "gzip, deflate")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+			t.Fatalf("Content-Encoding = %q, want gzip", got)
+		}
+		if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("Vary = %q, want Accept-Encoding", got)
+		}
+		if got := w.Header().Get("ETag"); got != `W/"abc"` {
+			t.Errorf("ETag = %q, want the weakened W/\"abc\"", got)
+		}
+		if w.Body.Len() >= len(body) {
+			t.Errorf("compressed body is %d bytes, not smaller than %d", w.Body.Len(), len(body))
+		}
+
+		zr, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatalf("gzip.NewReader() error = %v", err)
+		}
+		decoded, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("reading gzip body: %v", err)
+		}
+		if string(decoded) != body {
+			t.Error("decompressed body does not match the original")
+		}
+	})
+
+	t.Run("br accepted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/analyze", nil)
+		req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Content-Encoding"); got != "br" {
+			t.Fatalf("Content-Encoding = %q, want br", got)
+		}
+		if got := w.Header().Get("ETag"); got != `W/"abc"` {
+			t.Errorf("ETag = %q, want the weakened W/\"abc\"", got)
+		}
+		decoded, err := io.ReadAll(brotli.NewReader(w.Body))
+		if err != nil {
+			t.Fatalf("reading br body: %v", err)
+		}
+		if string(decoded) != body {
+			t.Error("decompressed body does not match the original")
+		}
+	})
+
+	t.Run("not accepted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/analyze", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("Content-Encoding = %q, want none", got)
+		}
+		if got := w.Header().Get("ETag"); got != `"abc"` {
+			t.Errorf("ETag = %q, want it unchanged", got)
+		}
+		if w.Body.String() != body {
+			t.Error("uncompressed body was altered")
+		}
+	})
+
+	t.Run("not modified", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/analyze", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("If-None-Match", `W/"abc"`)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotModified {
+			t.Fatalf("status = %d, want 304", w.Code)
+		}
+		if got := w.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("Content-Encoding = %q on a 304, want none", got)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("304 body = %d bytes, want none", w.Body.Len())
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		plain := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, body)
+		})
+		req := httptest.NewRequest(http.MethodGet, "/api/analyze", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		CompressionMiddleware(0)(plain).ServeHTTP(w, req)
+
+		if got := w.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("Content-Encoding = %q with level 0, want none", got)
+		}
+		if w.Body.String() != body {
+			t.Error("level 0 altered the body")
+		}
+	})
+}
+
+func TestCompressionMiddleware_Flush(t *testing.T) {
+	var flushedBytes int
+	handler := CompressionMiddleware(1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"type":"result"}`+"\n")
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush() error = %v", err)
+		}
+		flushedBytes = w.(*compressWriter).ResponseWriter.(*httptest.ResponseRecorder).Body.Len()
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/batch-ndjson", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if flushedBytes == 0 {
+		t.Error("Flush() left the first line buffered in the gzip writer")
+	}
+}
+
+// BenchmarkCompression_BatchResponse compares the CPU cost and wire size of each br and gzip
+// level on a 200-domain batch response. Compare the wire-bytes metric against "off".
+func BenchmarkCompression_BatchResponse(b *testing.B) {
+	resp := BatchAnalysisResponse{}
+	for i := 0; i < 200; i++ {
+		result := SingleAnalysisResponse{Domain: fmt.Sprintf("publisher%d.com", i), Timestamp: "2025-11-20T10:30:45Z"}
+		for j := 0; j < 100; j++ {
+			result.Advertisers = append(result.Advertisers, adstxt.AdvertiserCount{
+				Domain: fmt.Sprintf("ssp%d.com", (i+j)%150), Count: j + 1, Direct: j, Reseller: 1,
+			})
+		}
+		result.TotalAdvertisers = len(result.Advertisers)
+		resp.Results = append(resp.Results, result)
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, encoding := range []string{"gzip", "br"} {
+		for _, level := range []int{0, 1, 6, 9} {
+			name := fmt.Sprintf("%s/level=%d", encoding, level)
+			if level == 0 {
+				if encoding != "gzip" {
+					continue
+				}
+				name = "off"
+			}
+			b.Run(name, func(b *testing.B) {
+				handler := CompressionMiddleware(level)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write(body)
+				}))
+				req := httptest.NewRequest(http.MethodPost, "/api/batch-analysis", nil)
+				req.Header.Set("Accept-Encoding", encoding)
+
+				var wire int
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					w := httptest.NewRecorder()
+					handler.ServeHTTP(w, req)
+					wire = w.Body.Len()
+				}
+				b.ReportMetric(float64(wire), "wire-bytes")
+			})
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"adstxt-api/internal/adstxt"
+)
+
+// cachedEntries are the parsed records of a cached analysis, stored under entriesKeyFor so that
+// ?include_entries=true is served from the cache like any other request. Timestamp and Hash tie
+// them to the analysis they were parsed with: entries outlived by a refresh without them are not served.
+type cachedEntries struct {
+	Timestamp        string         `json:"timestamp"`
+	Hash             string         `json:"hash"` // contentHash of the analysis
+	Entries          []adstxt.Entry `json:"entries"`
+	EntriesTruncated bool           `json:"entries_truncated,omitempty"`
+}
+
+// entriesKeyFor returns the cache key holding the parsed entries of a domain's analysis.
+func entriesKeyFor(domain string) string {
+	return fmt.Sprintf("adstxt:entries:%s", domain)
+}
+
+// analyzeDomainWithEntries is analyzeDomain with the parsed entries attached. A cached analysis
+// is served along with its cached entries; only when either is missing is the file fetched,
+// which caches both again.
+func (h *Handler) analyzeDomainWithEntries(ctx context.Context, domain string) (*SingleAnalysisResponse, error) {
+	target := h.cacheTarget(domain)
+
+	if entries, ok := h.cachedEntries(target); ok {
+		if cachedData, err := h.cache.Get(cacheKeyFor(target)); err == nil {
+			if result, ok := h.fromCache(ctx, domain, target, cachedData); ok && entries.matches(result) {
+				result.Entries, result.EntriesTruncated = entries.Entries, entries.EntriesTruncated
+				return result, nil
+			}
+		}
+	}
+
+	return h.analyzeMiss(ctx, domain, target, true)
+}
+
+// matches reports whether entries were parsed with result.
+func (e cachedEntries) matches(result *SingleAnalysisResponse) bool {
+	return e.Timestamp == result.Timestamp && e.Hash == contentHash(result.Advertisers)
+}
+
+// cachedEntries returns the cached entries of target's analysis, if any.
+func (h *Handler) cachedEntries(target string) (cachedEntries, bool) {
+	var entries cachedEntries
+	data, err := h.cache.Get(entriesKeyFor(target))
+	if err != nil {
+		return entries, false
+	}
+	if err := unmarshalCacheData(data, &entries); err != nil {
+		h.logger.Warn("failed to unmarshal cached entries",
+			slog.String("domain", target),
+			slog.String("error", err.Error()))
+		return entries, false
+	}
+	return entries, true
+}
+
+// storeEntries caches the entries of result, an analysis of target just cached for ttl.
+func (h *Handler) storeEntries(ctx context.Context, target string, result *SingleAnalysisResponse, ttl time.Duration) {
+	data, err := h.cacheFormat.marshal(cachedEntries{
+		Timestamp:        result.Timestamp,
+		Hash:             contentHash(result.Advertisers),
+		Entries:          result.Entries,
+		EntriesTruncated: result.EntriesTruncated,
+	})
+	if err != nil {
+		return
+	}
+	if err := h.cache.Set(entriesKeyFor(target), data, ttl); err != nil {
+		h.logger.WarnContext(ctx, "failed to cache entries", slog.String("domain", target), slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"errors"
+	"net"
+
+	"adstxt-api/internal/adstxt"
+)
+
+// Stable, machine-readable error codes returned in ErrorResponse.Code.
+// Clients should branch on these rather than on the human-readable message, which may change.
+const (
+	CodeInvalidDomain          = "INVALID_DOMAIN"           // Domain failed validation
+	CodeDomainNotAllowed       = "DOMAIN_NOT_ALLOWED"       // Domain is outside the configured fetch allowlist
+	CodeInvalidJSON            = "INVALID_JSON"             // Request body is not valid JSON
+	CodeInvalidBody            = "INVALID_BODY"             // Request body could not be read
+	CodeBodyTooLarge           = "BODY_TOO_LARGE"           // Request body exceeds the endpoint's size limit
+	CodeInvalidParameter       = "INVALID_PARAMETER"        // A query parameter has an invalid value
+	CodeMissingField           = "MISSING_FIELD"            // A required body field is absent
+	CodeInvalidField           = "INVALID_FIELD"            // A body field has an invalid value
+	CodeUnknownField           = "UNKNOWN_FIELD"            // Body contains a field the endpoint does not accept
+	CodeEmptyContent           = "EMPTY_CONTENT"            // Submitted ads.txt content is empty
+	CodeEmptyBatch             = "EMPTY_BATCH"              // Batch request has no domains
+	CodeBatchTooLarge          = "BATCH_TOO_LARGE"          // Batch request exceeds the domain limit
+	CodeMethodNotAllowed       = "METHOD_NOT_ALLOWED"       // HTTP method not supported by the endpoint
+	CodeFetchFailed            = "FETCH_FAILED"             // ads.txt could not be fetched
+	CodeFetchNotFound          = "FETCH_NOT_FOUND"          // Publisher responded 404 for ads.txt
+	CodeFetchDNSFailure        = "FETCH_DNS_FAILURE"        // Publisher's domain does not resolve
+	CodeFetchConnectionRefused = "FETCH_CONNECTION_REFUSED" // Publisher's domain resolves but no web server accepts connections
+	CodeFetchTimeout           = "FETCH_TIMEOUT"            // Fetching ads.txt timed out
+	CodeFetchRedirect          = "FETCH_REDIRECT"           // ads.txt redirected off the publisher's domain
+	CodeFetchWWWOnly           = "FETCH_WWW_ONLY"           // Only the www host serves ads.txt and the www fallback is disabled
+	CodeCircuitOpen            = "CIRCUIT_OPEN"             // Publisher's recent fetches kept failing; not retried until the cooldown ends
+	CodeRateLimited            = "RATE_LIMITED"             // Client exceeded the rate limit
+	CodeServerBusy             = "SERVER_BUSY"              // Too many concurrent in-flight requests
+	CodeClientBusy             = "CLIENT_BUSY"              // Client has too many concurrent in-flight requests
+	CodeUnauthorized           = "UNAUTHORIZED"             // Missing or invalid admin token
+	CodeAdminDisabled          = "ADMIN_DISABLED"           // Admin endpoints are disabled (no ADMIN_TOKEN)
+	CodeCacheFailure           = "CACHE_FAILURE"            // A cache operation failed
+	CodeJobNotFound            = "JOB_NOT_FOUND"            // Job ID is unknown or the job has expired
+)
+
+// fetchErrorCode classifies an analyzeDomain error into one of the FETCH_* codes.
+func fetchErrorCode(err error) string {
+	var cached *cachedFetchError
+	if errors.As(err, &cached) {
+		return cached.code
+	}
+
+	switch {
+	case errors.Is(err, adstxt.ErrWWWOnly): // Before ErrNotFound, which the apex's 404 also matches
+		return CodeFetchWWWOnly
+	case errors.Is(err, adstxt.ErrNotFound):
+		return CodeFetchNotFound
+	case errors.Is(err, adstxt.ErrDNSFailure):
+		return CodeFetchDNSFailure
+	case errors.Is(err, adstxt.ErrConnectionRefused):
+		return CodeFetchConnectionRefused
+	}
+
+	if errors.Is(err, adstxt.ErrCircuitOpen) {
+		return CodeCircuitOpen
+	}
+
+	var redirectErr *adstxt.RedirectError
+	if errors.As(err, &redirectErr) {
+		return CodeFetchRedirect
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return CodeFetchTimeout
+	}
+
+	return CodeFetchFailed
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/adstxt"
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestFetchErrorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"not found", fmt.Errorf("wrapped: %w", &adstxt.StatusError{Code: http.StatusNotFound}), CodeFetchNotFound},
+		{"not found after other failures", fmt.Errorf("wrapped: %w: %w", adstxt.ErrNotFound, errors.New("lookup www.example.com: no such host")), CodeFetchNotFound},
+		{"dns failure", fmt.Errorf("wrapped: %w: %w", adstxt.ErrDNSFailure, errors.New("lookup example.invalid: no such host")), CodeFetchDNSFailure},
+		{"connection refused", fmt.Errorf("wrapped: %w: %w", adstxt.ErrConnectionRefused, errors.New("connect: connection refused")), CodeFetchConnectionRefused},
+		{"www only", fmt.Errorf("wrapped: %w: %w", adstxt.ErrWWWOnly, &adstxt.StatusError{Code: http.StatusNotFound}), CodeFetchWWWOnly},
+		{"server error", fmt.Errorf("wrapped: %w", &adstxt.StatusError{Code: http.StatusBadGateway}), CodeFetchFailed},
+		{"timeout", fmt.Errorf("wrapped: %w", timeoutError{}), CodeFetchTimeout},
+		{"cross-domain redirect", fmt.Errorf("wrapped: %w", &adstxt.RedirectError{From: "a.com", To: "b.com"}), CodeFetchRedirect},
+		{"circuit open", fmt.Errorf("wrapped: %w for example.com", adstxt.ErrCircuitOpen), CodeCircuitOpen},
+		{"other", fmt.Errorf("connection refused"), CodeFetchFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fetchErrorCode(tt.err); got != tt.want {
+				t.Errorf("fetchErrorCode() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_ErrorResponseCode(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := mustNewHandler(t, cacheStore, cfg, logger)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/analyze?domain=localhost", nil)
+	w := httptest.NewRecorder()
+
+	handler.AnalyzeSingle(w, req)
+
+	var response ErrorResponse
+	_ = json.NewDecoder(w.Body).Decode(&response)
+
+	if response.Code != CodeInvalidDomain {
+		t.Errorf("Expected code %s, got %s", CodeInvalidDomain, response.Code)
+	}
+	if response.Error != "Bad Request" || response.Message == "" {
+		t.Errorf("Expected error and message to be kept, got %+v", response)
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"adstxt-api/internal/adstxt"
+)
+
+// explainFetch fetches target's ads.txt for ?explain=true, recording every attempt in trace.
+// The trace must describe this request's own fetch, so the cached analysis, the negative
+// cache and any fetch already in flight for the domain are all bypassed; a successful
+// analysis is still cached as usual.
+func (h *Handler) explainFetch(ctx context.Context, domain, target string, withEntries bool, trace *adstxt.FetchTrace) (*SingleAnalysisResponse, error) {
+	start := time.Now()
+	result, err := h.fetchAndStore(adstxt.WithFetchTrace(ctx, trace), domain, target, withEntries)
+	if !errors.Is(err, adstxt.ErrCircuitOpen) { // Nothing was fetched
+		h.metrics.recordFetchLatency(time.Since(start))
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Trace = trace.Attempts
+	return result, nil
+}
+
+// sendExplainedError reports a failed ?explain=true analysis like sendError, with the
+// attempts that led to err.
+func (h *Handler) sendExplainedError(w http.ResponseWriter, r *http.Request, err error, trace *adstxt.FetchTrace) {
+	h.sendJSON(w, r, http.StatusInternalServerError, ErrorResponse{
+		Error:   http.StatusText(http.StatusInternalServerError),
+		Code:    fetchErrorCode(err),
+		Message: err.Error(),
+		Trace:   trace.Attempts,
+	})
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+func TestHandler_AnalyzeSingle_Explain(t *testing.T) {
+	// An egress proxy standing in for the publisher: https tunnels are refused, so the
+	// fetch falls back to http, and only publisher.example serves an ads.txt
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect || r.URL.Host != "publisher.example" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte("google.com, pub-1, DIRECT"))
+	}))
+	defer proxy.Close()
+
+	cfg := &config.Config{
+		CacheTTL:         1 * time.Hour,
+		RequestTimeout:   10 * time.Second,
+		NegativeCacheTTL: 5 * time.Minute,
+		FetchProxyURL:    proxy.URL,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	handler := mustNewHandler(t, cacheStore, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	analyze := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?"+query, nil))
+		return w
+	}
+
+	if w := analyze("domain=publisher.example"); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Explained requests fetch again despite the cached analysis
+	w := analyze("domain=publisher.example&explain=true")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result SingleAnalysisResponse
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Cached || len(result.Trace) != 2 {
+		t.Fatalf("Expected a fresh analysis with 2 attempts, got cached=%v trace=%+v", result.Cached, result.Trace)
+	}
+	if https := result.Trace[0]; https.URL != "https://publisher.example/ads.txt" || https.Error == "" {
+		t.Errorf("Unexpected https attempt %+v", https)
+	}
+	if http200 := result.Trace[1]; http200.URL != "http://publisher.example/ads.txt" || http200.StatusCode != http.StatusOK || http200.Error != "" {
+		t.Errorf("Unexpected http attempt %+v", http200)
+	}
+
+	// The trace is never cached
+	w = analyze("domain=publisher.example")
+	result = SingleAnalysisResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Cached || result.Trace != nil {
+		t.Errorf("Expected the cached analysis without a trace, got cached=%v trace=%+v", result.Cached, result.Trace)
+	}
+
+	// Failures report the attempts alongside the error
+	w = analyze("domain=other.example&explain=true")
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d: %s", w.Code, w.Body.String())
+	}
+	var errResp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&errResp); err != nil {
+		t.Fatal(err)
+	}
+	if len(errResp.Trace) != 3 || errResp.Trace[1].StatusCode != http.StatusForbidden {
+		t.Errorf("Expected all 3 attempts in the error, got %+v", errResp.Trace)
+	}
+}
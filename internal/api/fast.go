@@ -0,0 +1,35 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"adstxt-api/internal/adstxt"
+)
+
+// withFastFetch returns ctx carrying the ?fast override, if given: true races the fetch's
+// URL patterns, false requests them in order, and without it FETCH_RACE decides. On an
+// invalid value it writes the error response itself and returns false.
+func (h *Handler) withFastFetch(ctx context.Context, w http.ResponseWriter, r *http.Request) (context.Context, bool) {
+	fast, ok := h.fastParam(w, r)
+	if !ok || fast == nil {
+		return ctx, ok
+	}
+	return adstxt.WithFetchRace(ctx, *fast), true
+}
+
+// fastParam returns the ?fast override, or nil without one. On an invalid value it writes
+// the error response itself and returns false.
+func (h *Handler) fastParam(w http.ResponseWriter, r *http.Request) (*bool, bool) {
+	raw := r.URL.Query().Get("fast")
+	if raw == "" { // Defaults to FETCH_RACE rather than false
+		return nil, true
+	}
+	fast, err := strconv.ParseBool(raw)
+	if err != nil {
+		h.sendError(w, r, http.StatusBadRequest, CodeInvalidParameter, "fast must be a boolean")
+		return nil, false
+	}
+	return &fast, true
+}
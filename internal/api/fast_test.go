@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/adstxt"
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+// raceRecordingFetcher records the WithFetchRace override each fetch was made under.
+type raceRecordingFetcher struct {
+	mu        sync.Mutex
+	overrides map[string]*bool
+}
+
+func (f *raceRecordingFetcher) FetchAdsTxt(ctx context.Context, domain string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if enabled, ok := adstxt.FetchRaceFromContext(ctx); ok {
+		f.overrides[domain] = &enabled
+	} else {
+		f.overrides[domain] = nil
+	}
+	return "google.com, pub-1, DIRECT", nil
+}
+
+func TestHandler_AnalyzeSingle_Fast(t *testing.T) {
+	cfg := &config.Config{CacheTTL: 1 * time.Hour, RequestTimeout: 10 * time.Second}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	fetcher := &raceRecordingFetcher{overrides: make(map[string]*bool)}
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	defer handler.Close()
+
+	enabled, disabled := true, false
+	tests := []struct {
+		domain string
+		query  string
+		want   *bool // nil leaves FETCH_RACE to decide
+	}{
+		{"default.example", "", nil},
+		{"fast.example", "&fast=true", &enabled},
+		{"ordered.example", "&fast=false", &disabled},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain="+tt.domain+tt.query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("AnalyzeSingle(%q) status = %d: %s", tt.query, w.Code, w.Body.String())
+		}
+
+		fetcher.mu.Lock()
+		got, fetched := fetcher.overrides[tt.domain]
+		fetcher.mu.Unlock()
+		switch {
+		case !fetched:
+			t.Errorf("%s was not fetched", tt.domain)
+		case (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want:
+			t.Errorf("race override for %q = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=example.com&fast=maybe", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid fast, got %d", w.Code)
+	}
+}
+
+func TestHandler_Fast_Batches(t *testing.T) {
+	fetcher := &raceRecordingFetcher{overrides: make(map[string]*bool)}
+	_, router := newJobsTestRouter(t, fetcher, &config.Config{RequestTimeout: 10 * time.Second, BatchStreamMaxDomains: 10, BatchStreamTimeout: 10 * time.Second, JobWorkers: 1})
+
+	tests := []struct {
+		path   string
+		domain string
+		want   bool
+	}{
+		{"/api/batch-analysis?fast=true", "batch.example", true},
+		{"/api/batch-aggregate?fast=false", "aggregate.example", false},
+		{"/api/batch-ndjson?fast=true", "stream.example", true},
+		{"/api/jobs?fast=true", "job.example", true},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(`{"domains": ["`+tt.domain+`"]}`)))
+		if w.Code != http.StatusOK && w.Code != http.StatusAccepted {
+			t.Fatalf("POST %s status = %d: %s", tt.path, w.Code, w.Body.String())
+		}
+		if location := w.Header().Get("Location"); location != "" {
+			waitForJob(t, router, location)
+		}
+
+		fetcher.mu.Lock()
+		got := fetcher.overrides[tt.domain]
+		fetcher.mu.Unlock()
+		if got == nil || *got != tt.want {
+			t.Errorf("race override for %s = %v, want %t", tt.path, got, tt.want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/jobs?fast=maybe", strings.NewReader(`{"domains": ["example.com"]}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid fast, got %d", w.Code)
+	}
+}
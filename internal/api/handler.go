@@ -1,15 +1,25 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"net/http"
+	"runtime"
+	"runtime/debug"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"adstxt-api/internal/adstxt"
@@ -19,24 +29,80 @@ import (
 
 const maxBodySize = 1 << 20 // 1MB
 
+const maxBatchDomains = 50 // Per batch request
+
+// AdsTxtFetcher retrieves the raw ads.txt content for a domain.
+// *adstxt.Fetcher is the production implementation; tests can supply a deterministic fake.
+type AdsTxtFetcher interface {
+	FetchAdsTxt(ctx context.Context, domain string) (string, error)
+}
+
+// streamingFetcher is implemented by fetchers that can hand over the response body as it
+// arrives, as *adstxt.Fetcher does. Handlers prefer it over AdsTxtFetcher when available.
+type streamingFetcher interface {
+	StreamAdsTxt(ctx context.Context, domain string, consume func(io.Reader) error) error
+}
+
 type Handler struct {
-	cache   cache.Cache
-	fetcher *adstxt.Fetcher
-	cfg     *config.Config
-	logger  *slog.Logger
-	metrics *Metrics
+	cache            cache.Cache
+	fetcher          AdsTxtFetcher
+	cfg              *config.Config
+	logger           *slog.Logger
+	metrics          *Metrics
+	refresher        *refresher                // Keeps hot domains warm; nil when AUTO_REFRESH_TOP_K is 0
+	batchPool        *workerPool               // Shared batch workers; nil when BATCH_WORKERS is 0
+	jobs             *jobRunner                // Background processing for /api/jobs
+	coalescer        fetchCoalescer            // Shares concurrent fetches of the same domain across requests
+	checks           []HealthCheck             // Probes reported by /health; the cache check is always registered
+	cleanups         map[string]CleanupMonitor // Background sweeps reported by /health?verbose=true
+	directives       []adstxt.CommentDirective // COMMENT_DIRECTIVES followed by the defaults
+	draining         atomic.Bool               // Set by BeginShutdown; /ready and /health then report 503
+	healthMu         sync.Mutex                // Serializes health checks so concurrent probes share one run
+	lastHealth       *healthResult             // Most recent health check run, reused for HEALTH_CACHE_TTL
+	rawCache         bool                      // CACHE_MODE=raw: analyses are cached as the fetched file
+	millisTimestamps bool                      // TIMESTAMP_PRECISION=milliseconds, see formatTime
+	cacheFormat      cacheFormat               // CACHE_SERIALIZATION that analyses are written in
+	startedAt        time.Time
 }
 
 type SingleAnalysisResponse struct {
-	Domain           string                   `json:"domain"`
+	Domain           string                   `json:"domain,omitempty"`
 	TotalAdvertisers int                      `json:"total_advertisers"`
 	Advertisers      []adstxt.AdvertiserCount `json:"advertisers"`
+	Truncated        bool                     `json:"truncated,omitempty"`
+	SkippedLines     int                      `json:"skipped_lines,omitempty"`     // Lines over MAX_LINE_LENGTH, skipped as malformed
+	Suspicious       bool                     `json:"suspicious"`                  // Fewer advertisers than the min_advertisers threshold
+	Changes          *AdvertiserChanges       `json:"changes,omitempty"`           // Delta from the previous fetch, only with ?detect_changes=true
+	ContentHash      string                   `json:"content_hash,omitempty"`      // Pass back as ?since_hash= to get 304 when unchanged
+	RawContentHash   string                   `json:"raw_content_hash,omitempty"`  // SHA-256 of the fetched file, only with INCLUDE_RAW_HASH
+	LenientRecovered int                      `json:"lenient_recovered,omitempty"` // Records only accepted by ?lenient=true parsing
+	Recovered        []adstxt.AdvertiserCount `json:"recovered,omitempty"`         // Cached for applyLenient; never sent to clients
+	CommentMetadata  map[string]string        `json:"comment_metadata,omitempty"`  // From the leading comment block, only with ?verbose=true
+	Entries          []adstxt.Entry           `json:"entries,omitempty"`           // Parsed records in file order, only with ?include_entries=true; cached apart
+	EntriesTruncated bool                     `json:"entries_truncated,omitempty"` // Entries stopped at MAX_ADVERTISERS
+	HTTPStatus       int                      `json:"http_status,omitempty"`       // Final status of the fetch behind this analysis, only with ?debug=true
+	Redirects        *int                     `json:"redirects,omitempty"`         // Redirects that fetch followed, only with ?debug=true
+	WWWFallback      bool                     `json:"www_fallback,omitempty"`      // Only https://www.<domain>/ads.txt served the file
+	Trace            []adstxt.FetchAttempt    `json:"trace,omitempty"`             // URLs the fetch tried, only with ?explain=true; never cached
 	Cached           bool                     `json:"cached"`
+	Stale            bool                     `json:"stale,omitempty"`         // Expired analysis served because the re-fetch failed (SERVE_STALE_ON_ERROR)
+	CacheBackend     string                   `json:"cache_backend,omitempty"` // Store that served a cached result: memory, redis or file
 	Timestamp        string                   `json:"timestamp"`
+
+	rawContent *string // The fetched file, kept in CACHE_MODE=raw for encodeCacheEntry; never serialized
+}
+
+type ParseRequest struct {
+	Domain  string `json:"domain,omitempty"`
+	Content string `json:"content"`
 }
 
 type BatchAnalysisRequest struct {
-	Domains []string `json:"domains"`
+	Domains  []string          `json:"domains"`
+	Strict   bool              `json:"strict,omitempty"`   // Reject the whole batch if any domain is invalid
+	Timeouts map[string]string `json:"timeouts,omitempty"` // Per-domain fetch timeouts such as "20s", capped by the batch deadline
+
+	fetchTimeouts map[string]time.Duration // Timeouts parsed by decodeBatchRequest
 }
 
 type BatchAnalysisResponse struct {
@@ -44,36 +110,268 @@ type BatchAnalysisResponse struct {
 	Errors  map[string]string        `json:"errors,omitempty"`
 }
 
+// APIVersion is the current response shape. Unversioned routes alias to it.
+const APIVersion = "v1"
+
+// envelopeMediaType is the Accept value that requests the versioned envelope.
+const envelopeMediaType = "application/vnd.adstxt." + APIVersion + "+json"
+
+// Envelope wraps a successful response with the API version that produced its shape,
+// so clients can detect breaking changes when a new version is introduced.
+type Envelope struct {
+	APIVersion string      `json:"api_version"`
+	Data       interface{} `json:"data"`
+}
+
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message,omitempty"`
+	Error   string                `json:"error"`
+	Code    string                `json:"code"` // Stable machine-readable code, see errors.go
+	Message string                `json:"message,omitempty"`
+	Details map[string]string     `json:"details,omitempty"` // Per-item reasons, e.g. invalid domains in a strict batch
+	Trace   []adstxt.FetchAttempt `json:"trace,omitempty"`   // URLs a failed fetch tried, only with ?explain=true
 }
 
 type HealthResponse struct {
-	Status  string            `json:"status"`
-	Time    string            `json:"time"`
-	Version string            `json:"version,omitempty"`
-	Checks  map[string]string `json:"checks"`
+	Status       string            `json:"status"`
+	Time         string            `json:"time"`
+	CheckedAt    string            `json:"checked_at"` // When the checks last ran; may trail time by up to HEALTH_CACHE_TTL
+	Version      string            `json:"version,omitempty"`
+	CacheBackend string            `json:"cache_backend"` // Backend in use: memory, redis or file
+	Checks       map[string]string `json:"checks"`
+	Runtime      *RuntimeStats     `json:"runtime,omitempty"` // Only with ?verbose=true
+}
+
+// RuntimeStats is a lightweight snapshot of process health for spotting goroutine or memory leaks.
+type RuntimeStats struct {
+	Goroutines     int    `json:"goroutines"`
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	NumGC          uint32 `json:"num_gc"`
+	UptimeSeconds  int64  `json:"uptime_seconds"`
+
+	Cleanups map[string]CleanupStats `json:"cleanups,omitempty"` // Background sweeps, see AddCleanupMonitor
+}
+
+// CleanupStats reports when a background cleanup last completed.
+type CleanupStats struct {
+	LastRun string `json:"last_run"`
+	Stalled bool   `json:"stalled"` // No completed sweep for several intervals
+}
+
+type MetricsResponse struct {
+	RequestsTotal    int64         `json:"requests_total"`
+	CacheHits        int64         `json:"cache_hits"`
+	CacheMisses      int64         `json:"cache_misses"`
+	ErrorsTotal      int64         `json:"errors_total"`
+	RateLimitedTotal int64         `json:"rate_limited_total"`
+	CoalescedTotal   int64         `json:"fetches_coalesced_total"` // Fetches saved by sharing a concurrent fetch of the same domain
+	BytesInTotal     int64         `json:"bytes_in_total"`
+	BytesOutTotal    int64         `json:"bytes_out_total"`
+	StatusCounts     map[int]int64 `json:"status_counts"`
+
+	FetchDuration HistogramSnapshot `json:"fetch_duration_seconds"` // Fresh fetches, failures included
 }
 
 type Metrics struct {
-	requestsTotal int64
-	cacheHits     int64
-	cacheMisses   int64
-	errorTotal    int64
-	mu            sync.RWMutex
-	// TODO: Add histogram for response times
+	requestsTotal    int64
+	cacheHits        int64
+	cacheMisses      int64
+	errorTotal       int64
+	rateLimited      int64            // Requests rejected by RateLimitMiddleware or ClientConcurrencyMiddleware
+	coalescedFetches int64            // Cache misses served by another caller's in-flight fetch, see coalescedFetchAndStore
+	bytesIn          int64            // Request body bytes read, fed by LoggingMiddleware
+	bytesOut         int64            // Response body bytes written, fed by LoggingMiddleware
+	statusCounts     map[int]int64    // Response count per HTTP status code, fed by LoggingMiddleware
+	fetchLatency     latencyHistogram // Duration of each fresh fetch and analysis, fed by analyzeMiss
+	slowRequests     slowLog          // Slowest recent requests for /api/slowlog, fed by LoggingMiddleware
+	mu               sync.RWMutex
 	// TODO: Track errors by type (network, timeout, invalid domain)
 }
 
-func NewHandler(cache cache.Cache, cfg *config.Config, logger *slog.Logger) *Handler {
-	return &Handler{
-		cache:   cache,
-		fetcher: adstxt.NewFetcher(cfg.RequestTimeout),
-		cfg:     cfg,
-		logger:  logger,
-		metrics: &Metrics{},
+// recordStatus increments the response counter for the given HTTP status code.
+func (m *Metrics) recordStatus(code int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.statusCounts == nil {
+		m.statusCounts = make(map[int]int64)
+	}
+	m.statusCounts[code]++
+}
+
+// recordBytes adds one request's body bytes read and response body bytes written.
+func (m *Metrics) recordBytes(in, out int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.bytesIn += in
+	m.bytesOut += out
+}
+
+// recordFetchLatency adds one fresh fetch's duration to the latency histogram.
+func (m *Metrics) recordFetchLatency(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.fetchLatency.observe(d)
+}
+
+// recordRateLimited increments the count of requests rejected by the rate limiter.
+func (m *Metrics) recordRateLimited() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rateLimited++
+}
+
+// NewHandler creates a Handler that fetches ads.txt over the network using an
+// adstxt.Fetcher configured from cfg. It fails on fetcher settings that would otherwise
+// send requests somewhere other than the operator intended, such as an invalid FETCH_PROXY_URL,
+// connect with TLS settings other than those configured, or leave out configured credentials.
+func NewHandler(cache cache.Cache, cfg *config.Config, logger *slog.Logger) (*Handler, error) {
+	if _, ok := lookupCacheFormat(cfg.CacheSerialization); !ok {
+		return nil, fmt.Errorf("invalid CACHE_SERIALIZATION: unknown format %q", cfg.CacheSerialization)
+	}
+	credentials, err := adstxt.ParseCredentials(cfg.FetchBasicAuth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FETCH_BASIC_AUTH: %w", err)
+	}
+	tlsConfig, err := adstxt.LoadClientTLSConfig(cfg.FetchClientCert, cfg.FetchClientKey, cfg.FetchCACert)
+	if err != nil {
+		return nil, fmt.Errorf("invalid outbound TLS settings: %w", err)
+	}
+	if cfg.FetchClientCert != "" && len(cfg.FetchClientCertHosts) == 0 {
+		return nil, errors.New("FETCH_CLIENT_CERT requires FETCH_CLIENT_CERT_HOSTS, the partner hosts it is presented to")
+	}
+	minTLSVersion, err := adstxt.ParseTLSVersion(cfg.FetchMinTLSVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FETCH_MIN_TLS_VERSION: %w", err)
+	}
+	proxyURL, err := adstxt.ParseProxyURL(cfg.FetchProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FETCH_PROXY_URL: %w", err)
+	}
+	if proxyURL != nil {
+		logger.Info("fetching through egress proxy", slog.String("proxy", proxyURL.Redacted()))
+	}
+	httpFallback := adstxt.HTTPFallback(cfg.FetchHTTPFallback)
+	switch httpFallback {
+	case "", adstxt.HTTPFallbackTransport, adstxt.HTTPFallbackAlways, adstxt.HTTPFallbackNever:
+	default:
+		logger.Warn("unknown FETCH_HTTP_FALLBACK, falling back to transport", slog.String("value", cfg.FetchHTTPFallback))
+		httpFallback = adstxt.HTTPFallbackTransport
+	}
+	if len(cfg.FetchInsecureSkipVerifyHosts) > 0 {
+		logger.Warn("TLS certificate verification is DISABLED for some hosts; do not use in production",
+			slog.Any("hosts", cfg.FetchInsecureSkipVerifyHosts))
+	}
+
+	fetcher := adstxt.NewFetcherWithOptions(adstxt.FetcherOptions{
+		Timeout:         cfg.RequestTimeout,
+		MaxConcurrent:   cfg.FetchMaxConcurrent,
+		Credentials:     credentials,
+		DNSCacheTTL:     cfg.FetchDNSCacheTTL,
+		TLSConfig:       tlsConfig,
+		MinTLSVersion:   minTLSVersion,
+		ClientCertHosts: cfg.FetchClientCertHosts,
+		ProxyURL:        proxyURL,
+		OnTLSVersionRejected: func(url string) {
+			logger.Warn("publisher does not support the minimum TLS version",
+				slog.String("url", url), slog.String("min_tls_version", tls.VersionName(minTLSVersion)))
+		},
+
+		MaxIdleConns:        cfg.FetchMaxIdleConns,
+		MaxIdleConnsPerHost: cfg.FetchMaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.FetchIdleConnTimeout,
+
+		SameDomainRedirectsOnly: cfg.FetchSameDomainRedirectsOnly,
+		RedirectAllowedDomains:  cfg.FetchRedirectAllowedDomains,
+
+		HTTPFallback:  httpFallback,
+		MaxAttempts:   cfg.FetchMaxAttempts,
+		NoWWWFallback: cfg.FetchNoWWWFallback,
+		Race:          cfg.FetchRace,
+		RaceStagger:   cfg.FetchRaceStagger,
+
+		InsecureSkipVerifyHosts: cfg.FetchInsecureSkipVerifyHosts,
+		OnSkipVerify: func(host string) {
+			logger.Warn("TLS certificate verification skipped", slog.String("host", host))
+		},
+
+		MaxDomainLabels: cfg.FetchMaxDomainLabels,
+		MaxURLLength:    cfg.FetchMaxURLLength,
+
+		CircuitFailureThreshold: cfg.FetchCircuitFailureThreshold,
+		CircuitWindow:           cfg.FetchCircuitWindow,
+		CircuitCooldown:         cfg.FetchCircuitCooldown,
+	})
+
+	return NewHandlerWithFetcher(cache, fetcher, cfg, logger), nil
+}
+
+// NewHandlerWithFetcher creates a Handler that retrieves ads.txt content through fetcher.
+// Outbound fetch settings in cfg (timeouts, pool sizing, credentials) are the fetcher's concern and are not applied.
+func NewHandlerWithFetcher(cache cache.Cache, fetcher AdsTxtFetcher, cfg *config.Config, logger *slog.Logger) *Handler {
+	h := &Handler{
+		cache:     cache,
+		fetcher:   fetcher,
+		cfg:       cfg,
+		logger:    logger,
+		metrics:   &Metrics{},
+		startedAt: time.Now(),
+	}
+	h.metrics.slowRequests = slowLog{size: cfg.SlowLogSize, threshold: cfg.SlowLogThreshold, window: cfg.SlowLogWindow}
+	h.jobs = newJobRunner(h)
+
+	custom, err := adstxt.ParseCommentDirectives(cfg.CommentDirectives)
+	if err != nil {
+		logger.Warn("ignoring invalid COMMENT_DIRECTIVES entries", slog.String("error", err.Error()))
+	}
+	h.directives = append(custom, adstxt.DefaultCommentDirectives...)
+	switch cfg.CacheMode {
+	case "", cacheModeParsed:
+	case cacheModeRaw:
+		h.rawCache = true
+	default:
+		logger.Warn("unknown CACHE_MODE, falling back to parsed", slog.String("value", cfg.CacheMode))
+	}
+	format, ok := lookupCacheFormat(cfg.CacheSerialization)
+	if !ok {
+		logger.Warn("unknown CACHE_SERIALIZATION, falling back to json", slog.String("value", cfg.CacheSerialization))
+		format, _ = lookupCacheFormat(cacheSerializationJSON)
+	}
+	h.cacheFormat = format
+	switch cfg.TimestampPrecision {
+	case "", timestampSeconds:
+	case timestampMilliseconds:
+		h.millisTimestamps = true
+	default:
+		logger.Warn("unknown TIMESTAMP_PRECISION, falling back to seconds", slog.String("value", cfg.TimestampPrecision))
+	}
+	h.AddHealthCheck(cacheHealthCheck{cache: cache, logger: logger})
+	if monitor, ok := cache.(CleanupMonitor); ok {
+		h.AddCleanupMonitor("cache", monitor)
+	}
+
+	if cfg.AutoRefreshTopK > 0 {
+		h.refresher = newRefresher(h)
+		go h.refresher.run()
+	}
+	if cfg.BatchWorkers > 0 {
+		h.batchPool = newWorkerPool(cfg.BatchWorkers)
+	}
+
+	return h
+}
+
+// Close stops the handler's background workers. The cache is owned by the caller and is not closed.
+func (h *Handler) Close() {
+	if h.refresher != nil {
+		h.refresher.stop()
 	}
+	if h.batchPool != nil {
+		h.batchPool.stop()
+	}
+	h.jobs.stop()
 }
 
 func validateDomain(domain string) error {
@@ -81,6 +379,12 @@ func validateDomain(domain string) error {
 		return errors.New("domain cannot be empty")
 	}
 
+	// A fully-qualified name may end in a single dot (example.com.), which cacheTarget strips
+	domain = strings.TrimSuffix(domain, ".")
+	if domain == "" || strings.HasSuffix(domain, ".") {
+		return errors.New("invalid domain format")
+	}
+
 	// RFC 1035: domain max length is 253 characters
 	if len(domain) > 253 {
 		return errors.New("domain too long")
@@ -100,34 +404,177 @@ func validateDomain(domain string) error {
 	return nil
 }
 
+// checkDomain applies validateDomain and, so pathological inputs are rejected before any
+// work is queued, the fetcher's label limit (FETCH_MAX_DOMAIN_LABELS).
+func (h *Handler) checkDomain(domain string) error {
+	if err := validateDomain(domain); err != nil {
+		return err
+	}
+	maxLabels := h.cfg.FetchMaxDomainLabels
+	if maxLabels <= 0 {
+		maxLabels = adstxt.DefaultMaxDomainLabels
+	}
+	return adstxt.CheckDomainLabels(domain, maxLabels)
+}
+
+// domainAllowed reports whether domain may be fetched under FETCH_ALLOWED_DOMAINS and FETCH_ALLOWED_TLDS.
+// Everything is allowed when both lists are empty. Otherwise the domain must equal or be a subdomain of
+// an allowed domain, or end in an allowed TLD. Matching is case-insensitive.
+func (h *Handler) domainAllowed(domain string) bool {
+	if len(h.cfg.FetchAllowedDomains) == 0 && len(h.cfg.FetchAllowedTLDs) == 0 {
+		return true
+	}
+
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	for _, allowed := range h.cfg.FetchAllowedDomains {
+		allowed = strings.ToLower(allowed)
+		if domain == allowed || strings.HasSuffix(domain, "."+allowed) {
+			return true
+		}
+	}
+	for _, tld := range h.cfg.FetchAllowedTLDs {
+		if strings.HasSuffix(domain, "."+strings.ToLower(strings.TrimPrefix(tld, "."))) {
+			return true
+		}
+	}
+	return false
+}
+
 func (h *Handler) AnalyzeSingle(w http.ResponseWriter, r *http.Request) {
 	h.metrics.mu.Lock()
 	h.metrics.requestsTotal++
 	h.metrics.mu.Unlock()
 
 	domain := r.URL.Query().Get("domain")
-	if err := validateDomain(domain); err != nil {
-		h.logger.Warn("invalid domain", slog.String("domain", domain), slog.String("error", err.Error()))
-		h.sendError(w, http.StatusBadRequest, err.Error())
+	if err := h.checkDomain(domain); err != nil {
+		h.logger.WarnContext(r.Context(), "invalid domain", slog.String("domain", domain), slog.String("error", err.Error()))
+		h.sendError(w, r, http.StatusBadRequest, CodeInvalidDomain, err.Error())
+		return
+	}
+	if !h.domainAllowed(domain) {
+		h.logger.WarnContext(r.Context(), "domain not allowed", slog.String("domain", domain))
+		h.sendError(w, r, http.StatusForbidden, CodeDomainNotAllowed, "domain is not in the allowed list")
 		return
 	}
 
-	h.logger.Info("analyzing domain", slog.String("domain", domain))
-	result, err := h.analyzeDomain(domain)
+	minAdvertisers, ok := h.minAdvertisers(w, r)
+	if !ok {
+		return
+	}
+	detectChanges, ok := h.boolParam(w, r, "detect_changes")
+	if !ok {
+		return
+	}
+	lenient, ok := h.boolParam(w, r, "lenient")
+	if !ok {
+		return
+	}
+	verbose, ok := h.boolParam(w, r, "verbose")
+	if !ok {
+		return
+	}
+	groupAccounts, ok := h.boolParam(w, r, "group_accounts")
+	if !ok {
+		return
+	}
+	includePercentages, ok := h.boolParam(w, r, "include_percentages")
+	if !ok {
+		return
+	}
+	normalize, ok := h.boolParam(w, r, "normalize_advertisers")
+	if !ok {
+		return
+	}
+	includeEntries, ok := h.boolParam(w, r, "include_entries")
+	if !ok {
+		return
+	}
+	debugMode, ok := h.boolParam(w, r, "debug")
+	if !ok {
+		return
+	}
+	explain, ok := h.boolParam(w, r, "explain")
+	if !ok {
+		return
+	}
+	sorted := true
+	if r.URL.Query().Get("sorted") != "" { // Defaults to true, unlike other flags
+		if sorted, ok = h.boolParam(w, r, "sorted"); !ok {
+			return
+		}
+	}
+	relationship := r.URL.Query().Get("relationship")
+	if !validRelationship(relationship) {
+		h.sendError(w, r, http.StatusBadRequest, CodeInvalidParameter, "relationship must be one of: direct, reseller, all")
+		return
+	}
+	ctx, ok := h.withNoWWWFallback(r.Context(), w, r)
+	if !ok {
+		return
+	}
+	if ctx, ok = h.withFastFetch(ctx, w, r); !ok {
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "analyzing domain", slog.String("domain", domain))
+	var result *SingleAnalysisResponse
+	var err error
+	var trace *adstxt.FetchTrace
+	switch {
+	case explain:
+		trace = &adstxt.FetchTrace{}
+		result, err = h.explainFetch(ctx, domain, h.cacheTarget(domain), includeEntries, trace)
+	case includeEntries:
+		result, err = h.analyzeDomainWithEntries(ctx, domain)
+	default:
+		result, err = h.analyzeDomain(ctx, domain)
+	}
 	if err != nil {
 		h.metrics.mu.Lock()
 		h.metrics.errorTotal++
 		h.metrics.mu.Unlock()
-		h.logger.Error("failed to analyze domain", slog.String("domain", domain), slog.String("error", err.Error()))
-		h.sendError(w, http.StatusInternalServerError, err.Error())
+		h.logger.ErrorContext(r.Context(), "failed to analyze domain", slog.String("domain", domain), slog.String("error", err.Error()))
+		if trace != nil {
+			h.sendExplainedError(w, r, err, trace)
+			return
+		}
+		h.sendError(w, r, http.StatusInternalServerError, fetchErrorCode(err), err.Error())
 		return
 	}
 
-	h.logger.Info("domain analyzed successfully",
+	h.logger.InfoContext(r.Context(), "domain analyzed successfully",
 		slog.String("domain", domain),
 		slog.Bool("cached", result.Cached),
 		slog.Int("advertisers", result.TotalAdvertisers))
-	h.sendJSON(w, http.StatusOK, result)
+	maxAge := h.remainingTTL(result) // Before per-request filtering, which can empty the result
+	applyLenient(result, lenient)
+	if normalize {
+		normalizeAdvertisers(result)
+	}
+	filterRelationship(result, relationship, sorted)
+	flagSuspicious(result, minAdvertisers)
+	if !detectChanges {
+		result.Changes = nil
+	}
+	if !verbose {
+		stripVerbose(result)
+	} else if !groupAccounts {
+		stripAccountGroups(result)
+	}
+	if !debugMode {
+		stripDebug(result)
+	}
+
+	// Polling clients send the hash they last saw; skip the body if the advertisers are unchanged
+	result.ContentHash = contentHash(result.Advertisers)
+	if since := r.URL.Query().Get("since_hash"); since != "" && strings.EqualFold(since, result.ContentHash) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if includePercentages {
+		addPercentages(result.Advertisers) // After hashing, so the hash doesn't depend on the flag
+	}
+	h.respondCacheable(w, r, result, analysisETag(r, result.ContentHash), maxAge)
 }
 
 func (h *Handler) AnalyzeBatch(w http.ResponseWriter, r *http.Request) {
@@ -138,101 +585,492 @@ func (h *Handler) AnalyzeBatch(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
-	if r.Method != http.MethodPost {
-		h.sendError(w, http.StatusMethodNotAllowed, "only POST method is allowed")
+	opts, ok := h.batchOptions(w, r)
+	if !ok {
 		return
 	}
-	// Limit body size
-	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
-
-	var req BatchAnalysisRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.sendError(w, http.StatusBadRequest, "invalid JSON payload")
+	if ctx, ok = h.withFastFetch(ctx, w, r); !ok {
+		return
+	}
+	if ctx, ok = h.withNoWWWFallback(ctx, w, r); !ok {
 		return
 	}
 
-	if len(req.Domains) == 0 {
-		h.sendError(w, http.StatusBadRequest, "domains array cannot be empty")
+	req, ok := h.decodeBatchRequest(w, r, maxBatchDomains)
+	if !ok {
 		return
 	}
 
+	response := h.processBatch(ctx, req.Domains, req.fetchTimeouts)
+	opts.apply(&response)
+	h.respond(w, r, http.StatusOK, response)
+}
+
+// batchOptions holds the query flags shared by the batch analysis endpoints.
+type batchOptions struct {
+	minAdvertisers int
+	detectChanges  bool
+	lenient        bool
+	verbose        bool
+	groupAccounts  bool
+	normalize      bool
+	debug          bool
+}
+
+// batchOptions reads the batch query flags. On an invalid value it writes the error response itself and returns false.
+func (h *Handler) batchOptions(w http.ResponseWriter, r *http.Request) (batchOptions, bool) {
+	var opts batchOptions
+	var ok bool
+	if opts.minAdvertisers, ok = h.minAdvertisers(w, r); !ok {
+		return opts, false
+	}
+	if opts.detectChanges, ok = h.boolParam(w, r, "detect_changes"); !ok {
+		return opts, false
+	}
+	if opts.lenient, ok = h.boolParam(w, r, "lenient"); !ok {
+		return opts, false
+	}
+	if opts.verbose, ok = h.boolParam(w, r, "verbose"); !ok {
+		return opts, false
+	}
+	if opts.groupAccounts, ok = h.boolParam(w, r, "group_accounts"); !ok {
+		return opts, false
+	}
+	if opts.normalize, ok = h.boolParam(w, r, "normalize_advertisers"); !ok {
+		return opts, false
+	}
+	if opts.debug, ok = h.boolParam(w, r, "debug"); !ok {
+		return opts, false
+	}
+	return opts, true
+}
+
+// apply runs the per-request transforms selected by opts over every batch result.
+func (opts batchOptions) apply(response *BatchAnalysisResponse) {
+	for i := range response.Results {
+		opts.applyTo(&response.Results[i])
+	}
+}
+
+// applyTo runs the per-request transforms selected by opts over one result.
+func (opts batchOptions) applyTo(result *SingleAnalysisResponse) {
+	applyLenient(result, opts.lenient)
+	if opts.normalize {
+		normalizeAdvertisers(result)
+	}
+	flagSuspicious(result, opts.minAdvertisers)
+	if !opts.detectChanges {
+		result.Changes = nil
+	}
+	if !opts.verbose {
+		stripVerbose(result)
+	} else if !opts.groupAccounts {
+		stripAccountGroups(result)
+	}
+	if !opts.debug {
+		stripDebug(result)
+	}
+}
+
+// decodeBatchRequest validates the method, body size, and domain count (at most maxDomains)
+// of a batch request. On failure it writes the error response itself and returns false.
+func (h *Handler) decodeBatchRequest(w http.ResponseWriter, r *http.Request, maxDomains int) (*BatchAnalysisRequest, bool) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "only POST method is allowed")
+		return nil, false
+	}
+	// Limit body size, rejecting a declared oversized body before reading any of it
+	maxBytes := int64(h.cfg.BatchMaxBodyBytes)
+	if maxBytes <= 0 {
+		maxBytes = maxBodySize
+	}
+	if r.ContentLength > maxBytes {
+		h.sendError(w, r, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, fmt.Sprintf("request body exceeds %d bytes", maxBytes))
+		return nil, false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+	// The body limit bounds the domain list too; its length is checked once decoded
+	var req BatchAnalysisRequest
+	if code, err := h.decodeJSONBody(r, &req); err != nil {
+		h.sendError(w, r, decodeStatus(code), code, err.Error())
+		return nil, false
+	}
+
+	// A nil slice means the key was absent (or null), as opposed to an explicit empty array
+	if req.Domains == nil {
+		h.sendError(w, r, http.StatusBadRequest, CodeMissingField, `domains field is required, e.g. {"domains": ["example.com"]}`)
+		return nil, false
+	}
+
+	if !h.checkBatchSize(w, r, req.Domains, maxDomains) {
+		return nil, false
+	}
+
+	if len(req.Timeouts) > 0 {
+		req.fetchTimeouts = make(map[string]time.Duration, len(req.Timeouts))
+		for domain, value := range req.Timeouts {
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout <= 0 {
+				h.sendError(w, r, http.StatusBadRequest, CodeInvalidField, fmt.Sprintf("timeouts[%q] must be a positive duration such as \"20s\"", domain))
+				return nil, false
+			}
+			req.fetchTimeouts[domain] = timeout
+		}
+	}
+
+	// Strict mode is all-or-nothing: reject before any fetching if a single domain is invalid
+	if req.Strict {
+		invalid := make(map[string]string)
+		for _, d := range req.Domains {
+			if err := h.checkDomain(d); err != nil {
+				invalid[d] = err.Error()
+			}
+		}
+		if len(invalid) > 0 {
+			h.sendJSON(w, r, http.StatusBadRequest, ErrorResponse{
+				Error:   http.StatusText(http.StatusBadRequest),
+				Code:    CodeInvalidDomain,
+				Message: fmt.Sprintf("%d of %d domains are invalid; strict mode rejects the whole batch", len(invalid), len(req.Domains)),
+				Details: invalid,
+			})
+			return nil, false
+		}
+	}
+
+	return &req, true
+}
+
+// checkBatchSize rejects an empty batch or one over maxDomains.
+// On failure it writes the error response itself and returns false.
+func (h *Handler) checkBatchSize(w http.ResponseWriter, r *http.Request, domains []string, maxDomains int) bool {
+	if len(domains) == 0 {
+		h.sendError(w, r, http.StatusBadRequest, CodeEmptyBatch, "domains array cannot be empty")
+		return false
+	}
+
 	// Limit batch size to prevent resource exhaustion
-	// 50 is somewhat arbitrary - could make configurable via env var
-	if len(req.Domains) > 50 {
-		h.sendError(w, http.StatusBadRequest, "maximum 50 domains per batch request")
-		return
+	if len(domains) > maxDomains {
+		h.sendError(w, r, http.StatusBadRequest, CodeBatchTooLarge, fmt.Sprintf("maximum %d domains per batch request", maxDomains))
+		return false
+	}
+	return true
+}
+
+// decodeJSONBody decodes the request body into v and turns decoder failures into
+// actionable client messages (syntax error offsets, wrong field types, unknown fields).
+// Unknown fields are rejected when cfg.StrictJSON is set. The returned code is suitable for sendError.
+func (h *Handler) decodeJSONBody(r *http.Request, v interface{}) (string, error) {
+	decoder := json.NewDecoder(r.Body)
+	if h.cfg.StrictJSON {
+		decoder.DisallowUnknownFields()
+	}
+
+	if err := decoder.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	return "", nil
+}
+
+// decodeError turns a JSON decoding failure into an actionable client message and the
+// error code for sendError; see decodeStatus for the matching status.
+func decodeError(err error) (string, error) {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		return CodeInvalidJSON, fmt.Errorf("malformed JSON at byte offset %d: %v", syntaxErr.Offset, syntaxErr)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return CodeInvalidJSON, errors.New("malformed JSON: body ended unexpectedly")
+	case errors.As(err, &typeErr):
+		return CodeInvalidJSON, fmt.Errorf("field %q must be of type %s (byte offset %d)", typeErr.Field, typeErr.Type, typeErr.Offset)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for unknown fields
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		return CodeUnknownField, fmt.Errorf("request contains unknown field %s", field)
+	case errors.Is(err, io.EOF):
+		return CodeInvalidBody, errors.New("request body is empty")
+	case errors.As(err, &maxBytesErr):
+		return CodeBodyTooLarge, fmt.Errorf("request body exceeds %d bytes", maxBytesErr.Limit)
+	default:
+		return CodeInvalidJSON, errors.New("invalid JSON payload")
+	}
+}
+
+// decodeStatus returns the HTTP status for an error code from decodeJSONBody: 413 for an
+// oversized body, 400 for anything else.
+func decodeStatus(code string) int {
+	if code == CodeBodyTooLarge {
+		return http.StatusRequestEntityTooLarge
 	}
+	return http.StatusBadRequest
+}
 
+// processBatch analyzes domains and collects results and per-domain errors.
+// A domain in timeouts is fetched with that timeout instead of REQUEST_TIMEOUT, still within ctx's deadline.
+func (h *Handler) processBatch(ctx context.Context, domains []string, timeouts map[string]time.Duration) BatchAnalysisResponse {
 	response := BatchAnalysisResponse{
 		Results: make([]SingleAnalysisResponse, 0),
 		Errors:  make(map[string]string),
 	}
+	h.analyzeDomains(ctx, domains, timeouts, func(domain string, result *SingleAnalysisResponse, errMsg string) {
+		if result != nil {
+			response.Results = append(response.Results, *result)
+		} else {
+			response.Errors[domain] = errMsg
+		}
+	})
+	return response
+}
 
-	// Process domains concurrently for better performance
-	// Each domain analyzed in separate goroutine
+// analyzeDomains analyzes domains and reports each outcome to emit as soon as it is known:
+// a result, or a nil result with the error message. Cached entries are resolved with a single
+// bulk lookup and emitted first; only misses are fetched concurrently. Calls to emit are
+// serialized, and all of them have returned when analyzeDomains does.
+func (h *Handler) analyzeDomains(ctx context.Context, domains []string, timeouts map[string]time.Duration, emit func(domain string, result *SingleAnalysisResponse, errMsg string)) {
+	// Validate domains to prevent SSRF attacks, then look them all up in one cache round-trip
+	targets := make(map[string]string, len(domains))
+	keys := make([]string, 0, len(domains))
+	for _, d := range domains {
+		if err := h.checkDomain(d); err != nil {
+			emit(d, nil, "invalid domain: "+err.Error())
+			continue
+		}
+		if !h.domainAllowed(d) {
+			emit(d, nil, "domain not allowed")
+			continue
+		}
+		targets[d] = h.cacheTarget(d)
+		keys = append(keys, cacheKeyFor(targets[d]))
+	}
+
+	cached, err := h.cache.GetMulti(keys)
+	if err != nil {
+		// Fall back to fetching everything rather than failing the batch
+		h.logger.WarnContext(ctx, "bulk cache lookup failed", slog.String("error", err.Error()))
+		cached = nil
+	}
+
+	var misses []string
+	for _, d := range domains {
+		target, ok := targets[d]
+		if !ok {
+			continue
+		}
+		if data, hit := cached[cacheKeyFor(target)]; hit {
+			if result, ok := h.fromCache(ctx, d, target, data); ok {
+				emit(d, result, "")
+				continue
+			}
+		}
+		misses = append(misses, d)
+	}
+
+	// Fetch the misses concurrently on the shared worker pool, which bounds
+	// concurrency across all in-flight batches; without a pool, one goroutine per domain
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 
-	for _, domain := range req.Domains {
+	for _, d := range misses {
 		wg.Add(1)
-		go func(d string) {
+		job := func() {
 			defer wg.Done()
-			// Check context cancellation
-			select {
-			case <-ctx.Done():
+			// Isolate panics to the domain that caused them instead of crashing the process
+			defer func() {
+				if rec := recover(); rec != nil {
+					h.logger.ErrorContext(ctx, "panic in batch worker",
+						slog.String("domain", d),
+						slog.Any("panic", rec),
+						slog.String("stack", string(debug.Stack())))
+					mu.Lock()
+					emit(d, nil, "internal error processing domain")
+					mu.Unlock()
+				}
+			}()
+
+			// Check context cancellation; jobs queued behind a busy pool may start late
+			if ctx.Err() != nil {
 				mu.Lock()
-				response.Errors[d] = "request timeout"
+				emit(d, nil, "request timeout")
 				mu.Unlock()
 				return
-			default:
 			}
 
-			// Validate domain to prevent SSRF attacks
-			if err := validateDomain(d); err != nil {
-				mu.Lock()
-				response.Errors[d] = "invalid domain: " + err.Error()
-				mu.Unlock()
-				return
+			fetchCtx := ctx
+			if timeout, ok := timeouts[d]; ok {
+				var cancel context.CancelFunc
+				fetchCtx, cancel = context.WithTimeout(adstxt.WithFetchTimeout(ctx, timeout), timeout)
+				defer cancel()
 			}
 
-			result, err := h.analyzeDomain(d)
+			result, err := h.analyzeMiss(fetchCtx, d, targets[d], false)
 			mu.Lock()
 			defer mu.Unlock()
 
-			if err != nil {
-				response.Errors[d] = err.Error()
+			if err != nil && fetchCtx.Err() != nil {
+				// The batch deadline or the domain's own timeout cut the fetch short; this is not a failure of the domain
+				emit(d, nil, "request timeout")
+			} else if err != nil {
+				emit(d, nil, err.Error())
 			} else {
-				response.Results = append(response.Results, *result)
+				emit(d, result, "")
 			}
-		}(domain)
+		}
+
+		if h.batchPool == nil {
+			go job()
+		} else if err := h.batchPool.submit(ctx, job); err != nil {
+			// Never started, so the domain is reported here; a done ctx is the batch deadline
+			mu.Lock()
+			if ctx.Err() != nil {
+				emit(d, nil, "request timeout")
+			} else {
+				emit(d, nil, "server shutting down")
+			}
+			mu.Unlock()
+			wg.Done()
+		}
 	}
 
 	wg.Wait()
+}
 
-	h.sendJSON(w, http.StatusOK, response)
+// ParseContent analyzes ads.txt content supplied in the request body without fetching anything.
+// Accepts either a JSON ParseRequest or a raw text/plain body (with an optional ?domain= param).
+func (h *Handler) ParseContent(w http.ResponseWriter, r *http.Request) {
+	h.metrics.mu.Lock()
+	h.metrics.requestsTotal++
+	h.metrics.mu.Unlock()
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	minAdvertisers, ok := h.minAdvertisers(w, r)
+	if !ok {
+		return
+	}
+	lenient, ok := h.boolParam(w, r, "lenient")
+	if !ok {
+		return
+	}
+	verbose, ok := h.boolParam(w, r, "verbose")
+	if !ok {
+		return
+	}
+	groupAccounts, ok := h.boolParam(w, r, "group_accounts")
+	if !ok {
+		return
+	}
+	normalize, ok := h.boolParam(w, r, "normalize_advertisers")
+	if !ok {
+		return
+	}
+	includeEntries, ok := h.boolParam(w, r, "include_entries")
+	if !ok {
+		return
+	}
+	req, ok := h.decodeContentRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var result *SingleAnalysisResponse
+	if includeEntries {
+		result = h.buildAnalysisWithEntries(req.Domain, req.Content)
+	} else {
+		result = h.buildAnalysis(req.Domain, req.Content)
+	}
+	applyLenient(result, lenient)
+	if normalize {
+		normalizeAdvertisers(result)
+	}
+	flagSuspicious(result, minAdvertisers)
+	if !verbose {
+		stripVerbose(result)
+	} else if !groupAccounts {
+		stripAccountGroups(result)
+	}
+	h.respond(w, r, http.StatusOK, result)
+}
+
+// decodeContentRequest reads ads.txt content supplied in the request body, either as a
+// JSON ParseRequest or a raw text/plain body with the optional domain in ?domain=.
+// On failure, including empty content, it writes the error response itself and returns false.
+func (h *Handler) decodeContentRequest(w http.ResponseWriter, r *http.Request) (*ParseRequest, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+
+	var req ParseRequest
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/plain") {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			h.sendError(w, r, http.StatusBadRequest, CodeInvalidBody, "failed to read request body")
+			return nil, false
+		}
+		req.Content = string(body)
+		req.Domain = r.URL.Query().Get("domain")
+	} else if code, err := h.decodeJSONBody(r, &req); err != nil {
+		h.sendError(w, r, decodeStatus(code), code, err.Error())
+		return nil, false
+	}
+
+	if strings.TrimSpace(req.Content) == "" {
+		h.sendError(w, r, http.StatusBadRequest, CodeEmptyContent, "content cannot be empty")
+		return nil, false
+	}
+	return &req, true
 }
 
+// FlushCache removes every entry from the configured cache backend.
+// Intended for operators; the router guards it with AdminAuthMiddleware.
+func (h *Handler) FlushCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+
+	if err := h.cache.Flush(); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to flush cache", slog.String("error", err.Error()))
+		h.sendError(w, r, http.StatusInternalServerError, CodeCacheFailure, "failed to flush cache")
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "cache flushed", slog.String("remote_addr", r.RemoteAddr))
+	h.sendJSON(w, r, http.StatusOK, map[string]string{"status": "flushed"})
+}
+
+// Health runs the registered health checks (the cache by default, see AddHealthCheck)
+// and reports each by name. Results are reused for HEALTH_CACHE_TTL, so frequent probes
+// don't touch the cache backend every time; checked_at tells when they last ran.
+// With ?verbose=true it also includes runtime stats, which are opt-in because
+// ReadMemStats briefly stops the world.
 func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
-	checks := make(map[string]string)
-	overallStatus := "healthy"
+	verbose, ok := h.boolParam(w, r, "verbose")
+	if !ok {
+		return
+	}
 
-	// Check cache
-	testKey := "health:check"
-	if err := h.cache.Set(testKey, []byte("ok"), 10*time.Second); err != nil {
-		checks["cache"] = "unhealthy: " + err.Error()
+	result := h.checkHealth(r.Context())
+	overallStatus := "healthy"
+	if result.degraded {
 		overallStatus = "degraded"
-	} else {
-		checks["cache"] = "healthy"
-		if err := h.cache.Delete(testKey); err != nil {
-			h.logger.Warn("failed to delete health check key", slog.String("error", err.Error()))
-		}
+	}
+	if h.draining.Load() {
+		overallStatus = statusShuttingDown
 	}
 
 	response := HealthResponse{
-		Status:  overallStatus,
-		Time:    time.Now().Format(time.RFC3339),
-		Version: "1.0.0",
-		Checks:  checks,
+		Status:       overallStatus,
+		Time:         h.formatTime(time.Now()),
+		CheckedAt:    h.formatTime(result.checkedAt),
+		Version:      BuildVersion,
+		CacheBackend: h.cache.Name(),
+		Checks:       result.checks,
+	}
+	if verbose {
+		response.Runtime = h.runtimeStats()
 	}
 
 	statusCode := http.StatusOK
@@ -240,97 +1078,710 @@ func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 		statusCode = http.StatusServiceUnavailable
 	}
 
-	h.sendJSON(w, statusCode, response)
+	h.respond(w, r, statusCode, response)
+}
+
+// runtimeStats collects goroutine, heap, and GC figures along with the handler's uptime.
+func (h *Handler) runtimeStats() *RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	return &RuntimeStats{
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAllocBytes: mem.HeapAlloc,
+		NumGC:          mem.NumGC,
+		UptimeSeconds:  int64(time.Since(h.startedAt).Seconds()),
+		Cleanups:       h.cleanupStats(),
+	}
 }
 
 func (h *Handler) Metrics(w http.ResponseWriter, r *http.Request) {
+	h.respond(w, r, http.StatusOK, h.metricsSnapshot())
+}
+
+// metricsSnapshot copies the counters under the lock, so encoding happens on a snapshot
+// rather than the live counters.
+func (h *Handler) metricsSnapshot() MetricsResponse {
 	h.metrics.mu.RLock()
 	defer h.metrics.mu.RUnlock()
 
-	h.sendJSON(w, http.StatusOK, map[string]int64{
-		"requests_total": h.metrics.requestsTotal,
-		"cache_hits":     h.metrics.cacheHits,
-		"cache_misses":   h.metrics.cacheMisses,
-		"errors_total":   h.metrics.errorTotal,
-	})
+	statusCounts := make(map[int]int64, len(h.metrics.statusCounts))
+	for code, count := range h.metrics.statusCounts {
+		statusCounts[code] = count
+	}
+
+	return MetricsResponse{
+		RequestsTotal:    h.metrics.requestsTotal,
+		CacheHits:        h.metrics.cacheHits,
+		CacheMisses:      h.metrics.cacheMisses,
+		ErrorsTotal:      h.metrics.errorTotal,
+		RateLimitedTotal: h.metrics.rateLimited,
+		CoalescedTotal:   h.metrics.coalescedFetches,
+		BytesInTotal:     h.metrics.bytesIn,
+		BytesOutTotal:    h.metrics.bytesOut,
+		StatusCounts:     statusCounts,
+		FetchDuration:    h.metrics.fetchLatency.snapshot(),
+	}
 }
 
-func (h *Handler) analyzeDomain(domain string) (*SingleAnalysisResponse, error) {
-	cacheKey := fmt.Sprintf("adstxt:%s", domain)
+func (h *Handler) analyzeDomain(ctx context.Context, domain string) (*SingleAnalysisResponse, error) {
+	target := h.cacheTarget(domain)
 
 	// Try to get from cache (works for all cache types: memory, file, redis)
-	cachedData, err := h.cache.Get(cacheKey)
-	if err == nil {
-		var result SingleAnalysisResponse
-		if unmarshalErr := json.Unmarshal(cachedData, &result); unmarshalErr == nil {
-			result.Cached = true
-			h.metrics.mu.Lock()
-			h.metrics.cacheHits++
-			h.metrics.mu.Unlock()
-			return &result, nil
-		} else {
-			h.logger.Warn("failed to unmarshal cached data",
-				slog.String("domain", domain),
-				slog.String("error", unmarshalErr.Error()))
+	if cachedData, err := h.cache.Get(cacheKeyFor(target)); err == nil {
+		if result, ok := h.fromCache(ctx, domain, target, cachedData); ok {
+			return result, nil
 		}
 	}
 
-	// Cache miss - fetch fresh data
+	return h.analyzeMiss(ctx, domain, target, false)
+}
+
+// cacheTarget returns the domain whose analysis is fetched and cached for domain.
+// The trailing dot of a fully-qualified name is dropped, so example.com. and example.com
+// share one entry, and when configured www and apex share one entry under the apex; the
+// caller's original input is still echoed in the response domain field.
+func (h *Handler) cacheTarget(domain string) string {
+	domain = strings.TrimSuffix(domain, ".")
+	if h.cfg.NormalizeWWW {
+		return apexDomain(domain)
+	}
+	return domain
+}
+
+// fromCache decodes a cached analysis for domain and records the hit.
+// Returns false if the cached data is unreadable or expired, or was served by the www
+// fallback that ctx does not allow, in which case it should be treated as a miss.
+func (h *Handler) fromCache(ctx context.Context, domain, target string, cachedData []byte) (*SingleAnalysisResponse, bool) {
+	result, err := h.decodeCacheEntry(domain, cachedData)
+	if err != nil {
+		h.logger.Warn("failed to unmarshal cached data",
+			slog.String("domain", domain),
+			slog.String("error", err.Error()))
+		return nil, false
+	}
+	if h.expired(result) {
+		return nil, false // Kept only for serveStale
+	}
+	if result.WWWFallback && !h.wwwFallback(ctx) {
+		return nil, false // The re-fetch checks whether the apex serves a file by now
+	}
+	if !h.cfg.IncludeRawHash {
+		result.RawContentHash = "" // Cached while INCLUDE_RAW_HASH was set
+	}
+
+	result.Domain = domain
+	result.Cached = true
+	result.CacheBackend = h.cache.Name()
+	h.metrics.mu.Lock()
+	h.metrics.cacheHits++
+	h.metrics.mu.Unlock()
+	if h.refresher != nil {
+		fetchedAt, _ := time.Parse(time.RFC3339, result.Timestamp)
+		h.refresher.recordHit(target, fetchedAt)
+	}
+	return result, true
+}
+
+// analyzeMiss records a cache miss and fetches fresh data for domain, with the parsed
+// entries attached if withEntries is set.
+// A recent failure for target is replayed from the negative cache instead of re-fetching.
+// Either failure is answered with the expired analysis instead under SERVE_STALE_ON_ERROR.
+// Fetches abandoned because ctx ended are not negative-cached, as they say nothing about target.
+func (h *Handler) analyzeMiss(ctx context.Context, domain, target string, withEntries bool) (*SingleAnalysisResponse, error) {
 	h.metrics.mu.Lock()
 	h.metrics.cacheMisses++
 	h.metrics.mu.Unlock()
 
-	content, err := h.fetcher.FetchAdsTxt(domain)
+	if err := h.cachedFailure(target); err != nil {
+		return h.serveStale(ctx, domain, target, withEntries, err)
+	}
+
+	result, err := h.coalescedFetchAndStore(ctx, domain, target, withEntries)
+	if errors.Is(err, adstxt.ErrWWWOnly) {
+		// The publisher is up and its file valid for requests allowing the www fallback,
+		// so neither cache the failure nor serve a stale analysis likely fetched from www
+		return nil, err
+	}
+	if err != nil {
+		// An open circuit already fails fast and must not outlast its cooldown in the negative cache
+		if ctx.Err() == nil && !errors.Is(err, adstxt.ErrCircuitOpen) {
+			h.storeFailure(target, err)
+		}
+		return h.serveStale(ctx, domain, target, withEntries, err)
+	}
+
+	if h.refresher != nil {
+		h.refresher.recordHit(target, time.Now())
+	}
+	return result, nil
+}
+
+// minAdvertisers returns the suspicious-result threshold for the request: the
+// ?min_advertisers=N override if present, otherwise MIN_ADVERTISERS_THRESHOLD.
+// On an invalid value it writes the error response itself and returns false.
+func (h *Handler) minAdvertisers(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("min_advertisers")
+	if value == "" {
+		return h.cfg.MinAdvertisersThreshold, true
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		h.sendError(w, r, http.StatusBadRequest, CodeInvalidParameter, "min_advertisers must be a non-negative integer")
+		return 0, false
+	}
+	return n, true
+}
+
+// boolParam parses an optional boolean query parameter, defaulting to false when absent.
+// On an invalid value it writes the error response itself and returns false for ok.
+func (h *Handler) boolParam(w http.ResponseWriter, r *http.Request, name string) (value bool, ok bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return false, true
+	}
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		h.sendError(w, r, http.StatusBadRequest, CodeInvalidParameter, name+" must be a boolean")
+		return false, false
+	}
+	return value, true
+}
+
+// flagSuspicious marks result as suspicious when it has fewer advertisers than threshold.
+// It is applied per request rather than cached, since the threshold can be overridden.
+func flagSuspicious(result *SingleAnalysisResponse, threshold int) {
+	result.Suspicious = threshold > 0 && result.TotalAdvertisers < threshold
+}
+
+// stripVerbose drops each advertiser's certification authority IDs, per-subdomain counts and
+// account groups and the comment metadata, which are only sent with ?verbose=true to keep default responses
+// small. They are always cached (or derived at response time), so one cached analysis serves both forms.
+func stripVerbose(result *SingleAnalysisResponse) {
+	for i := range result.Advertisers {
+		result.Advertisers[i].CertAuthorities = nil
+		result.Advertisers[i].Subdomains = nil
+	}
+	result.CommentMetadata = nil
+	stripAccountGroups(result)
+}
+
+// stripAccountGroups drops each advertiser's account groups, which are only sent with
+// ?verbose=true&group_accounts=true.
+func stripAccountGroups(result *SingleAnalysisResponse) {
+	for i := range result.Advertisers {
+		result.Advertisers[i].AccountGroups = nil
+	}
+}
+
+// stripDebug drops the fetch details kept with each analysis, which are only sent with ?debug=true.
+func stripDebug(result *SingleAnalysisResponse) {
+	result.HTTPStatus = 0
+	result.Redirects = nil
+}
+
+// addPercentages sets each advertiser's share of all entries, i.e. its count over the sum
+// of all counts (not the number of distinct advertisers), as a percentage rounded to two decimals.
+func addPercentages(advertisers []adstxt.AdvertiserCount) {
+	total := 0
+	for _, adv := range advertisers {
+		total += adv.Count
+	}
+	if total == 0 {
+		return
+	}
+	for i := range advertisers {
+		advertisers[i].Percentage = math.Round(float64(advertisers[i].Count)*10000/float64(total)) / 100
+	}
+}
+
+// cacheKeyFor returns the cache key under which a domain's analysis is stored.
+func cacheKeyFor(domain string) string {
+	return fmt.Sprintf("adstxt:%s", domain)
+}
+
+// fetchAndStore fetches target's ads.txt, analyzes it, and caches the result,
+// bypassing any cached entry. domain is the caller's original input echoed in the response.
+// With withEntries the result also carries the parsed entries, which are cached apart from it.
+func (h *Handler) fetchAndStore(ctx context.Context, domain, target string, withEntries bool) (*SingleAnalysisResponse, error) {
+	result, err := h.fetchAnalysis(ctx, domain, target, withEntries)
 	if err != nil {
 		// Don't cache errors - domain might be temporarily unavailable
 		return nil, fmt.Errorf("failed to fetch ads.txt: %w", err)
 	}
 
-	advertisersMap := adstxt.ParseAdsTxt(content)
-	advertisers := adstxt.MapToSlice(advertisersMap)
+	h.recordChanges(target, result)
 
-	sort.Slice(advertisers, func(i, j int) bool {
-		if advertisers[i].Count == advertisers[j].Count {
-			return advertisers[i].Domain < advertisers[j].Domain
+	// Store in cache for future requests (works for all cache types)
+	if ttl := h.resultTTL(result); ttl > 0 {
+		if data, err := h.encodeCacheEntry(result); err == nil {
+			if err := h.cache.Set(cacheKeyFor(target), data, h.storageTTL(ttl)); err != nil {
+				h.logger.WarnContext(ctx, "failed to cache result", slog.String("domain", domain), slog.String("error", err.Error()))
+			}
 		}
-		return advertisers[i].Count > advertisers[j].Count
+		if withEntries {
+			h.storeEntries(ctx, target, result, h.storageTTL(ttl))
+		}
+	}
+
+	return result, nil
+}
+
+// resultTTL returns how long a fetched analysis is cached. Analyses without any advertisers
+// get EMPTY_RESULT_CACHE_TTL unless CACHE_EMPTY_RESULTS is set, so a transiently empty file
+// is not pinned for the full CACHE_TTL after the publisher fixes it. 0 means not cached.
+func (h *Handler) resultTTL(result *SingleAnalysisResponse) time.Duration {
+	if result.TotalAdvertisers == 0 && len(result.Recovered) == 0 && !h.cfg.CacheEmptyResults {
+		return h.cfg.EmptyResultCacheTTL
+	}
+	return h.cfg.CacheTTL
+}
+
+// apexDomain strips a leading "www." label from domain.
+// The domain is returned unchanged if stripping would leave no dot (e.g. "www.com").
+func apexDomain(domain string) string {
+	if len(domain) > 4 && strings.EqualFold(domain[:4], "www.") && strings.Contains(domain[4:], ".") {
+		return domain[4:]
+	}
+	return domain
+}
+
+// fetchAnalysis fetches and analyzes target's ads.txt and records the final status and redirect
+// count of the fetch, when the fetcher reports them. Fetchers that support streaming are parsed
+// straight from the response body, so the file is never held in memory as a whole.
+func (h *Handler) fetchAnalysis(ctx context.Context, domain, target string, withEntries bool) (*SingleAnalysisResponse, error) {
+	var info adstxt.FetchInfo
+	result, err := h.fetchAndParse(adstxt.WithFetchInfo(ctx, &info), domain, target, withEntries)
+	if err != nil {
+		return nil, err
+	}
+	if info.StatusCode != 0 {
+		result.HTTPStatus = info.StatusCode
+		result.Redirects = &info.Redirects
+		result.WWWFallback = info.WWWFallback
+	}
+	if h.cfg.IncludeRawHash && info.BodySHA256 != "" {
+		result.RawContentHash = info.BodySHA256 // The bytes as served, before charset transcoding
+	}
+	return result, nil
+}
+
+// fetchAndParse fetches and parses target's ads.txt for fetchAnalysis.
+func (h *Handler) fetchAndParse(ctx context.Context, domain, target string, withEntries bool) (*SingleAnalysisResponse, error) {
+	streamer, ok := h.fetcher.(streamingFetcher)
+	if !ok {
+		content, err := h.fetcher.FetchAdsTxt(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		var result *SingleAnalysisResponse
+		if withEntries {
+			result = h.buildAnalysisWithEntries(domain, content)
+		} else {
+			result = h.buildAnalysis(domain, content)
+		}
+		if h.rawCache {
+			result.rawContent = &content
+		}
+		if h.cfg.IncludeRawHash {
+			result.RawContentHash = rawContentHash(content)
+		}
+		return result, nil
+	}
+
+	var result *SingleAnalysisResponse
+	err := streamer.StreamAdsTxt(ctx, target, func(body io.Reader) error {
+		head := &headCapture{limit: commentHeaderLimit}
+		if h.rawCache {
+			head.limit = math.MaxInt // The whole file is cached, so keep all of it
+		}
+		hash := sha256.New()
+		reader := io.TeeReader(body, io.MultiWriter(head, hash))
+		parsed, err := adstxt.ParseLenientReader(reader, h.parseOptions(withEntries))
+		if err != nil {
+			return err
+		}
+		content := head.buf.String()
+		result = h.analysisFromParse(domain, parsed)
+		result.CommentMetadata = adstxt.ExtractCommentMetadata(content, h.directives)
+		if h.rawCache {
+			result.rawContent = &content
+		}
+		if h.cfg.IncludeRawHash {
+			// The parser reads to EOF, so this covers the whole file. It is of the transcoded text;
+			// fetchAnalysis replaces it with the hash of the bytes served when the fetcher reports one.
+			result.RawContentHash = hex.EncodeToString(hash.Sum(nil))
+		}
+		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// rawContentHash returns the hex SHA-256 of a fetched ads.txt, which unlike contentHash
+// changes with any byte of the file, comments and formatting included.
+func rawContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// buildAnalysis parses raw ads.txt content and returns the sorted advertiser breakdown.
+func (h *Handler) buildAnalysis(domain, content string) *SingleAnalysisResponse {
+	result := h.analysisFromParse(domain, adstxt.ParseLenient(content, h.parseOptions(false)))
+	result.CommentMetadata = adstxt.ExtractCommentMetadata(content, h.directives)
+	return result
+}
+
+// buildAnalysisWithEntries is buildAnalysis with every parsed record attached as Entries.
+func (h *Handler) buildAnalysisWithEntries(domain, content string) *SingleAnalysisResponse {
+	result := h.analysisFromParse(domain, adstxt.ParseLenient(content, h.parseOptions(true)))
+	result.CommentMetadata = adstxt.ExtractCommentMetadata(content, h.directives)
+	return result
+}
+
+// parseOptions applies the configured parser limits. Entries are capped by cfg.MaxAdvertisers
+// like the distinct advertisers, to bound memory.
+func (h *Handler) parseOptions(withEntries bool) adstxt.ParseOptions {
+	return adstxt.ParseOptions{
+		MaxAdvertisers: h.cfg.MaxAdvertisers,
+		WithEntries:    withEntries,
+		MaxEntries:     h.cfg.MaxAdvertisers,
+		MaxLineLength:  h.cfg.MaxLineLength,
+	}
+}
 
-	result := &SingleAnalysisResponse{
+// commentHeaderLimit bounds how much of a streamed file is kept for comment metadata,
+// which only comes from the comment block at the top.
+const commentHeaderLimit = 16 << 10
+
+// headCapture keeps the first limit bytes written to it and discards the rest.
+type headCapture struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (c *headCapture) Write(p []byte) (int, error) {
+	if room := c.limit - c.buf.Len(); room > 0 {
+		c.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// analysisFromParse turns a parsed file into a response. Records recovered by lenient
+// parsing are kept apart in Recovered until applyLenient decides whether to count them.
+// Advertisers are ordered by count descending, then by domain name for stable output.
+// The number of distinct advertisers is capped by cfg.MaxAdvertisers to bound memory.
+func (h *Handler) analysisFromParse(domain string, parsed *adstxt.ParseResult) *SingleAnalysisResponse {
+	if parsed.Truncated {
+		h.logger.Warn("advertiser cap reached, response truncated",
+			slog.String("domain", domain),
+			slog.Int("max_advertisers", h.cfg.MaxAdvertisers))
+	}
+	if parsed.SkippedLines > 0 {
+		h.logger.Warn("skipped over-length ads.txt lines",
+			slog.String("domain", domain),
+			slog.Int("skipped_lines", parsed.SkippedLines))
+	}
+	advertisers := adstxt.RelationshipsToSlice(parsed.Advertisers)
+	h.groupAccounts(advertisers, parsed.Advertisers)
+	sortAdvertisers(advertisers)
+	recovered := adstxt.RelationshipsToSlice(parsed.Recovered)
+	h.groupAccounts(recovered, parsed.Recovered)
+
+	return &SingleAnalysisResponse{
 		Domain:           domain,
 		TotalAdvertisers: len(advertisers),
 		Advertisers:      advertisers,
+		Truncated:        parsed.Truncated,
+		SkippedLines:     parsed.SkippedLines,
+		Recovered:        recovered,
+		Entries:          parsed.Entries,
+		EntriesTruncated: parsed.EntriesTruncated,
 		Cached:           false, // Fresh data, not from cache
-		Timestamp:        time.Now().Format(time.RFC3339),
+		Timestamp:        h.formatTime(time.Now()),
 	}
+}
 
-	// Store in cache for future requests (works for all cache types)
-	if data, err := json.Marshal(result); err == nil {
-		if err := h.cache.Set(cacheKey, data, h.cfg.CacheTTL); err != nil {
-			h.logger.Warn("failed to cache result", slog.String("domain", domain), slog.String("error", err.Error()))
+// groupAccounts sets each advertiser's AccountGroups from its parsed account IDs, grouped by
+// cfg.AccountPrefixGroups. Groups are cached with the analysis like the certification
+// authorities, and only sent with ?verbose=true&group_accounts=true.
+func (h *Handler) groupAccounts(advertisers []adstxt.AdvertiserCount, counts map[string]adstxt.RelationshipCounts) {
+	for i := range advertisers {
+		advertisers[i].AccountGroups = adstxt.GroupAccounts(counts[advertisers[i].Domain].AccountIDs, h.cfg.AccountPrefixGroups)
+	}
+}
+
+// sortAdvertisers orders advertisers by count descending, then by domain name for stable output.
+func sortAdvertisers(advertisers []adstxt.AdvertiserCount) {
+	sort.Slice(advertisers, func(i, j int) bool {
+		if advertisers[i].Count == advertisers[j].Count {
+			return advertisers[i].Domain < advertisers[j].Domain
 		}
+		return advertisers[i].Count > advertisers[j].Count
+	})
+}
+
+// timestampFields lists the response keys holding RFC3339 timestamps that ?ts=unix rewrites.
+var timestampFields = map[string]bool{
+	"timestamp":    true,
+	"time":         true,
+	"fetched_at":   true,
+	"created_at":   true,
+	"finished_at":  true,
+	"last_run":     true,
+	"checked_at":   true,
+	"generated_at": true,
+}
+
+// countFields lists the response keys holding integer counts and metrics that
+// ?counts_as_strings=true renders as strings. A key holding a map, such as status_counts,
+// has each of its values converted.
+var countFields = map[string]bool{
+	"count":                   true,
+	"total_advertisers":       true,
+	"direct":                  true,
+	"reseller":                true,
+	"skipped_lines":           true,
+	"lenient_recovered":       true,
+	"redirects":               true,
+	"subdomains":              true,
+	"account_groups":          true,
+	"publishers":              true,
+	"total_publishers":        true,
+	"records":                 true,
+	"comments":                true,
+	"variables":               true,
+	"malformed":               true,
+	"previous":                true,
+	"current":                 true,
+	"summary":                 true,
+	"total":                   true,
+	"completed":               true,
+	"succeeded":               true,
+	"failed":                  true,
+	"hits":                    true,
+	"misses":                  true,
+	"requests_total":          true,
+	"cache_hits":              true,
+	"cache_misses":            true,
+	"errors_total":            true,
+	"rate_limited_total":      true,
+	"fetches_coalesced_total": true,
+	"bytes_in_total":          true,
+	"bytes_out_total":         true,
+	"status_counts":           true,
+	"goroutines":              true,
+	"heap_alloc_bytes":        true,
+	"num_gc":                  true,
+	"uptime_seconds":          true,
+}
+
+// respond writes a JSON response after applying any request-driven output options.
+func (h *Handler) respond(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	if data, ok := h.prepare(w, r, data); ok {
+		h.sendJSON(w, r, status, data)
 	}
+}
 
-	return result, nil
+// prepare applies request-driven output options to a response payload.
+// Supports ?counts_as_strings=true, which renders count fields as strings, ?ts=unix, which
+// renders timestamp fields as integer Unix seconds, ?fields=a,b, which keeps only the named
+// top-level fields, and the versioned envelope requested via wantsEnvelope.
+// If an option is invalid it sends the error response instead and returns false.
+func (h *Handler) prepare(w http.ResponseWriter, r *http.Request, data interface{}) (interface{}, bool) {
+	countsAsStrings, ok := h.boolParam(w, r, "counts_as_strings")
+	if !ok {
+		return nil, false
+	}
+	// Before ?ts=unix, so converted timestamps stay numeric
+	if countsAsStrings {
+		converted, err := toStringCounts(data)
+		if err != nil {
+			h.logger.WarnContext(r.Context(), "failed to convert counts", slog.String("error", err.Error()))
+		} else {
+			data = converted
+		}
+	}
+	if r.URL.Query().Get("ts") == "unix" {
+		converted, err := toUnixTimestamps(data)
+		if err != nil {
+			h.logger.WarnContext(r.Context(), "failed to convert timestamps", slog.String("error", err.Error()))
+		} else {
+			data = converted
+		}
+	}
+	if fields := r.URL.Query().Get("fields"); fields != "" {
+		selected, err := selectFields(data, fields)
+		if err != nil {
+			h.logger.WarnContext(r.Context(), "failed to select fields", slog.String("error", err.Error()))
+		} else {
+			data = selected
+		}
+	}
+	if wantsEnvelope(r) {
+		data = Envelope{APIVersion: APIVersion, Data: data}
+	}
+	return data, true
+}
+
+// wantsEnvelope reports whether the client asked for the versioned response envelope,
+// either with ?envelope=true or by accepting the vendor media type.
+func wantsEnvelope(r *http.Request) bool {
+	return r.URL.Query().Get("envelope") == "true" ||
+		strings.Contains(r.Header.Get("Accept"), envelopeMediaType)
+}
+
+// toUnixTimestamps round-trips data through JSON and replaces RFC3339 timestamp
+// fields with Unix seconds, so every response type is handled the same way.
+func toUnixTimestamps(data interface{}) (interface{}, error) {
+	generic, err := toGeneric(data)
+	if err != nil {
+		return nil, err
+	}
+	return convertTimestamps(generic), nil
+}
+
+// toStringCounts round-trips data through JSON and renders the countFields as decimal
+// strings, for clients that decode numbers into float64 (JavaScript's Number) and would
+// lose precision above 2^53. Other numbers, such as a 50 percentage, stay numeric.
+func toStringCounts(data interface{}) (interface{}, error) {
+	generic, err := toGeneric(data)
+	if err != nil {
+		return nil, err
+	}
+	return stringifyCounts(generic), nil
 }
 
-func (h *Handler) sendJSON(w http.ResponseWriter, status int, data interface{}) {
+func stringifyCounts(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for key, child := range val {
+			if countFields[key] {
+				val[key] = stringifyCount(child)
+				continue
+			}
+			val[key] = stringifyCounts(child)
+		}
+	case []interface{}:
+		for i := range val {
+			val[i] = stringifyCounts(val[i])
+		}
+	}
+	return v
+}
+
+// stringifyCount renders a count, or each count in a map of them such as status_counts, as a string.
+func stringifyCount(v interface{}) interface{} {
+	switch val := v.(type) {
+	case json.Number:
+		return val.String()
+	case map[string]interface{}:
+		for key, child := range val {
+			if n, ok := child.(json.Number); ok {
+				val[key] = n.String()
+			}
+		}
+	}
+	return v
+}
+
+// selectFields keeps only the comma-separated top-level fields of data's JSON form.
+// Unknown names are ignored; payloads that are not JSON objects are returned whole.
+func selectFields(data interface{}, fields string) (interface{}, error) {
+	generic, err := toGeneric(data)
+	if err != nil {
+		return nil, err
+	}
+	object, ok := generic.(map[string]interface{})
+	if !ok {
+		return generic, nil
+	}
+
+	keep := make(map[string]bool)
+	for _, field := range strings.Split(fields, ",") {
+		keep[strings.TrimSpace(field)] = true
+	}
+	for key := range object {
+		if !keep[key] {
+			delete(object, key)
+		}
+	}
+	return object, nil
+}
+
+// toGeneric round-trips data through JSON into maps and slices, so output options can
+// rewrite any response type the same way.
+func toGeneric(data interface{}) (interface{}, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber() // Keep counts exact instead of converting to float64
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
+
+func convertTimestamps(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for key, child := range val {
+			if str, ok := child.(string); ok && timestampFields[key] {
+				if t, err := time.Parse(time.RFC3339, str); err == nil {
+					val[key] = t.Unix()
+					continue
+				}
+			}
+			val[key] = convertTimestamps(child)
+		}
+	case []interface{}:
+		for i := range val {
+			val[i] = convertTimestamps(val[i])
+		}
+	}
+	return v
+}
+
+// sendJSON writes data as compact JSON, or indented by two spaces when the request has ?pretty=true.
+func (h *Handler) sendJSON(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
 	defer func() {
-		if r := recover(); r != nil {
-			h.logger.Error("panic in JSON encoding", slog.Any("panic", r))
+		if rec := recover(); rec != nil {
+			h.logger.ErrorContext(r.Context(), "panic in JSON encoding", slog.Any("panic", rec))
 		}
 	}()
 
+	if resp, ok := data.(ErrorResponse); ok {
+		data = localizeError(w, r, resp)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		h.logger.Error("failed to encode JSON response", slog.String("error", err.Error()))
+	encoder := json.NewEncoder(w)
+	if wantsPretty(r) {
+		encoder.SetIndent("", "  ")
 	}
+	if err := encoder.Encode(data); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to encode JSON response", slog.String("error", err.Error()))
+	}
+}
+
+// wantsPretty reports whether the client asked for indented JSON with ?pretty=true.
+func wantsPretty(r *http.Request) bool {
+	return r != nil && r.URL.Query().Get("pretty") == "true"
 }
 
-func (h *Handler) sendError(w http.ResponseWriter, status int, message string) {
-	h.sendJSON(w, status, ErrorResponse{
+func (h *Handler) sendError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	h.sendJSON(w, r, status, ErrorResponse{
 		Error:   http.StatusText(status),
+		Code:    code,
 		Message: message,
 	})
 }
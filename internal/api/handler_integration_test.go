@@ -2,18 +2,55 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"adstxt-api/internal/adstxt"
 	"adstxt-api/internal/cache"
 	"adstxt-api/internal/config"
 )
 
+// fakeFetcher serves canned ads.txt content per domain without touching the network.
+type fakeFetcher struct {
+	mu      sync.Mutex
+	content map[string]string
+	calls   map[string]int
+}
+
+func newFakeFetcher(content map[string]string) *fakeFetcher {
+	return &fakeFetcher{content: content, calls: make(map[string]int)}
+}
+
+func (f *fakeFetcher) FetchAdsTxt(ctx context.Context, domain string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls[domain]++
+	content, ok := f.content[domain]
+	if !ok {
+		return "", &adstxt.StatusError{Code: http.StatusNotFound}
+	}
+	return content, nil
+}
+
+// blockingFetcher never answers on its own; fetches end only when their context does.
+type blockingFetcher struct{}
+
+func (blockingFetcher) FetchAdsTxt(ctx context.Context, domain string) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
 func TestHandler_AnalyzeSingle_WithCache(t *testing.T) {
 	cfg := &config.Config{
 		CacheTTL:       1 * time.Hour,
@@ -21,7 +58,10 @@ func TestHandler_AnalyzeSingle_WithCache(t *testing.T) {
 	}
 	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	handler := NewHandler(cacheStore, cfg, logger)
+	fetcher := newFakeFetcher(map[string]string{
+		"example.com": "google.com, pub-1, DIRECT\ngoogle.com, pub-2, RESELLER\nappnexus.com, 1, DIRECT",
+	})
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, logger)
 
 	// First request (cache miss)
 	req := httptest.NewRequest(http.MethodGet, "/api/analyze?domain=example.com", nil)
@@ -29,8 +69,100 @@ func TestHandler_AnalyzeSingle_WithCache(t *testing.T) {
 
 	handler.AnalyzeSingle(w, req)
 
-	if w.Code != http.StatusOK && w.Code != http.StatusInternalServerError {
-		t.Errorf("Expected 200 or 500, got %d", w.Code)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	var response SingleAnalysisResponse
+	_ = json.NewDecoder(w.Body).Decode(&response)
+
+	want := []adstxt.AdvertiserCount{
+		{Domain: "google.com", Count: 2, Direct: 1, Reseller: 1},
+		{Domain: "appnexus.com", Count: 1, Direct: 1},
+	}
+	if response.Cached || response.TotalAdvertisers != len(want) || len(response.Advertisers) != len(want) {
+		t.Fatalf("Unexpected fresh response: %+v", response)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(response.Advertisers[i], want[i]) {
+			t.Errorf("Advertisers[%d] = %+v, want %+v", i, response.Advertisers[i], want[i])
+		}
+	}
+
+	// Second request (cache hit) must not fetch again
+	w = httptest.NewRecorder()
+	handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=example.com", nil))
+
+	response = SingleAnalysisResponse{}
+	_ = json.NewDecoder(w.Body).Decode(&response)
+	if !response.Cached {
+		t.Error("Expected second response to be served from cache")
+	}
+	if fetcher.calls["example.com"] != 1 {
+		t.Errorf("Expected 1 fetch, got %d", fetcher.calls["example.com"])
+	}
+}
+
+func TestHandler_AnalyzeSingle_EmptyResultCaching(t *testing.T) {
+	tests := []struct {
+		name        string
+		cacheEmpty  bool
+		wantFetches int
+	}{
+		{"empty results not cached", false, 2},
+		{"empty results cached", true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				CacheTTL:          1 * time.Hour,
+				RequestTimeout:    10 * time.Second,
+				CacheEmptyResults: tt.cacheEmpty,
+			}
+			cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+			defer cacheStore.Close()
+			fetcher := newFakeFetcher(map[string]string{"empty.com": "# nothing here yet\n"})
+			handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+				handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=empty.com", nil))
+				if w.Code != http.StatusOK {
+					t.Fatalf("Expected 200, got %d", w.Code)
+				}
+				if !tt.cacheEmpty && w.Header().Get("Cache-Control") != "public, max-age=0" {
+					t.Errorf("Expected max-age=0 for an uncached empty result, got %q", w.Header().Get("Cache-Control"))
+				}
+			}
+			if fetcher.calls["empty.com"] != tt.wantFetches {
+				t.Errorf("Expected %d fetches, got %d", tt.wantFetches, fetcher.calls["empty.com"])
+			}
+		})
+	}
+}
+
+func TestHandler_AnalyzeSingle_FetchNotFound(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandlerWithFetcher(cacheStore, newFakeFetcher(nil), cfg, logger)
+
+	w := httptest.NewRecorder()
+	handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=missing.com", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d", w.Code)
+	}
+
+	var response ErrorResponse
+	_ = json.NewDecoder(w.Body).Decode(&response)
+	if response.Code != CodeFetchNotFound {
+		t.Errorf("Expected code %s, got %s", CodeFetchNotFound, response.Code)
 	}
 }
 
@@ -41,7 +173,7 @@ func TestHandler_AnalyzeBatch_Timeout(t *testing.T) {
 	}
 	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	handler := NewHandler(cacheStore, cfg, logger)
+	handler := mustNewHandler(t, cacheStore, cfg, logger)
 
 	reqBody := BatchAnalysisRequest{
 		Domains: []string{"example.com", "test.com"},
@@ -73,7 +205,7 @@ func TestHandler_AnalyzeBatch_MaxBodySize(t *testing.T) {
 	}
 	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	handler := NewHandler(cacheStore, cfg, logger)
+	handler := mustNewHandler(t, cacheStore, cfg, logger)
 
 	// Create a body larger than maxBodySize
 	largeDomains := make([]string, 10000)
@@ -97,6 +229,84 @@ func TestHandler_AnalyzeBatch_MaxBodySize(t *testing.T) {
 	}
 }
 
+func TestHandler_AnalyzeBatch_BodyLimits(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:          1 * time.Hour,
+		RequestTimeout:    10 * time.Second,
+		BatchMaxBodyBytes: 4096,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	handler := NewHandlerWithFetcher(cacheStore, newFakeFetcher(nil), cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	oversized := `{"domains": ["` + strings.Repeat("a", 5000) + `.com"]}`
+	domains := make([]string, maxBatchDomains+1)
+	for i := range domains {
+		domains[i] = fmt.Sprintf(`"d%d.com"`, i)
+	}
+	tooMany := `{"domains": [` + strings.Join(domains, ",") + `]}`
+
+	tests := []struct {
+		name          string
+		body          string
+		unknownLength bool // Sent without Content-Length, as with chunked encoding
+		wantStatus    int
+		wantCode      string
+	}{
+		{"declared oversized body", oversized, false, http.StatusRequestEntityTooLarge, CodeBodyTooLarge},
+		{"chunked oversized body", oversized, true, http.StatusRequestEntityTooLarge, CodeBodyTooLarge},
+		{"too many domains", tooMany, false, http.StatusBadRequest, CodeBatchTooLarge},
+		{"null domains", `{"domains": null}`, false, http.StatusBadRequest, CodeMissingField},
+		{"domain of wrong type", `{"domains": ["a.com", 7]}`, false, http.StatusBadRequest, CodeInvalidJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/batch-analysis", strings.NewReader(tt.body))
+			if tt.unknownLength {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			handler.AnalyzeBatch(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			var response ErrorResponse
+			_ = json.NewDecoder(w.Body).Decode(&response)
+			if response.Code != tt.wantCode {
+				t.Errorf("Expected code %s, got %s (%s)", tt.wantCode, response.Code, response.Message)
+			}
+		})
+	}
+}
+
+func TestHandler_CheckDomain_Labels(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	fetcher := newFakeFetcher(nil)
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	w := httptest.NewRecorder()
+	handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=a.b.c.d.e.f.g.h.i.j.example.com", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for too many labels, got %d", w.Code)
+	}
+
+	// The configured limit applies, falling back to the fetcher default when unset
+	cfg.FetchMaxDomainLabels = 2
+	if err := handler.checkDomain("www.example.com"); err == nil {
+		t.Error("Expected www.example.com to exceed a 2-label limit")
+	}
+	if len(fetcher.calls) != 0 {
+		t.Errorf("Expected nothing to be fetched, got %v", fetcher.calls)
+	}
+}
+
 func TestHandler_ValidateDomain(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -111,6 +321,10 @@ func TestHandler_ValidateDomain(t *testing.T) {
 		{"no dot", "localhost", true},
 		{"with path", "example.com/path", true},
 		{"with protocol", "https://example.com", true},
+		{"trailing dot", "example.com.", false},
+		{"two trailing dots", "example.com..", true},
+		{"only a dot", ".", true},
+		{"no dot before trailing dot", "localhost.", true},
 	}
 
 	for _, tt := range tests {
@@ -129,7 +343,7 @@ func TestHandler_Health_WithCache(t *testing.T) {
 	}
 	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	handler := NewHandler(cacheStore, cfg, logger)
+	handler := mustNewHandler(t, cacheStore, cfg, logger)
 
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 	w := httptest.NewRecorder()
@@ -162,7 +376,7 @@ func TestHandler_Metrics(t *testing.T) {
 	}
 	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-	handler := NewHandler(cacheStore, cfg, logger)
+	handler := mustNewHandler(t, cacheStore, cfg, logger)
 
 	// Make a request to increment metrics
 	req := httptest.NewRequest(http.MethodGet, "/api/analyze?domain=test.com", nil)
@@ -178,13 +392,227 @@ func TestHandler_Metrics(t *testing.T) {
 		t.Errorf("Metrics() status = %d, want %d", w.Code, http.StatusOK)
 	}
 
-	var metrics map[string]int64
+	var metrics MetricsResponse
 	err := json.NewDecoder(w.Body).Decode(&metrics)
 	if err != nil {
 		t.Fatalf("Failed to decode metrics: %v", err)
 	}
 
-	if metrics["requests_total"] < 1 {
-		t.Errorf("Metrics() requests_total = %d, want >= 1", metrics["requests_total"])
+	if metrics.RequestsTotal < 1 {
+		t.Errorf("Metrics() requests_total = %d, want >= 1", metrics.RequestsTotal)
+	}
+}
+
+// panicContext panics when a batch worker checks it for cancellation,
+// standing in for a bug anywhere in the per-domain analysis path.
+type panicContext struct {
+	context.Context
+}
+
+func (panicContext) Err() error {
+	panic("simulated worker panic")
+}
+
+func TestHandler_ProcessBatch_WorkerPanic(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := mustNewHandler(t, cacheStore, cfg, logger)
+
+	// Cache hits are resolved without a worker, so they must survive a worker panic
+	data, _ := json.Marshal(SingleAnalysisResponse{Domain: "cached.com", TotalAdvertisers: 1})
+	_ = cacheStore.Set(cacheKeyFor("cached.com"), data, cfg.CacheTTL)
+
+	response := handler.processBatch(panicContext{context.Background()}, []string{"cached.com", "one.com", "two.com"}, nil)
+
+	if len(response.Results) != 1 || response.Results[0].Domain != "cached.com" {
+		t.Errorf("Expected the cached result to be returned, got %+v", response.Results)
+	}
+	for _, d := range []string{"one.com", "two.com"} {
+		if response.Errors[d] != "internal error processing domain" {
+			t.Errorf("Expected internal error for %s, got %q", d, response.Errors[d])
+		}
+	}
+}
+
+func TestHandler_ProcessBatch_WorkerPool(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+		BatchWorkers:   2,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	fetcher := newFakeFetcher(map[string]string{
+		"one.com":   "google.com, pub-1, DIRECT",
+		"two.com":   "google.com, pub-1, DIRECT",
+		"three.com": "google.com, pub-1, DIRECT",
+	})
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, logger)
+	defer handler.Close()
+
+	response := handler.processBatch(context.Background(), []string{"one.com", "two.com", "three.com", "missing.com"}, nil)
+
+	if len(response.Results) != 3 {
+		t.Errorf("Expected 3 results, got %d", len(response.Results))
+	}
+	if _, ok := response.Errors["missing.com"]; !ok {
+		t.Errorf("Expected an error for missing.com, got %v", response.Errors)
+	}
+
+	// A panicking job must not take its worker down with it
+	response = handler.processBatch(panicContext{context.Background()}, []string{"four.com", "five.com", "six.com"}, nil)
+	if len(response.Errors) != 3 {
+		t.Errorf("Expected 3 errors after worker panics, got %v", response.Errors)
+	}
+}
+
+func TestHandler_ProcessBatch_DeadlineCancelsFetch(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:         1 * time.Hour,
+		RequestTimeout:   10 * time.Second,
+		NegativeCacheTTL: 5 * time.Minute,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandlerWithFetcher(cacheStore, blockingFetcher{}, cfg, logger)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	response := handler.processBatch(ctx, []string{"slow.com"}, nil)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("processBatch() took %v, expected the deadline to cancel the fetch", elapsed)
+	}
+	if response.Errors["slow.com"] != "request timeout" {
+		t.Errorf("Expected request timeout for slow.com, got %q", response.Errors["slow.com"])
+	}
+	if err := handler.cachedFailure("slow.com"); err != nil {
+		t.Errorf("Expected the timeout not to be negative-cached, got %v", err)
+	}
+}
+
+func TestHandler_ProcessBatch_PerDomainTimeout(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandlerWithFetcher(cacheStore, blockingFetcher{}, cfg, logger)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	response := handler.processBatch(ctx, []string{"slow.com"}, map[string]time.Duration{"slow.com": 50 * time.Millisecond})
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("processBatch() took %v, expected the domain timeout to cancel the fetch", elapsed)
+	}
+	if response.Errors["slow.com"] != "request timeout" {
+		t.Errorf("Expected request timeout for slow.com, got %q", response.Errors["slow.com"])
+	}
+}
+
+func TestHandler_AnalyzeBatch_InvalidTimeouts(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	handler := NewHandlerWithFetcher(cacheStore, newFakeFetcher(nil), cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	for _, body := range []string{
+		`{"domains": ["slow.com"], "timeouts": {"slow.com": "soon"}}`,
+		`{"domains": ["slow.com"], "timeouts": {"slow.com": "-5s"}}`,
+	} {
+		w := httptest.NewRecorder()
+		handler.AnalyzeBatch(w, httptest.NewRequest(http.MethodPost, "/api/batch-analysis", strings.NewReader(body)))
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status 400 for %s, got %d", body, w.Code)
+		}
+		var response ErrorResponse
+		_ = json.NewDecoder(w.Body).Decode(&response)
+		if response.Code != CodeInvalidField {
+			t.Errorf("Expected code %s, got %s", CodeInvalidField, response.Code)
+		}
+	}
+}
+
+func TestHandler_AnalyzeSingle_IncludeEntries(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+		MaxAdvertisers: 100,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	fetcher := newFakeFetcher(map[string]string{"example.com": "google.com, pub-1, DIRECT\nappnexus.com 1 RESELLER\ngoogle.com, pub-2, RESELLER"})
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	analyze := func(query string) SingleAnalysisResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=example.com"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("AnalyzeSingle(%q) status = %d: %s", query, w.Code, w.Body.String())
+		}
+		var result SingleAnalysisResponse
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	if result := analyze(""); result.Entries != nil {
+		t.Errorf("Expected no entries by default, got %+v", result.Entries)
+	}
+
+	// The cached analysis has no entries yet, so the first request for them fetches the file
+	result := analyze("&include_entries=true")
+	if result.Cached || fetcher.calls["example.com"] != 2 {
+		t.Errorf("Expected a fresh fetch for entries, got cached=%t after %d fetches", result.Cached, fetcher.calls["example.com"])
+	}
+	want := []adstxt.Entry{
+		{Domain: "google.com", AccountID: "pub-1", Relationship: "DIRECT", Line: 1},
+		{Domain: "google.com", AccountID: "pub-2", Relationship: "RESELLER", Line: 3},
+	}
+	if !reflect.DeepEqual(result.Entries, want) {
+		t.Errorf("Entries = %+v, want %+v", result.Entries, want)
+	}
+	if result.TotalAdvertisers != 1 || result.Advertisers[0].Count != 2 {
+		t.Errorf("Expected the counts alongside the entries, got %+v", result.Advertisers)
+	}
+
+	// Later requests for entries are served from the cache; lenient records are only listed with ?lenient=true
+	if result := analyze("&include_entries=true&lenient=true"); !result.Cached || len(result.Entries) != 3 || !result.Entries[1].Lenient {
+		t.Errorf("Expected the cached entries with the recovered appnexus.com record as lenient, got cached=%t entries=%+v", result.Cached, result.Entries)
+	}
+	if fetcher.calls["example.com"] != 2 {
+		t.Errorf("Expected cached entries not to be fetched again, got %d fetches", fetcher.calls["example.com"])
+	}
+
+	// The cached analysis itself doesn't carry them
+	if result := analyze(""); !result.Cached || result.Entries != nil {
+		t.Errorf("Expected a cached analysis without entries, got cached=%t entries=%+v", result.Cached, result.Entries)
+	}
+
+	// Entries parsed with another analysis than the cached one are not served with it
+	cacheAnalysis(t, handler, "example.com", "google.com, pub-1, DIRECT")
+	if result := analyze("&include_entries=true"); result.Cached || len(result.Entries) != 2 || fetcher.calls["example.com"] != 3 {
+		t.Errorf("Expected a fresh fetch for entries of a replaced analysis, got cached=%t entries=%+v after %d fetches",
+			result.Cached, result.Entries, fetcher.calls["example.com"])
 	}
 }
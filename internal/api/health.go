@@ -0,0 +1,186 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"adstxt-api/internal/cache"
+)
+
+// healthCheckTimeout bounds a whole /health probe. Checks still running when it
+// expires are reported unhealthy, so one slow dependency cannot hang the probe.
+const healthCheckTimeout = 2 * time.Second
+
+// statusShuttingDown is reported by /ready and /health once BeginShutdown has been called.
+const statusShuttingDown = "shutting_down"
+
+// ReadyResponse is the body of /ready.
+type ReadyResponse struct {
+	Status string `json:"status"`
+}
+
+// BeginShutdown marks the handler as draining: /ready and /health start returning 503 so
+// load balancers stop routing new traffic here, while requests already accepted are
+// still served normally. Call it when the shutdown signal arrives, before the server drains.
+func (h *Handler) BeginShutdown() {
+	h.draining.Store(true)
+}
+
+// Ready reports whether this instance should receive traffic. It is cheap enough for
+// frequent load-balancer probes: it runs no health checks and only fails once shutdown has begun.
+func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
+	if h.draining.Load() {
+		h.respond(w, r, http.StatusServiceUnavailable, ReadyResponse{Status: statusShuttingDown})
+		return
+	}
+	h.respond(w, r, http.StatusOK, ReadyResponse{Status: "ready"})
+}
+
+// HealthCheck is a named dependency probe reported in the /health checks map.
+// Check should return promptly once ctx is done; a non-nil error marks the service degraded.
+type HealthCheck interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// AddHealthCheck registers an additional check reported by /health.
+// Register checks during setup, before the handler starts serving requests.
+func (h *Handler) AddHealthCheck(check HealthCheck) {
+	h.checks = append(h.checks, check)
+}
+
+// cleanupStallIntervals is how many cleanup intervals may pass without a completed sweep
+// before the cleanup is reported as stalled.
+const cleanupStallIntervals = 3
+
+// CleanupMonitor is a component with a periodic background cleanup, such as the memory
+// cache or the rate limiter, whose progress /health?verbose=true reports.
+type CleanupMonitor interface {
+	LastCleanup() time.Time
+	CleanupInterval() time.Duration
+}
+
+// AddCleanupMonitor registers a background cleanup under name. A cache that implements
+// CleanupMonitor is registered automatically as "cache".
+// Register monitors during setup, before the handler starts serving requests.
+func (h *Handler) AddCleanupMonitor(name string, monitor CleanupMonitor) {
+	if h.cleanups == nil {
+		h.cleanups = make(map[string]CleanupMonitor)
+	}
+	h.cleanups[name] = monitor
+}
+
+// cleanupStats reports each registered cleanup's last completed sweep, flagging those
+// that have not completed one for cleanupStallIntervals intervals.
+func (h *Handler) cleanupStats() map[string]CleanupStats {
+	if len(h.cleanups) == 0 {
+		return nil
+	}
+	stats := make(map[string]CleanupStats, len(h.cleanups))
+	for name, monitor := range h.cleanups {
+		last := monitor.LastCleanup()
+		stats[name] = CleanupStats{
+			LastRun: h.formatTime(last),
+			Stalled: time.Since(last) > cleanupStallIntervals*monitor.CleanupInterval(),
+		}
+	}
+	return stats
+}
+
+// healthResult is one run of the health checks as reported by /health.
+// Its checks map is shared between responses and must not be modified.
+type healthResult struct {
+	checks    map[string]string
+	degraded  bool
+	checkedAt time.Time
+}
+
+// checkHealth returns the health check results, running the checks only if the last run
+// is older than HEALTH_CACHE_TTL. Probes arriving while checks run wait for that run rather
+// than starting their own. The run is detached from the probe's cancellation, since its
+// result is shared, and is bounded by healthCheckTimeout instead.
+func (h *Handler) checkHealth(ctx context.Context) healthResult {
+	h.healthMu.Lock()
+	defer h.healthMu.Unlock()
+
+	if h.lastHealth != nil && time.Since(h.lastHealth.checkedAt) < h.cfg.HealthCacheTTL {
+		return *h.lastHealth
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), healthCheckTimeout)
+	defer cancel()
+
+	result := healthResult{checks: make(map[string]string), checkedAt: time.Now()}
+	for name, err := range h.runHealthChecks(ctx) {
+		if err != nil {
+			result.checks[name] = "unhealthy: " + err.Error()
+			result.degraded = true
+		} else {
+			result.checks[name] = "healthy"
+		}
+	}
+	h.lastHealth = &result
+	return result
+}
+
+// runHealthChecks runs every registered check concurrently and returns each one's
+// result keyed by name. Checks that panic or outlive ctx are reported as errors.
+func (h *Handler) runHealthChecks(ctx context.Context) map[string]error {
+	type outcome struct {
+		name string
+		err  error
+	}
+	outcomes := make(chan outcome, len(h.checks))
+
+	for _, check := range h.checks {
+		go func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					outcomes <- outcome{check.Name(), fmt.Errorf("panic: %v", rec)}
+				}
+			}()
+			outcomes <- outcome{check.Name(), check.Check(ctx)}
+		}()
+	}
+
+	results := make(map[string]error, len(h.checks))
+	for range h.checks {
+		select {
+		case o := <-outcomes:
+			results[o.name] = o.err
+		case <-ctx.Done():
+			for _, check := range h.checks {
+				if _, done := results[check.Name()]; !done {
+					results[check.Name()] = fmt.Errorf("timed out: %w", ctx.Err())
+				}
+			}
+			return results
+		}
+	}
+	return results
+}
+
+// cacheHealthCheck verifies the cache accepts writes. A failed cleanup of the
+// test key is only logged, as the key expires on its own.
+type cacheHealthCheck struct {
+	cache  cache.Cache
+	logger *slog.Logger
+}
+
+func (c cacheHealthCheck) Name() string {
+	return "cache"
+}
+
+func (c cacheHealthCheck) Check(ctx context.Context) error {
+	testKey := "health:check"
+	if err := c.cache.Set(testKey, []byte("ok"), 10*time.Second); err != nil {
+		return err
+	}
+	if err := c.cache.Delete(testKey); err != nil {
+		c.logger.Warn("failed to delete health check key", slog.String("error", err.Error()))
+	}
+	return nil
+}
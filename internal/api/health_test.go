@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+// stubCheck is a HealthCheck whose behavior is supplied by the test.
+type stubCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (s stubCheck) Name() string                    { return s.name }
+func (s stubCheck) Check(ctx context.Context) error { return s.check(ctx) }
+
+// stubCleanup is a CleanupMonitor reporting a fixed last sweep.
+type stubCleanup struct {
+	last     time.Time
+	interval time.Duration
+}
+
+func (s stubCleanup) LastCleanup() time.Time         { return s.last }
+func (s stubCleanup) CleanupInterval() time.Duration { return s.interval }
+
+func newHealthTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	t.Cleanup(func() { cacheStore.Close() })
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	return mustNewHandler(t, cacheStore, cfg, logger)
+}
+
+func TestHandler_Health_RegisteredChecks(t *testing.T) {
+	handler := newHealthTestHandler(t)
+	handler.AddHealthCheck(stubCheck{"upstream", func(context.Context) error { return nil }})
+	handler.AddHealthCheck(stubCheck{"disk", func(context.Context) error { return errors.New("disk full") }})
+
+	w := httptest.NewRecorder()
+	handler.Health(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+
+	var response HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Status != "degraded" {
+		t.Errorf("Expected degraded status, got %s", response.Status)
+	}
+	if response.Checks["cache"] != "healthy" || response.Checks["upstream"] != "healthy" {
+		t.Errorf("Expected cache and upstream to be healthy, got %v", response.Checks)
+	}
+	if response.Checks["disk"] != "unhealthy: disk full" {
+		t.Errorf("Expected disk to be unhealthy, got %q", response.Checks["disk"])
+	}
+}
+
+func TestHandler_RunHealthChecks_TimeoutAndPanic(t *testing.T) {
+	handler := newHealthTestHandler(t)
+	handler.AddHealthCheck(stubCheck{"slow", func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}})
+	handler.AddHealthCheck(stubCheck{"broken", func(context.Context) error { panic("boom") }})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	results := handler.runHealthChecks(ctx)
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("runHealthChecks() took %v, expected it to stop at the timeout", elapsed)
+	}
+	if results["cache"] != nil {
+		t.Errorf("Expected cache check to pass, got %v", results["cache"])
+	}
+	if err := results["slow"]; err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected slow check to time out, got %v", err)
+	}
+	if err := results["broken"]; err == nil || !strings.Contains(err.Error(), "panic") {
+		t.Errorf("Expected broken check to report its panic, got %v", err)
+	}
+}
+
+func TestHandler_BeginShutdown(t *testing.T) {
+	handler := newHealthTestHandler(t)
+
+	w := httptest.NewRecorder()
+	handler.Ready(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 before shutdown, got %d", w.Code)
+	}
+
+	handler.BeginShutdown()
+
+	w = httptest.NewRecorder()
+	handler.Ready(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected /ready status 503 while draining, got %d", w.Code)
+	}
+	var ready ReadyResponse
+	if err := json.NewDecoder(w.Body).Decode(&ready); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if ready.Status != "shutting_down" {
+		t.Errorf("Expected shutting_down status, got %s", ready.Status)
+	}
+
+	w = httptest.NewRecorder()
+	handler.Health(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected /health status 503 while draining, got %d", w.Code)
+	}
+	var health HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&health); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if health.Status != "shutting_down" || health.Checks["cache"] != "healthy" {
+		t.Errorf("Expected shutting_down with healthy checks, got %+v", health)
+	}
+
+	// Requests accepted while draining are still served
+	w = httptest.NewRecorder()
+	handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=invalid", nil))
+	if w.Code == http.StatusServiceUnavailable {
+		t.Error("Expected analysis endpoints to keep serving while draining")
+	}
+}
+
+func TestHandler_Health_CleanupStats(t *testing.T) {
+	handler := newHealthTestHandler(t)
+	handler.AddCleanupMonitor("rate_limiter", stubCleanup{time.Now().Add(-10 * time.Minute), time.Minute})
+
+	w := httptest.NewRecorder()
+	handler.Health(w, httptest.NewRequest(http.MethodGet, "/health?verbose=true", nil))
+
+	var response HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Runtime == nil {
+		t.Fatal("Expected runtime stats with ?verbose=true")
+	}
+
+	// The memory cache registers itself and has just been created
+	cacheStats, ok := response.Runtime.Cleanups["cache"]
+	if !ok || cacheStats.Stalled || cacheStats.LastRun == "" {
+		t.Errorf("Expected a fresh cache cleanup, got %+v", response.Runtime.Cleanups)
+	}
+	if !response.Runtime.Cleanups["rate_limiter"].Stalled {
+		t.Errorf("Expected a cleanup idle for 10 intervals to be stalled, got %+v", response.Runtime.Cleanups["rate_limiter"])
+	}
+
+	// Not part of the default response
+	w = httptest.NewRecorder()
+	handler.Health(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if strings.Contains(w.Body.String(), "cleanups") {
+		t.Errorf("Expected no cleanup stats without ?verbose, got %s", w.Body.String())
+	}
+}
+
+func TestHandler_Health_CacheTTL(t *testing.T) {
+	handler := newHealthTestHandler(t)
+	var runs atomic.Int32
+	handler.AddHealthCheck(stubCheck{"upstream", func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+
+	probe := func() HealthResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.Health(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+		var response HealthResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response
+	}
+
+	// Without a TTL every probe runs the checks
+	probe()
+	probe()
+	if got := runs.Load(); got != 2 {
+		t.Errorf("Expected 2 runs with HEALTH_CACHE_TTL=0, got %d", got)
+	}
+
+	// With one, probes reuse the last run (here the second probe above) until it expires
+	handler.cfg.HealthCacheTTL = time.Hour
+	first := probe()
+	second := probe()
+	if got := runs.Load(); got != 2 {
+		t.Errorf("Expected probes within HEALTH_CACHE_TTL to reuse the last run, got %d runs", got)
+	}
+	if first.CheckedAt == "" || second.CheckedAt != first.CheckedAt {
+		t.Errorf("Expected both probes to report the same checked_at, got %q and %q", first.CheckedAt, second.CheckedAt)
+	}
+	if second.Checks["upstream"] != "healthy" {
+		t.Errorf("Expected the memoized checks to be reported, got %v", second.Checks)
+	}
+
+	// Draining is reported immediately, not after the TTL
+	handler.BeginShutdown()
+	if response := probe(); response.Status != statusShuttingDown {
+		t.Errorf("Expected %s status, got %s", statusShuttingDown, response.Status)
+	}
+}
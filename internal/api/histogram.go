@@ -0,0 +1,67 @@
+package api
+
+import (
+	"strconv"
+	"time"
+)
+
+// fetchLatencyBuckets are the upper bounds of the fetch duration histogram. Anything slower
+// than the last bound falls in the implicit +Inf bucket.
+var fetchLatencyBuckets = []time.Duration{
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2 * time.Second,
+	5 * time.Second,
+}
+
+// latencyHistogram counts durations into fetchLatencyBuckets. It is not synchronized;
+// Metrics guards it with its mutex.
+type latencyHistogram struct {
+	counts [8]int64 // One per bucket in fetchLatencyBuckets, then +Inf
+	total  int64
+	sum    time.Duration
+}
+
+// observe records one duration in the first bucket whose bound it does not exceed.
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := 0
+	for i < len(fetchLatencyBuckets) && d > fetchLatencyBuckets[i] {
+		i++
+	}
+	h.counts[i]++
+	h.total++
+	h.sum += d
+}
+
+// HistogramBucket is the number of observations at or below an upper bound in seconds.
+// Counts are cumulative, as in Prometheus, so the "+Inf" bucket equals the total count.
+type HistogramBucket struct {
+	LE    string `json:"le"`
+	Count int64  `json:"count"`
+}
+
+// HistogramSnapshot reports a latency histogram. Approximate percentiles can be read off the
+// buckets: p95 is the bound of the first bucket whose count reaches 95% of Count.
+type HistogramSnapshot struct {
+	Buckets    []HistogramBucket `json:"buckets"`
+	Count      int64             `json:"count"`
+	SumSeconds float64           `json:"sum_seconds"`
+}
+
+// snapshot returns the histogram with cumulative bucket counts.
+func (h *latencyHistogram) snapshot() HistogramSnapshot {
+	buckets := make([]HistogramBucket, 0, len(h.counts))
+	var cumulative int64
+	for i, count := range h.counts {
+		cumulative += count
+		le := "+Inf"
+		if i < len(fetchLatencyBuckets) {
+			le = strconv.FormatFloat(fetchLatencyBuckets[i].Seconds(), 'f', -1, 64)
+		}
+		buckets = append(buckets, HistogramBucket{LE: le, Count: cumulative})
+	}
+	return HistogramSnapshot{Buckets: buckets, Count: h.total, SumSeconds: h.sum.Seconds()}
+}
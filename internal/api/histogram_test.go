@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	var h latencyHistogram
+	for _, d := range []time.Duration{
+		10 * time.Millisecond,
+		50 * time.Millisecond, // Bounds are inclusive
+		300 * time.Millisecond,
+		2 * time.Second,
+		30 * time.Second,
+	} {
+		h.observe(d)
+	}
+
+	snapshot := h.snapshot()
+	want := []HistogramBucket{
+		{LE: "0.05", Count: 2},
+		{LE: "0.1", Count: 2},
+		{LE: "0.25", Count: 2},
+		{LE: "0.5", Count: 3},
+		{LE: "1", Count: 3},
+		{LE: "2", Count: 4},
+		{LE: "5", Count: 4},
+		{LE: "+Inf", Count: 5},
+	}
+	if !reflect.DeepEqual(snapshot.Buckets, want) {
+		t.Errorf("Buckets = %v, want %v", snapshot.Buckets, want)
+	}
+	if snapshot.Count != 5 {
+		t.Errorf("Count = %d, want 5", snapshot.Count)
+	}
+	if snapshot.SumSeconds != 32.36 {
+		t.Errorf("SumSeconds = %v, want 32.36", snapshot.SumSeconds)
+	}
+}
+
+func TestHandler_Metrics_FetchDuration(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	fetcher := newFakeFetcher(map[string]string{"example.com": "google.com, pub-1, DIRECT"})
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	// The second request is a cache hit and must not be counted as a fetch
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=example.com", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("AnalyzeSingle() status = %d", w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.Metrics(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	var metrics MetricsResponse
+	if err := json.NewDecoder(w.Body).Decode(&metrics); err != nil {
+		t.Fatalf("Failed to decode metrics: %v", err)
+	}
+
+	buckets := metrics.FetchDuration.Buckets
+	if metrics.FetchDuration.Count != 1 || len(buckets) != len(fetchLatencyBuckets)+1 {
+		t.Fatalf("Expected one fetch across %d buckets, got %+v", len(fetchLatencyBuckets)+1, metrics.FetchDuration)
+	}
+	if last := buckets[len(buckets)-1]; last.LE != "+Inf" || last.Count != 1 {
+		t.Errorf("Expected the +Inf bucket to hold every fetch, got %+v", last)
+	}
+}
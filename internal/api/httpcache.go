@@ -0,0 +1,114 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"adstxt-api/internal/adstxt"
+)
+
+// respondCacheable writes a 200 JSON response with Cache-Control and ETag headers so
+// clients and CDNs can cache it for maxAge. If the request's If-None-Match matches etag,
+// 304 Not Modified is sent without a body.
+func (h *Handler) respondCacheable(w http.ResponseWriter, r *http.Request, data interface{}, etag string, maxAge time.Duration) {
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		setCacheHeaders(w, etag, maxAge)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data, ok := h.prepare(w, r, data)
+	if !ok {
+		return
+	}
+	setCacheHeaders(w, etag, maxAge) // Not on the error prepare sent
+	var body []byte
+	var err error
+	if wantsPretty(r) {
+		body, err = json.MarshalIndent(data, "", "  ")
+	} else {
+		body, err = json.Marshal(data)
+	}
+	if err != nil {
+		// Let sendJSON log the failure and write whatever it can
+		h.sendJSON(w, r, http.StatusOK, data)
+		return
+	}
+	body = append(body, '\n') // Match json.Encoder output used by sendJSON
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to write response", slog.String("error", err.Error()))
+	}
+}
+
+func setCacheHeaders(w http.ResponseWriter, etag string, maxAge time.Duration) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	w.Header().Set("ETag", etag)
+}
+
+// analysisETag returns the ETag of an analysis with the given content hash, rendered for r.
+// It covers the advertisers through the hash and everything the request chooses about the
+// body (the query parameters, minus since_hash, and the envelope), but not volatile fields
+// such as cached and cache_backend, so a cache hit revalidates against the fetch before it.
+func analysisETag(r *http.Request, contentHash string) string {
+	query := r.URL.Query()
+	query.Del("since_hash")
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s\n%t", contentHash, query.Encode(), wantsEnvelope(r))))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// remainingTTL returns how long result stays in our cache, so downstream caches expire in sync.
+// Falls back to the full TTL when the result timestamp cannot be parsed.
+func (h *Handler) remainingTTL(result *SingleAnalysisResponse) time.Duration {
+	ttl := h.resultTTL(result)
+	fetchedAt, err := time.Parse(time.RFC3339, result.Timestamp)
+	if err != nil {
+		return ttl
+	}
+
+	remaining := ttl - time.Since(fetchedAt)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag.
+// Handles "*", comma-separated lists, and weak validators (W/"...") per RFC 9110 weak comparison.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// contentHash returns a deterministic hash of an advertiser list for ?since_hash= polling.
+// Entries are hashed in domain order and volatile fields like the timestamp are excluded,
+// so the hash only changes when the advertisers or their counts do.
+func contentHash(advertisers []adstxt.AdvertiserCount) string {
+	sorted := make([]adstxt.AdvertiserCount, len(advertisers))
+	copy(sorted, advertisers)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Domain < sorted[j].Domain })
+
+	hash := sha256.New()
+	for _, adv := range sorted {
+		fmt.Fprintf(hash, "%s,%d,%d,%d\n", adv.Domain, adv.Count, adv.Direct, adv.Reseller)
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/adstxt"
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+func TestHandler_AnalyzeSingle_CachingHeaders(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := mustNewHandler(t, cacheStore, cfg, logger)
+
+	// Entry fetched 20 minutes ago has ~40 minutes left
+	cached := SingleAnalysisResponse{
+		Domain:           "example.com",
+		TotalAdvertisers: 1,
+		Timestamp:        time.Now().Add(-20 * time.Minute).Format(time.RFC3339),
+	}
+	data, _ := json.Marshal(cached)
+	_ = cacheStore.Set(cacheKeyFor("example.com"), data, cfg.CacheTTL)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/analyze?domain=example.com", nil)
+	w := httptest.NewRecorder()
+	handler.AnalyzeSingle(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	cacheControl := w.Header().Get("Cache-Control")
+	maxAge, err := strconv.Atoi(strings.TrimPrefix(cacheControl, "public, max-age="))
+	if err != nil {
+		t.Fatalf("Unexpected Cache-Control header: %q", cacheControl)
+	}
+	if maxAge > 40*60 || maxAge < 39*60 {
+		t.Errorf("Expected max-age of about 2400s, got %d", maxAge)
+	}
+
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected ETag header")
+	}
+
+	// Conditional request with the same ETag gets 304 and no body
+	req = httptest.NewRequest(http.MethodGet, "/api/analyze?domain=example.com", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	handler.AnalyzeSingle(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected empty body for 304, got %q", w.Body.String())
+	}
+
+	// Pretty output is a different representation, so it gets its own ETag
+	req = httptest.NewRequest(http.MethodGet, "/api/analyze?domain=example.com&pretty=true", nil)
+	w = httptest.NewRecorder()
+	handler.AnalyzeSingle(w, req)
+
+	if !strings.Contains(w.Body.String(), "\n  \"domain\": \"example.com\"") {
+		t.Errorf("Expected indented body, got %q", w.Body.String())
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Error("Expected pretty response to have a different ETag")
+	}
+}
+
+func TestHandler_AnalyzeSingle_ETagIgnoresCacheState(t *testing.T) {
+	cfg := &config.Config{CacheTTL: time.Hour, RequestTimeout: 10 * time.Second}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	fetcher := newFakeFetcher(map[string]string{"example.com": "google.com, pub-1, DIRECT\n"})
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	defer handler.Close()
+
+	analyze := func(target, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		w := httptest.NewRecorder()
+		handler.AnalyzeSingle(w, req)
+		return w
+	}
+
+	// The fetch and the cache hit after it differ in "cached" and "cache_backend" only
+	miss := analyze("/api/analyze?domain=example.com", "")
+	etag := miss.Header().Get("ETag")
+	if hit := analyze("/api/analyze?domain=example.com", ""); hit.Header().Get("ETag") != etag || !strings.Contains(hit.Body.String(), `"cached":true`) {
+		t.Errorf("cache hit ETag = %q, want the fetch's %q", hit.Header().Get("ETag"), etag)
+	}
+	if w := analyze("/api/analyze?domain=example.com", etag); w.Code != http.StatusNotModified {
+		t.Errorf("revalidating a cache hit: status = %d, want 304", w.Code)
+	}
+
+	// Options that change the body change the ETag
+	if w := analyze("/api/analyze?domain=example.com&verbose=true", etag); w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("verbose: status = %d, ETag = %q, want 200 and an ETag of its own", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	etag := `"abc"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"xyz", "abc"`, true},
+		{`"xyz"`, false},
+		{"*", true},
+	}
+
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, etag); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	a := []adstxt.AdvertiserCount{{Domain: "google.com", Count: 2}, {Domain: "openx.com", Count: 1}}
+	b := []adstxt.AdvertiserCount{{Domain: "openx.com", Count: 1}, {Domain: "google.com", Count: 2}}
+	c := []adstxt.AdvertiserCount{{Domain: "google.com", Count: 3}, {Domain: "openx.com", Count: 1}}
+
+	if contentHash(a) != contentHash(b) {
+		t.Error("Expected hash to be independent of advertiser order")
+	}
+	if contentHash(a) == contentHash(c) {
+		t.Error("Expected hash to change when a count changes")
+	}
+}
+
+func TestHandler_AnalyzeSingle_SinceHash(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := mustNewHandler(t, cacheStore, cfg, logger)
+
+	cached := handler.buildAnalysis("example.com", "google.com, pub-1, DIRECT")
+	data, _ := json.Marshal(cached)
+	_ = cacheStore.Set(cacheKeyFor("example.com"), data, cfg.CacheTTL)
+
+	w := httptest.NewRecorder()
+	handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=example.com", nil))
+
+	var response SingleAnalysisResponse
+	_ = json.NewDecoder(w.Body).Decode(&response)
+	if response.ContentHash == "" {
+		t.Fatal("Expected content_hash in response")
+	}
+
+	w = httptest.NewRecorder()
+	handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=example.com&since_hash="+response.ContentHash, nil))
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304 for matching hash, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected empty body on 304, got %q", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=example.com&since_hash=stale", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for stale hash, got %d", w.Code)
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// MessageCatalog translates ErrorResponse messages into one language. Only the message is
+// translated; the error and code fields stay language-neutral for clients to branch on.
+type MessageCatalog struct {
+	// Messages maps an English message, exactly as the API sends it, to its translation.
+	Messages map[string]string
+	// Codes maps an error code to a translation used when the message is not in Messages,
+	// typically one that embeds a domain, a limit or an underlying error.
+	Codes map[string]string
+}
+
+// translate returns the translation of message, sent with code, and false if it has none.
+func (c MessageCatalog) translate(code, message string) (string, bool) {
+	if translated, ok := c.Messages[message]; ok {
+		return translated, true
+	}
+	translated, ok := c.Codes[code]
+	return translated, ok
+}
+
+// The registered catalogs. English is the language messages are written in, so it needs no
+// catalog and is what the matcher falls back to.
+var (
+	catalogMu       sync.RWMutex
+	catalogTags     = []language.Tag{language.English, language.Spanish}
+	catalogs        = []MessageCatalog{{}, spanishCatalog}
+	catalogsMatcher = language.NewMatcher(catalogTags)
+)
+
+// RegisterMessageCatalog adds or replaces the catalog for tag, so error messages are sent in
+// that language to clients that prefer it in Accept-Language. Call it at startup.
+func RegisterMessageCatalog(tag language.Tag, catalog MessageCatalog) {
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+
+	for i, t := range catalogTags {
+		if t == tag {
+			catalogs[i] = catalog
+			return
+		}
+	}
+	catalogTags = append(catalogTags, tag)
+	catalogs = append(catalogs, catalog)
+	catalogsMatcher = language.NewMatcher(catalogTags)
+}
+
+// localizeError translates resp's message into the language r prefers in Accept-Language,
+// setting Content-Language when it does. English, an unparseable header or a language
+// without a catalog leave the message as is.
+func localizeError(w http.ResponseWriter, r *http.Request, resp ErrorResponse) ErrorResponse {
+	if r == nil {
+		return resp
+	}
+	accept := r.Header.Get("Accept-Language")
+	if accept == "" {
+		return resp
+	}
+	preferred, _, err := language.ParseAcceptLanguage(accept)
+	if err != nil || len(preferred) == 0 {
+		return resp
+	}
+
+	catalogMu.RLock()
+	_, index, confidence := catalogsMatcher.Match(preferred...)
+	tag, catalog := catalogTags[index], catalogs[index]
+	catalogMu.RUnlock()
+	if confidence == language.No || index == 0 {
+		return resp
+	}
+
+	if translated, ok := catalog.translate(resp.Code, resp.Message); ok {
+		resp.Message = translated
+		w.Header().Set("Content-Language", tag.String())
+	}
+	return resp
+}
+
+// writeError writes an error response for middleware, which has no Handler to sendError with.
+func writeError(w http.ResponseWriter, r *http.Request, status int, errText, code, message string) {
+	resp := localizeError(w, r, ErrorResponse{Error: errText, Code: code, Message: message})
+	body, _ := json.Marshal(resp) // Only strings, cannot fail
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
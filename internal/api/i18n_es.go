@@ -0,0 +1,61 @@
+package api
+
+// spanishCatalog holds the Spanish error messages.
+var spanishCatalog = MessageCatalog{
+	Messages: map[string]string{
+		"only POST method is allowed":                                            "solo se permite el método POST",
+		"only GET method is allowed":                                             "solo se permite el método GET",
+		`domains field is required, e.g. {"domains": ["example.com"]}`:           `el campo domains es obligatorio, p. ej. {"domains": ["example.com"]}`,
+		"domains array cannot be empty":                                          "el array domains no puede estar vacío",
+		"domains query parameter is required":                                    "el parámetro domains es obligatorio",
+		"domain is not in the allowed list":                                      "el dominio no está en la lista de dominios permitidos",
+		"content cannot be empty":                                                "el contenido no puede estar vacío",
+		"failed to read request body":                                            "no se pudo leer el cuerpo de la solicitud",
+		"request body is empty":                                                  "el cuerpo de la solicitud está vacío",
+		"malformed JSON: body ended unexpectedly":                                "JSON mal formado: el cuerpo terminó inesperadamente",
+		"invalid JSON payload":                                                   "JSON no válido",
+		"top must be a positive integer":                                         "top debe ser un entero positivo",
+		"min_advertisers must be a non-negative integer":                         "min_advertisers debe ser un entero no negativo",
+		"relationship must be one of: direct, reseller, all":                     "relationship debe ser direct, reseller o all",
+		"job not found or expired":                                               "trabajo no encontrado o caducado",
+		"failed to create job":                                                   "no se pudo crear el trabajo",
+		"failed to load job":                                                     "no se pudo cargar el trabajo",
+		"failed to load job results":                                             "no se pudieron cargar los resultados del trabajo",
+		"failed to flush cache":                                                  "no se pudo vaciar la caché",
+		"Too many requests. Please try again later.":                             "Demasiadas solicitudes. Inténtelo de nuevo más tarde.",
+		"Too many concurrent requests. Please try again later.":                  "Demasiadas solicitudes simultáneas. Inténtelo de nuevo más tarde.",
+		"Too many concurrent requests from this client. Please try again later.": "Demasiadas solicitudes simultáneas desde este cliente. Inténtelo de nuevo más tarde.",
+		"Admin endpoints are disabled.":                                          "Los endpoints de administración están desactivados.",
+		"Missing or invalid admin token.":                                        "Falta el token de administración o no es válido.",
+	},
+	Codes: map[string]string{
+		CodeInvalidDomain:          "dominio no válido",
+		CodeDomainNotAllowed:       "el dominio no está en la lista de dominios permitidos",
+		CodeInvalidJSON:            "el cuerpo de la solicitud no es un JSON válido",
+		CodeInvalidBody:            "no se pudo leer el cuerpo de la solicitud",
+		CodeBodyTooLarge:           "el cuerpo de la solicitud supera el tamaño máximo",
+		CodeInvalidParameter:       "un parámetro de la consulta tiene un valor no válido",
+		CodeMissingField:           "falta un campo obligatorio",
+		CodeInvalidField:           "un campo del cuerpo tiene un valor no válido",
+		CodeUnknownField:           "el cuerpo contiene un campo desconocido",
+		CodeEmptyContent:           "el contenido no puede estar vacío",
+		CodeEmptyBatch:             "el lote no contiene dominios",
+		CodeBatchTooLarge:          "el lote supera el número máximo de dominios",
+		CodeMethodNotAllowed:       "método HTTP no permitido",
+		CodeFetchFailed:            "no se pudo obtener el ads.txt",
+		CodeFetchNotFound:          "el servidor del editor respondió 404 para ads.txt",
+		CodeFetchDNSFailure:        "el dominio del editor no se resuelve",
+		CodeFetchConnectionRefused: "ningún servidor web del editor acepta conexiones",
+		CodeFetchTimeout:           "se agotó el tiempo al obtener el ads.txt",
+		CodeFetchRedirect:          "el ads.txt redirige fuera del dominio del editor",
+		CodeFetchWWWOnly:           "el ads.txt solo se sirve en el host www",
+		CodeCircuitOpen:            "las últimas descargas del editor fallaron; se reintentará tras la pausa",
+		CodeRateLimited:            "Demasiadas solicitudes. Inténtelo de nuevo más tarde.",
+		CodeServerBusy:             "Demasiadas solicitudes simultáneas. Inténtelo de nuevo más tarde.",
+		CodeClientBusy:             "Demasiadas solicitudes simultáneas desde este cliente. Inténtelo de nuevo más tarde.",
+		CodeUnauthorized:           "Falta el token de administración o no es válido.",
+		CodeAdminDisabled:          "Los endpoints de administración están desactivados.",
+		CodeCacheFailure:           "falló una operación de la caché",
+		CodeJobNotFound:            "trabajo no encontrado o caducado",
+	},
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/text/language"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+func TestHandler_LocalizedErrors(t *testing.T) {
+	cfg := &config.Config{CacheTTL: time.Hour}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	handler := mustNewHandler(t, cacheStore, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		url            string
+		wantMessage    string
+		wantLanguage   string
+	}{
+		{"no header", "", "/api/analyze?domain=example.com&relationship=both", "relationship must be one of: direct, reseller, all", ""},
+		{"english", "en-US", "/api/analyze?domain=example.com&relationship=both", "relationship must be one of: direct, reseller, all", ""},
+		{"spanish", "es", "/api/analyze?domain=example.com&relationship=both", "relationship debe ser direct, reseller o all", "es"},
+		{"regional spanish first", "es-MX,en;q=0.5", "/api/analyze?domain=example.com&relationship=both", "relationship debe ser direct, reseller o all", "es"},
+		{"english preferred", "en,es;q=0.5", "/api/analyze?domain=example.com&relationship=both", "relationship must be one of: direct, reseller, all", ""},
+		{"no catalog", "fr", "/api/analyze?domain=example.com&relationship=both", "relationship must be one of: direct, reseller, all", ""},
+		{"malformed header", ";;q=x", "/api/analyze?domain=example.com&relationship=both", "relationship must be one of: direct, reseller, all", ""},
+		{"dynamic message", "es", "/api/analyze?domain=example.com&verbose=maybe", "un parámetro de la consulta tiene un valor no válido", "es"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			w := httptest.NewRecorder()
+
+			handler.AnalyzeSingle(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			var resp ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", resp.Message, tt.wantMessage)
+			}
+			// The machine-readable fields never change with the language
+			if resp.Code != CodeInvalidParameter || resp.Error != "Bad Request" {
+				t.Errorf("error/code = %q/%q, want Bad Request/%s", resp.Error, resp.Code, CodeInvalidParameter)
+			}
+			if got := w.Header().Get("Content-Language"); got != tt.wantLanguage {
+				t.Errorf("Content-Language = %q, want %q", got, tt.wantLanguage)
+			}
+		})
+	}
+}
+
+func TestAdminAuthMiddleware_LocalizedErrors(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := AdminAuthMiddleware("secret")(next)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/cache/flush", nil)
+	req.Header.Set("Accept-Language", "es-ES")
+	w := httptest.NewRecorder()
+
+	middleware.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	var resp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Code != CodeUnauthorized || resp.Message != "Falta el token de administración o no es válido." {
+		t.Errorf("got %+v, want a Spanish UNAUTHORIZED message", resp)
+	}
+}
+
+func TestRegisterMessageCatalog(t *testing.T) {
+	RegisterMessageCatalog(language.German, MessageCatalog{
+		Messages: map[string]string{"content cannot be empty": "Inhalt darf nicht leer sein"},
+	})
+	t.Cleanup(func() {
+		catalogMu.Lock()
+		defer catalogMu.Unlock()
+		catalogTags = catalogTags[:len(catalogTags)-1]
+		catalogs = catalogs[:len(catalogs)-1]
+		catalogsMatcher = language.NewMatcher(catalogTags)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/parse", nil)
+	req.Header.Set("Accept-Language", "de-CH, en;q=0.8")
+
+	tests := []struct {
+		code, message, want string
+	}{
+		{CodeEmptyContent, "content cannot be empty", "Inhalt darf nicht leer sein"},
+		{CodeInvalidJSON, "invalid JSON payload", "invalid JSON payload"}, // No translation, stays English
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		got := localizeError(w, req, ErrorResponse{Error: "Bad Request", Code: tt.code, Message: tt.message})
+		if got.Message != tt.want {
+			t.Errorf("message for %s = %q, want %q", tt.code, got.Message, tt.want)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"adstxt-api/internal/adstxt"
+	"adstxt-api/internal/cache"
+)
+
+// maxJobBodySize caps POST /api/jobs bodies. Jobs exist for lists far beyond the batch
+// limit, so they get more room than maxBodySize; JOB_MAX_DOMAINS is the real bound.
+const maxJobBodySize = 16 << 20 // 16MB
+
+// Job statuses reported by GET /api/jobs/{id}.
+const (
+	JobQueued      = "queued"
+	JobRunning     = "running"
+	JobDone        = "done"
+	JobInterrupted = "interrupted" // The server shut down before every domain was analyzed
+)
+
+type JobRequest struct {
+	Domains []string `json:"domains"`
+}
+
+// JobResponse reports a job's progress along with the results completed so far.
+type JobResponse struct {
+	ID         string                   `json:"id"`
+	Status     string                   `json:"status"`
+	Total      int                      `json:"total"`
+	Completed  int                      `json:"completed"` // Domains finished, including those in Errors
+	CreatedAt  string                   `json:"created_at"`
+	FinishedAt string                   `json:"finished_at,omitempty"`
+	Results    []SingleAnalysisResponse `json:"results"`
+	Errors     map[string]string        `json:"errors,omitempty"`
+}
+
+// jobRecord is the stored state of a job. Per-domain outcomes are stored separately
+// under jobEntryKeyFor as they complete, so progress never rewrites the whole job.
+type jobRecord struct {
+	ID         string   `json:"id"`
+	Status     string   `json:"status"`
+	Domains    []string `json:"domains"`
+	CreatedAt  string   `json:"created_at"`
+	FinishedAt string   `json:"finished_at,omitempty"`
+	Fast       *bool    `json:"fast,omitempty"` // The ?fast override the job was created with
+
+	NoWWWFallback *bool `json:"no_www_fallback,omitempty"` // The ?no_www_fallback override the job was created with
+}
+
+// fetchContext returns ctx carrying the fetch overrides the job was created with.
+func (job *jobRecord) fetchContext(ctx context.Context) context.Context {
+	if job.Fast != nil {
+		ctx = adstxt.WithFetchRace(ctx, *job.Fast)
+	}
+	if job.NoWWWFallback != nil {
+		ctx = adstxt.WithWWWFallback(ctx, !*job.NoWWWFallback)
+	}
+	return ctx
+}
+
+// jobEntry is the outcome of one domain of a job: either a result or an error message.
+type jobEntry struct {
+	Result *SingleAnalysisResponse `json:"result,omitempty"`
+	Error  string                  `json:"error,omitempty"`
+}
+
+// jobKeyFor returns the cache key holding a job's record.
+func jobKeyFor(id string) string {
+	return fmt.Sprintf("adstxt:job:%s", id)
+}
+
+// jobEntryKeyFor returns the cache key holding the outcome of domain within a job.
+func jobEntryKeyFor(id, domain string) string {
+	return fmt.Sprintf("adstxt:job:%s:%s", id, domain)
+}
+
+// newJobID returns a random 128-bit hex job ID.
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validJobID reports whether id has the shape produced by newJobID.
+func validJobID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// jobRunner analyzes job domains in the background. Every job shares the same slots
+// and fetch pace, so concurrent jobs together stay within JOB_WORKERS analyses in
+// flight and JOB_FETCH_RATE outbound fetches per second.
+type jobRunner struct {
+	h      *Handler
+	slots  chan struct{}
+	ticker *time.Ticker // Paces cache-miss fetches; nil when JOB_FETCH_RATE is 0
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newJobRunner(h *Handler) *jobRunner {
+	workers := h.cfg.JobWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	jr := &jobRunner{
+		h:      h,
+		slots:  make(chan struct{}, workers),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	if h.cfg.JobFetchRate > 0 {
+		jr.ticker = time.NewTicker(time.Second / time.Duration(h.cfg.JobFetchRate))
+	}
+	return jr
+}
+
+// start processes job in the background. The record must already be stored.
+func (jr *jobRunner) start(job *jobRecord) {
+	jr.wg.Add(1)
+	go jr.run(job)
+}
+
+func (jr *jobRunner) run(job *jobRecord) {
+	defer jr.wg.Done()
+
+	job.Status = JobRunning
+	jr.save(job)
+
+	var wg sync.WaitGroup
+dispatch:
+	for _, domain := range job.Domains {
+		select {
+		case jr.slots <- struct{}{}:
+		case <-jr.ctx.Done():
+			break dispatch
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-jr.slots }()
+			jr.process(job, domain)
+		}()
+	}
+	wg.Wait()
+
+	job.Status = JobDone
+	if jr.ctx.Err() != nil {
+		job.Status = JobInterrupted
+	}
+	job.FinishedAt = jr.h.formatTime(time.Now())
+	jr.save(job)
+}
+
+// process analyzes one domain of a job and stores its outcome.
+// Domains cut short by stop are not stored, so they do not count as completed.
+func (jr *jobRunner) process(job *jobRecord, domain string) {
+	id := job.ID
+	var entry jobEntry
+	result, err := jr.analyze(job.fetchContext(jr.ctx), domain)
+	if err != nil {
+		if jr.ctx.Err() != nil {
+			return
+		}
+		entry.Error = err.Error()
+	} else {
+		entry.Result = result
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		jr.h.logger.Error("failed to marshal job entry", slog.String("job", id), slog.String("domain", domain), slog.String("error", err.Error()))
+		return
+	}
+	if err := jr.h.cache.Set(jobEntryKeyFor(id, domain), data, jr.h.cfg.JobTTL); err != nil {
+		jr.h.logger.Warn("failed to store job entry", slog.String("job", id), slog.String("domain", domain), slog.String("error", err.Error()))
+	}
+}
+
+// analyze returns the analysis of domain, from the cache when possible, under ctx, the runner's
+// context with the job's fetch overrides. Only cache misses wait for the fetch pace, as hits
+// cost no outbound traffic.
+func (jr *jobRunner) analyze(ctx context.Context, domain string) (result *SingleAnalysisResponse, err error) {
+	h := jr.h
+
+	// Isolate panics to the domain that caused them instead of crashing the process
+	defer func() {
+		if rec := recover(); rec != nil {
+			h.logger.Error("panic in job worker",
+				slog.String("domain", domain),
+				slog.Any("panic", rec),
+				slog.String("stack", string(debug.Stack())))
+			result, err = nil, errors.New("internal error processing domain")
+		}
+	}()
+
+	if err := h.checkDomain(domain); err != nil {
+		return nil, fmt.Errorf("invalid domain: %w", err)
+	}
+	if !h.domainAllowed(domain) {
+		return nil, errors.New("domain not allowed")
+	}
+
+	target := h.cacheTarget(domain)
+	if cachedData, err := h.cache.Get(cacheKeyFor(target)); err == nil {
+		if result, ok := h.fromCache(ctx, domain, target, cachedData); ok {
+			return result, nil
+		}
+	}
+
+	if jr.ticker != nil {
+		select {
+		case <-jr.ticker.C:
+		case <-jr.ctx.Done():
+			return nil, jr.ctx.Err()
+		}
+	}
+	return h.analyzeMiss(ctx, domain, target, false)
+}
+
+// save stores the job record for JOB_TTL. Failures are only logged; the job keeps running.
+func (jr *jobRunner) save(job *jobRecord) {
+	if err := jr.h.storeJob(job); err != nil {
+		jr.h.logger.Warn("failed to store job", slog.String("job", job.ID), slog.String("error", err.Error()))
+	}
+}
+
+// stop abandons in-flight jobs, marking them interrupted, and waits for them to exit.
+// Safe to call more than once.
+func (jr *jobRunner) stop() {
+	jr.cancel()
+	jr.wg.Wait()
+	if jr.ticker != nil {
+		jr.ticker.Stop()
+	}
+}
+
+func (h *Handler) storeJob(job *jobRecord) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return h.cache.Set(jobKeyFor(job.ID), data, h.cfg.JobTTL)
+}
+
+// CreateJob accepts a domain list of any size up to JOB_MAX_DOMAINS and analyzes it in
+// the background, responding 202 with the job ID straight away. Duplicate domains are analyzed once,
+// and ?fast and ?no_www_fallback overrides apply to every fetch of the job.
+func (h *Handler) CreateJob(w http.ResponseWriter, r *http.Request) {
+	h.metrics.mu.Lock()
+	h.metrics.requestsTotal++
+	h.metrics.mu.Unlock()
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	fast, ok := h.fastParam(w, r)
+	if !ok {
+		return
+	}
+	noWWW, ok := h.noWWWFallbackParam(w, r)
+	if !ok {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxJobBodySize)
+
+	var req JobRequest
+	if code, err := h.decodeJSONBody(r, &req); err != nil {
+		h.sendError(w, r, decodeStatus(code), code, err.Error())
+		return
+	}
+	if req.Domains == nil {
+		h.sendError(w, r, http.StatusBadRequest, CodeMissingField, `domains field is required, e.g. {"domains": ["example.com"]}`)
+		return
+	}
+
+	domains := dedupeDomains(req.Domains)
+	if len(domains) == 0 {
+		h.sendError(w, r, http.StatusBadRequest, CodeEmptyBatch, "domains array cannot be empty")
+		return
+	}
+	if h.cfg.JobMaxDomains > 0 && len(domains) > h.cfg.JobMaxDomains {
+		h.sendError(w, r, http.StatusBadRequest, CodeBatchTooLarge, fmt.Sprintf("maximum %d domains per job", h.cfg.JobMaxDomains))
+		return
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to generate job ID", slog.String("error", err.Error()))
+		h.sendError(w, r, http.StatusInternalServerError, CodeCacheFailure, "failed to create job")
+		return
+	}
+
+	job := &jobRecord{
+		ID:        id,
+		Status:    JobQueued,
+		Domains:   domains,
+		CreatedAt: h.formatTime(time.Now()),
+		Fast:      fast,
+
+		NoWWWFallback: noWWW,
+	}
+	if err := h.storeJob(job); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to store job", slog.String("job", id), slog.String("error", err.Error()))
+		h.sendError(w, r, http.StatusInternalServerError, CodeCacheFailure, "failed to create job")
+		return
+	}
+
+	response := JobResponse{
+		ID:        job.ID,
+		Status:    job.Status,
+		Total:     len(job.Domains),
+		CreatedAt: job.CreatedAt,
+		Results:   make([]SingleAnalysisResponse, 0),
+	}
+	h.jobs.start(job)
+
+	h.logger.InfoContext(r.Context(), "job created", slog.String("job", id), slog.Int("domains", len(domains)))
+	w.Header().Set("Location", r.URL.Path+"/"+id)
+	h.respond(w, r, http.StatusAccepted, response)
+}
+
+// GetJob reports a job's progress and every result completed so far, in submission order.
+// Results are transformed by the same query flags as batch analysis, read by batchOptions.
+func (h *Handler) GetJob(w http.ResponseWriter, r *http.Request) {
+	h.metrics.mu.Lock()
+	h.metrics.requestsTotal++
+	h.metrics.mu.Unlock()
+
+	opts, ok := h.batchOptions(w, r)
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	if !validJobID(id) {
+		h.sendError(w, r, http.StatusNotFound, CodeJobNotFound, "job not found or expired")
+		return
+	}
+
+	data, err := h.cache.Get(jobKeyFor(id))
+	if errors.Is(err, cache.ErrCacheNotFound) {
+		h.sendError(w, r, http.StatusNotFound, CodeJobNotFound, "job not found or expired")
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to load job", slog.String("job", id), slog.String("error", err.Error()))
+		h.sendError(w, r, http.StatusInternalServerError, CodeCacheFailure, "failed to load job")
+		return
+	}
+	var job jobRecord
+	if err := json.Unmarshal(data, &job); err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to unmarshal job", slog.String("job", id), slog.String("error", err.Error()))
+		h.sendError(w, r, http.StatusInternalServerError, CodeCacheFailure, "failed to load job")
+		return
+	}
+
+	keys := make([]string, len(job.Domains))
+	for i, d := range job.Domains {
+		keys[i] = jobEntryKeyFor(id, d)
+	}
+	entries, err := h.cache.GetMulti(keys)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to load job results", slog.String("job", id), slog.String("error", err.Error()))
+		h.sendError(w, r, http.StatusInternalServerError, CodeCacheFailure, "failed to load job results")
+		return
+	}
+
+	response := JobResponse{
+		ID:         job.ID,
+		Status:     job.Status,
+		Total:      len(job.Domains),
+		CreatedAt:  job.CreatedAt,
+		FinishedAt: job.FinishedAt,
+		Results:    make([]SingleAnalysisResponse, 0, len(entries)),
+		Errors:     make(map[string]string),
+	}
+	for i, d := range job.Domains {
+		raw, ok := entries[keys[i]]
+		if !ok {
+			continue
+		}
+		var entry jobEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			h.logger.WarnContext(r.Context(), "failed to unmarshal job entry", slog.String("job", id), slog.String("domain", d), slog.String("error", err.Error()))
+			continue
+		}
+
+		response.Completed++
+		if entry.Result == nil {
+			response.Errors[d] = entry.Error
+			continue
+		}
+		opts.applyTo(entry.Result)
+		response.Results = append(response.Results, *entry.Result)
+	}
+
+	h.respond(w, r, http.StatusOK, response)
+}
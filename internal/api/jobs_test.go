@@ -0,0 +1,244 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+	"adstxt-api/internal/ratelimit"
+)
+
+func newJobsTestRouter(t *testing.T, fetcher AdsTxtFetcher, cfg *config.Config) (*Handler, http.Handler) {
+	t.Helper()
+	cfg.CacheTTL = 1 * time.Hour
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	t.Cleanup(func() { cacheStore.Close() })
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, logger)
+	t.Cleanup(handler.Close)
+	limiter := ratelimit.NewRateLimiter(1000)
+	t.Cleanup(limiter.Stop)
+	return handler, NewRouter(handler, limiter)
+}
+
+func createJob(t *testing.T, router http.Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func getJob(t *testing.T, router http.Handler, path string) (int, JobResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	var response JobResponse
+	if w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode job: %v", err)
+		}
+	}
+	return w.Code, response
+}
+
+// waitForJob polls a job until it leaves the queued and running states.
+func waitForJob(t *testing.T, router http.Handler, path string) JobResponse {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		code, response := getJob(t, router, path)
+		if code != http.StatusOK {
+			t.Fatalf("Expected status 200 polling job, got %d", code)
+		}
+		if response.Status != JobQueued && response.Status != JobRunning {
+			return response
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("job did not finish in time")
+	return JobResponse{}
+}
+
+func TestJobs_CreateAndPoll(t *testing.T) {
+	fetcher := newFakeFetcher(map[string]string{
+		"a.com": "google.com, pub-1, DIRECT\nappnexus.com, 2, RESELLER\n",
+		"b.com": "google.com, pub-2, DIRECT\n",
+	})
+	_, router := newJobsTestRouter(t, fetcher, &config.Config{JobWorkers: 2, JobFetchRate: 100})
+
+	w := createJob(t, router, `{"domains": ["a.com", "b.com", "missing.com", "not a domain", "a.com"]}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var created JobResponse
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !validJobID(created.ID) {
+		t.Fatalf("Expected a job ID, got %q", created.ID)
+	}
+	if created.Status != JobQueued || created.Total != 4 {
+		t.Errorf("Expected queued job of 4 deduplicated domains, got %s with %d", created.Status, created.Total)
+	}
+	location := w.Header().Get("Location")
+	if location != "/api/jobs/"+created.ID {
+		t.Errorf("Expected Location /api/jobs/%s, got %q", created.ID, location)
+	}
+
+	job := waitForJob(t, router, location)
+	if job.Status != JobDone || job.FinishedAt == "" {
+		t.Errorf("Expected finished done job, got %s (finished_at %q)", job.Status, job.FinishedAt)
+	}
+	if job.Completed != 4 {
+		t.Errorf("Expected 4 completed domains, got %d", job.Completed)
+	}
+	if len(job.Results) != 2 || job.Results[0].Domain != "a.com" || job.Results[1].Domain != "b.com" {
+		t.Fatalf("Expected results for a.com then b.com, got %+v", job.Results)
+	}
+	if job.Results[0].TotalAdvertisers != 2 {
+		t.Errorf("Expected 2 advertisers for a.com, got %d", job.Results[0].TotalAdvertisers)
+	}
+	if _, ok := job.Errors["missing.com"]; !ok {
+		t.Errorf("Expected an error for missing.com, got %v", job.Errors)
+	}
+	if !strings.HasPrefix(job.Errors["not a domain"], "invalid domain") {
+		t.Errorf("Expected invalid domain error, got %q", job.Errors["not a domain"])
+	}
+	if fetcher.calls["a.com"] != 1 {
+		t.Errorf("Expected a.com to be fetched once, got %d", fetcher.calls["a.com"])
+	}
+
+	// The versioned alias serves the same job
+	if code, _ := getJob(t, router, "/v1/api/jobs/"+created.ID); code != http.StatusOK {
+		t.Errorf("Expected status 200 from versioned path, got %d", code)
+	}
+}
+
+func TestJobs_ResultOptions(t *testing.T) {
+	fetcher := newFakeFetcher(map[string]string{
+		"a.com": "google.com, pub-1, DIRECT\nrubiconproject.com, 1, DIRECT\nfastlane.rubiconproject.com, 2, DIRECT\n",
+	})
+	_, router := newJobsTestRouter(t, fetcher, &config.Config{})
+
+	w := createJob(t, router, `{"domains": ["a.com"]}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d", w.Code)
+	}
+	location := w.Header().Get("Location")
+	waitForJob(t, router, location)
+
+	_, job := getJob(t, router, location+"?min_advertisers=5")
+	if len(job.Results) != 1 || !job.Results[0].Suspicious {
+		t.Errorf("Expected a.com flagged suspicious below min_advertisers, got %+v", job.Results)
+	}
+	if code, _ := getJob(t, router, location+"?min_advertisers=-1"); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid min_advertisers, got %d", code)
+	}
+
+	_, job = getJob(t, router, location+"?normalize_advertisers=true")
+	if len(job.Results) != 1 || job.Results[0].TotalAdvertisers != 2 {
+		t.Errorf("Expected the rubiconproject.com subdomain merged into 2 advertisers, got %+v", job.Results)
+	}
+	if code, _ := getJob(t, router, location+"?detect_changes=maybe"); code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid detect_changes, got %d", code)
+	}
+}
+
+func TestJobs_CreateValidation(t *testing.T) {
+	_, router := newJobsTestRouter(t, newFakeFetcher(nil), &config.Config{JobMaxDomains: 2})
+
+	tests := []struct {
+		name string
+		body string
+		code string
+	}{
+		{"missing domains", `{}`, CodeMissingField},
+		{"empty domains", `{"domains": []}`, CodeEmptyBatch},
+		{"too many domains", `{"domains": ["a.com", "b.com", "c.com"]}`, CodeBatchTooLarge},
+		{"malformed JSON", `{"domains": [`, CodeInvalidJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := createJob(t, router, tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("Expected status 400, got %d", w.Code)
+			}
+			var response ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Code != tt.code {
+				t.Errorf("Expected code %s, got %s", tt.code, response.Code)
+			}
+		})
+	}
+
+	// Duplicates do not count against the limit
+	if w := createJob(t, router, `{"domains": ["a.com", "a.com", "b.com"]}`); w.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202 for duplicated domains within the limit, got %d", w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/jobs", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET /api/jobs, got %d", w.Code)
+	}
+}
+
+func TestJobs_NotFound(t *testing.T) {
+	_, router := newJobsTestRouter(t, newFakeFetcher(nil), &config.Config{})
+
+	for _, path := range []string{"/api/jobs/not-a-job", "/api/jobs/0123456789abcdef0123456789abcdef"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", path, w.Code)
+			continue
+		}
+		var response ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.Code != CodeJobNotFound {
+			t.Errorf("%s: expected code %s, got %s", path, CodeJobNotFound, response.Code)
+		}
+	}
+}
+
+func TestJobs_CloseInterruptsRunningJobs(t *testing.T) {
+	handler, router := newJobsTestRouter(t, blockingFetcher{}, &config.Config{JobWorkers: 1})
+
+	w := createJob(t, router, `{"domains": ["slow.com", "slower.com"]}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d", w.Code)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		handler.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not return while a job was fetching")
+	}
+
+	_, job := getJob(t, router, w.Header().Get("Location"))
+	if job.Status != JobInterrupted {
+		t.Errorf("Expected interrupted job, got %s", job.Status)
+	}
+	if job.Completed != 0 {
+		t.Errorf("Expected no completed domains, got %d", job.Completed)
+	}
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"adstxt-api/internal/adstxt"
+)
+
+// applyLenient resolves the advertisers recovered by lenient parsing for one response.
+// With lenient set they are merged into result's advertisers and counted in
+// LenientRecovered; otherwise they are dropped, leaving the spec-compliant analysis.
+// Either way they are removed from the response, as they are only stored for this merge.
+// Like relationship filtering, this happens at response time so one cached analysis serves both modes.
+// Entries only lenient parsing accepted are likewise dropped unless lenient is set.
+func applyLenient(result *SingleAnalysisResponse, lenient bool) {
+	if !lenient && len(result.Entries) > 0 {
+		strict := make([]adstxt.Entry, 0, len(result.Entries)) // Not in place: other responses may share the array
+		for _, entry := range result.Entries {
+			if !entry.Lenient {
+				strict = append(strict, entry)
+			}
+		}
+		result.Entries = strict
+	}
+
+	recovered := result.Recovered
+	result.Recovered = nil
+	if !lenient || len(recovered) == 0 {
+		return
+	}
+
+	index := make(map[string]int, len(result.Advertisers))
+	merged := make([]adstxt.AdvertiserCount, len(result.Advertisers), len(result.Advertisers)+len(recovered))
+	copy(merged, result.Advertisers)
+	for i, adv := range merged {
+		index[adv.Domain] = i
+	}
+
+	for _, adv := range recovered {
+		result.LenientRecovered += adv.Count
+		if i, ok := index[adv.Domain]; ok {
+			merged[i].Count += adv.Count
+			merged[i].Direct += adv.Direct
+			merged[i].Reseller += adv.Reseller
+			merged[i].CertAuthorities = unionSorted(merged[i].CertAuthorities, adv.CertAuthorities)
+			merged[i].AccountGroups = sumCounts(merged[i].AccountGroups, adv.AccountGroups)
+			continue
+		}
+		index[adv.Domain] = len(merged)
+		merged = append(merged, adv)
+	}
+
+	sortAdvertisers(merged)
+	result.Advertisers = merged
+	result.TotalAdvertisers = len(merged)
+}
+
+// sumCounts returns the per-key sum of a and b as a new map, leaving both untouched since
+// they may belong to a cached result. Returns a as is if b is empty.
+func sumCounts(a, b map[string]int) map[string]int {
+	if len(b) == 0 {
+		return a
+	}
+	sum := make(map[string]int, len(a)+len(b))
+	for k, v := range a {
+		sum[k] += v
+	}
+	for k, v := range b {
+		sum[k] += v
+	}
+	return sum
+}
+
+// unionSorted merges two sorted string slices, dropping duplicates.
+func unionSorted(a, b []string) []string {
+	if len(b) == 0 {
+		return a
+	}
+	if len(a) == 0 {
+		return b
+	}
+
+	merged := make([]string, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case j == len(b) || (i < len(a) && a[i] < b[j]):
+			merged = append(merged, a[i])
+			i++
+		case i == len(a) || b[j] < a[i]:
+			merged = append(merged, b[j])
+			j++
+		default:
+			merged = append(merged, a[i])
+			i++
+			j++
+		}
+	}
+	return merged
+}
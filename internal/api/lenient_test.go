@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+func TestApplyLenient(t *testing.T) {
+	cfg := &config.Config{CacheTTL: 1 * time.Hour}
+	handler := mustNewHandler(t, cache.NewMemoryCache(cfg.CacheTTL), cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	content := "google.com, pub-1, DIRECT\ngoogle.com pub-2 DIRECT\nappnexus.com;1;RESELLER"
+
+	strict := handler.buildAnalysis("example.com", content)
+	applyLenient(strict, false)
+	if strict.TotalAdvertisers != 1 || strict.Advertisers[0].Count != 1 || strict.LenientRecovered != 0 {
+		t.Errorf("Expected only the strict google.com record, got %+v", strict)
+	}
+	if strict.Recovered != nil {
+		t.Error("Expected recovered advertisers to be stripped")
+	}
+
+	lenient := handler.buildAnalysis("example.com", content)
+	applyLenient(lenient, true)
+	if lenient.TotalAdvertisers != 2 || lenient.LenientRecovered != 2 {
+		t.Errorf("Expected 2 advertisers with 2 recovered records, got %+v", lenient)
+	}
+	if adv := lenient.Advertisers[0]; adv.Domain != "google.com" || adv.Count != 2 || adv.Direct != 2 {
+		t.Errorf("Expected recovered google.com record merged into the strict one, got %+v", adv)
+	}
+	if lenient.Recovered != nil {
+		t.Error("Expected recovered advertisers to be stripped")
+	}
+}
+
+func TestUnionSorted(t *testing.T) {
+	tests := []struct {
+		a, b []string
+		want []string
+	}{
+		{nil, nil, nil},
+		{[]string{"a"}, nil, []string{"a"}},
+		{nil, []string{"b"}, []string{"b"}},
+		{[]string{"a", "c"}, []string{"b", "c", "d"}, []string{"a", "b", "c", "d"}},
+	}
+
+	for _, tt := range tests {
+		if got := unionSorted(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("unionSorted(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestHandler_ParseContent_Lenient(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	handler := mustNewHandler(t, cacheStore, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	content := "google.com, pub-1, DIRECT\nappnexus.com\t1\tRESELLER"
+	tests := []struct {
+		url       string
+		total     int
+		recovered int
+	}{
+		{"/api/parse", 1, 0},
+		{"/api/parse?lenient=true", 2, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(content))
+			req.Header.Set("Content-Type", "text/plain")
+			w := httptest.NewRecorder()
+
+			handler.ParseContent(w, req)
+
+			if strings.Contains(w.Body.String(), `"recovered"`) {
+				t.Errorf("Expected recovered advertisers not to be exposed, got %s", w.Body.String())
+			}
+			var response SingleAnalysisResponse
+			_ = json.NewDecoder(w.Body).Decode(&response)
+			if response.TotalAdvertisers != tt.total || response.LenientRecovered != tt.recovered {
+				t.Errorf("Expected %d advertisers and %d recovered, got %d and %d",
+					tt.total, tt.recovered, response.TotalAdvertisers, response.LenientRecovered)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/parse?lenient=maybe", strings.NewReader(content))
+	w := httptest.NewRecorder()
+	handler.ParseContent(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid lenient, got %d", w.Code)
+	}
+}
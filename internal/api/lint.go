@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+
+	"adstxt-api/internal/adstxt"
+)
+
+// LintResponse is the line-by-line diagnosis of submitted ads.txt content.
+type LintResponse struct {
+	Valid     bool                `json:"valid"` // No line is malformed
+	Records   int                 `json:"records"`
+	Comments  int                 `json:"comments"`
+	Variables int                 `json:"variables"`
+	Malformed int                 `json:"malformed"`
+	Lines     []adstxt.LineReport `json:"lines"`
+}
+
+// LintContent reports every non-blank line of ads.txt content supplied in the request body
+// as a record, comment, variable, or malformed line with the reason. Accepts the same
+// bodies as ParseContent; a domain, if given, is ignored. Nothing is fetched or cached.
+func (h *Handler) LintContent(w http.ResponseWriter, r *http.Request) {
+	h.metrics.mu.Lock()
+	h.metrics.requestsTotal++
+	h.metrics.mu.Unlock()
+
+	if r.Method != http.MethodPost {
+		h.sendError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+	req, ok := h.decodeContentRequest(w, r)
+	if !ok {
+		return
+	}
+
+	response := LintResponse{Lines: adstxt.Lint(req.Content)}
+	for _, line := range response.Lines {
+		switch line.Kind {
+		case adstxt.LineRecord:
+			response.Records++
+		case adstxt.LineComment:
+			response.Comments++
+		case adstxt.LineVariable:
+			response.Variables++
+		case adstxt.LineMalformed:
+			response.Malformed++
+		}
+	}
+	response.Valid = response.Malformed == 0
+
+	h.respond(w, r, http.StatusOK, response)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/adstxt"
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+func newLintTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	cfg := &config.Config{CacheTTL: 1 * time.Hour}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	t.Cleanup(func() { cacheStore.Close() })
+	return mustNewHandler(t, cacheStore, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+}
+
+func TestHandler_LintContent(t *testing.T) {
+	handler := newLintTestHandler(t)
+
+	content := "# comment\ngoogle.com, pub-1, DIRECT\ncontact=ads@example.com\nappnexus.com, 1, PARTNER\n"
+	req := httptest.NewRequest(http.MethodPost, "/api/lint", strings.NewReader(content))
+	req.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+	handler.LintContent(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response LintResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.Valid {
+		t.Error("Expected content with a malformed line to be invalid")
+	}
+	if response.Records != 1 || response.Comments != 1 || response.Variables != 1 || response.Malformed != 1 {
+		t.Errorf("Unexpected summary: %+v", response)
+	}
+	if len(response.Lines) != 4 {
+		t.Fatalf("Expected 4 line reports, got %d", len(response.Lines))
+	}
+	bad := response.Lines[3]
+	if bad.Line != 4 || bad.Kind != adstxt.LineMalformed || bad.Raw != "appnexus.com, 1, PARTNER" || bad.Reason == "" {
+		t.Errorf("Unexpected malformed line report: %+v", bad)
+	}
+}
+
+func TestHandler_LintContent_JSONBody(t *testing.T) {
+	handler := newLintTestHandler(t)
+
+	body := `{"content": "google.com, pub-1, DIRECT\nappnexus.com, 1, RESELLER"}`
+	w := httptest.NewRecorder()
+	handler.LintContent(w, httptest.NewRequest(http.MethodPost, "/api/lint", strings.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var response LintResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !response.Valid || response.Records != 2 {
+		t.Errorf("Expected 2 valid records, got %+v", response)
+	}
+}
+
+func TestHandler_LintContent_Errors(t *testing.T) {
+	handler := newLintTestHandler(t)
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+		code   string
+	}{
+		{"wrong method", http.MethodGet, "", http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{"empty content", http.MethodPost, `{"content": "  "}`, http.StatusBadRequest, CodeEmptyContent},
+		{"malformed JSON", http.MethodPost, `{"content":`, http.StatusBadRequest, CodeInvalidJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.LintContent(w, httptest.NewRequest(tt.method, "/api/lint", strings.NewReader(tt.body)))
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			var response ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Code != tt.code {
+				t.Errorf("Expected code %s, got %s", tt.code, response.Code)
+			}
+		})
+	}
+}
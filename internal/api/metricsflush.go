@@ -0,0 +1,84 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MetricsFlush is the final metrics snapshot FlushMetrics writes, so the last counter values
+// of a process survive its restart.
+type MetricsFlush struct {
+	FlushedAt string          `json:"flushed_at"`
+	Build     VersionResponse `json:"build"`
+	Metrics   MetricsResponse `json:"metrics"`
+}
+
+// FlushMetrics writes a snapshot of the current metrics to destination: POSTed as JSON if it
+// is an http or https URL, otherwise written to it as a file path, replacing any earlier
+// snapshot. Call it on shutdown, before the server stops; ctx bounds the POST.
+func (h *Handler) FlushMetrics(ctx context.Context, destination string) error {
+	data, err := json.Marshal(MetricsFlush{
+		FlushedAt: h.formatTime(time.Now()),
+		Build:     buildInfo(),
+		Metrics:   h.metricsSnapshot(),
+	})
+	if err != nil {
+		return fmt.Errorf("encode metrics: %w", err)
+	}
+
+	if strings.HasPrefix(destination, "http://") || strings.HasPrefix(destination, "https://") {
+		return postMetrics(ctx, destination, data)
+	}
+	return writeMetricsFile(destination, data)
+}
+
+// postMetrics POSTs data to url, failing on any non-2xx response.
+func postMetrics(ctx context.Context, url string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("post metrics: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("post metrics: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxBodySize))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("post metrics: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// writeMetricsFile writes data to path through a temporary file in the same directory,
+// so a reader never sees a half-written snapshot.
+func writeMetricsFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write metrics: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("write metrics: %w", err)
+	}
+	return nil
+}
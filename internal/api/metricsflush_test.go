@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+func newFlushTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	cfg := &config.Config{
+		CacheTTL:       time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	t.Cleanup(func() { cacheStore.Close() })
+
+	handler := NewHandlerWithFetcher(cacheStore, newFakeFetcher(map[string]string{"example.com": "google.com, pub-1, DIRECT\n"}), cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	t.Cleanup(handler.Close)
+
+	// One miss, then one hit
+	for i := 0; i < 2; i++ {
+		handler.AnalyzeSingle(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/analyze?domain=example.com", nil))
+	}
+	return handler
+}
+
+func TestHandler_FlushMetrics_File(t *testing.T) {
+	handler := newFlushTestHandler(t)
+	path := filepath.Join(t.TempDir(), "metrics.json")
+
+	// An earlier snapshot is replaced
+	if err := os.WriteFile(path, []byte(`{"stale": true}`), 0644); err != nil {
+		t.Fatalf("Failed to write earlier snapshot: %v", err)
+	}
+
+	if err := handler.FlushMetrics(context.Background(), path); err != nil {
+		t.Fatalf("FlushMetrics() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read snapshot: %v", err)
+	}
+	var flushed MetricsFlush
+	if err := json.Unmarshal(data, &flushed); err != nil {
+		t.Fatalf("Failed to decode snapshot: %v", err)
+	}
+	if flushed.Metrics.RequestsTotal != 2 || flushed.Metrics.CacheHits != 1 || flushed.Metrics.CacheMisses != 1 {
+		t.Errorf("metrics = %+v, want 2 requests, 1 hit and 1 miss", flushed.Metrics)
+	}
+	if flushed.FlushedAt == "" || flushed.Build.Version == "" {
+		t.Errorf("snapshot = %+v, want flushed_at and build info", flushed)
+	}
+
+	// No temporary files are left behind
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("directory has %d entries, want only the snapshot", len(entries))
+	}
+}
+
+func TestHandler_FlushMetrics_URL(t *testing.T) {
+	handler := newFlushTestHandler(t)
+
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "accepted", status: http.StatusNoContent},
+		{name: "rejected", status: http.StatusInternalServerError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, contentType string
+			var flushed MetricsFlush
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method, contentType = r.Method, r.Header.Get("Content-Type")
+				body, _ := io.ReadAll(r.Body)
+				_ = json.Unmarshal(body, &flushed)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := handler.FlushMetrics(context.Background(), server.URL+"/flush")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FlushMetrics() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if method != http.MethodPost || contentType != "application/json" {
+				t.Errorf("request = %s with Content-Type %q, want a JSON POST", method, contentType)
+			}
+			if flushed.Metrics.RequestsTotal != 2 {
+				t.Errorf("posted metrics = %+v, want 2 requests", flushed.Metrics)
+			}
+		})
+	}
+}
@@ -1,19 +1,27 @@
 package api
 
 import (
+	"crypto/subtle"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"adstxt-api/internal/ratelimit"
+	"adstxt-api/internal/tracing"
 )
 
-// responseWriter wraps http.ResponseWriter to capture the status code for logging.
+// responseWriter wraps http.ResponseWriter to capture the status code and body size for logging.
 // This allows middleware to log the response status without interfering with the handler.
 type responseWriter struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode   int
+	bytesWritten int64
 }
 
 // WriteHeader captures the status code and delegates to the underlying ResponseWriter.
@@ -22,70 +30,314 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Write counts the body bytes written and delegates to the underlying ResponseWriter.
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytesWritten += int64(n)
+	return n, err
+}
+
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController, so streaming
+// handlers can flush and extend write deadlines through this wrapper.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// countingBody wraps a request body to count the bytes the handler actually reads.
+type countingBody struct {
+	io.ReadCloser
+	bytesRead int64
+}
+
+func (cb *countingBody) Read(p []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(p)
+	cb.bytesRead += int64(n)
+	return n, err
+}
+
+// TracingMiddleware starts an OpenTelemetry span per request, continuing the caller's trace
+// from a W3C traceparent header or starting a new one, and stores it in the request context.
+// Logs written with that context then carry trace_id and span_id, and outbound fetches pass
+// the trace on. Spans come from the global tracer provider, so until one is installed this
+// records nothing. When disabled, requests pass through untouched.
+func TracingMiddleware(enabled bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !enabled {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := tracing.StartServer(r)
+			rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			defer func() { tracing.End(span, rw.statusCode, nil) }()
+			next.ServeHTTP(rw, r.WithContext(ctx))
+		})
+	}
+}
+
 // LoggingMiddleware logs all HTTP requests and responses with structured logging.
 // It logs the request method, path, and remote address when the request starts,
 // and logs the status code and duration when the request completes.
+// If metrics is non-nil, the response status code and the request/response body bytes
+// are also recorded for /metrics, and slow requests for /api/slowlog.
 // Uses slog for structured JSON logging with contextual fields.
-func LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		wrapped := &responseWriter{ResponseWriter: w, statusCode: 200}
+func LoggingMiddleware(metrics *Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			wrapped := &responseWriter{ResponseWriter: w, statusCode: 200}
+			body := &countingBody{ReadCloser: r.Body}
+			r.Body = body
+
+			slog.InfoContext(r.Context(), "incoming request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("remote_addr", r.RemoteAddr))
 
-		slog.Info("incoming request",
-			slog.String("method", r.Method),
-			slog.String("path", r.URL.Path),
-			slog.String("remote_addr", r.RemoteAddr))
+			next.ServeHTTP(wrapped, r)
+			duration := time.Since(start)
 
-		next.ServeHTTP(wrapped, r)
+			if metrics != nil {
+				metrics.recordStatus(wrapped.statusCode)
+				metrics.recordBytes(body.bytesRead, wrapped.bytesWritten)
+				metrics.recordRequest(r.Method, r.URL.Path, wrapped.statusCode, start, duration)
+			}
+
+			slog.InfoContext(r.Context(), "request completed",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", wrapped.statusCode),
+				slog.Duration("duration", duration))
+		})
+	}
+}
 
-		slog.Info("request completed",
-			slog.String("method", r.Method),
-			slog.String("path", r.URL.Path),
-			slog.Int("status", wrapped.statusCode),
-			slog.Duration("duration", time.Since(start)))
-	})
+// clientIP returns the IP address of the client, i.e. r.RemoteAddr without the port.
+// IPv6 addresses ("[::1]:12345") are returned whole and without brackets. A RemoteAddr
+// that has no port is used as is rather than truncated at its last colon.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
 }
 
 // RateLimitMiddleware creates a middleware that enforces rate limiting per client IP.
 // It uses the provided RateLimiter to track and limit requests from each remote address.
 // If a client exceeds the rate limit, a 429 Too Many Requests response is returned.
 // The middleware extracts only the IP address from r.RemoteAddr (strips the port).
-func RateLimitMiddleware(limiter *ratelimit.RateLimiter) func(http.Handler) http.Handler {
+// If metrics is non-nil, each rejection is counted as rate_limited_total in /metrics.
+func RateLimitMiddleware(limiter *ratelimit.RateLimiter, metrics *Metrics) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Extract IP without port (r.RemoteAddr format: "IP:port")
-			clientIP := r.RemoteAddr
-			if colonIndex := strings.LastIndex(clientIP, ":"); colonIndex != -1 {
-				clientIP = clientIP[:colonIndex]
+			if !limiter.Allow(clientIP(r)) {
+				if metrics != nil {
+					metrics.recordRateLimited()
+				}
+				writeError(w, r, http.StatusTooManyRequests, "Rate limit exceeded", CodeRateLimited, "Too many requests. Please try again later.")
+				return
 			}
 
-			if !limiter.Allow(clientIP) {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusTooManyRequests)
-				_, _ = w.Write([]byte(`{"error":"Rate limit exceeded","message":"Too many requests. Please try again later."}`))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientConcurrencyMiddleware caps the number of requests each client IP may have in flight
+// at once, so a single client cannot tie up the server with many slow requests; the rate
+// limiter alone only bounds how fast requests start. A client at its limit is rejected
+// immediately with 429 Too Many Requests and a Retry-After header. Counts are released when
+// each request finishes and clients with nothing in flight are forgotten, so the map stays bounded.
+// Requests to exempt paths bypass the limit. A limit of 0 disables it.
+// If metrics is non-nil, each rejection is counted as rate_limited_total in /metrics.
+func ClientConcurrencyMiddleware(limit int, metrics *Metrics, exempt ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+
+		var mu sync.Mutex
+		inflight := make(map[string]int)
+		exemptPaths := make(map[string]bool, len(exempt))
+		for _, path := range exempt {
+			exemptPaths[path] = true
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exemptPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
 				return
 			}
 
+			client := clientIP(r)
+			mu.Lock()
+			if inflight[client] >= limit {
+				mu.Unlock()
+				if metrics != nil {
+					metrics.recordRateLimited()
+				}
+				w.Header().Set("Retry-After", "1")
+				writeError(w, r, http.StatusTooManyRequests, "Too Many Requests", CodeClientBusy, "Too many concurrent requests from this client. Please try again later.")
+				return
+			}
+			inflight[client]++
+			mu.Unlock()
+
+			defer func() {
+				mu.Lock()
+				if inflight[client]--; inflight[client] == 0 {
+					delete(inflight, client)
+				}
+				mu.Unlock()
+			}()
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// Default CORS values used when no methods or headers are configured.
+var (
+	defaultCORSMethods = []string{"GET", "POST", "OPTIONS"}
+	defaultCORSHeaders = []string{"Content-Type"}
+)
+
 // CORSMiddleware adds Cross-Origin Resource Sharing (CORS) headers to all responses.
-// It allows requests from any origin (*) with common HTTP methods and headers.
-// Pre-flight OPTIONS requests are handled automatically and return 200 OK.
+// It allows requests from any origin (*) with the given methods and headers; empty
+// lists fall back to GET, POST, OPTIONS and Content-Type.
+// Pre-flight OPTIONS requests are handled automatically and return 200 OK. When maxAge
+// is positive they also carry Access-Control-Max-Age so browsers can cache the preflight.
 // This enables the API to be called from web browsers running on different domains.
-func CORSMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
+func CORSMiddleware(methods, headers []string, maxAge time.Duration) func(http.Handler) http.Handler {
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(headers, ", ")
+	maxAgeSeconds := strconv.Itoa(int(maxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+
+			if r.Method == http.MethodOptions {
+				if maxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", maxAgeSeconds)
+				}
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// negotiatedHeaders are the request headers any response may depend on: Accept selects the
+// versioned envelope (see wantsEnvelope) and Accept-Language the language of error messages.
+var negotiatedHeaders = []string{"Accept", "Accept-Language"}
+
+// VaryMiddleware lists the request headers responses are negotiated on in Vary, so shared
+// caches and CDNs store a separate copy per representation instead of serving, say, an
+// enveloped or Spanish response to a client that asked for neither. fields are listed along
+// with Accept and Accept-Language; CompressionMiddleware adds Accept-Encoding itself when enabled.
+func VaryMiddleware(fields []string) func(http.Handler) http.Handler {
+	fields = append(slices.Clone(negotiatedHeaders), fields...)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addVary(w.Header(), fields...)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// addVary merges fields into header's Vary as one sorted list without duplicates, so each
+// middleware can add the request headers it negotiates on. A Vary of "*" is left as is.
+func addVary(header http.Header, fields ...string) {
+	seen := make(map[string]bool)
+	var merged []string
+	for _, value := range append(header.Values("Vary"), fields...) {
+		for _, field := range strings.Split(value, ",") {
+			field = http.CanonicalHeaderKey(strings.TrimSpace(field))
+			switch {
+			case field == "*":
+				header.Set("Vary", "*")
+				return
+			case field == "" || seen[field]:
+				continue
+			}
+			seen[field] = true
+			merged = append(merged, field)
 		}
+	}
+	if len(merged) == 0 {
+		return
+	}
+	slices.Sort(merged)
+	header.Set("Vary", strings.Join(merged, ", "))
+}
 
-		next.ServeHTTP(w, r)
-	})
+// AdminAuthMiddleware restricts access to admin endpoints using a static bearer token.
+// Requests must send "Authorization: Bearer <token>"; the scheme is matched case-insensitively
+// and a bare token without it is refused. If token is empty, admin endpoints are disabled
+// entirely and every request receives 403 Forbidden.
+// Tokens are compared in constant time to avoid leaking them through timing.
+func AdminAuthMiddleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				writeError(w, r, http.StatusForbidden, "Forbidden", CodeAdminDisabled, "Admin endpoints are disabled.")
+				return
+			}
+
+			scheme, provided, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, r, http.StatusUnauthorized, "Unauthorized", CodeUnauthorized, "Missing or invalid admin token.")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MaxInflightMiddleware caps the number of requests being served concurrently.
+// A buffered channel acts as a semaphore: a slot is taken when a request starts and
+// released when it finishes. When no slot is free the request is rejected immediately
+// with 503 Service Unavailable and a Retry-After header instead of queueing.
+// Requests to exempt paths (e.g. health probes) bypass the limit. A limit of 0 disables it.
+func MaxInflightMiddleware(limit int, exempt ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+
+		sem := make(chan struct{}, limit)
+		exemptPaths := make(map[string]bool, len(exempt))
+		for _, path := range exempt {
+			exemptPaths[path] = true
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exemptPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				next.ServeHTTP(w, r)
+			default:
+				w.Header().Set("Retry-After", "1")
+				writeError(w, r, http.StatusServiceUnavailable, "Service Unavailable", CodeServerBusy, "Too many concurrent requests. Please try again later.")
+			}
+		})
+	}
 }
@@ -2,15 +2,25 @@ package api
 
 import (
 	"bytes"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
 	"adstxt-api/internal/ratelimit"
+	"adstxt-api/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 // TestLoggingMiddleware tests that requests and responses are logged
@@ -29,7 +39,7 @@ func TestLoggingMiddleware(t *testing.T) {
 	})
 
 	// Wrap with logging middleware
-	middleware := LoggingMiddleware(handler)
+	middleware := LoggingMiddleware(nil)(handler)
 
 	// Make a request
 	req := httptest.NewRequest("GET", "/test-path", nil)
@@ -79,7 +89,7 @@ func TestLoggingMiddleware_StatusCode(t *testing.T) {
 				w.WriteHeader(tt.statusCode)
 			})
 
-			middleware := LoggingMiddleware(handler)
+			middleware := LoggingMiddleware(nil)(handler)
 
 			req := httptest.NewRequest("GET", "/test", nil)
 			w := httptest.NewRecorder()
@@ -112,7 +122,7 @@ func TestLoggingMiddleware_Duration(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	middleware := LoggingMiddleware(handler)
+	middleware := LoggingMiddleware(nil)(handler)
 
 	req := httptest.NewRequest("GET", "/test", nil)
 	w := httptest.NewRecorder()
@@ -125,6 +135,30 @@ func TestLoggingMiddleware_Duration(t *testing.T) {
 	}
 }
 
+// TestLoggingMiddleware_StatusCounts tests that response status codes are recorded in metrics
+func TestLoggingMiddleware_StatusCounts(t *testing.T) {
+	metrics := &Metrics{}
+
+	status := http.StatusOK
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+	middleware := LoggingMiddleware(metrics)(handler)
+
+	for _, code := range []int{http.StatusOK, http.StatusOK, http.StatusNotFound} {
+		status = code
+		req := httptest.NewRequest("GET", "/test", nil)
+		middleware.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if metrics.statusCounts[http.StatusOK] != 2 {
+		t.Errorf("Expected 2 responses with status 200, got %d", metrics.statusCounts[http.StatusOK])
+	}
+	if metrics.statusCounts[http.StatusNotFound] != 1 {
+		t.Errorf("Expected 1 response with status 404, got %d", metrics.statusCounts[http.StatusNotFound])
+	}
+}
+
 // TestRateLimitMiddleware tests rate limiting functionality
 func TestRateLimitMiddleware(t *testing.T) {
 	limiter := ratelimit.NewRateLimiter(2) // 2 requests per second
@@ -135,7 +169,7 @@ func TestRateLimitMiddleware(t *testing.T) {
 		_, _ = w.Write([]byte("success"))
 	})
 
-	middleware := RateLimitMiddleware(limiter)(handler)
+	middleware := RateLimitMiddleware(limiter, nil)(handler)
 
 	// First 2 requests should succeed
 	for i := 0; i < 2; i++ {
@@ -165,6 +199,32 @@ func TestRateLimitMiddleware(t *testing.T) {
 	if !strings.Contains(body, "Rate limit exceeded") && !strings.Contains(body, "rate limit exceeded") {
 		t.Errorf("Expected rate limit message, got: %s", body)
 	}
+	if !strings.Contains(body, `"code":"RATE_LIMITED"`) {
+		t.Errorf("Expected RATE_LIMITED code, got: %s", body)
+	}
+}
+
+// TestRateLimitMiddleware_CountsRejections tests that 429 responses are counted in metrics
+func TestRateLimitMiddleware_CountsRejections(t *testing.T) {
+	limiter := ratelimit.NewRateLimiter(1)
+	defer limiter.Stop()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	metrics := &Metrics{}
+	middleware := RateLimitMiddleware(limiter, metrics)(handler)
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+		middleware.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if metrics.rateLimited != 2 {
+		t.Errorf("Expected 2 rate-limited requests, got %d", metrics.rateLimited)
+	}
 }
 
 // TestRateLimitMiddleware_DifferentClients tests that different clients have separate limits
@@ -176,7 +236,7 @@ func TestRateLimitMiddleware_DifferentClients(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	middleware := RateLimitMiddleware(limiter)(handler)
+	middleware := RateLimitMiddleware(limiter, nil)(handler)
 
 	// Client 1 makes 2 requests
 	for i := 0; i < 2; i++ {
@@ -214,6 +274,66 @@ func TestRateLimitMiddleware_DifferentClients(t *testing.T) {
 	}
 }
 
+// TestRateLimitMiddleware_IPv6 tests that IPv6 clients are keyed by their full address
+func TestRateLimitMiddleware_IPv6(t *testing.T) {
+	limiter := ratelimit.NewRateLimiter(1) // 1 request per second
+	defer limiter.Stop()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	middleware := RateLimitMiddleware(limiter, nil)(handler)
+
+	// Clients sharing every group but the last would collide if the address were truncated
+	clients := []string{"[2001:db8::1]:12345", "[2001:db8::2]:12345"}
+	for _, remoteAddr := range clients {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+
+		middleware.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Client %s: expected status 200, got %d", remoteAddr, w.Code)
+		}
+	}
+
+	// Another connection from the first client shares its bucket
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.RemoteAddr = "[2001:db8::1]:54321"
+	w := httptest.NewRecorder()
+
+	middleware.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Client %s: expected status 429, got %d", req.RemoteAddr, w.Code)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		want       string
+	}{
+		{"127.0.0.1:12345", "127.0.0.1"},
+		{"[::1]:12345", "::1"},
+		{"[2001:db8::1]:443", "2001:db8::1"},
+		{"2001:db8::1", "2001:db8::1"},
+		{"127.0.0.1", "127.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.remoteAddr, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if got := clientIP(req); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 // TestRateLimitMiddleware_Reset tests that rate limits reset after time window
 func TestRateLimitMiddleware_Reset(t *testing.T) {
 	limiter := ratelimit.NewRateLimiter(1) // 1 request per second
@@ -223,7 +343,7 @@ func TestRateLimitMiddleware_Reset(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	middleware := RateLimitMiddleware(limiter)(handler)
+	middleware := RateLimitMiddleware(limiter, nil)(handler)
 
 	// First request should succeed
 	req := httptest.NewRequest("GET", "/test", nil)
@@ -269,7 +389,7 @@ func TestCORSMiddleware(t *testing.T) {
 		_, _ = w.Write([]byte("test"))
 	})
 
-	middleware := CORSMiddleware(handler)
+	middleware := CORSMiddleware(nil, nil, 0)(handler)
 
 	req := httptest.NewRequest("GET", "/test", nil)
 	w := httptest.NewRecorder()
@@ -302,7 +422,7 @@ func TestCORSMiddleware_OptionsRequest(t *testing.T) {
 		t.Error("Handler should not be called for OPTIONS request")
 	})
 
-	middleware := CORSMiddleware(handler)
+	middleware := CORSMiddleware(nil, nil, 0)(handler)
 
 	req := httptest.NewRequest("OPTIONS", "/test", nil)
 	w := httptest.NewRecorder()
@@ -320,6 +440,29 @@ func TestCORSMiddleware_OptionsRequest(t *testing.T) {
 	}
 }
 
+// TestLoggingMiddleware_ByteCounts tests that request and response body sizes are recorded in metrics
+func TestLoggingMiddleware_ByteCounts(t *testing.T) {
+	metrics := &Metrics{}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		_, _ = w.Write([]byte("hello"))
+	})
+	middleware := LoggingMiddleware(metrics)(handler)
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/test", strings.NewReader(`{"domains":[]}`))
+		middleware.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if metrics.bytesIn != 28 {
+		t.Errorf("Expected 28 bytes in, got %d", metrics.bytesIn)
+	}
+	if metrics.bytesOut != 10 {
+		t.Errorf("Expected 10 bytes out, got %d", metrics.bytesOut)
+	}
+}
+
 // TestResponseWriter_WriteHeader tests the custom responseWriter
 func TestResponseWriter_WriteHeader(t *testing.T) {
 	recorder := httptest.NewRecorder()
@@ -373,9 +516,9 @@ func TestMiddlewareChain(t *testing.T) {
 	})
 
 	// Chain middleware: CORS -> RateLimit -> Logging
-	middleware := CORSMiddleware(
-		RateLimitMiddleware(limiter)(
-			LoggingMiddleware(handler),
+	middleware := CORSMiddleware(nil, nil, 0)(
+		RateLimitMiddleware(limiter, nil)(
+			LoggingMiddleware(nil)(handler),
 		),
 	)
 
@@ -416,9 +559,9 @@ func TestMiddlewareChain_RateLimited(t *testing.T) {
 		_, _ = w.Write([]byte("success"))
 	})
 
-	middleware := CORSMiddleware(
-		RateLimitMiddleware(limiter)(
-			LoggingMiddleware(handler),
+	middleware := CORSMiddleware(nil, nil, 0)(
+		RateLimitMiddleware(limiter, nil)(
+			LoggingMiddleware(nil)(handler),
 		),
 	)
 
@@ -450,6 +593,41 @@ func TestMiddlewareChain_RateLimited(t *testing.T) {
 	}
 }
 
+// TestCORSMiddleware_Configured tests custom methods, headers and preflight max age
+func TestCORSMiddleware_Configured(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	middleware := CORSMiddleware([]string{"GET", "DELETE"}, []string{"Content-Type", "X-API-Key"}, 24*time.Hour)(handler)
+
+	req := httptest.NewRequest("OPTIONS", "/test", nil)
+	w := httptest.NewRecorder()
+
+	middleware.ServeHTTP(w, req)
+
+	headers := w.Header()
+	if got := headers.Get("Access-Control-Allow-Methods"); got != "GET, DELETE" {
+		t.Errorf("Expected Access-Control-Allow-Methods: GET, DELETE, got: %s", got)
+	}
+	if got := headers.Get("Access-Control-Allow-Headers"); got != "Content-Type, X-API-Key" {
+		t.Errorf("Expected Access-Control-Allow-Headers: Content-Type, X-API-Key, got: %s", got)
+	}
+	if got := headers.Get("Access-Control-Max-Age"); got != "86400" {
+		t.Errorf("Expected Access-Control-Max-Age: 86400, got: %s", got)
+	}
+
+	// Max age only applies to preflight responses
+	req = httptest.NewRequest("GET", "/test", nil)
+	w = httptest.NewRecorder()
+
+	middleware.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Access-Control-Max-Age"); got != "" {
+		t.Errorf("Expected no Access-Control-Max-Age on GET, got: %s", got)
+	}
+}
+
 // TestCORSMiddleware_AllMethods tests that all allowed methods get CORS headers
 func TestCORSMiddleware_AllMethods(t *testing.T) {
 	methods := []string{"GET", "POST", "OPTIONS"}
@@ -460,7 +638,7 @@ func TestCORSMiddleware_AllMethods(t *testing.T) {
 				w.WriteHeader(http.StatusOK)
 			})
 
-			middleware := CORSMiddleware(handler)
+			middleware := CORSMiddleware(nil, nil, 0)(handler)
 
 			req := httptest.NewRequest(method, "/test", nil)
 			w := httptest.NewRecorder()
@@ -487,7 +665,7 @@ func TestLoggingMiddleware_MultiplePaths(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	middleware := LoggingMiddleware(handler)
+	middleware := LoggingMiddleware(nil)(handler)
 
 	paths := []string{"/api/analyze", "/health", "/metrics"}
 
@@ -515,7 +693,7 @@ func TestRateLimitMiddleware_HighLoad(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	middleware := RateLimitMiddleware(limiter)(handler)
+	middleware := RateLimitMiddleware(limiter, nil)(handler)
 
 	// Make 100 concurrent requests (should all succeed)
 	type result struct {
@@ -548,3 +726,301 @@ func TestRateLimitMiddleware_HighLoad(t *testing.T) {
 		t.Errorf("Expected 100 successful requests, got %d", successCount)
 	}
 }
+
+// TestAdminAuthMiddleware tests bearer token checks for admin endpoints
+func TestAdminAuthMiddleware(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		token      string
+		authHeader string
+		want       int
+	}{
+		{"disabled without token", "", "Bearer anything", http.StatusForbidden},
+		{"missing header", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer wrong", http.StatusUnauthorized},
+		{"valid token", "secret", "Bearer secret", http.StatusOK},
+		{"scheme is case-insensitive", "secret", "bearer secret", http.StatusOK},
+		{"token without scheme", "secret", "secret", http.StatusUnauthorized},
+		{"other scheme", "secret", "Basic secret", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			middleware := AdminAuthMiddleware(tt.token)(handler)
+
+			req := httptest.NewRequest("POST", "/api/cache/flush", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+			w := httptest.NewRecorder()
+
+			middleware.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+// TestMaxInflightMiddleware tests that concurrent requests beyond the limit get 503
+func TestMaxInflightMiddleware(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+	middleware := MaxInflightMiddleware(1, "/health")(handler)
+
+	// Occupy the only slot
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		middleware.ServeHTTP(w, httptest.NewRequest("GET", "/api/analyze", nil))
+		done <- w.Code
+	}()
+	<-started
+
+	// Second request is rejected immediately
+	w := httptest.NewRecorder()
+	middleware.ServeHTTP(w, httptest.NewRequest("GET", "/api/analyze", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header on 503")
+	}
+
+	// Exempt path still gets through
+	go func() {
+		w := httptest.NewRecorder()
+		middleware.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+		done <- w.Code
+	}()
+	<-started
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if code := <-done; code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", code)
+		}
+	}
+
+	// Slot is released after completion
+	w = httptest.NewRecorder()
+	middleware.ServeHTTP(w, httptest.NewRequest("GET", "/api/analyze", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 after slot release, got %d", w.Code)
+	}
+}
+
+// TestMaxInflightMiddleware_Disabled tests that a zero limit passes requests through
+func TestMaxInflightMiddleware_Disabled(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	middleware := MaxInflightMiddleware(0)(handler)
+
+	w := httptest.NewRecorder()
+	middleware.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+// TestClientConcurrencyMiddleware tests that a client beyond its concurrent limit gets 429
+// while other clients and exempt paths are unaffected
+func TestClientConcurrencyMiddleware(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 3)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+	metrics := &Metrics{}
+	middleware := ClientConcurrencyMiddleware(1, metrics, "/health")(handler)
+
+	request := func(path, remoteAddr string) *http.Request {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remoteAddr
+		return req
+	}
+
+	// Occupy the first client's only slot
+	done := make(chan int)
+	go func() {
+		w := httptest.NewRecorder()
+		middleware.ServeHTTP(w, request("/api/analyze", "10.0.0.1:1111"))
+		done <- w.Code
+	}()
+	<-started
+
+	// Same client from another port is rejected immediately
+	w := httptest.NewRecorder()
+	middleware.ServeHTTP(w, request("/api/analyze", "10.0.0.1:2222"))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header on 429")
+	}
+	if !strings.Contains(w.Body.String(), CodeClientBusy) {
+		t.Errorf("Expected %s code, got %s", CodeClientBusy, w.Body.String())
+	}
+	if metrics.rateLimited != 1 {
+		t.Errorf("Expected 1 rejected request, got %d", metrics.rateLimited)
+	}
+
+	// Another client and the exempt path still get through
+	go func() {
+		w := httptest.NewRecorder()
+		middleware.ServeHTTP(w, request("/api/analyze", "10.0.0.2:1111"))
+		done <- w.Code
+	}()
+	go func() {
+		w := httptest.NewRecorder()
+		middleware.ServeHTTP(w, request("/health", "10.0.0.1:3333"))
+		done <- w.Code
+	}()
+	<-started
+	<-started
+
+	close(release)
+	for i := 0; i < 3; i++ {
+		if code := <-done; code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", code)
+		}
+	}
+
+	// The slot is released after completion
+	w = httptest.NewRecorder()
+	middleware.ServeHTTP(w, request("/api/analyze", "10.0.0.1:4444"))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 after slot release, got %d", w.Code)
+	}
+}
+
+// TestClientConcurrencyMiddleware_Disabled tests that a zero limit passes requests through
+func TestClientConcurrencyMiddleware_Disabled(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	middleware := ClientConcurrencyMiddleware(0, nil)(handler)
+
+	w := httptest.NewRecorder()
+	middleware.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(tracing.NewLogHandler(slog.NewJSONHandler(&buf, nil))))
+	defer slog.SetDefault(previous)
+
+	var span trace.SpanContext
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span = trace.SpanContextFromContext(r.Context())
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := TracingMiddleware(true)(LoggingMiddleware(nil)(inner))
+
+	const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest("GET", "/api/analyze", nil)
+	req.Header.Set(tracing.Header, incoming)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !span.IsValid() {
+		t.Fatal("Expected a span in the request context")
+	}
+	if got := span.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the caller's trace ID, got %s", got)
+	}
+	if got := span.SpanID().String(); got == "00f067aa0ba902b7" {
+		t.Error("Expected a child span, got the caller's span ID")
+	}
+	logs := buf.String()
+	if !strings.Contains(logs, `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`) || !strings.Contains(logs, `"span_id":"`+span.SpanID().String()+`"`) {
+		t.Errorf("Expected request logs to carry trace_id and span_id, got: %s", logs)
+	}
+	if ended := recorder.Ended(); len(ended) != 1 || ended[0].SpanContext().SpanID() != span.SpanID() {
+		t.Fatalf("Expected the request span to be ended, got %d spans", len(ended))
+	}
+	if attrs := recorder.Ended()[0].Attributes(); !slices.Contains(attrs, attribute.Int("http.response.status_code", http.StatusTeapot)) {
+		t.Errorf("Expected the response status on the span, got %v", attrs)
+	}
+
+	// Without a traceparent a new trace is started
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/analyze", nil))
+	if !span.IsValid() || span.TraceID().String() == "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected a new trace, got %v", span.TraceID())
+	}
+}
+
+func TestTracingMiddleware_Disabled(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	var hasSpan bool
+	handler := TracingMiddleware(false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hasSpan = trace.SpanContextFromContext(r.Context()).IsValid()
+	}))
+
+	req := httptest.NewRequest("GET", "/api/analyze", nil)
+	req.Header.Set(tracing.Header, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if hasSpan || len(recorder.Ended()) != 0 {
+		t.Error("Expected no span when tracing is disabled")
+	}
+}
+
+func TestVaryMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   []string
+		existing string // Vary already set by an outer middleware
+		want     string
+	}{
+		{name: "negotiated headers", want: "Accept, Accept-Language"},
+		{name: "extra headers", fields: []string{"x-tenant", "Origin"}, want: "Accept, Accept-Language, Origin, X-Tenant"},
+		{name: "merged without duplicates", existing: "accept-language, Cookie", want: "Accept, Accept-Language, Cookie"},
+		{name: "star kept", existing: "*", want: "*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := VaryMiddleware(tt.fields)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			w := httptest.NewRecorder()
+			if tt.existing != "" {
+				w.Header().Set("Vary", tt.existing)
+			}
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/analyze", nil))
+
+			if got := w.Header().Values("Vary"); len(got) != 1 || got[0] != tt.want {
+				t.Errorf("Vary = %q, want a single %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// batchStreamWriteGrace is how long past BATCH_STREAM_TIMEOUT the stream may keep writing,
+// so the summary line still goes out after the last domain times out.
+const batchStreamWriteGrace = 10 * time.Second
+
+// BatchStreamLine is one domain's outcome in a /api/batch-ndjson stream. Type is "result"
+// with Result set, or "error" with Error set.
+type BatchStreamLine struct {
+	Type   string                  `json:"type"`
+	Domain string                  `json:"domain"`
+	Result *SingleAnalysisResponse `json:"result,omitempty"`
+	Error  string                  `json:"error,omitempty"`
+}
+
+// BatchStreamSummary is the final line of a /api/batch-ndjson stream.
+type BatchStreamSummary struct {
+	Type            string  `json:"type"` // Always "summary"
+	Total           int     `json:"total"`
+	Succeeded       int     `json:"succeeded"`
+	Failed          int     `json:"failed"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// AnalyzeBatchStream analyzes up to BATCH_STREAM_MAX_DOMAINS domains and streams the outcomes
+// as newline-delimited JSON, one BatchStreamLine per domain as soon as it completes (cache hits
+// first), then a BatchStreamSummary. Each line is flushed straight away. Accepts the same body
+// and query flags as AnalyzeBatch; duplicate domains are analyzed once. Request errors found
+// before streaming starts get the usual JSON error response.
+func (h *Handler) AnalyzeBatchStream(w http.ResponseWriter, r *http.Request) {
+	h.metrics.mu.Lock()
+	h.metrics.requestsTotal++
+	h.metrics.mu.Unlock()
+
+	opts, ok := h.batchOptions(w, r)
+	if !ok {
+		return
+	}
+
+	req, ok := h.decodeBatchRequest(w, r, h.cfg.BatchStreamMaxDomains)
+	if !ok {
+		return
+	}
+
+	ctx, ok := h.withFastFetch(r.Context(), w, r)
+	if !ok {
+		return
+	}
+	if ctx, ok = h.withNoWWWFallback(ctx, w, r); !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.BatchStreamTimeout)
+	defer cancel()
+
+	// The server's WriteTimeout is sized for single responses, not a stream of this length
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(h.cfg.BatchStreamTimeout + batchStreamWriteGrace)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		h.logger.WarnContext(r.Context(), "failed to extend write deadline for batch stream", slog.String("error", err.Error()))
+	}
+
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	start := time.Now()
+	encoder := json.NewEncoder(w)
+	var writeErr error
+	write := func(line interface{}) {
+		if writeErr != nil {
+			return // The client is gone; keep draining results without writing
+		}
+		if writeErr = encoder.Encode(line); writeErr == nil {
+			writeErr = rc.Flush()
+		}
+		if writeErr != nil {
+			h.logger.WarnContext(r.Context(), "batch stream aborted", slog.String("error", writeErr.Error()))
+			cancel()
+		}
+	}
+
+	domains := dedupeDomains(req.Domains)
+	summary := BatchStreamSummary{Type: "summary", Total: len(domains)}
+	h.analyzeDomains(ctx, domains, req.fetchTimeouts, func(domain string, result *SingleAnalysisResponse, errMsg string) {
+		if result == nil {
+			summary.Failed++
+			write(BatchStreamLine{Type: "error", Domain: domain, Error: errMsg})
+			return
+		}
+		summary.Succeeded++
+		opts.applyTo(result)
+		write(BatchStreamLine{Type: "result", Domain: domain, Result: result})
+	})
+
+	summary.DurationSeconds = time.Since(start).Seconds()
+	write(summary)
+}
+
+// dedupeDomains returns domains without repeats, keeping the first occurrence of each.
+func dedupeDomains(domains []string) []string {
+	seen := make(map[string]bool, len(domains))
+	unique := make([]string, 0, len(domains))
+	for _, d := range domains {
+		if !seen[d] {
+			seen[d] = true
+			unique = append(unique, d)
+		}
+	}
+	return unique
+}
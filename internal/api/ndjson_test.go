@@ -0,0 +1,142 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+	"adstxt-api/internal/ratelimit"
+)
+
+func newBatchStreamTestRouter(t *testing.T, fetcher AdsTxtFetcher, maxDomains int) (*Handler, http.Handler) {
+	t.Helper()
+	cfg := &config.Config{
+		CacheTTL:              1 * time.Hour,
+		RequestTimeout:        10 * time.Second,
+		BatchStreamMaxDomains: maxDomains,
+		BatchStreamTimeout:    10 * time.Second,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	t.Cleanup(func() { cacheStore.Close() })
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	limiter := ratelimit.NewRateLimiter(100)
+	t.Cleanup(limiter.Stop)
+	return handler, NewRouter(handler, limiter)
+}
+
+// readBatchStream splits an NDJSON body into its per-domain lines and the summary line.
+func readBatchStream(t *testing.T, body *bytes.Buffer) ([]BatchStreamLine, BatchStreamSummary) {
+	t.Helper()
+	var lines []BatchStreamLine
+	var summary BatchStreamSummary
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		if summary.Type != "" {
+			t.Fatalf("Unexpected line after the summary: %s", scanner.Text())
+		}
+		if strings.Contains(scanner.Text(), `"type":"summary"`) {
+			if err := json.Unmarshal(scanner.Bytes(), &summary); err != nil {
+				t.Fatalf("Failed to decode summary line: %v", err)
+			}
+			continue
+		}
+		var line BatchStreamLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Failed to decode line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	if summary.Type == "" {
+		t.Fatal("Expected the stream to end with a summary line")
+	}
+	return lines, summary
+}
+
+func TestHandler_AnalyzeBatchStream(t *testing.T) {
+	content := make(map[string]string)
+	var domains []string
+	for i := 0; i < 60; i++ { // Over the 50-domain cap of /api/batch-analysis
+		d := fmt.Sprintf("pub%d.com", i)
+		content[d] = "google.com, pub-1, DIRECT\nopenx.com, 1, RESELLER"
+		domains = append(domains, d)
+	}
+	domains = append(domains, "missing.com", "not a domain", "pub0.com")
+	fetcher := newFakeFetcher(content)
+	_, router := newBatchStreamTestRouter(t, fetcher, 100)
+
+	body, _ := json.Marshal(BatchAnalysisRequest{Domains: domains})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/batch-ndjson", bytes.NewReader(body)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ndjsonContentType {
+		t.Errorf("Expected Content-Type %s, got %s", ndjsonContentType, ct)
+	}
+	if !w.Flushed {
+		t.Error("Expected lines to be flushed as they were written")
+	}
+
+	lines, summary := readBatchStream(t, w.Body)
+	if len(lines) != 62 {
+		t.Fatalf("Expected one line per unique domain (62), got %d", len(lines))
+	}
+	if summary.Total != 62 || summary.Succeeded != 60 || summary.Failed != 2 {
+		t.Errorf("Expected summary 62/60/2, got %+v", summary)
+	}
+
+	errs := make(map[string]string)
+	for _, line := range lines {
+		switch line.Type {
+		case "result":
+			if line.Result == nil || line.Result.TotalAdvertisers != 2 {
+				t.Errorf("Expected a 2-advertiser result for %s, got %+v", line.Domain, line.Result)
+			}
+		case "error":
+			errs[line.Domain] = line.Error
+		default:
+			t.Errorf("Unexpected line type %q", line.Type)
+		}
+	}
+	if !strings.HasPrefix(errs["not a domain"], "invalid domain") || errs["missing.com"] == "" {
+		t.Errorf("Expected errors for the invalid and missing domains, got %v", errs)
+	}
+	if fetcher.calls["pub0.com"] != 1 {
+		t.Errorf("Expected the duplicate domain to be fetched once, got %d", fetcher.calls["pub0.com"])
+	}
+}
+
+func TestHandler_AnalyzeBatchStream_Limits(t *testing.T) {
+	_, router := newBatchStreamTestRouter(t, newFakeFetcher(nil), 2)
+
+	body, _ := json.Marshal(BatchAnalysisRequest{Domains: []string{"a.com", "b.com", "c.com"}})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/batch-ndjson", bytes.NewReader(body)))
+
+	// Rejected before streaming starts, so it is a regular JSON error
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+	var errResp ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&errResp); err != nil || errResp.Code != CodeBatchTooLarge {
+		t.Errorf("Expected %s error, got %+v (%v)", CodeBatchTooLarge, errResp, err)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/batch-ndjson", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET, got %d", w.Code)
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+// negativeEntry is a fetch failure stored under negativeKeyFor so that known-bad
+// domains are not re-fetched on every request.
+type negativeEntry struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// cachedFetchError replays a negatively cached failure.
+// fetchErrorCode returns its stored code so clients see the same status as the original failure.
+type cachedFetchError struct {
+	code    string
+	message string
+}
+
+func (e *cachedFetchError) Error() string {
+	return e.message
+}
+
+// negativeKeyFor returns the cache key holding a domain's most recent fetch failure.
+func negativeKeyFor(domain string) string {
+	return fmt.Sprintf("adstxt:failed:%s", domain)
+}
+
+// cachedFailure returns the negatively cached fetch error for target, or nil if there is none
+// or negative caching is disabled (NEGATIVE_CACHE_TTL of 0).
+func (h *Handler) cachedFailure(target string) error {
+	if h.cfg.NegativeCacheTTL <= 0 {
+		return nil
+	}
+
+	data, err := h.cache.Get(negativeKeyFor(target))
+	if err != nil {
+		return nil
+	}
+
+	var entry negativeEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		h.logger.Warn("failed to unmarshal cached fetch failure",
+			slog.String("domain", target),
+			slog.String("error", err.Error()))
+		return nil
+	}
+	return &cachedFetchError{code: entry.Code, message: entry.Message}
+}
+
+// storeFailure caches fetchErr for target for NEGATIVE_CACHE_TTL, independent of the success TTL.
+func (h *Handler) storeFailure(target string, fetchErr error) {
+	if h.cfg.NegativeCacheTTL <= 0 {
+		return
+	}
+
+	data, err := json.Marshal(negativeEntry{Code: fetchErrorCode(fetchErr), Message: fetchErr.Error()})
+	if err != nil {
+		return
+	}
+	if err := h.cache.Set(negativeKeyFor(target), data, h.cfg.NegativeCacheTTL); err != nil {
+		h.logger.Warn("failed to cache fetch failure", slog.String("domain", target), slog.String("error", err.Error()))
+	}
+}
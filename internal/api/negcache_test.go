@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+func TestHandler_NegativeCache(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		wantCalls int
+	}{
+		{"enabled", 5 * time.Minute, 1},
+		{"disabled", 0, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				CacheTTL:         1 * time.Hour,
+				RequestTimeout:   10 * time.Second,
+				NegativeCacheTTL: tt.ttl,
+			}
+			cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+			defer cacheStore.Close()
+
+			fetcher := newFakeFetcher(nil)
+			handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+				handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=missing.com", nil))
+
+				var response ErrorResponse
+				_ = json.NewDecoder(w.Body).Decode(&response)
+				if response.Code != CodeFetchNotFound {
+					t.Errorf("Request %d: expected code %s, got %s", i+1, CodeFetchNotFound, response.Code)
+				}
+			}
+
+			if fetcher.calls["missing.com"] != tt.wantCalls {
+				t.Errorf("Expected %d fetches, got %d", tt.wantCalls, fetcher.calls["missing.com"])
+			}
+		})
+	}
+}
+
+func TestHandler_NegativeCache_Expires(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:         1 * time.Hour,
+		RequestTimeout:   10 * time.Second,
+		NegativeCacheTTL: 20 * time.Millisecond,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+
+	fetcher := newFakeFetcher(nil)
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	if _, err := handler.analyzeDomain(context.Background(), "missing.com"); err == nil {
+		t.Fatal("Expected fetch failure")
+	}
+
+	// The domain comes back before the negative entry expires, then is retried once it has
+	fetcher.mu.Lock()
+	fetcher.content = map[string]string{"missing.com": "google.com, pub-1, DIRECT"}
+	fetcher.mu.Unlock()
+
+	if _, err := handler.analyzeDomain(context.Background(), "missing.com"); err == nil {
+		t.Error("Expected cached failure before the negative TTL elapses")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := handler.analyzeDomain(context.Background(), "missing.com"); err != nil {
+		t.Errorf("Expected a fresh fetch after the negative TTL, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"adstxt-api/internal/adstxt"
+)
+
+// normalizeAdvertisers collapses advertisers listed under several subdomains of one vendor
+// (rubiconproject.com, fastlane.rubiconproject.com) into a single entry for the registrable
+// domain, summing their counts. Merged entries keep the raw count per listed domain in
+// Subdomains, which stripVerbose drops unless ?verbose=true. Like the other advertiser
+// transforms this runs at response time, so the cached analysis keeps the listed domains.
+func normalizeAdvertisers(result *SingleAnalysisResponse) {
+	index := make(map[string]int, len(result.Advertisers))
+	normalized := make([]adstxt.AdvertiserCount, 0, len(result.Advertisers))
+	for _, adv := range result.Advertisers {
+		domain := adstxt.RegistrableDomain(adv.Domain)
+		i, ok := index[domain]
+		if !ok {
+			index[domain] = len(normalized)
+			normalized = append(normalized, adstxt.AdvertiserCount{Domain: domain})
+			i = len(normalized) - 1
+		}
+
+		merged := &normalized[i]
+		merged.Count += adv.Count
+		merged.Direct += adv.Direct
+		merged.Reseller += adv.Reseller
+		merged.CertAuthorities = unionSorted(merged.CertAuthorities, adv.CertAuthorities)
+		merged.AccountGroups = sumCounts(merged.AccountGroups, adv.AccountGroups)
+		if merged.Subdomains == nil {
+			merged.Subdomains = make(map[string]int)
+		}
+		merged.Subdomains[adv.Domain] += adv.Count
+	}
+
+	// Entries that only ever had their own domain listed were not merged
+	for i, adv := range normalized {
+		if _, self := adv.Subdomains[adv.Domain]; self && len(adv.Subdomains) == 1 {
+			normalized[i].Subdomains = nil
+		}
+	}
+
+	sortAdvertisers(normalized)
+	result.Advertisers = normalized
+	result.TotalAdvertisers = len(normalized)
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/adstxt"
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+func TestNormalizeAdvertisers(t *testing.T) {
+	result := &SingleAnalysisResponse{
+		TotalAdvertisers: 4,
+		Advertisers: []adstxt.AdvertiserCount{
+			{Domain: "google.com", Count: 4, Direct: 4, CertAuthorities: []string{"f08c47fec0942fa0"}},
+			{Domain: "fastlane.rubiconproject.com", Count: 2, Reseller: 2, CertAuthorities: []string{"0bfd66d529a55807"}},
+			{Domain: "rubiconproject.com", Count: 2, Direct: 1, Reseller: 1},
+			{Domain: "ads.example.co.uk", Count: 1, Direct: 1},
+		},
+	}
+
+	normalizeAdvertisers(result)
+
+	want := []adstxt.AdvertiserCount{
+		{Domain: "google.com", Count: 4, Direct: 4, CertAuthorities: []string{"f08c47fec0942fa0"}},
+		{Domain: "rubiconproject.com", Count: 4, Direct: 1, Reseller: 3, CertAuthorities: []string{"0bfd66d529a55807"},
+			Subdomains: map[string]int{"fastlane.rubiconproject.com": 2, "rubiconproject.com": 2}},
+		{Domain: "example.co.uk", Count: 1, Direct: 1, Subdomains: map[string]int{"ads.example.co.uk": 1}},
+	}
+	if !reflect.DeepEqual(result.Advertisers, want) {
+		t.Errorf("Advertisers = %+v, want %+v", result.Advertisers, want)
+	}
+	if result.TotalAdvertisers != 3 {
+		t.Errorf("TotalAdvertisers = %d, want 3", result.TotalAdvertisers)
+	}
+}
+
+func TestHandler_AnalyzeSingle_NormalizeAdvertisers(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	fetcher := newFakeFetcher(map[string]string{"example.com": "rubiconproject.com, 1, DIRECT\nfastlane.rubiconproject.com, 2, RESELLER\ngoogle.com, pub-1, DIRECT"})
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	analyze := func(query string) SingleAnalysisResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=example.com"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("AnalyzeSingle(%q) status = %d: %s", query, w.Code, w.Body.String())
+		}
+		var result SingleAnalysisResponse
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	if result := analyze(""); result.TotalAdvertisers != 3 {
+		t.Errorf("Expected 3 listed advertisers by default, got %d", result.TotalAdvertisers)
+	}
+
+	result := analyze("&normalize_advertisers=true")
+	if result.TotalAdvertisers != 2 || result.Advertisers[0].Domain != "rubiconproject.com" || result.Advertisers[0].Count != 2 {
+		t.Fatalf("Expected subdomains collapsed under rubiconproject.com, got %+v", result.Advertisers)
+	}
+	if result.Advertisers[0].Subdomains != nil {
+		t.Errorf("Expected per-subdomain counts only in verbose mode, got %v", result.Advertisers[0].Subdomains)
+	}
+
+	result = analyze("&normalize_advertisers=true&verbose=true")
+	if want := map[string]int{"rubiconproject.com": 1, "fastlane.rubiconproject.com": 1}; !reflect.DeepEqual(result.Advertisers[0].Subdomains, want) {
+		t.Errorf("Subdomains = %v, want %v", result.Advertisers[0].Subdomains, want)
+	}
+
+	// Normalization is per request; the cached analysis keeps the listed domains
+	if result := analyze(""); result.TotalAdvertisers != 3 {
+		t.Errorf("Expected the cached analysis to be unaffected, got %d advertisers", result.TotalAdvertisers)
+	}
+	if fetcher.calls["example.com"] != 1 {
+		t.Errorf("Expected one fetch, got %d", fetcher.calls["example.com"])
+	}
+}
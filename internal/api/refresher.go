@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// refresher keeps the most requested domains warm by re-fetching them shortly before
+// their cache entries expire. Popularity is tracked with an LFU counter that is halved
+// every cycle, so domains that stop being requested age out and the map stays bounded.
+type refresher struct {
+	h       *Handler
+	mu      sync.Mutex
+	hits    map[string]int64     // Decaying request count per domain
+	fetched map[string]time.Time // When each domain's cached entry was fetched
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newRefresher(h *Handler) *refresher {
+	return &refresher{
+		h:       h,
+		hits:    make(map[string]int64),
+		fetched: make(map[string]time.Time),
+		done:    make(chan struct{}),
+	}
+}
+
+// recordHit counts a request for domain and remembers when its cached entry was fetched.
+// A zero fetchedAt (e.g. unparsable timestamp) leaves the previous fetch time untouched.
+func (r *refresher) recordHit(domain string, fetchedAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hits[domain]++
+	if !fetchedAt.IsZero() {
+		r.fetched[domain] = fetchedAt
+	}
+}
+
+// dueDomains returns the top K domains whose entries expire within the refresh-ahead window,
+// then decays all counters. Domains whose counter reaches zero are forgotten.
+func (r *refresher) dueDomains(now time.Time) []string {
+	cfg := r.h.cfg
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	domains := make([]string, 0, len(r.hits))
+	for domain := range r.hits {
+		domains = append(domains, domain)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if r.hits[domains[i]] == r.hits[domains[j]] {
+			return domains[i] < domains[j]
+		}
+		return r.hits[domains[i]] > r.hits[domains[j]]
+	})
+	if len(domains) > cfg.AutoRefreshTopK {
+		domains = domains[:cfg.AutoRefreshTopK]
+	}
+
+	due := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		fetchedAt, ok := r.fetched[domain]
+		if ok && now.Sub(fetchedAt) >= cfg.CacheTTL-cfg.AutoRefreshAhead {
+			due = append(due, domain)
+		}
+	}
+
+	for domain, count := range r.hits {
+		if count /= 2; count == 0 {
+			delete(r.hits, domain)
+			delete(r.fetched, domain)
+		} else {
+			r.hits[domain] = count
+		}
+	}
+
+	return due
+}
+
+// run refreshes due domains every AutoRefreshInterval until stop is called.
+// Fetches are spaced by 1/AutoRefreshRate seconds to bound background outbound traffic.
+func (r *refresher) run() {
+	defer func() {
+		if rec := recover(); rec != nil {
+			r.h.logger.Error("panic in refresher goroutine", slog.Any("panic", rec))
+		}
+	}()
+
+	interval := r.h.cfg.AutoRefreshInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	spacing := time.Second
+	if r.h.cfg.AutoRefreshRate > 0 {
+		spacing = time.Second / time.Duration(r.h.cfg.AutoRefreshRate)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		}
+
+		for i, domain := range r.dueDomains(time.Now()) {
+			if i > 0 {
+				select {
+				case <-r.done:
+					return
+				case <-time.After(spacing):
+				}
+			}
+			r.refresh(domain)
+		}
+	}
+}
+
+func (r *refresher) refresh(domain string) {
+	if _, err := r.h.coalescedFetchAndStore(context.Background(), domain, domain, false); err != nil {
+		r.h.logger.Warn("background refresh failed", slog.String("domain", domain), slog.String("error", err.Error()))
+		return
+	}
+
+	r.mu.Lock()
+	r.fetched[domain] = time.Now()
+	r.mu.Unlock()
+
+	r.h.logger.Debug("refreshed hot domain", slog.String("domain", domain))
+}
+
+// stop terminates the background goroutine. Safe to call more than once.
+func (r *refresher) stop() {
+	r.once.Do(func() { close(r.done) })
+}
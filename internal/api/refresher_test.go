@@ -0,0 +1,102 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+func TestRefresher_DueDomains(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:         1 * time.Hour,
+		AutoRefreshTopK:  2,
+		AutoRefreshAhead: 5 * time.Minute,
+	}
+	r := newRefresher(&Handler{cfg: cfg})
+
+	now := time.Now()
+	stale := now.Add(-58 * time.Minute) // Expires in 2 minutes, inside the refresh window
+	fresh := now.Add(-10 * time.Minute)
+
+	for i := 0; i < 5; i++ {
+		r.recordHit("hot.com", stale)
+	}
+	for i := 0; i < 3; i++ {
+		r.recordHit("warm.com", fresh)
+	}
+	r.recordHit("cold.com", stale) // Stale but not in the top 2
+
+	due := r.dueDomains(now)
+
+	if len(due) != 1 || due[0] != "hot.com" {
+		t.Errorf("Expected only hot.com to be due, got %v", due)
+	}
+
+	// Counters decay each cycle and single hits age out
+	if r.hits["hot.com"] != 2 {
+		t.Errorf("Expected hot.com count to halve to 2, got %d", r.hits["hot.com"])
+	}
+	if _, ok := r.hits["cold.com"]; ok {
+		t.Error("Expected cold.com to be forgotten after decay")
+	}
+}
+
+func TestRefresher_RefreshesHotDomain(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte("google.com, pub-1, DIRECT"))
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	cfg := &config.Config{
+		CacheTTL:            1 * time.Hour,
+		RequestTimeout:      5 * time.Second,
+		AutoRefreshTopK:     1,
+		AutoRefreshInterval: 20 * time.Millisecond,
+		AutoRefreshAhead:    1 * time.Hour, // Every tracked entry is due
+		AutoRefreshRate:     100,
+	}
+
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := mustNewHandler(t, cacheStore, cfg, logger)
+	defer handler.Close()
+
+	handler.refresher.recordHit(host, time.Now().Add(-time.Hour))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&requests) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if atomic.LoadInt32(&requests) == 0 {
+		t.Fatal("Expected refresher to re-fetch the hot domain")
+	}
+	if _, err := cacheStore.Get(cacheKeyFor(host)); err != nil {
+		t.Errorf("Expected refreshed result to be cached, got %v", err)
+	}
+}
+
+func TestHandler_Close_WithoutRefresher(t *testing.T) {
+	cfg := &config.Config{CacheTTL: 1 * time.Hour}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+
+	handler := mustNewHandler(t, cacheStore, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	// Close must be safe when no background workers were started
+	handler.Close()
+	handler.Close()
+}
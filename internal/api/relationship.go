@@ -0,0 +1,48 @@
+package api
+
+import (
+	"strings"
+
+	"adstxt-api/internal/adstxt"
+)
+
+// validRelationship reports whether value is an accepted ?relationship= filter.
+// An empty value is treated as "all".
+func validRelationship(value string) bool {
+	switch strings.ToLower(value) {
+	case "", "all", "direct", "reseller":
+		return true
+	}
+	return false
+}
+
+// filterRelationship restricts result to advertisers' DIRECT or RESELLER lines, so each
+// count reflects only that relationship. Advertisers with no matching lines are dropped.
+// "all" (or empty) leaves result untouched. Filtering happens at response time, so a single
+// cached analysis serves every filter. Unless sorted is false, the filtered advertisers are
+// re-sorted by their new counts; otherwise they keep the cached order by total count.
+func filterRelationship(result *SingleAnalysisResponse, relationship string, sorted bool) {
+	relationship = strings.ToLower(relationship)
+	if relationship != "direct" && relationship != "reseller" {
+		return
+	}
+
+	filtered := make([]adstxt.AdvertiserCount, 0, len(result.Advertisers))
+	for _, adv := range result.Advertisers {
+		count := adv.Direct
+		if relationship == "reseller" {
+			count = adv.Reseller
+		}
+		if count == 0 {
+			continue
+		}
+		adv.Count = count
+		filtered = append(filtered, adv)
+	}
+
+	if sorted {
+		sortAdvertisers(filtered)
+	}
+	result.Advertisers = filtered
+	result.TotalAdvertisers = len(filtered)
+}
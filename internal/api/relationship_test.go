@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/adstxt"
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+func TestFilterRelationship(t *testing.T) {
+	newResult := func() *SingleAnalysisResponse {
+		return &SingleAnalysisResponse{
+			TotalAdvertisers: 3,
+			Advertisers: []adstxt.AdvertiserCount{
+				{Domain: "google.com", Count: 5, Direct: 1, Reseller: 4},
+				{Domain: "appnexus.com", Count: 3, Direct: 3},
+				{Domain: "openx.com", Count: 1},
+			},
+		}
+	}
+
+	tests := []struct {
+		relationship string
+		sorted       bool
+		want         []adstxt.AdvertiserCount
+	}{
+		{"direct", true, []adstxt.AdvertiserCount{
+			{Domain: "appnexus.com", Count: 3, Direct: 3},
+			{Domain: "google.com", Count: 1, Direct: 1, Reseller: 4},
+		}},
+		{"RESELLER", true, []adstxt.AdvertiserCount{
+			{Domain: "google.com", Count: 4, Direct: 1, Reseller: 4},
+		}},
+		{"all", true, newResult().Advertisers},
+		{"", true, newResult().Advertisers},
+		// Unsorted keeps the cached order by total count
+		{"direct", false, []adstxt.AdvertiserCount{
+			{Domain: "google.com", Count: 1, Direct: 1, Reseller: 4},
+			{Domain: "appnexus.com", Count: 3, Direct: 3},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/sorted=%t", tt.relationship, tt.sorted), func(t *testing.T) {
+			result := newResult()
+			filterRelationship(result, tt.relationship, tt.sorted)
+
+			if result.TotalAdvertisers != len(tt.want) {
+				t.Errorf("TotalAdvertisers = %d, want %d", result.TotalAdvertisers, len(tt.want))
+			}
+			if len(result.Advertisers) != len(tt.want) {
+				t.Fatalf("Advertisers = %+v, want %+v", result.Advertisers, tt.want)
+			}
+			for i := range tt.want {
+				if !reflect.DeepEqual(result.Advertisers[i], tt.want[i]) {
+					t.Errorf("Advertisers[%d] = %+v, want %+v", i, result.Advertisers[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_AnalyzeSingle_Relationship(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	handler := mustNewHandler(t, cacheStore, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	cached := handler.buildAnalysis("example.com", "google.com, pub-1, DIRECT\nappnexus.com, 1, RESELLER")
+	data, _ := json.Marshal(cached)
+	_ = cacheStore.Set(cacheKeyFor("example.com"), data, cfg.CacheTTL)
+
+	w := httptest.NewRecorder()
+	handler.AnalyzeSingle(w, httptest.NewRequest("GET", "/api/analyze?domain=example.com&relationship=direct", nil))
+
+	var response SingleAnalysisResponse
+	_ = json.NewDecoder(w.Body).Decode(&response)
+	if response.TotalAdvertisers != 1 || response.Advertisers[0].Domain != "google.com" {
+		t.Errorf("Expected only google.com for direct, got %+v", response.Advertisers)
+	}
+
+	w = httptest.NewRecorder()
+	handler.AnalyzeSingle(w, httptest.NewRequest("GET", "/api/analyze?domain=example.com&relationship=owned", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid relationship, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.AnalyzeSingle(w, httptest.NewRequest("GET", "/api/analyze?domain=example.com&relationship=direct&sorted=false", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for sorted=false, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.AnalyzeSingle(w, httptest.NewRequest("GET", "/api/analyze?domain=example.com&sorted=sometimes", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid sorted, got %d", w.Code)
+	}
+}
+
+// benchmarkContent returns an ads.txt with n distinct advertisers.
+func benchmarkContent(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "adv%d.com, pub-%d, DIRECT\n", i, i)
+	}
+	return b.String()
+}
+
+// Sorting 50k advertisers costs roughly a quarter of a fresh analysis, but it runs once per fetch
+// and is cached; only the re-sort after a relationship filter is paid per request (?sorted=false skips it).
+func BenchmarkSortAdvertisers(b *testing.B) {
+	advertisersMap, _, _ := adstxt.ParseRelationshipsLenient(benchmarkContent(50000), 0)
+	advertisers := adstxt.RelationshipsToSlice(advertisersMap)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		shuffled := append([]adstxt.AdvertiserCount(nil), advertisers...)
+		b.StartTimer()
+		sortAdvertisers(shuffled)
+	}
+}
+
+func BenchmarkBuildAnalysis(b *testing.B) {
+	content := benchmarkContent(50000)
+	handler := &Handler{cfg: &config.Config{}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler.buildAnalysis("example.com", content)
+	}
+}
@@ -9,26 +9,67 @@ import (
 // NewRouter creates and configures the HTTP router with all endpoints and middleware.
 // It sets up the following routes:
 //   - GET  /health          - Health check endpoint
+//   - GET  /ready           - Readiness probe, 503 once shutdown has begun
 //   - GET  /metrics         - Metrics endpoint
+//   - GET  /version         - Build version, git commit, build time and Go version
 //   - GET  /api/analyze     - Single domain analysis (with ?domain= query param)
 //   - POST /api/batch-analysis - Batch domain analysis
+//   - POST /api/batch-aggregate - Advertiser ranking merged across a batch
+//   - POST /api/batch-ndjson - Larger batch analysis streamed as NDJSON, one line per domain
+//   - GET  /api/batch-link  - Batch analysis of a compressed domain list in ?domains=, for shareable links
+//   - POST /api/parse       - Analyze ads.txt content supplied in the request body
+//   - POST /api/lint        - Line-by-line syntax report for ads.txt content in the request body
+//   - POST /api/jobs        - Start a background analysis of a large domain list
+//   - GET  /api/jobs/{id}   - Progress and completed results of a job
+//   - POST /api/cache/flush - Remove all cache entries (requires ADMIN_TOKEN)
+//   - POST /api/cache/verify - Compare cached analyses with the live ads.txt files (requires ADMIN_TOKEN)
+//   - GET  /api/snapshot    - Metrics, cache stats, runtime and redacted config in one document (requires ADMIN_TOKEN)
+//   - GET  /api/slowlog     - Slowest recent requests (requires ADMIN_TOKEN)
+//
+// The analysis endpoints are also served under /v1 (e.g. /v1/api/analyze); the
+// unversioned paths are aliases for the current APIVersion.
 //
 // The router applies middleware in the following order:
-//  1. LoggingMiddleware    - Logs all requests and responses
-//  2. RateLimitMiddleware  - Rate limiting per client IP
-//  3. CORSMiddleware       - CORS headers for cross-origin requests
+//  1. TracingMiddleware           - Continues or starts a W3C trace for log correlation (when TRACING_ENABLED)
+//  2. LoggingMiddleware           - Logs all requests and records response status codes
+//  3. VaryMiddleware              - Vary on the negotiated request headers, so shared caches key correctly
+//  4. CompressionMiddleware       - Compresses response bodies for clients that accept gzip (or a registered coding)
+//  5. MaxInflightMiddleware       - Caps concurrent in-flight requests (health and readiness probes exempt)
+//  6. ClientConcurrencyMiddleware - Caps concurrent in-flight requests per client IP (health and readiness probes exempt)
+//  7. RateLimitMiddleware         - Rate limiting per client IP
+//  8. CORSMiddleware              - CORS headers for cross-origin requests
 func NewRouter(handler *Handler, rateLimiter *ratelimit.RateLimiter) http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/health", handler.Health)
+	mux.HandleFunc("/ready", handler.Ready)
 	mux.HandleFunc("/metrics", handler.Metrics)
-	mux.HandleFunc("/api/analyze", handler.AnalyzeSingle)
-	mux.HandleFunc("/api/batch-analysis", handler.AnalyzeBatch)
+	mux.HandleFunc("/version", handler.Version)
+	for _, prefix := range []string{"", "/" + APIVersion} {
+		mux.HandleFunc(prefix+"/api/analyze", handler.AnalyzeSingle)
+		mux.HandleFunc(prefix+"/api/batch-analysis", handler.AnalyzeBatch)
+		mux.HandleFunc(prefix+"/api/batch-aggregate", handler.AnalyzeBatchAggregate)
+		mux.HandleFunc(prefix+"/api/batch-ndjson", handler.AnalyzeBatchStream)
+		mux.HandleFunc(prefix+"/api/batch-link", handler.AnalyzeBatchLink)
+		mux.HandleFunc(prefix+"/api/parse", handler.ParseContent)
+		mux.HandleFunc(prefix+"/api/lint", handler.LintContent)
+		mux.HandleFunc(prefix+"/api/jobs", handler.CreateJob)
+		mux.HandleFunc(prefix+"/api/jobs/{id}", handler.GetJob)
+	}
+	mux.Handle("/api/cache/flush", AdminAuthMiddleware(handler.cfg.AdminToken)(http.HandlerFunc(handler.FlushCache)))
+	mux.Handle("/api/cache/verify", AdminAuthMiddleware(handler.cfg.AdminToken)(http.HandlerFunc(handler.VerifyCache)))
+	mux.Handle("/api/snapshot", AdminAuthMiddleware(handler.cfg.AdminToken)(http.HandlerFunc(handler.Snapshot)))
+	mux.Handle("/api/slowlog", AdminAuthMiddleware(handler.cfg.AdminToken)(http.HandlerFunc(handler.SlowLog)))
 
 	var h http.Handler = mux
-	h = CORSMiddleware(h)
-	h = RateLimitMiddleware(rateLimiter)(h)
-	h = LoggingMiddleware(h)
+	h = CORSMiddleware(handler.cfg.CORSAllowedMethods, handler.cfg.CORSAllowedHeaders, handler.cfg.CORSMaxAge)(h)
+	h = RateLimitMiddleware(rateLimiter, handler.metrics)(h)
+	h = ClientConcurrencyMiddleware(handler.cfg.MaxConcurrentPerClient, handler.metrics, "/health", "/ready")(h)
+	h = MaxInflightMiddleware(handler.cfg.MaxInflightRequests, "/health", "/ready")(h)
+	h = CompressionMiddleware(handler.cfg.ResponseCompressionLevel)(h)
+	h = VaryMiddleware(handler.cfg.VaryHeaders)(h)
+	h = LoggingMiddleware(handler.metrics)(h)
+	h = TracingMiddleware(handler.cfg.TracingEnabled)(h)
 
 	return h
 }
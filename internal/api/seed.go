@@ -0,0 +1,54 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SeedCache populates the cache from a directory of pre-captured ads.txt files, each named
+// by its domain (e.g. "example.com"), so known data is served without any network access.
+// Files with an invalid domain name or that cannot be read are skipped with a warning;
+// subdirectories and dotfiles are ignored.
+// Seeded entries are kept for the full CACHE_TTL. Returns the number of domains seeded.
+func (h *Handler) SeedCache(dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read seed directory: %w", err)
+	}
+
+	seeded := 0
+	for _, entry := range entries {
+		domain := entry.Name()
+		if !entry.Type().IsRegular() || strings.HasPrefix(domain, ".") {
+			continue // Subdirectories and dotfiles such as .gitkeep
+		}
+		if err := h.checkDomain(domain); err != nil {
+			h.logger.Warn("skipping seed file", slog.String("file", domain), slog.String("error", err.Error()))
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, domain))
+		if err != nil {
+			h.logger.Warn("skipping seed file", slog.String("file", domain), slog.String("error", err.Error()))
+			continue
+		}
+
+		target := h.cacheTarget(domain)
+		raw := string(content)
+		result := h.buildAnalysis(domain, raw)
+		result.rawContent = &raw
+		data, err := h.encodeCacheEntry(result)
+		if err != nil {
+			h.logger.Warn("skipping seed file", slog.String("file", domain), slog.String("error", err.Error()))
+			continue
+		}
+		if err := h.cache.Set(cacheKeyFor(target), data, h.storageTTL(h.cfg.CacheTTL)); err != nil {
+			return seeded, fmt.Errorf("failed to seed %s: %w", domain, err)
+		}
+		seeded++
+	}
+	return seeded, nil
+}
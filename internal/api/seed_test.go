@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+func TestHandler_SeedCache(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"example.com":  "google.com, pub-1, DIRECT\nappnexus.com, 1, RESELLER\n",
+		"other.org":    "openx.com, 2, DIRECT\n",
+		"notadomain":   "google.com, pub-1, DIRECT\n",
+		".gitkeep":     "",
+		"bad:name.com": "google.com, pub-1, DIRECT\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub.com"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{CacheTTL: 1 * time.Hour, RequestTimeout: 10 * time.Second}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	fetcher := newFakeFetcher(map[string]string{})
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	seeded, err := handler.SeedCache(dir)
+	if err != nil {
+		t.Fatalf("SeedCache failed: %v", err)
+	}
+	if seeded != 2 {
+		t.Errorf("Expected 2 domains seeded, got %d", seeded)
+	}
+
+	result, err := handler.analyzeDomain(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("Expected seeded domain to be served from cache: %v", err)
+	}
+	if !result.Cached || result.TotalAdvertisers != 2 {
+		t.Errorf("Unexpected seeded result: %+v", result)
+	}
+	if len(fetcher.calls) != 0 {
+		t.Errorf("Expected no fetches, got %v", fetcher.calls)
+	}
+}
+
+func TestHandler_SeedCache_MissingDir(t *testing.T) {
+	cfg := &config.Config{CacheTTL: 1 * time.Hour}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	handler := mustNewHandler(t, cacheStore, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	if _, err := handler.SeedCache(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error for a missing seed directory")
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"time"
+)
+
+// slowLog keeps the slowest requests of the last window for /api/slowlog: at most size of
+// them, each taking at least threshold, slowest first. A window of 0 never expires them.
+// It is not synchronized; Metrics guards it with its mutex.
+type slowLog struct {
+	size      int
+	threshold time.Duration
+	window    time.Duration
+	entries   []slowRequest
+}
+
+type slowRequest struct {
+	method   string
+	path     string
+	status   int
+	start    time.Time
+	duration time.Duration
+}
+
+// add records req if it is among the size slowest requests of the window ending at now.
+func (l *slowLog) add(req slowRequest, now time.Time) {
+	if l.size <= 0 || req.duration < l.threshold {
+		return
+	}
+	l.expire(now)
+
+	// Ties keep the earlier request first
+	i := sort.Search(len(l.entries), func(i int) bool { return l.entries[i].duration < req.duration })
+	if i >= l.size {
+		return
+	}
+	l.entries = append(l.entries, slowRequest{})
+	copy(l.entries[i+1:], l.entries[i:])
+	l.entries[i] = req
+	if len(l.entries) > l.size {
+		l.entries = l.entries[:l.size]
+	}
+}
+
+// expire drops the requests that started before the window ending at now.
+func (l *slowLog) expire(now time.Time) {
+	if l.window <= 0 {
+		return
+	}
+	cutoff := now.Add(-l.window)
+	kept := l.entries[:0]
+	for _, req := range l.entries {
+		if !req.start.Before(cutoff) {
+			kept = append(kept, req)
+		}
+	}
+	clear(l.entries[len(kept):])
+	l.entries = kept
+}
+
+// recent returns a copy of the requests of the window ending at now, slowest first.
+func (l *slowLog) recent(now time.Time) []slowRequest {
+	l.expire(now)
+	return append([]slowRequest(nil), l.entries...)
+}
+
+// recordRequest offers a completed request to the slow request log.
+func (m *Metrics) recordRequest(method, path string, status int, start time.Time, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.slowRequests.add(slowRequest{method: method, path: path, status: status, start: start, duration: duration}, time.Now())
+}
+
+// SlowLogResponse lists the slowest recent requests, see SLOW_LOG_SIZE.
+type SlowLogResponse struct {
+	ThresholdSeconds float64       `json:"threshold_seconds"`
+	WindowSeconds    float64       `json:"window_seconds"`
+	Requests         []SlowRequest `json:"requests"` // Slowest first
+}
+
+// SlowRequest is one request in the slow request log.
+type SlowRequest struct {
+	Method          string  `json:"method"`
+	Path            string  `json:"path"`
+	Status          int     `json:"status"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Timestamp       string  `json:"timestamp"` // When the request started
+}
+
+// SlowLog reports the slowest requests of the last SLOW_LOG_WINDOW, complementing the latency
+// histogram in /metrics. Paths are listed without their query, so it shows which endpoints are
+// slow, not with what input; the router guards it with AdminAuthMiddleware regardless.
+func (h *Handler) SlowLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+
+	h.metrics.mu.Lock()
+	entries := h.metrics.slowRequests.recent(time.Now())
+	h.metrics.mu.Unlock()
+
+	resp := SlowLogResponse{
+		ThresholdSeconds: h.cfg.SlowLogThreshold.Seconds(),
+		WindowSeconds:    h.cfg.SlowLogWindow.Seconds(),
+		Requests:         make([]SlowRequest, 0, len(entries)),
+	}
+	for _, req := range entries {
+		resp.Requests = append(resp.Requests, SlowRequest{
+			Method:          req.method,
+			Path:            req.path,
+			Status:          req.status,
+			DurationSeconds: req.duration.Seconds(),
+			Timestamp:       h.formatTime(req.start),
+		})
+	}
+	h.respond(w, r, http.StatusOK, resp)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+	"adstxt-api/internal/ratelimit"
+)
+
+func TestSlowLog_Add(t *testing.T) {
+	now := time.Date(2025, 11, 20, 12, 0, 0, 0, time.UTC)
+	log := slowLog{size: 3, threshold: 100 * time.Millisecond, window: time.Hour}
+	add := func(path string, duration time.Duration, age time.Duration) {
+		log.add(slowRequest{method: http.MethodGet, path: path, status: http.StatusOK, start: now.Add(-age), duration: duration}, now)
+	}
+
+	add("/fast", 50*time.Millisecond, 0) // Under the threshold
+	add("/old", 5*time.Second, 2*time.Hour)
+	add("/a", 300*time.Millisecond, time.Minute)
+	add("/b", time.Second, time.Minute)
+	add("/c", 200*time.Millisecond, time.Minute)
+	add("/d", 400*time.Millisecond, time.Minute) // Pushes out /c
+	add("/e", 150*time.Millisecond, time.Minute) // Not among the 3 slowest
+
+	var paths []string
+	for _, req := range log.recent(now) {
+		paths = append(paths, req.path)
+	}
+	if want := []string{"/b", "/d", "/a"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("recent() paths = %v, want %v", paths, want)
+	}
+
+	// Once the window has passed them, they make room for faster requests
+	later := now.Add(time.Hour)
+	log.add(slowRequest{path: "/f", start: later, duration: 150 * time.Millisecond}, later)
+	if got := log.recent(later); len(got) != 1 || got[0].path != "/f" {
+		t.Errorf("recent() after the window = %+v, want only /f", got)
+	}
+
+	disabled := slowLog{threshold: 0}
+	disabled.add(slowRequest{path: "/a", start: now, duration: time.Second}, now)
+	if got := disabled.recent(now); len(got) != 0 {
+		t.Errorf("recent() with size 0 = %+v, want none", got)
+	}
+}
+
+func TestHandler_SlowLog(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:         time.Hour,
+		RequestTimeout:   10 * time.Second,
+		AdminToken:       "admin-secret",
+		SlowLogSize:      2,
+		SlowLogThreshold: 0, // Every request qualifies
+		SlowLogWindow:    time.Hour,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+
+	handler := NewHandlerWithFetcher(cacheStore, newFakeFetcher(map[string]string{"example.com": "google.com, pub-1, DIRECT\n"}), cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	defer handler.Close()
+	rateLimiter := ratelimit.NewRateLimiter(100)
+	defer rateLimiter.Stop()
+	router := NewRouter(handler, rateLimiter)
+
+	for _, url := range []string{"/api/analyze?domain=example.com", "/api/analyze?domain=missing.example", "/version"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, url, nil))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/slowlog", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("without a token: status = %d, want 401", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/slowlog", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	var resp SlowLogResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.WindowSeconds != 3600 || len(resp.Requests) != 2 {
+		t.Fatalf("slowlog = %+v, want the 2 slowest of the window", resp)
+	}
+	if first, second := resp.Requests[0], resp.Requests[1]; first.DurationSeconds < second.DurationSeconds {
+		t.Errorf("requests not slowest first: %+v", resp.Requests)
+	}
+	for _, slow := range resp.Requests {
+		if slow.Method != http.MethodGet || slow.Status == 0 || slow.Timestamp == "" {
+			t.Errorf("incomplete entry %+v", slow)
+		}
+		if slow.Path != "/api/analyze" && slow.Path != "/version" && slow.Path != "/api/slowlog" {
+			t.Errorf("unexpected path %q", slow.Path)
+		}
+	}
+}
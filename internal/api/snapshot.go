@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// SnapshotResponse gathers the service's diagnostics into one document, so an operator can
+// attach a single file to a bug report instead of scraping several endpoints.
+type SnapshotResponse struct {
+	GeneratedAt string                 `json:"generated_at"`
+	Build       VersionResponse        `json:"build"`
+	Runtime     *RuntimeStats          `json:"runtime"`
+	Metrics     MetricsResponse        `json:"metrics"`
+	Cache       CacheStats             `json:"cache"`
+	Config      map[string]interface{} `json:"config"` // Keyed by Config field name, secrets redacted
+}
+
+// CacheStats summarizes the cache backend and how well it is serving lookups.
+type CacheStats struct {
+	Backend  string  `json:"backend"`
+	Mode     string  `json:"mode"` // CACHE_MODE: parsed or raw
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"` // hits / (hits + misses), 0 before any lookup
+}
+
+// Snapshot reports build, runtime, metrics, cache and configuration in one response.
+// Credentials in the configuration are redacted; the router still guards it with
+// AdminAuthMiddleware since the rest of the configuration describes the deployment.
+func (h *Handler) Snapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "only GET method is allowed")
+		return
+	}
+
+	metrics := h.metricsSnapshot()
+	stats := CacheStats{
+		Backend: h.cache.Name(),
+		Mode:    cacheModeParsed,
+		Hits:    metrics.CacheHits,
+		Misses:  metrics.CacheMisses,
+	}
+	if h.rawCache {
+		stats.Mode = cacheModeRaw
+	}
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(lookups)
+	}
+
+	h.respond(w, r, http.StatusOK, SnapshotResponse{
+		GeneratedAt: h.formatTime(time.Now()),
+		Build:       buildInfo(),
+		Runtime:     h.runtimeStats(),
+		Metrics:     metrics,
+		Cache:       stats,
+		Config:      h.cfg.Summary(),
+	})
+}
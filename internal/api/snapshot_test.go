@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+	"adstxt-api/internal/ratelimit"
+)
+
+func TestHandler_Snapshot(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:       time.Hour,
+		RequestTimeout: 10 * time.Second,
+		AdminToken:     "admin-secret",
+		RedisPassword:  "redis-secret",
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+
+	handler := NewHandlerWithFetcher(cacheStore, newFakeFetcher(map[string]string{"example.com": "google.com, pub-1, DIRECT\n"}), cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	defer handler.Close()
+	rateLimiter := ratelimit.NewRateLimiter(100)
+	defer rateLimiter.Stop()
+	router := NewRouter(handler, rateLimiter)
+
+	// One miss, then one hit
+	for i := 0; i < 2; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/analyze?domain=example.com", nil))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/snapshot", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("without a token: status = %d, want 401", w.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/api/snapshot", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	body := w.Body.String()
+	for _, secret := range []string{"admin-secret", "redis-secret"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("snapshot leaks %q", secret)
+		}
+	}
+
+	var snapshot SnapshotResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Build.GoVersion == "" || snapshot.Runtime == nil || snapshot.GeneratedAt == "" {
+		t.Errorf("snapshot = %+v, want build and runtime sections", snapshot)
+	}
+	want := CacheStats{Backend: "memory", Mode: "parsed", Hits: 1, Misses: 1, HitRatio: 0.5}
+	if snapshot.Cache != want {
+		t.Errorf("cache = %+v, want %+v", snapshot.Cache, want)
+	}
+	if snapshot.Metrics.CacheHits != 1 || snapshot.Metrics.RequestsTotal == 0 {
+		t.Errorf("metrics = %+v, want the recorded requests", snapshot.Metrics)
+	}
+	if snapshot.Config["RedisPassword"] != "[REDACTED]" || snapshot.Config["CacheTTL"] != "1h0m0s" {
+		t.Errorf("config = %v, want settings with secrets redacted", snapshot.Config)
+	}
+
+	req = httptest.NewRequest("POST", "/api/snapshot", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status = %d, want 405", w.Code)
+	}
+}
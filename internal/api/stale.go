@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// With SERVE_STALE_ON_ERROR, analyses are stored for STALE_GRACE_PERIOD past their TTL.
+// A read past the TTL is a miss like any other, but if the re-fetch then fails the expired
+// analysis is served instead of the error, flagged stale and keeping its original timestamp.
+// Freshness is judged from the analysis timestamp, so this works on every cache backend.
+
+// storageTTL returns how long an analysis cached for ttl is kept in the backend.
+func (h *Handler) storageTTL(ttl time.Duration) time.Duration {
+	if h.cfg.ServeStaleOnError && h.cfg.StaleGracePeriod > 0 {
+		return ttl + h.cfg.StaleGracePeriod
+	}
+	return ttl
+}
+
+// expired reports whether a cached analysis is only being kept for the stale grace period.
+// Without SERVE_STALE_ON_ERROR the backend's TTL is authoritative and nothing is expired.
+func (h *Handler) expired(result *SingleAnalysisResponse) bool {
+	if !h.cfg.ServeStaleOnError {
+		return false
+	}
+	fetchedAt, err := time.Parse(time.RFC3339, result.Timestamp)
+	if err != nil {
+		return false
+	}
+	return time.Since(fetchedAt) >= h.resultTTL(result)
+}
+
+// serveStale returns target's last cached analysis in place of fetchErr, if SERVE_STALE_ON_ERROR
+// is set and one is still within its grace period; otherwise it returns fetchErr. Requests that
+// need parsed entries, and callers that gave up (ctx ended), always get the error.
+func (h *Handler) serveStale(ctx context.Context, domain, target string, withEntries bool, fetchErr error) (*SingleAnalysisResponse, error) {
+	if !h.cfg.ServeStaleOnError || withEntries || ctx.Err() != nil {
+		return nil, fetchErr
+	}
+	data, err := h.cache.Get(cacheKeyFor(target))
+	if err != nil {
+		return nil, fetchErr
+	}
+	result, err := h.decodeCacheEntry(domain, data)
+	if err != nil {
+		return nil, fetchErr
+	}
+
+	h.logger.WarnContext(ctx, "serving stale analysis after fetch failure",
+		slog.String("domain", domain),
+		slog.String("fetched_at", result.Timestamp),
+		slog.String("error", fetchErr.Error()))
+	result.Domain = domain
+	result.Cached = true
+	result.CacheBackend = h.cache.Name()
+	result.Stale = true
+	return result, nil
+}
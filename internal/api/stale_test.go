@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+func TestHandler_ServeStaleOnError(t *testing.T) {
+	fetchedAt := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	expired, _ := json.Marshal(SingleAnalysisResponse{TotalAdvertisers: 3, Timestamp: fetchedAt})
+
+	newHandler := func(t *testing.T, serveStale bool, fetcher *fakeFetcher) (*Handler, cache.Cache) {
+		cfg := &config.Config{
+			CacheTTL:          time.Hour,
+			RequestTimeout:    10 * time.Second,
+			NegativeCacheTTL:  5 * time.Minute,
+			ServeStaleOnError: serveStale,
+			StaleGracePeriod:  24 * time.Hour,
+		}
+		cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+		t.Cleanup(func() { cacheStore.Close() })
+		// An analysis past its 1h TTL, still in the backend for the grace period
+		_ = cacheStore.Set(cacheKeyFor("example.com"), expired, 24*time.Hour)
+		handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+		t.Cleanup(handler.Close)
+		return handler, cacheStore
+	}
+
+	t.Run("failed re-fetch serves the expired analysis", func(t *testing.T) {
+		fetcher := newFakeFetcher(nil) // Every fetch fails with 404
+		handler, _ := newHandler(t, true, fetcher)
+
+		// Twice: the second failure is replayed from the negative cache, and still served stale
+		for i := 0; i < 2; i++ {
+			w := httptest.NewRecorder()
+			handler.AnalyzeSingle(w, httptest.NewRequest("GET", "/api/analyze?domain=example.com", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("request %d: status = %d, body: %s", i+1, w.Code, w.Body.String())
+			}
+			var result SingleAnalysisResponse
+			if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+			if !result.Stale || !result.Cached || result.TotalAdvertisers != 3 {
+				t.Errorf("request %d: result = %+v, want the expired analysis flagged stale", i+1, result)
+			}
+			if result.Timestamp != fetchedAt {
+				t.Errorf("request %d: timestamp = %s, want the original fetch time %s", i+1, result.Timestamp, fetchedAt)
+			}
+		}
+		if fetcher.calls["example.com"] != 1 {
+			t.Errorf("fetched %d times, want 1", fetcher.calls["example.com"])
+		}
+
+		batch := handler.processBatch(context.Background(), []string{"example.com"}, nil)
+		if len(batch.Results) != 1 || !batch.Results[0].Stale {
+			t.Errorf("batch = %+v, want the stale analysis", batch)
+		}
+	})
+
+	t.Run("successful re-fetch replaces the expired analysis", func(t *testing.T) {
+		fetcher := newFakeFetcher(map[string]string{"example.com": "google.com, pub-1, DIRECT\n"})
+		handler, cacheStore := newHandler(t, true, fetcher)
+
+		result, err := handler.analyzeDomain(context.Background(), "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Stale || result.Cached || result.TotalAdvertisers != 1 {
+			t.Errorf("result = %+v, want a fresh analysis", result)
+		}
+		stored, _ := cacheStore.Get(cacheKeyFor("example.com"))
+		var entry SingleAnalysisResponse
+		_ = json.Unmarshal(stored, &entry)
+		if entry.Stale || entry.TotalAdvertisers != 1 {
+			t.Errorf("cached entry = %+v, want the fresh analysis", entry)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		fetcher := newFakeFetcher(nil)
+		handler, cacheStore := newHandler(t, false, fetcher)
+
+		// The backend's TTL is authoritative, so the entry is still a hit
+		result, err := handler.analyzeDomain(context.Background(), "example.com")
+		if err != nil || result.Stale || fetcher.calls["example.com"] != 0 {
+			t.Errorf("result = %+v, err = %v, want a plain cache hit", result, err)
+		}
+
+		_ = cacheStore.Delete(cacheKeyFor("example.com"))
+		if _, err := handler.analyzeDomain(context.Background(), "example.com"); err == nil {
+			t.Error("expected the fetch error without SERVE_STALE_ON_ERROR")
+		}
+	})
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"time"
+)
+
+// TIMESTAMP_PRECISION values.
+const (
+	timestampSeconds      = "seconds"
+	timestampMilliseconds = "milliseconds"
+)
+
+// layoutMilliseconds is time.RFC3339 with exactly three fractional digits. Timestamps in
+// either precision parse with time.RFC3339, which accepts fractional seconds.
+const layoutMilliseconds = "2006-01-02T15:04:05.000Z07:00"
+
+// formatTime renders t for a response field: in UTC, so output does not depend on the host's
+// TZ, and to the TIMESTAMP_PRECISION. Every time field in a response goes through it.
+func (h *Handler) formatTime(t time.Time) string {
+	layout := time.RFC3339
+	if h.millisTimestamps {
+		layout = layoutMilliseconds
+	}
+	return t.UTC().Format(layout)
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+func TestFormatTime(t *testing.T) {
+	// A host in another timezone must produce the same strings
+	at := time.Date(2025, 11, 20, 12, 30, 45, 123456789, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		precision string
+		want      string
+	}{
+		{"", "2025-11-20T11:30:45Z"},
+		{"seconds", "2025-11-20T11:30:45Z"},
+		{"milliseconds", "2025-11-20T11:30:45.123Z"},
+		{"nanoseconds", "2025-11-20T11:30:45Z"}, // Unknown, falls back to seconds
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.precision, func(t *testing.T) {
+			cfg := &config.Config{CacheTTL: time.Hour, TimestampPrecision: tt.precision}
+			cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+			defer cacheStore.Close()
+			handler := mustNewHandler(t, cacheStore, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+			got := handler.formatTime(at)
+			if got != tt.want {
+				t.Errorf("formatTime() = %q, want %q", got, tt.want)
+			}
+			if _, err := time.Parse(time.RFC3339, got); err != nil {
+				t.Errorf("Expected %q to parse as RFC 3339: %v", got, err)
+			}
+		})
+	}
+}
+
+func TestHandler_Timestamps_UTCMilliseconds(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:           1 * time.Hour,
+		RequestTimeout:     10 * time.Second,
+		TimestampPrecision: "milliseconds",
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	fetcher := newFakeFetcher(map[string]string{"example.com": "google.com, pub-1, DIRECT"})
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	w := httptest.NewRecorder()
+	handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=example.com", nil))
+	var result SingleAnalysisResponse
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	handler.Health(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&health); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, ts := range map[string]string{"timestamp": result.Timestamp, "time": health.Time, "checked_at": health.CheckedAt} {
+		if !strings.HasSuffix(ts, "Z") || len(ts) != len("2006-01-02T15:04:05.000Z") {
+			t.Errorf("%s = %q, want UTC with milliseconds", name, ts)
+		}
+	}
+
+	// ?ts=unix still reads them
+	w = httptest.NewRecorder()
+	handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=example.com&ts=unix", nil))
+	var unix map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&unix); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := unix["timestamp"].(float64); !ok {
+		t.Errorf("Expected a Unix timestamp with ?ts=unix, got %v", unix["timestamp"])
+	}
+}
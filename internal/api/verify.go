@@ -0,0 +1,264 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"adstxt-api/internal/cache"
+)
+
+// Cache verification outcomes, reported per domain by /api/cache/verify.
+const (
+	VerifyMatched     = "matched"      // The publisher still serves what is cached
+	VerifyDrifted     = "drifted"      // The publisher's advertisers have changed since the entry was cached
+	VerifyFetchFailed = "fetch_failed" // The live ads.txt could not be fetched, so nothing was compared
+	VerifyNotCached   = "not_cached"   // There is no readable cached entry to compare against
+)
+
+// CacheVerifyRequest is the body of POST /api/cache/verify.
+// Without domains (or without a body) the cache is verified a page of domains at a time.
+type CacheVerifyRequest struct {
+	Domains []string `json:"domains"`
+}
+
+// CacheVerifyResult compares one domain's cached analysis with its live ads.txt.
+// Hashes are content_hash values, so only changes to the advertiser list and its counts are drift.
+type CacheVerifyResult struct {
+	Domain     string `json:"domain"`
+	Status     string `json:"status"`
+	CachedAt   string `json:"cached_at,omitempty"`
+	CachedHash string `json:"cached_hash,omitempty"`
+	LiveHash   string `json:"live_hash,omitempty"`
+	Code       string `json:"code,omitempty"` // FETCH_* code of a failed fetch
+	Error      string `json:"error,omitempty"`
+}
+
+// CacheVerifyResponse reports every verified domain along with how many ended in each status.
+// When a page of the whole cache was verified, NextCursor is set if more domains remain.
+type CacheVerifyResponse struct {
+	Results    []CacheVerifyResult `json:"results"`
+	Summary    map[string]int      `json:"summary"`
+	VerifiedAt string              `json:"verified_at"`
+	NextCursor string              `json:"next_cursor,omitempty"` // Pass as ?cursor to verify the next page
+}
+
+// VerifyCache re-fetches cached domains and reports, for each, whether the cached analysis
+// still matches what the publisher serves. The cache is never updated, so drifted entries keep
+// being served until they expire or are refreshed. Domains are verified concurrently on the
+// batch worker pool within CACHE_VERIFY_TIMEOUT. Without a domain list the cache is verified
+// maxBatchDomains at a time, paged with ?cursor.
+// Intended for operators; the router guards it with AdminAuthMiddleware.
+func (h *Handler) VerifyCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "only POST method is allowed")
+		return
+	}
+
+	var domains []string
+	if r.ContentLength != 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		var req CacheVerifyRequest
+		if code, err := h.decodeJSONBody(r, &req); err != nil {
+			h.sendError(w, r, decodeStatus(code), code, err.Error())
+			return
+		}
+		if req.Domains != nil {
+			domains = dedupeDomains(req.Domains)
+			if !h.checkBatchSize(w, r, domains, maxBatchDomains) || !h.checkVerifyDomains(w, r, domains) {
+				return
+			}
+		}
+	}
+
+	var nextCursor string
+	if domains == nil {
+		var ok bool
+		if domains, ok = h.cachedDomains(w, r); !ok {
+			return
+		}
+		domains, nextCursor = cachedDomainsPage(domains, r.URL.Query().Get("cursor"), maxBatchDomains)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.cfg.CacheVerifyTimeout)
+	defer cancel()
+
+	// The server's WriteTimeout is sized for single responses, not a re-fetch of the whole cache
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(h.cfg.CacheVerifyTimeout + batchStreamWriteGrace)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		h.logger.WarnContext(r.Context(), "failed to extend write deadline for cache verification", slog.String("error", err.Error()))
+	}
+
+	response := CacheVerifyResponse{
+		Results:    h.verifyDomains(ctx, domains),
+		Summary:    map[string]int{VerifyMatched: 0, VerifyDrifted: 0, VerifyFetchFailed: 0, VerifyNotCached: 0},
+		NextCursor: nextCursor,
+	}
+	for _, result := range response.Results {
+		response.Summary[result.Status]++
+	}
+	response.VerifiedAt = h.formatTime(time.Now())
+
+	h.logger.InfoContext(r.Context(), "cache verified",
+		slog.Int("domains", len(domains)),
+		slog.Int("drifted", response.Summary[VerifyDrifted]),
+		slog.Int("fetch_failed", response.Summary[VerifyFetchFailed]))
+	h.respond(w, r, http.StatusOK, response)
+}
+
+// checkVerifyDomains rejects the request if any domain is invalid, listing each in the details.
+// On failure it writes the error response itself and returns false.
+func (h *Handler) checkVerifyDomains(w http.ResponseWriter, r *http.Request, domains []string) bool {
+	invalid := make(map[string]string)
+	for _, d := range domains {
+		if err := h.checkDomain(d); err != nil {
+			invalid[d] = err.Error()
+		}
+	}
+	if len(invalid) > 0 {
+		h.sendJSON(w, r, http.StatusBadRequest, ErrorResponse{
+			Error:   http.StatusText(http.StatusBadRequest),
+			Code:    CodeInvalidDomain,
+			Message: fmt.Sprintf("%d of %d domains are invalid", len(invalid), len(domains)),
+			Details: invalid,
+		})
+		return false
+	}
+	return true
+}
+
+// cachedDomains lists the domains with a cached analysis, in order, for verifying the whole cache.
+// It needs a cache.KeyLister backend. On failure it writes the error response itself and returns false.
+func (h *Handler) cachedDomains(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	lister, ok := h.cache.(cache.KeyLister)
+	if !ok {
+		h.sendError(w, r, http.StatusNotImplemented, CodeCacheFailure,
+			fmt.Sprintf("the %s cache cannot list its entries; pass the domains to verify", h.cache.Name()))
+		return nil, false
+	}
+
+	prefix := cacheKeyFor("")
+	keys, err := lister.Keys(prefix)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to list cache entries", slog.String("error", err.Error()))
+		h.sendError(w, r, http.StatusInternalServerError, CodeCacheFailure, "failed to list cache entries")
+		return nil, false
+	}
+
+	domains := make([]string, 0, len(keys))
+	for _, key := range keys {
+		// Previous analyses, failures and jobs share the prefix, but no domain contains a colon
+		if domain := strings.TrimPrefix(key, prefix); !strings.Contains(domain, ":") {
+			domains = append(domains, domain)
+		}
+	}
+	sort.Strings(domains)
+	return domains, true
+}
+
+// cachedDomainsPage returns the first limit of the sorted domains after cursor, along with the
+// cursor of the next page, which is empty once no domains remain. Cursors are domain names, so
+// entries added or expiring between pages never shift the pages that follow.
+func cachedDomainsPage(domains []string, cursor string, limit int) (page []string, nextCursor string) {
+	start := sort.SearchStrings(domains, cursor)
+	if start < len(domains) && domains[start] == cursor {
+		start++
+	}
+	page = domains[start:]
+	if len(page) > limit {
+		page = page[:limit]
+		nextCursor = page[limit-1]
+	}
+	return page, nextCursor
+}
+
+// verifyDomains verifies each domain concurrently and returns the results in the order of domains.
+func (h *Handler) verifyDomains(ctx context.Context, domains []string) []CacheVerifyResult {
+	results := make([]CacheVerifyResult, len(domains))
+	var wg sync.WaitGroup
+	for i, d := range domains {
+		wg.Add(1)
+		job := func() {
+			defer wg.Done()
+			// Isolate panics to the domain that caused them instead of crashing the process
+			defer func() {
+				if rec := recover(); rec != nil {
+					h.logger.ErrorContext(ctx, "panic in cache verification",
+						slog.String("domain", d),
+						slog.Any("panic", rec),
+						slog.String("stack", string(debug.Stack())))
+					results[i] = CacheVerifyResult{Domain: d, Status: VerifyFetchFailed, Error: "internal error processing domain"}
+				}
+			}()
+			results[i] = h.verifyDomain(ctx, d)
+		}
+
+		if h.batchPool == nil {
+			go job()
+		} else if err := h.batchPool.submit(ctx, job); err != nil {
+			// Never started, so the domain is reported here; a done ctx is the verification deadline
+			results[i] = CacheVerifyResult{Domain: d, Status: VerifyFetchFailed, Error: "server shutting down"}
+			if ctx.Err() != nil {
+				results[i].Code, results[i].Error = CodeFetchTimeout, "request timeout"
+			}
+			wg.Done()
+		}
+	}
+	wg.Wait()
+	return results
+}
+
+// verifyDomain compares domain's cached analysis with a fresh fetch that bypasses,
+// and leaves untouched, the cache and the negative cache.
+func (h *Handler) verifyDomain(ctx context.Context, domain string) CacheVerifyResult {
+	result := CacheVerifyResult{Domain: domain, Status: VerifyNotCached}
+	target := h.cacheTarget(domain)
+
+	data, err := h.cache.Get(cacheKeyFor(target))
+	if err != nil {
+		if !errors.Is(err, cache.ErrCacheNotFound) {
+			result.Error = "cache lookup failed: " + err.Error()
+		}
+		return result
+	}
+	cached, err := h.decodeCacheEntry(target, data)
+	if err != nil {
+		result.Error = "unreadable cache entry: " + err.Error()
+		return result
+	}
+	result.CachedAt = cached.Timestamp
+	result.CachedHash = contentHash(cached.Advertisers)
+
+	result.Status = VerifyFetchFailed
+	switch {
+	case !h.domainAllowed(domain):
+		result.Code, result.Error = CodeDomainNotAllowed, "domain not allowed"
+		return result
+	case ctx.Err() != nil: // Jobs queued behind a busy pool may start late
+		result.Code, result.Error = CodeFetchTimeout, "request timeout"
+		return result
+	}
+
+	live, err := h.fetchAnalysis(ctx, target, target, false)
+	if err != nil {
+		result.Code, result.Error = fetchErrorCode(err), err.Error()
+		if ctx.Err() != nil {
+			result.Code, result.Error = CodeFetchTimeout, "request timeout"
+		}
+		return result
+	}
+
+	result.LiveHash = contentHash(live.Advertisers)
+	result.Status = VerifyMatched
+	if result.LiveHash != result.CachedHash {
+		result.Status = VerifyDrifted
+	}
+	return result
+}
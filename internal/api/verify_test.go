@@ -0,0 +1,255 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+	"adstxt-api/internal/ratelimit"
+)
+
+// cacheAnalysis stores an analysis of content for domain the way a fetch would.
+func cacheAnalysis(t *testing.T, h *Handler, domain, content string) {
+	t.Helper()
+	data, err := h.encodeCacheEntry(h.buildAnalysis(domain, content))
+	if err != nil {
+		t.Fatalf("encodeCacheEntry() error = %v", err)
+	}
+	if err := h.cache.Set(cacheKeyFor(domain), data, 0); err != nil {
+		t.Fatalf("cache.Set() error = %v", err)
+	}
+}
+
+func verifyCache(t *testing.T, h *Handler, body string) (*httptest.ResponseRecorder, CacheVerifyResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/cache/verify", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.VerifyCache(w, req)
+
+	var response CacheVerifyResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+	}
+	return w, response
+}
+
+func TestHandler_VerifyCache(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:           1 * time.Hour,
+		RequestTimeout:     10 * time.Second,
+		CacheVerifyTimeout: 10 * time.Second,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+
+	fetcher := newFakeFetcher(map[string]string{
+		"same.com":    "google.com, pub-1, DIRECT",
+		"changed.com": "google.com, pub-1, DIRECT\nappnexus.com, 2, RESELLER",
+	})
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	defer handler.Close()
+
+	cacheAnalysis(t, handler, "same.com", "google.com, pub-1, DIRECT")
+	cacheAnalysis(t, handler, "changed.com", "google.com, pub-1, DIRECT")
+	cacheAnalysis(t, handler, "gone.com", "google.com, pub-1, DIRECT")
+	before, _ := cacheStore.Get(cacheKeyFor("changed.com"))
+
+	w, response := verifyCache(t, handler, `{"domains": ["same.com", "changed.com", "gone.com", "uncached.com"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	want := []struct {
+		domain, status, code string
+	}{
+		{"same.com", VerifyMatched, ""},
+		{"changed.com", VerifyDrifted, ""},
+		{"gone.com", VerifyFetchFailed, CodeFetchNotFound},
+		{"uncached.com", VerifyNotCached, ""},
+	}
+	if len(response.Results) != len(want) {
+		t.Fatalf("Expected %d results, got %+v", len(want), response.Results)
+	}
+	for i, tt := range want {
+		got := response.Results[i]
+		if got.Domain != tt.domain || got.Status != tt.status || got.Code != tt.code {
+			t.Errorf("Result %d = %+v, want %s %s %s", i, got, tt.domain, tt.status, tt.code)
+		}
+	}
+	if r := response.Results[1]; r.CachedHash == "" || r.LiveHash == "" || r.CachedHash == r.LiveHash {
+		t.Errorf("Expected differing hashes for the drifted domain, got %+v", r)
+	}
+	if r := response.Results[0]; r.CachedHash != r.LiveHash || r.CachedAt == "" {
+		t.Errorf("Expected equal hashes and cached_at for the matched domain, got %+v", r)
+	}
+	if response.Summary[VerifyMatched] != 1 || response.Summary[VerifyDrifted] != 1 ||
+		response.Summary[VerifyFetchFailed] != 1 || response.Summary[VerifyNotCached] != 1 {
+		t.Errorf("Unexpected summary %v", response.Summary)
+	}
+
+	// Verification never touches the cache; uncached domains are not even fetched
+	if after, _ := cacheStore.Get(cacheKeyFor("changed.com")); string(after) != string(before) {
+		t.Error("Expected the drifted entry to be left as cached")
+	}
+	if fetcher.calls["uncached.com"] != 0 {
+		t.Error("Expected an uncached domain not to be fetched")
+	}
+}
+
+func TestHandler_VerifyCache_All(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:           1 * time.Hour,
+		RequestTimeout:     10 * time.Second,
+		CacheVerifyTimeout: 10 * time.Second,
+		AdminToken:         "secret",
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+
+	fetcher := newFakeFetcher(map[string]string{
+		"a.com": "google.com, pub-1, DIRECT",
+		"b.com": "google.com, pub-1, DIRECT",
+	})
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	defer handler.Close()
+	rateLimiter := ratelimit.NewRateLimiter(100)
+	defer rateLimiter.Stop()
+	router := NewRouter(handler, rateLimiter)
+
+	cacheAnalysis(t, handler, "b.com", "google.com, pub-1, DIRECT")
+	cacheAnalysis(t, handler, "a.com", "google.com, pub-1, DIRECT")
+	// Entries sharing the prefix that are not analyses
+	_ = cacheStore.Set(previousKeyFor("a.com"), []byte("{}"), 0)
+	_ = cacheStore.Set(negativeKeyFor("c.com"), []byte("{}"), 0)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/cache/verify", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response CacheVerifyResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Results) != 2 || response.Results[0].Domain != "a.com" || response.Results[1].Domain != "b.com" {
+		t.Fatalf("Expected a.com and b.com in order, got %+v", response.Results)
+	}
+	if response.Summary[VerifyMatched] != 2 {
+		t.Errorf("Expected both domains to match, got %v", response.Summary)
+	}
+
+	// Without the token the endpoint is refused like every admin endpoint
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/cache/verify", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without the admin token, got %d", w.Code)
+	}
+}
+
+func TestHandler_VerifyCache_Paged(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:           1 * time.Hour,
+		RequestTimeout:     10 * time.Second,
+		CacheVerifyTimeout: 10 * time.Second,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+
+	pages := make(map[string]string)
+	for i := 0; i < maxBatchDomains+5; i++ {
+		pages[fmt.Sprintf("site%03d.com", i)] = "google.com, pub-1, DIRECT"
+	}
+	handler := NewHandlerWithFetcher(cacheStore, newFakeFetcher(pages), cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	defer handler.Close()
+	for domain, content := range pages {
+		cacheAnalysis(t, handler, domain, content)
+	}
+
+	verifyPage := func(cursor string) CacheVerifyResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/cache/verify?cursor="+cursor, nil)
+		w := httptest.NewRecorder()
+		handler.VerifyCache(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var response CacheVerifyResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response
+	}
+
+	first := verifyPage("")
+	if len(first.Results) != maxBatchDomains || first.NextCursor != "site049.com" {
+		t.Fatalf("Expected %d results and a cursor after site049.com, got %d and %q", maxBatchDomains, len(first.Results), first.NextCursor)
+	}
+	second := verifyPage(first.NextCursor)
+	if len(second.Results) != 5 || second.Results[0].Domain != "site050.com" || second.NextCursor != "" {
+		t.Errorf("Expected the last 5 domains from site050.com without a cursor, got %+v (cursor %q)", second.Results, second.NextCursor)
+	}
+}
+
+// unlistedCache hides the KeyLister implementation of the cache it wraps.
+type unlistedCache struct {
+	cache.Cache
+}
+
+func TestHandler_VerifyCache_Errors(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:           1 * time.Hour,
+		RequestTimeout:     10 * time.Second,
+		CacheVerifyTimeout: 10 * time.Second,
+	}
+	memory := cache.NewMemoryCache(cfg.CacheTTL)
+	defer memory.Close()
+	handler := NewHandlerWithFetcher(unlistedCache{memory}, newFakeFetcher(nil), cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+	defer handler.Close()
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "invalid domain", body: `{"domains": ["example.com", "not a domain"]}`, wantStatus: http.StatusBadRequest, wantCode: CodeInvalidDomain},
+		{name: "empty domains", body: `{"domains": []}`, wantStatus: http.StatusBadRequest, wantCode: CodeEmptyBatch},
+		{name: "malformed body", body: `{"domains": `, wantStatus: http.StatusBadRequest, wantCode: CodeInvalidJSON},
+		{name: "all without a listing cache", body: `{}`, wantStatus: http.StatusNotImplemented, wantCode: CodeCacheFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, _ := verifyCache(t, handler, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			var errResp ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &errResp); err != nil {
+				t.Fatalf("Failed to decode error response: %v", err)
+			}
+			if errResp.Code != tt.wantCode {
+				t.Errorf("Expected code %s, got %s", tt.wantCode, errResp.Code)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	handler.VerifyCache(w, httptest.NewRequest(http.MethodGet, "/api/cache/verify", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET, got %d", w.Code)
+	}
+}
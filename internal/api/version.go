@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"runtime"
+)
+
+// Build metadata, injected at build time with -ldflags, e.g.
+//
+//	-X adstxt-api/internal/api.BuildVersion=1.2.0 -X adstxt-api/internal/api.BuildCommit=abc1234
+//	-X adstxt-api/internal/api.BuildTime=2025-11-20T10:30:00Z
+//
+// The Makefile and Dockerfile set all three; plain go build/run leaves the defaults.
+var (
+	BuildVersion = "dev"
+	BuildCommit  = "unknown"
+	BuildTime    = "unknown"
+)
+
+// VersionResponse identifies the running build, so deploys can be verified.
+type VersionResponse struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"git_commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Version reports the build metadata and the Go version the binary was compiled with.
+func (h *Handler) Version(w http.ResponseWriter, r *http.Request) {
+	h.respond(w, r, http.StatusOK, buildInfo())
+}
+
+func buildInfo() VersionResponse {
+	return VersionResponse{
+		Version:   BuildVersion,
+		GitCommit: BuildCommit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+}
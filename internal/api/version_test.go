@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+	"adstxt-api/internal/ratelimit"
+)
+
+func TestRouter_Version(t *testing.T) {
+	defer func(version, commit, buildTime string) {
+		BuildVersion, BuildCommit, BuildTime = version, commit, buildTime
+	}(BuildVersion, BuildCommit, BuildTime)
+	BuildVersion, BuildCommit, BuildTime = "v1.2.3", "abc1234", "2025-11-20T10:30:00Z"
+
+	cfg := &config.Config{
+		CacheTTL:       1 * time.Hour,
+		RequestTimeout: 10 * time.Second,
+	}
+
+	cache := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cache.Close()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := mustNewHandler(t, cache, cfg, logger)
+	rateLimiter := ratelimit.NewRateLimiter(100)
+	defer rateLimiter.Stop()
+	router := NewRouter(handler, rateLimiter)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/version", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var version VersionResponse
+	if err := json.NewDecoder(w.Body).Decode(&version); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	want := VersionResponse{Version: "v1.2.3", GitCommit: "abc1234", BuildTime: "2025-11-20T10:30:00Z", GoVersion: runtime.Version()}
+	if version != want {
+		t.Errorf("Expected %+v, got %+v", want, version)
+	}
+
+	// Health reports the same version
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+
+	var health HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&health); err != nil {
+		t.Fatalf("Failed to decode health response: %v", err)
+	}
+	if health.Version != "v1.2.3" {
+		t.Errorf("Expected health version v1.2.3, got %q", health.Version)
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errPoolStopped is returned by workerPool.submit once the pool is stopped.
+var errPoolStopped = errors.New("worker pool stopped")
+
+// workerPool runs batch analysis jobs on a fixed set of goroutines shared by every
+// in-flight batch request, so total batch concurrency stays bounded however many
+// batches arrive at once. Jobs are expected to recover their own panics.
+type workerPool struct {
+	jobs chan func()
+	done chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+func newWorkerPool(size int) *workerPool {
+	p := &workerPool{jobs: make(chan func()), done: make(chan struct{})}
+	for i := 0; i < size; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+func (p *workerPool) work() {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-p.done:
+			return
+		case job := <-p.jobs:
+			job()
+		}
+	}
+}
+
+// submit hands job to the next free worker, blocking while all workers are busy. It gives up
+// with ctx's error once ctx is done, or with errPoolStopped once the pool is stopped; job is
+// then never run.
+func (p *workerPool) submit(ctx context.Context, job func()) error {
+	select {
+	case <-p.done:
+		return errPoolStopped
+	default:
+	}
+	select {
+	case p.jobs <- job:
+		return nil
+	case <-p.done:
+		return errPoolStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stop lets the workers finish their current jobs and waits for them to exit. Submissions
+// still waiting for a worker fail with errPoolStopped. Safe to call more than once.
+func (p *workerPool) stop() {
+	p.once.Do(func() { close(p.done) })
+	p.wg.Wait()
+}
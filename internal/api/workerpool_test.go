@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool_BoundsConcurrency(t *testing.T) {
+	pool := newWorkerPool(2)
+	defer pool.stop()
+
+	var running, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		_ = pool.submit(context.Background(), func() {
+			defer wg.Done()
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		})
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("Expected at most 2 concurrent jobs, got %d", peak)
+	}
+}
+
+func TestWorkerPool_StopIsIdempotent(t *testing.T) {
+	pool := newWorkerPool(1)
+
+	done := make(chan struct{})
+	if err := pool.submit(context.Background(), func() { close(done) }); err != nil {
+		t.Fatalf("submit() error = %v", err)
+	}
+	<-done
+
+	pool.stop()
+	pool.stop()
+}
+
+func TestWorkerPool_SubmitGivesUp(t *testing.T) {
+	pool := newWorkerPool(1)
+
+	// Keep the only worker busy so further submissions have to wait
+	release := make(chan struct{})
+	started := make(chan struct{})
+	if err := pool.submit(context.Background(), func() { close(started); <-release }); err != nil {
+		t.Fatalf("submit() error = %v", err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pool.submit(ctx, func() { t.Error("Expected the timed-out job never to run") }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("submit() with an expired ctx error = %v, want context.DeadlineExceeded", err)
+	}
+
+	// A submission waiting when the pool stops fails instead of blocking forever
+	waiting := make(chan error)
+	go func() {
+		waiting <- pool.submit(context.Background(), func() { t.Error("Expected the waiting job never to run") })
+	}()
+	time.Sleep(10 * time.Millisecond)
+	go pool.stop()
+	if err := <-waiting; !errors.Is(err, errPoolStopped) {
+		t.Errorf("submit() while stopping error = %v, want errPoolStopped", err)
+	}
+	close(release)
+	pool.stop()
+
+	// Submitting after stop no longer panics
+	if err := pool.submit(context.Background(), func() {}); !errors.Is(err, errPoolStopped) {
+		t.Errorf("submit() after stop error = %v, want errPoolStopped", err)
+	}
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"adstxt-api/internal/adstxt"
+)
+
+// wwwFallback reports whether fetches under ctx may fall back to https://www.<domain>/ads.txt:
+// a ?no_www_fallback override carried in ctx, otherwise the inverse of FETCH_NO_WWW_FALLBACK.
+func (h *Handler) wwwFallback(ctx context.Context) bool {
+	if enabled, ok := adstxt.WWWFallbackFromContext(ctx); ok {
+		return enabled
+	}
+	return !h.cfg.FetchNoWWWFallback
+}
+
+// withNoWWWFallback returns ctx carrying the ?no_www_fallback override, if given, for the
+// fetcher and the cache lookups. On an invalid value it writes the error response itself
+// and returns false.
+func (h *Handler) withNoWWWFallback(ctx context.Context, w http.ResponseWriter, r *http.Request) (context.Context, bool) {
+	noWWW, ok := h.noWWWFallbackParam(w, r)
+	if !ok || noWWW == nil {
+		return ctx, ok
+	}
+	return adstxt.WithWWWFallback(ctx, !*noWWW), true
+}
+
+// noWWWFallbackParam returns the ?no_www_fallback override, or nil without one. On an
+// invalid value it writes the error response itself and returns false.
+func (h *Handler) noWWWFallbackParam(w http.ResponseWriter, r *http.Request) (*bool, bool) {
+	raw := r.URL.Query().Get("no_www_fallback")
+	if raw == "" { // Defaults to FETCH_NO_WWW_FALLBACK rather than false
+		return nil, true
+	}
+	noWWW, err := strconv.ParseBool(raw)
+	if err != nil {
+		h.sendError(w, r, http.StatusBadRequest, CodeInvalidParameter, "no_www_fallback must be a boolean")
+		return nil, false
+	}
+	return &noWWW, true
+}
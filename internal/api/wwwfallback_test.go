@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/adstxt"
+	"adstxt-api/internal/cache"
+	"adstxt-api/internal/config"
+)
+
+// wwwOnlyFetcher is a publisher whose ads.txt is only on the www host: fetches without the
+// www fallback fail with adstxt.ErrWWWOnly like the real fetcher's.
+type wwwOnlyFetcher struct {
+	*fakeFetcher
+}
+
+func (f wwwOnlyFetcher) FetchAdsTxt(ctx context.Context, domain string) (string, error) {
+	if enabled, ok := adstxt.WWWFallbackFromContext(ctx); ok && !enabled {
+		f.mu.Lock()
+		f.calls[domain]++
+		f.mu.Unlock()
+		return "", fmt.Errorf("failed to fetch ads.txt for %s: %w: %w", domain, adstxt.ErrWWWOnly, &adstxt.StatusError{Code: http.StatusNotFound})
+	}
+	return f.fakeFetcher.FetchAdsTxt(ctx, domain)
+}
+
+func TestHandler_AnalyzeSingle_NoWWWFallback(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:         1 * time.Hour,
+		RequestTimeout:   10 * time.Second,
+		NegativeCacheTTL: 5 * time.Minute,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	fetcher := wwwOnlyFetcher{newFakeFetcher(map[string]string{"example.com": "google.com, pub-1, DIRECT"})}
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	// An analysis the fetcher could only get from https://www.example.com/ads.txt
+	cached := handler.buildAnalysis("example.com", "google.com, pub-1, DIRECT")
+	cached.WWWFallback = true
+	data, _ := json.Marshal(cached)
+	_ = cacheStore.Set(cacheKeyFor("example.com"), data, cfg.CacheTTL)
+
+	analyze := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=example.com"+query, nil))
+		return w
+	}
+
+	w := analyze("")
+	var result SingleAnalysisResponse
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || !result.Cached || !result.WWWFallback {
+		t.Fatalf("Expected the cached www analysis flagged www_fallback, got %d %+v", w.Code, result)
+	}
+
+	for i := 1; i <= 2; i++ {
+		w = analyze("&no_www_fallback=true")
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("Expected 500 without the www fallback, got %d: %s", w.Code, w.Body.String())
+		}
+		var errResp ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&errResp); err != nil {
+			t.Fatal(err)
+		}
+		if errResp.Code != CodeFetchWWWOnly {
+			t.Errorf("Expected code %s, got %s", CodeFetchWWWOnly, errResp.Code)
+		}
+		// The cached analysis may predate a fix on the apex, and the failure is not cached
+		if fetcher.calls["example.com"] != i {
+			t.Errorf("Expected %d apex-only fetches, got %d", i, fetcher.calls["example.com"])
+		}
+	}
+
+	// Requests allowing the fallback still get the cached analysis
+	if w = analyze("&no_www_fallback=false"); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 with the www fallback allowed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w = analyze("&no_www_fallback=maybe"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid no_www_fallback, got %d", w.Code)
+	}
+}
+
+func TestHandler_AnalyzeSingle_NoWWWFallbackConfig(t *testing.T) {
+	cfg := &config.Config{
+		CacheTTL:           1 * time.Hour,
+		RequestTimeout:     10 * time.Second,
+		FetchNoWWWFallback: true,
+	}
+	cacheStore := cache.NewMemoryCache(cfg.CacheTTL)
+	defer cacheStore.Close()
+	fetcher := wwwOnlyFetcher{newFakeFetcher(map[string]string{"example.com": "google.com, pub-1, DIRECT"})}
+	handler := NewHandlerWithFetcher(cacheStore, fetcher, cfg, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	cached := handler.buildAnalysis("example.com", "google.com, pub-1, DIRECT")
+	cached.WWWFallback = true
+	data, _ := json.Marshal(cached)
+	_ = cacheStore.Set(cacheKeyFor("example.com"), data, cfg.CacheTTL)
+
+	w := httptest.NewRecorder()
+	handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=example.com&no_www_fallback=false", nil))
+	if w.Code != http.StatusOK || fetcher.calls["example.com"] != 0 {
+		t.Fatalf("Expected the override to allow the cached www analysis, got %d after %d fetches", w.Code, fetcher.calls["example.com"])
+	}
+
+	// Under FETCH_NO_WWW_FALLBACK the cached www analysis is a miss
+	w = httptest.NewRecorder()
+	handler.AnalyzeSingle(w, httptest.NewRequest(http.MethodGet, "/api/analyze?domain=example.com", nil))
+	if fetcher.calls["example.com"] != 1 {
+		t.Errorf("Expected the default request to re-fetch, got %d fetches", fetcher.calls["example.com"])
+	}
+}
+
+// wwwRecordingFetcher records the WithWWWFallback override each fetch was made under.
+type wwwRecordingFetcher struct {
+	mu        sync.Mutex
+	overrides map[string]*bool
+}
+
+func (f *wwwRecordingFetcher) FetchAdsTxt(ctx context.Context, domain string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if enabled, ok := adstxt.WWWFallbackFromContext(ctx); ok {
+		f.overrides[domain] = &enabled
+	} else {
+		f.overrides[domain] = nil
+	}
+	return "google.com, pub-1, DIRECT", nil
+}
+
+func TestHandler_NoWWWFallback_Batches(t *testing.T) {
+	fetcher := &wwwRecordingFetcher{overrides: make(map[string]*bool)}
+	_, router := newJobsTestRouter(t, fetcher, &config.Config{RequestTimeout: 10 * time.Second, BatchStreamMaxDomains: 10, BatchStreamTimeout: 10 * time.Second, JobWorkers: 1})
+
+	tests := []struct {
+		path   string
+		domain string
+		want   bool // The www fallback the fetch was allowed
+	}{
+		{"/api/batch-analysis?no_www_fallback=true", "batch.example", false},
+		{"/api/batch-aggregate?no_www_fallback=false", "aggregate.example", true},
+		{"/api/batch-ndjson?no_www_fallback=true", "stream.example", false},
+		{"/api/jobs?no_www_fallback=true", "job.example", false},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(`{"domains": ["`+tt.domain+`"]}`)))
+		if w.Code != http.StatusOK && w.Code != http.StatusAccepted {
+			t.Fatalf("POST %s status = %d: %s", tt.path, w.Code, w.Body.String())
+		}
+		if location := w.Header().Get("Location"); location != "" {
+			waitForJob(t, router, location)
+		}
+
+		fetcher.mu.Lock()
+		got := fetcher.overrides[tt.domain]
+		fetcher.mu.Unlock()
+		if got == nil || *got != tt.want {
+			t.Errorf("www fallback override for %s = %v, want %t", tt.path, got, tt.want)
+		}
+	}
+
+	for _, path := range []string{"/api/batch-analysis?no_www_fallback=maybe", "/api/jobs?no_www_fallback=maybe"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"domains": ["example.com"]}`)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("POST %s status = %d, want 400 for an invalid no_www_fallback", path, w.Code)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package cache
 
 import (
 	"errors"
+	"log/slog"
 	"time"
 
 	"adstxt-api/internal/config"
@@ -18,27 +19,46 @@ type Cache interface {
 	// Get retrieves a value from the cache. Returns ErrCacheNotFound if the key doesn't exist or has expired.
 	Get(key string) ([]byte, error)
 
+	// GetMulti retrieves several keys at once. Keys that don't exist or have expired
+	// are omitted from the returned map rather than reported as errors.
+	GetMulti(keys []string) (map[string][]byte, error)
+
 	// Set stores a value in the cache with the specified TTL. A TTL of 0 uses the default TTL.
 	Set(key string, value []byte, ttl time.Duration) error
 
 	// Delete removes a key from the cache. Returns nil if the key doesn't exist.
 	Delete(key string) error
 
+	// Flush removes every entry owned by this cache.
+	Flush() error
+
 	// Close releases any resources held by the cache implementation.
 	Close() error
+
+	// Name returns the backend type: "memory", "redis" or "file".
+	Name() string
+}
+
+// KeyLister is implemented by caches that can enumerate their keys. It is kept out of
+// Cache because listing walks every entry; callers check for it with a type assertion.
+type KeyLister interface {
+	// Keys returns the unexpired keys that start with prefix, in no particular order.
+	Keys(prefix string) ([]string, error)
 }
 
 // NewCache creates a new Cache instance based on the specified type.
-// Supported types: "memory", "redis", "file". Defaults to "memory" for unknown types.
+// Supported types: "memory", "redis", "file". Unknown types fall back to "memory" with a
+// warning; a "redis" cache that cannot connect is an error, never a silent fallback.
 func NewCache(cacheType string, cfg *config.Config) (Cache, error) {
 	switch cacheType {
 	case "memory":
-		return NewMemoryCache(cfg.CacheTTL), nil
+		return NewMemoryCacheWithCleanup(cfg.CacheTTL, cfg.MemoryCleanupInterval), nil
 	case "redis":
 		return NewRedisCache(cfg)
 	case "file":
 		return NewFileCache(cfg.FileStoragePath, cfg.CacheTTL)
 	default:
-		return NewMemoryCache(cfg.CacheTTL), nil
+		slog.Warn("unknown cache type, falling back to memory", slog.String("cache_type", cacheType))
+		return NewMemoryCacheWithCleanup(cfg.CacheTTL, cfg.MemoryCleanupInterval), nil
 	}
 }
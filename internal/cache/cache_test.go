@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"adstxt-api/internal/config"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestNewCache_UnknownTypeWarns(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(previous)
+
+	cfg := &config.Config{CacheTTL: time.Hour, MemoryCleanupInterval: time.Minute}
+	c, err := NewCache("memcached", cfg)
+	if err != nil {
+		t.Fatalf("NewCache() error = %v", err)
+	}
+	defer c.Close()
+
+	if _, ok := c.(*MemoryCache); !ok {
+		t.Errorf("NewCache() = %T, want *MemoryCache", c)
+	}
+	if !strings.Contains(buf.String(), "unknown cache type") || !strings.Contains(buf.String(), "memcached") {
+		t.Errorf("Expected a fallback warning naming the cache type, got: %s", buf.String())
+	}
+}
+
+func TestNewCache_RedisUnreachable(t *testing.T) {
+	cfg := &config.Config{CacheTTL: time.Hour, RedisAddr: "127.0.0.1:1"}
+	if _, err := NewCache("redis", cfg); err == nil {
+		t.Error("Expected an error for an unreachable redis, got nil")
+	}
+}
+
+func TestNewCache_Name(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	cfg := &config.Config{
+		CacheTTL:              time.Hour,
+		MemoryCleanupInterval: time.Minute,
+		FileStoragePath:       t.TempDir(),
+		RedisAddr:             mr.Addr(),
+	}
+	for _, cacheType := range []string{"memory", "file", "redis"} {
+		c, err := NewCache(cacheType, cfg)
+		if err != nil {
+			t.Fatalf("NewCache(%q) error = %v", cacheType, err)
+		}
+		defer c.Close()
+
+		if got := c.Name(); got != cacheType {
+			t.Errorf("NewCache(%q).Name() = %q, want %q", cacheType, got, cacheType)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
@@ -13,6 +14,7 @@ import (
 // fileCacheEntry represents a cache entry stored on disk as JSON.
 // It contains the cached value and its expiration time.
 type fileCacheEntry struct {
+	Key        string    `json:"key,omitempty"` // Original key, as the filename is its hash; empty in older files
 	Value      []byte    `json:"value"`
 	Expiration time.Time `json:"expiration"`
 }
@@ -75,6 +77,24 @@ func (fc *FileCache) Get(key string) ([]byte, error) {
 	return entry.Value, nil
 }
 
+// GetMulti retrieves several keys by reading each corresponding file in turn.
+// Missing or expired keys are omitted from the result.
+func (fc *FileCache) GetMulti(keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		value, err := fc.Get(key)
+		if err == ErrCacheNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+
+	return values, nil
+}
+
 // Set stores a value in the file cache by writing it to a JSON file.
 // If ttl is 0, the default TTL is used. The file is created with 0644 permissions.
 // The key is sanitized (hashed) to prevent path traversal attacks.
@@ -87,6 +107,7 @@ func (fc *FileCache) Set(key string, value []byte, ttl time.Duration) error {
 	defer fc.mu.Unlock()
 
 	entry := fileCacheEntry{
+		Key:        key,
 		Value:      value,
 		Expiration: time.Now().Add(ttl),
 	}
@@ -113,8 +134,61 @@ func (fc *FileCache) Delete(key string) error {
 	return os.Remove(filePath)
 }
 
+// Flush removes all cache entry files from the base path.
+// Only *.json files are removed, so unrelated files in the directory are left untouched.
+func (fc *FileCache) Flush() error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(fc.basePath, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Keys returns the unexpired keys that start with prefix by reading every entry file.
+// Files written before entries recorded their key are skipped. Implements KeyLister.
+func (fc *FileCache) Keys(prefix string) ([]string, error) {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+
+	files, err := filepath.Glob(filepath.Join(fc.basePath, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var keys []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue // Removed since the glob, or unreadable like a missing entry in Get
+		}
+		var entry fileCacheEntry
+		if err := json.Unmarshal(data, &entry); err != nil || entry.Key == "" {
+			continue
+		}
+		if strings.HasPrefix(entry.Key, prefix) && !now.After(entry.Expiration) {
+			keys = append(keys, entry.Key)
+		}
+	}
+	return keys, nil
+}
+
 // Close is a no-op for FileCache as there are no persistent connections or resources to clean up.
 // Implements the Cache interface.
 func (fc *FileCache) Close() error {
 	return nil
 }
+
+// Name returns "file". Implements the Cache interface.
+func (fc *FileCache) Name() string {
+	return "file"
+}
@@ -3,6 +3,7 @@ package cache
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 )
@@ -175,3 +176,82 @@ func TestFileCache_CreateDirectory(t *testing.T) {
 		t.Error("NewFileCache() did not create directory")
 	}
 }
+
+func TestFileCache_Flush(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	fc, err := NewFileCache(tmpDir, 1*time.Hour)
+	if err != nil {
+		t.Fatalf("NewFileCache() error = %v", err)
+	}
+	defer fc.Close()
+
+	_ = fc.Set("a", []byte("1"), 0)
+	_ = fc.Set("b", []byte("2"), 0)
+
+	// Unrelated files in the directory must survive a flush
+	otherFile := filepath.Join(tmpDir, "keep.txt")
+	if err := os.WriteFile(otherFile, []byte("keep"), 0644); err != nil {
+		t.Fatalf("Failed to write unrelated file: %v", err)
+	}
+
+	if err := fc.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if _, err := fc.Get("a"); err != ErrCacheNotFound {
+		t.Errorf("Get() after Flush() error = %v, want ErrCacheNotFound", err)
+	}
+	if _, err := os.Stat(otherFile); err != nil {
+		t.Errorf("Flush() removed unrelated file: %v", err)
+	}
+}
+
+// TestFileCache_GetMulti tests that hits are returned and misses omitted
+func TestFileCache_GetMulti(t *testing.T) {
+	fc, err := NewFileCache(t.TempDir(), 1*time.Hour)
+	if err != nil {
+		t.Fatalf("NewFileCache() error = %v", err)
+	}
+	defer fc.Close()
+
+	_ = fc.Set("a", []byte("1"), 0)
+	_ = fc.Set("b", []byte("2"), 0)
+
+	values, err := fc.GetMulti([]string{"a", "b", "missing"})
+	if err != nil {
+		t.Fatalf("GetMulti() error = %v", err)
+	}
+	if len(values) != 2 || string(values["a"]) != "1" || string(values["b"]) != "2" {
+		t.Errorf("GetMulti() = %v, want a=1 b=2", values)
+	}
+}
+
+// TestFileCache_Keys tests that keys are recovered from the entries despite hashed filenames
+func TestFileCache_Keys(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	fc, err := NewFileCache(tmpDir, 1*time.Hour)
+	if err != nil {
+		t.Fatalf("NewFileCache() error = %v", err)
+	}
+	defer fc.Close()
+
+	_ = fc.Set("adstxt:a", []byte("1"), 0)
+	_ = fc.Set("adstxt:expired", []byte("2"), -time.Second)
+	_ = fc.Set("other:b", []byte("3"), 0)
+
+	// An entry written before keys were recorded is skipped
+	legacy := `{"value":"MQ==","expiration":"2999-01-01T00:00:00Z"}`
+	if err := os.WriteFile(filepath.Join(tmpDir, "legacy.json"), []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write legacy entry: %v", err)
+	}
+
+	keys, err := fc.Keys("adstxt:")
+	if err != nil {
+		t.Fatalf("Keys() error = %v", err)
+	}
+	if !slices.Equal(keys, []string{"adstxt:a"}) {
+		t.Errorf("Keys() = %v, want [adstxt:a]", keys)
+	}
+}
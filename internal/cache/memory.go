@@ -2,7 +2,9 @@ package cache
 
 import (
 	"log"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,24 +15,43 @@ type cacheEntry struct {
 }
 
 // MemoryCache is an in-memory cache implementation that stores data in a map with expiration times.
-// It automatically cleans up expired entries every 5 minutes via a background goroutine.
+// It automatically cleans up expired entries on a configurable interval via a background goroutine.
 // All methods are safe for concurrent use.
 type MemoryCache struct {
 	data       map[string]*cacheEntry
 	mu         sync.RWMutex
 	defaultTTL time.Duration
 	cleanupT   *time.Ticker
+
+	cleanupInterval time.Duration
+	lastCleanup     atomic.Int64 // UnixNano of the last completed sweep, or of creation
 }
 
-// NewMemoryCache creates a new MemoryCache with the specified default TTL.
-// Starts background goroutine for cleanup every 5 minutes.
+// DefaultMemoryCleanupInterval is how often MemoryCache sweeps expired entries by default.
 // Tried 1min interval but caused unnecessary CPU usage for our TTLs (1h default)
+const DefaultMemoryCleanupInterval = 5 * time.Minute
+
+// NewMemoryCache creates a new MemoryCache with the specified default TTL.
+// Starts background goroutine for cleanup every DefaultMemoryCleanupInterval.
 func NewMemoryCache(defaultTTL time.Duration) *MemoryCache {
+	return NewMemoryCacheWithCleanup(defaultTTL, DefaultMemoryCleanupInterval)
+}
+
+// NewMemoryCacheWithCleanup creates a new MemoryCache that sweeps expired entries every cleanupInterval.
+// Shorter intervals bound memory under high key churn at the cost of more frequent locking.
+// A non-positive interval falls back to DefaultMemoryCleanupInterval.
+func NewMemoryCacheWithCleanup(defaultTTL, cleanupInterval time.Duration) *MemoryCache {
+	if cleanupInterval <= 0 {
+		cleanupInterval = DefaultMemoryCleanupInterval
+	}
+
 	mc := &MemoryCache{
-		data:       make(map[string]*cacheEntry),
-		defaultTTL: defaultTTL,
-		cleanupT:   time.NewTicker(5 * time.Minute),
+		data:            make(map[string]*cacheEntry),
+		defaultTTL:      defaultTTL,
+		cleanupT:        time.NewTicker(cleanupInterval),
+		cleanupInterval: cleanupInterval,
 	}
+	mc.lastCleanup.Store(time.Now().UnixNano())
 
 	go mc.cleanup()
 	return mc
@@ -50,6 +71,24 @@ func (mc *MemoryCache) Get(key string) ([]byte, error) {
 	return entry.value, nil
 }
 
+// GetMulti retrieves several keys under a single read lock.
+// Missing or expired keys are omitted from the result.
+func (mc *MemoryCache) GetMulti(keys []string) (map[string][]byte, error) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	now := time.Now()
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		entry, exists := mc.data[key]
+		if exists && !now.After(entry.expiration) {
+			values[key] = entry.value
+		}
+	}
+
+	return values, nil
+}
+
 // Set stores a value in the cache with the specified TTL.
 // If ttl is 0, the default TTL is used. The entry will be automatically
 // removed after it expires during the next cleanup cycle.
@@ -79,6 +118,30 @@ func (mc *MemoryCache) Delete(key string) error {
 	return nil
 }
 
+// Flush removes all entries from the cache.
+func (mc *MemoryCache) Flush() error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.data = make(map[string]*cacheEntry)
+	return nil
+}
+
+// Keys returns the unexpired keys that start with prefix. Implements KeyLister.
+func (mc *MemoryCache) Keys(prefix string) ([]string, error) {
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+
+	now := time.Now()
+	var keys []string
+	for key, entry := range mc.data {
+		if strings.HasPrefix(key, prefix) && !now.After(entry.expiration) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
 // Close stops the background cleanup goroutine and releases resources.
 // Should be called when the cache is no longer needed to prevent goroutine leaks.
 func (mc *MemoryCache) Close() error {
@@ -86,12 +149,30 @@ func (mc *MemoryCache) Close() error {
 	return nil
 }
 
-// cleanup is a background goroutine that removes expired entries every 5 minutes.
+// Name returns "memory". Implements the Cache interface.
+func (mc *MemoryCache) Name() string {
+	return "memory"
+}
+
+// LastCleanup returns when the last expired-entry sweep completed, or when the cache was
+// created if none has yet. Sweeps that panic are not counted, so a broken cleanup shows up as stale.
+func (mc *MemoryCache) LastCleanup() time.Time {
+	return time.Unix(0, mc.lastCleanup.Load())
+}
+
+// CleanupInterval returns how often expired entries are swept.
+func (mc *MemoryCache) CleanupInterval() time.Duration {
+	return mc.cleanupInterval
+}
+
+// cleanup is a background goroutine that removes expired entries on every cleanup tick.
 // It iterates through all entries and deletes those that have passed their expiration time.
+// If the goroutine itself panics, it is restarted so sweeping never silently stops.
 func (mc *MemoryCache) cleanup() {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("PANIC in MemoryCache cleanup goroutine: %v", r)
+			log.Printf("PANIC in MemoryCache cleanup goroutine, restarting: %v", r)
+			go mc.cleanup()
 		}
 	}()
 
@@ -118,6 +199,7 @@ func (mc *MemoryCache) cleanup() {
 			if deleted > 0 {
 				log.Printf("MemoryCache cleanup: removed %d expired entries", deleted)
 			}
+			mc.lastCleanup.Store(time.Now().UnixNano())
 		}()
 	}
 }
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"slices"
 	"testing"
 	"time"
 )
@@ -194,3 +195,103 @@ func TestMemoryCache_Close(t *testing.T) {
 		t.Error("Should still be able to use cache after Close()")
 	}
 }
+
+func TestMemoryCache_CustomCleanupInterval(t *testing.T) {
+	cache := NewMemoryCacheWithCleanup(1*time.Hour, 20*time.Millisecond)
+	defer cache.Close()
+
+	_ = cache.Set("short", []byte("value"), 10*time.Millisecond)
+	_ = cache.Set("long", []byte("value"), 1*time.Hour)
+
+	// Give the background sweep a few ticks to run
+	time.Sleep(100 * time.Millisecond)
+
+	cache.mu.RLock()
+	_, shortExists := cache.data["short"]
+	_, longExists := cache.data["long"]
+	cache.mu.RUnlock()
+
+	if shortExists {
+		t.Error("Expected expired entry to be removed by background cleanup")
+	}
+	if !longExists {
+		t.Error("Expected unexpired entry to survive cleanup")
+	}
+}
+
+func TestMemoryCache_LastCleanup(t *testing.T) {
+	cache := NewMemoryCacheWithCleanup(1*time.Hour, 20*time.Millisecond)
+	defer cache.Close()
+
+	created := cache.LastCleanup()
+	if time.Since(created) > time.Second {
+		t.Errorf("Expected LastCleanup to start at creation, got %v", created)
+	}
+	if cache.CleanupInterval() != 20*time.Millisecond {
+		t.Errorf("Expected cleanup interval 20ms, got %v", cache.CleanupInterval())
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if !cache.LastCleanup().After(created) {
+		t.Error("Expected LastCleanup to advance after background sweeps")
+	}
+}
+
+func TestMemoryCache_Flush(t *testing.T) {
+	cache := NewMemoryCache(1 * time.Hour)
+	defer cache.Close()
+
+	_ = cache.Set("a", []byte("1"), 0)
+	_ = cache.Set("b", []byte("2"), 0)
+
+	if err := cache.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	if _, err := cache.Get("a"); err != ErrCacheNotFound {
+		t.Errorf("Expected ErrCacheNotFound after flush, got %v", err)
+	}
+
+	// Cache remains usable after a flush
+	_ = cache.Set("c", []byte("3"), 0)
+	if _, err := cache.Get("c"); err != nil {
+		t.Errorf("Expected Set/Get to work after flush, got %v", err)
+	}
+}
+
+func TestMemoryCache_GetMulti(t *testing.T) {
+	cache := NewMemoryCache(1 * time.Hour)
+	defer cache.Close()
+
+	_ = cache.Set("a", []byte("1"), 0)
+	_ = cache.Set("expired", []byte("2"), 1*time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	values, err := cache.GetMulti([]string{"a", "expired", "missing"})
+	if err != nil {
+		t.Fatalf("GetMulti failed: %v", err)
+	}
+	if len(values) != 1 || string(values["a"]) != "1" {
+		t.Errorf("Expected only a=1, got %v", values)
+	}
+}
+
+func TestMemoryCache_Keys(t *testing.T) {
+	cache := NewMemoryCache(1 * time.Hour)
+	defer cache.Close()
+
+	_ = cache.Set("adstxt:a", []byte("1"), 0)
+	_ = cache.Set("adstxt:b", []byte("2"), 0)
+	_ = cache.Set("adstxt:expired", []byte("3"), 1*time.Millisecond)
+	_ = cache.Set("other:c", []byte("4"), 0)
+	time.Sleep(5 * time.Millisecond)
+
+	keys, err := cache.Keys("adstxt:")
+	if err != nil {
+		t.Fatalf("Keys failed: %v", err)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"adstxt:a", "adstxt:b"}) {
+		t.Errorf("Expected adstxt:a and adstxt:b, got %v", keys)
+	}
+}
@@ -2,6 +2,10 @@ package cache
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"adstxt-api/internal/config"
@@ -11,59 +15,182 @@ import (
 
 // RedisCache is a Redis-based cache implementation that stores data in a Redis server.
 // It provides distributed caching capabilities with automatic expiration.
+// The underlying client may be a single node, a Sentinel failover set, or a Cluster.
 // All methods are safe for concurrent use as they use the underlying Redis client's thread-safe operations.
 type RedisCache struct {
-	client     *redis.Client
+	client     redis.UniversalClient
 	defaultTTL time.Duration
 	ctx        context.Context
+	keyPrefix  string // Namespace prepended to every key; empty means the whole DB is owned
 }
 
 // NewRedisCache creates a new RedisCache using the configuration provided.
 // It establishes a connection to the Redis server and verifies connectivity with a PING command.
-// Returns an error if the Redis server is unreachable or authentication fails.
+// Returns an error if the Redis server is unreachable, authentication fails, or the mode is unknown.
 func NewRedisCache(cfg *config.Config) (*RedisCache, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.RedisAddr,
-		Password: cfg.RedisPassword,
-		DB:       cfg.RedisDB,
-	})
+	client, err := newRedisClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	ctx := context.Background()
 	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, err
+		_ = client.Close()
+		return nil, fmt.Errorf("connect to redis: %w", err)
 	}
 
 	return &RedisCache{
 		client:     client,
 		defaultTTL: cfg.CacheTTL,
 		ctx:        ctx,
+		keyPrefix:  cfg.RedisKeyPrefix,
 	}, nil
 }
 
+// newRedisClient builds the Redis client for cfg.RedisMode.
+// Supported modes: "single" (default), "sentinel", and "cluster".
+// Cluster mode ignores RedisDB since Redis Cluster only supports database 0.
+func newRedisClient(cfg *config.Config) (redis.UniversalClient, error) {
+	switch cfg.RedisMode {
+	case "", "single":
+		return redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr,
+			Password: cfg.RedisPassword,
+			DB:       cfg.RedisDB,
+		}), nil
+	case "sentinel":
+		if len(cfg.RedisSentinelAddrs) == 0 || cfg.RedisMasterName == "" {
+			return nil, errors.New("redis sentinel mode requires sentinel addresses and a master name")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    cfg.RedisMasterName,
+			SentinelAddrs: cfg.RedisSentinelAddrs,
+			Password:      cfg.RedisPassword,
+			DB:            cfg.RedisDB,
+		}), nil
+	case "cluster":
+		if len(cfg.RedisClusterAddrs) == 0 {
+			return nil, errors.New("redis cluster mode requires cluster addresses")
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    cfg.RedisClusterAddrs,
+			Password: cfg.RedisPassword,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown redis mode: %s", cfg.RedisMode)
+	}
+}
+
 // Get retrieves a value from Redis by key.
 // Returns ErrCacheNotFound if the key doesn't exist or has expired.
 // Redis handles expiration automatically, so expired keys are treated as not found.
 func (rc *RedisCache) Get(key string) ([]byte, error) {
-	val, err := rc.client.Get(rc.ctx, key).Bytes()
+	val, err := rc.client.Get(rc.ctx, rc.keyPrefix+key).Bytes()
 	if err == redis.Nil {
 		return nil, ErrCacheNotFound
 	}
 	return val, err
 }
 
+// GetMulti retrieves several keys in a single pipelined round-trip.
+// A pipeline of GETs is used rather than MGET so that keys hashing to
+// different slots still work in cluster mode. Missing keys are omitted from the result.
+func (rc *RedisCache) GetMulti(keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := rc.client.Pipelined(rc.ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(rc.ctx, rc.keyPrefix+key)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	for i, cmd := range cmds {
+		val, err := cmd.Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[keys[i]] = val
+	}
+
+	return values, nil
+}
+
 // Set stores a value in Redis with the specified TTL.
 // If ttl is 0, the default TTL is used. Redis will automatically remove the key after expiration.
 func (rc *RedisCache) Set(key string, value []byte, ttl time.Duration) error {
 	if ttl == 0 {
 		ttl = rc.defaultTTL
 	}
-	return rc.client.Set(rc.ctx, key, value, ttl).Err()
+	return rc.client.Set(rc.ctx, rc.keyPrefix+key, value, ttl).Err()
 }
 
 // Delete removes a key from Redis.
 // Returns nil even if the key doesn't exist.
 func (rc *RedisCache) Delete(key string) error {
-	return rc.client.Del(rc.ctx, key).Err()
+	return rc.client.Del(rc.ctx, rc.keyPrefix+key).Err()
+}
+
+// Flush removes the cache's entries from Redis. FLUSHALL is never used.
+// With a key prefix configured only keys in that namespace are deleted (via SCAN),
+// so a shared database is left intact; otherwise the configured DB is cleared with FLUSHDB.
+func (rc *RedisCache) Flush() error {
+	flushNode := func(ctx context.Context, client redis.Cmdable) error {
+		if rc.keyPrefix == "" {
+			return client.FlushDB(ctx).Err()
+		}
+
+		iter := client.Scan(ctx, 0, rc.keyPrefix+"*", 100).Iterator()
+		for iter.Next(ctx) {
+			if err := client.Del(ctx, iter.Val()).Err(); err != nil {
+				return err
+			}
+		}
+		return iter.Err()
+	}
+
+	// Cluster keys are spread across shards, so every master has to be flushed
+	if cluster, ok := rc.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(rc.ctx, func(ctx context.Context, node *redis.Client) error {
+			return flushNode(ctx, node)
+		})
+	}
+	return flushNode(rc.ctx, rc.client)
+}
+
+// Keys returns the keys that start with prefix, without the configured key prefix,
+// found via SCAN on every master in cluster mode. Implements KeyLister.
+// prefix is used in a SCAN pattern, so it must not contain glob metacharacters.
+func (rc *RedisCache) Keys(prefix string) ([]string, error) {
+	var mu sync.Mutex
+	var keys []string
+	scanNode := func(ctx context.Context, client redis.Cmdable) error {
+		iter := client.Scan(ctx, 0, rc.keyPrefix+prefix+"*", 100).Iterator()
+		for iter.Next(ctx) {
+			mu.Lock()
+			keys = append(keys, strings.TrimPrefix(iter.Val(), rc.keyPrefix))
+			mu.Unlock()
+		}
+		return iter.Err()
+	}
+
+	if cluster, ok := rc.client.(*redis.ClusterClient); ok {
+		err := cluster.ForEachMaster(rc.ctx, func(ctx context.Context, node *redis.Client) error {
+			return scanNode(ctx, node)
+		})
+		return keys, err
+	}
+	return keys, scanNode(rc.ctx, rc.client)
 }
 
 // Close closes the Redis client connection and releases resources.
@@ -71,3 +198,8 @@ func (rc *RedisCache) Delete(key string) error {
 func (rc *RedisCache) Close() error {
 	return rc.client.Close()
 }
+
+// Name returns "redis". Implements the Cache interface.
+func (rc *RedisCache) Name() string {
+	return "redis"
+}
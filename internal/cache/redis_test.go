@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"slices"
 	"testing"
 	"time"
 
@@ -524,3 +525,204 @@ func TestRedisCache_ContextCancellation(t *testing.T) {
 		t.Error("Set() after context cancel should error, got nil")
 	}
 }
+
+// TestNewRedisCache_Modes tests client construction for the supported Redis modes
+func TestNewRedisCache_Modes(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	tests := []struct {
+		name    string
+		cfg     *config.Config
+		wantErr bool
+	}{
+		{"default single", &config.Config{RedisAddr: mr.Addr()}, false},
+		{"explicit single", &config.Config{RedisMode: "single", RedisAddr: mr.Addr()}, false},
+		{"sentinel without addrs", &config.Config{RedisMode: "sentinel", RedisMasterName: "mymaster"}, true},
+		{"sentinel without master", &config.Config{RedisMode: "sentinel", RedisSentinelAddrs: []string{mr.Addr()}}, true},
+		{"cluster without addrs", &config.Config{RedisMode: "cluster"}, true},
+		{"unknown mode", &config.Config{RedisMode: "bogus", RedisAddr: mr.Addr()}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := NewRedisCache(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRedisCache() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if cache != nil {
+				cache.Close()
+			}
+		})
+	}
+}
+
+// TestNewRedisClient_Types tests that each mode builds the expected client type
+func TestNewRedisClient_Types(t *testing.T) {
+	sentinel, err := newRedisClient(&config.Config{
+		RedisMode:          "sentinel",
+		RedisSentinelAddrs: []string{"localhost:26379"},
+		RedisMasterName:    "mymaster",
+	})
+	if err != nil {
+		t.Fatalf("newRedisClient() sentinel error = %v", err)
+	}
+	defer sentinel.Close()
+	if _, ok := sentinel.(*redis.Client); !ok {
+		t.Errorf("Expected failover *redis.Client, got %T", sentinel)
+	}
+
+	cluster, err := newRedisClient(&config.Config{
+		RedisMode:         "cluster",
+		RedisClusterAddrs: []string{"localhost:7000"},
+	})
+	if err != nil {
+		t.Fatalf("newRedisClient() cluster error = %v", err)
+	}
+	defer cluster.Close()
+	if _, ok := cluster.(*redis.ClusterClient); !ok {
+		t.Errorf("Expected *redis.ClusterClient, got %T", cluster)
+	}
+}
+
+// TestRedisCache_Flush tests flushing the whole DB when no prefix is configured
+func TestRedisCache_Flush(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	cache, err := NewRedisCache(&config.Config{RedisAddr: mr.Addr(), CacheTTL: 5 * time.Minute})
+	if err != nil {
+		t.Fatalf("NewRedisCache() error = %v", err)
+	}
+	defer cache.Close()
+
+	_ = cache.Set("a", []byte("1"), 0)
+	_ = cache.Set("b", []byte("2"), 0)
+
+	if err := cache.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if keys := mr.Keys(); len(keys) != 0 {
+		t.Errorf("Expected empty DB after Flush(), got keys %v", keys)
+	}
+}
+
+// TestRedisCache_FlushWithPrefix tests that only the prefix namespace is flushed
+func TestRedisCache_FlushWithPrefix(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	cache, err := NewRedisCache(&config.Config{
+		RedisAddr:      mr.Addr(),
+		RedisKeyPrefix: "adstxt-api:",
+		CacheTTL:       5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewRedisCache() error = %v", err)
+	}
+	defer cache.Close()
+
+	_ = cache.Set("a", []byte("1"), 0)
+	if !mr.Exists("adstxt-api:a") {
+		t.Fatal("Expected key to be stored under the prefix")
+	}
+
+	// Key owned by another application sharing the DB
+	_ = mr.Set("other-app:key", "value")
+
+	if err := cache.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if _, err := cache.Get("a"); err != ErrCacheNotFound {
+		t.Errorf("Get() after Flush() error = %v, want ErrCacheNotFound", err)
+	}
+	if !mr.Exists("other-app:key") {
+		t.Error("Flush() removed a key outside the prefix namespace")
+	}
+}
+
+// TestRedisCache_GetMulti tests that hits are returned and misses omitted in one call
+func TestRedisCache_GetMulti(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	cache, err := NewRedisCache(&config.Config{
+		RedisAddr:      mr.Addr(),
+		RedisKeyPrefix: "adstxt-api:",
+		CacheTTL:       5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewRedisCache() error = %v", err)
+	}
+	defer cache.Close()
+
+	_ = cache.Set("a", []byte("1"), 0)
+	_ = cache.Set("b", []byte("2"), 0)
+
+	values, err := cache.GetMulti([]string{"a", "missing", "b"})
+	if err != nil {
+		t.Fatalf("GetMulti() error = %v", err)
+	}
+	if len(values) != 2 {
+		t.Fatalf("GetMulti() returned %d values, want 2", len(values))
+	}
+	if string(values["a"]) != "1" || string(values["b"]) != "2" {
+		t.Errorf("GetMulti() = %v, want a=1 b=2", values)
+	}
+	if _, ok := values["missing"]; ok {
+		t.Error("GetMulti() should omit missing keys")
+	}
+
+	// Empty input needs no round-trip
+	values, err = cache.GetMulti(nil)
+	if err != nil || len(values) != 0 {
+		t.Errorf("GetMulti(nil) = %v, %v; want empty map, nil", values, err)
+	}
+}
+
+// TestRedisCache_Keys tests that keys are listed without the configured prefix
+func TestRedisCache_Keys(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	cache, err := NewRedisCache(&config.Config{
+		RedisAddr:      mr.Addr(),
+		RedisKeyPrefix: "adstxt-api:",
+		CacheTTL:       5 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewRedisCache() error = %v", err)
+	}
+	defer cache.Close()
+
+	_ = cache.Set("adstxt:a", []byte("1"), 0)
+	_ = cache.Set("adstxt:b", []byte("2"), 0)
+	_ = cache.Set("other:c", []byte("3"), 0)
+	_ = mr.Set("adstxt:outside", "value") // Outside the prefix namespace
+
+	keys, err := cache.Keys("adstxt:")
+	if err != nil {
+		t.Fatalf("Keys() error = %v", err)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"adstxt:a", "adstxt:b"}) {
+		t.Errorf("Keys() = %v, want [adstxt:a adstxt:b]", keys)
+	}
+}
@@ -1,52 +1,321 @@
 // Package config provides configuration management for the ads.txt API service.
-// Configuration is loaded from environment variables with sensible defaults.
+// Configuration is loaded from environment variables and an optional config file
+// with sensible defaults. Precedence is env > file > default.
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // Config holds all configuration values for the application.
 // All values are loaded from environment variables with fallback defaults.
 type Config struct {
-	Port               string        // HTTP server port (default: 8080)
-	CacheType          string        // Cache backend: memory, redis, or file (default: memory)
-	CacheTTL           time.Duration // Cache entry time-to-live (default: 1h)
-	RateLimitPerSecond int           // Rate limit per client per second (default: 10)
-	RedisAddr          string        // Redis server address (default: localhost:6379)
-	RedisPassword      string        // Redis password (default: empty)
-	RedisDB            int           // Redis database number (default: 0)
-	FileStoragePath    string        // File cache storage path (default: ./cache)
-	RequestTimeout     time.Duration // HTTP request timeout (default: 10s)
+	Port                string        // HTTP server port (default: 8080)
+	CacheType           string        // Cache backend: memory, redis, or file (default: memory)
+	CacheTTL            time.Duration // Cache entry time-to-live (default: 1h)
+	CacheMode           string        // What is cached per domain: parsed analyses, or raw ads.txt re-parsed on read (default: parsed)
+	CacheSerialization  string        // Encoding of cached analyses: json, gob, msgpack, or a codec registered by the embedder (default: json)
+	RateLimitPerSecond  int           // Rate limit per client per second (default: 10)
+	RateLimitBurst      int           // Requests a client may make in a spike, refilled at RateLimitPerSecond; 0 uses RateLimitPerSecond (default: 0)
+	RedisAddr           string        // Redis server address (default: localhost:6379)
+	RedisPassword       string        // Redis password (default: empty)
+	RedisDB             int           // Redis database number (default: 0)
+	RedisMode           string        // Redis topology: single, sentinel, or cluster (default: single)
+	RedisSentinelAddrs  []string      // Sentinel addresses for sentinel mode (default: empty)
+	RedisMasterName     string        // Sentinel master name for sentinel mode (default: empty)
+	RedisClusterAddrs   []string      // Node addresses for cluster mode (default: empty)
+	RedisKeyPrefix      string        // Namespace prefix for all Redis keys (default: empty)
+	FileStoragePath     string        // File cache storage path (default: ./cache)
+	RequestTimeout      time.Duration // HTTP request timeout (default: 10s)
+	FetchMaxConcurrent  int           // Max outbound ads.txt requests in flight, 0 disables (default: 100)
+	FetchBasicAuth      []string      // Outbound Basic Auth as domain=user:pass entries (default: empty)
+	FetchDNSCacheTTL    time.Duration // How long resolved publisher addresses are reused, 0 disables (default: 60s)
+	CommentDirectives   []string      // Extra name=regexp patterns for leading comment metadata (default: empty)
+	AccountPrefixGroups []string      // Account ID prefixes for ?group_accounts, longest match wins (default: empty, grouped by leading non-digits)
+	MaxAdvertisers      int           // Max distinct advertisers tracked per file, 0 disables (default: 100000)
+	MaxLineLength       int           // Longer ads.txt lines are skipped as malformed (default: 8192)
+	ChangeHistoryTTL    time.Duration // How long the previous analysis is kept for change detection, 0 disables (default: 0)
+	NegativeCacheTTL    time.Duration // How long fetch failures are cached before retrying, 0 disables (default: 5m)
+	NormalizeWWW        bool          // Treat www.example.com and example.com as one cache entry (default: false)
+	AdminToken          string        // Bearer token for admin endpoints; empty disables them (default: empty)
+	StrictJSON          bool          // Reject request bodies containing unknown fields (default: true)
+	TimestampPrecision  string        // Response timestamps, always UTC, to the "seconds" or "milliseconds" (default: seconds)
+	IncludeRawHash      bool          // Add raw_content_hash, the SHA-256 of the fetched ads.txt bytes, to analyses (default: false)
+
+	// Outbound fetcher connection pool (0 uses the fetcher defaults)
+	FetchMaxIdleConns        int           // Idle connections kept across all publishers (default: 100)
+	FetchMaxIdleConnsPerHost int           // Idle connections kept per publisher (default: 10)
+	FetchIdleConnTimeout     time.Duration // How long an idle connection is kept (default: 90s)
+
+	// Outbound input limits (0 uses the fetcher defaults)
+	FetchMaxDomainLabels int // Max labels in a domain, checked before anything is fetched (default: 10)
+	FetchMaxURLLength    int // Max length of a constructed ads.txt URL (default: 2048)
+
+	// Outbound circuit breaker, per publisher
+	FetchCircuitFailureThreshold int           // Consecutive failures that open a publisher's circuit, 0 disables (default: 5)
+	FetchCircuitWindow           time.Duration // How long failures count as consecutive (default: 1m)
+	FetchCircuitCooldown         time.Duration // How long an open circuit fails fast before a probe fetch (default: 30s)
+
+	// Fetch allowlist; when either list is set, only matching domains may be analyzed
+	FetchAllowedDomains []string // Allowed publisher domains, subdomains included (default: empty, all allowed)
+	FetchAllowedTLDs    []string // Allowed top-level domains such as com or co.uk (default: empty, all allowed)
+
+	// Outbound redirect policy
+	FetchSameDomainRedirectsOnly bool     // Reject redirects that leave the publisher's registrable domain (default: false)
+	FetchRedirectAllowedDomains  []string // Extra redirect targets allowed in same-domain mode (default: empty)
+
+	// Plain http fallback after a failed https fetch: "transport" only when https got no
+	// response (TLS or connection failure), "always" after any failure, or "never"
+	FetchHTTPFallback  string // (default: transport)
+	FetchMaxAttempts   int    // URLs tried per fetch: https apex, http apex, https www (default: 3)
+	FetchNoWWWFallback bool   // Only accept ads.txt from the exact host, reporting www-only files as errors (default: false)
+
+	// Racing the URL patterns instead of trying them in order, for latency over outbound traffic
+	FetchRace        bool          // Request the URL patterns concurrently and keep the first 200 (default: false)
+	FetchRaceStagger time.Duration // Delay before each raced pattern starts, unless the previous one failed (default: 250ms)
+
+	// Outbound mutual TLS, applied to https fetches only
+	FetchClientCert      string   // PEM client certificate presented to publishers, requires FetchClientKey (default: empty, none)
+	FetchClientKey       string   // PEM private key for FetchClientCert (default: empty)
+	FetchCACert          string   // PEM CA bundle trusted in addition to the system roots (default: empty)
+	FetchMinTLSVersion   string   // Lowest TLS version negotiated with publishers: 1.0, 1.1, 1.2 or 1.3 (default: 1.2)
+	FetchClientCertHosts []string // Partner hosts, with their subdomains, FetchClientCert is presented to; required with it (default: empty)
+
+	// Outbound egress proxy
+	FetchProxyURL string // http, https or socks5 proxy URL for all fetches, may embed user:pass (default: empty, direct)
+
+	// Testing only: hosts whose TLS certificates are not verified
+	FetchInsecureSkipVerifyHosts []string // Exact hosts, never a global skip; each unverified connection is logged (default: empty, all verified)
+
+	// Inbound server limits
+	MaxInflightRequests    int // Max concurrent inbound requests, 0 disables (default: 1000)
+	MaxConcurrentPerClient int // Max concurrent inbound requests per client IP, 0 disables (default: 20)
+	BatchWorkers           int // Workers shared by all batch requests, 0 uses one goroutine per domain (default: 32)
+	BatchMaxBodyBytes      int // Max batch request body, larger ones get 413 (default: 1048576)
+
+	// Streamed NDJSON batches (/api/batch-ndjson)
+	BatchStreamMaxDomains int           // Max domains per streamed batch (default: 500)
+	BatchStreamTimeout    time.Duration // Deadline for a whole streamed batch (default: 5m)
+
+	// Caching of empty results
+	CacheEmptyResults   bool          // Cache zero-advertiser results for the full CACHE_TTL (default: false)
+	EmptyResultCacheTTL time.Duration // TTL for zero-advertiser results unless CACHE_EMPTY_RESULTS, 0 skips caching them (default: 5m)
+
+	// Serving expired analyses when a re-fetch fails
+	ServeStaleOnError bool          // Answer with the expired analysis, flagged stale, instead of a fetch error (default: false)
+	StaleGracePeriod  time.Duration // How long entries are kept past their TTL for ServeStaleOnError (default: 24h)
+
+	// Cache seeding
+	SeedDir string // Directory of ads.txt files named by domain, loaded into the cache on startup (default: empty, disabled)
+
+	// Health checks
+	HealthCacheTTL time.Duration // How long /health reuses its last check results, 0 checks on every probe (default: 5s)
+
+	// Slow request log (/api/slowlog)
+	SlowLogSize      int           // Slowest requests kept, 0 disables (default: 20)
+	SlowLogThreshold time.Duration // Only requests taking at least this long are kept (default: 1s)
+	SlowLogWindow    time.Duration // Requests older than this drop out of the log (default: 1h)
+
+	// Cache verification (/api/cache/verify)
+	CacheVerifyTimeout time.Duration // Deadline for re-fetching every domain being verified (default: 2m)
+
+	// Graceful shutdown
+	ShutdownDrainDelay      time.Duration // How long /ready fails before the server stops accepting connections (default: 5s)
+	MetricsFlushOnShutdown  bool          // Write a final metrics snapshot before the server shuts down (default: false)
+	MetricsFlushDestination string        // File path to write the snapshot to, or http(s) URL to POST it to (default: empty)
+
+	// Trace correlation
+	TracingEnabled bool // Start OpenTelemetry spans per request and log trace_id/span_id (default: false)
+
+	// Response compression
+	ResponseCompressionLevel int // Brotli/gzip level from 1 (fastest) to 9 (smallest), 0 disables (default: 6)
+
+	// Vary header
+	VaryHeaders []string // Extra request headers listed in Vary, for proxies that also vary on them (default: empty)
+
+	// CORS
+	CORSAllowedMethods []string      // Methods advertised to browsers (default: empty, meaning GET, POST, OPTIONS)
+	CORSAllowedHeaders []string      // Request headers advertised to browsers (default: empty, meaning Content-Type)
+	CORSMaxAge         time.Duration // How long browsers may cache a preflight response, 0 omits the header (default: 24h)
+
+	// Background cleanup settings
+	MemoryCleanupInterval    time.Duration // Memory cache expired-entry sweep interval (default: 5m)
+	RateLimitCleanupInterval time.Duration // Rate limiter inactive-client sweep interval (default: 1m)
+	RateLimitClientTTL       time.Duration // Inactivity before a rate-limited client is forgotten (default: 5m)
+	RateLimitMaxClients      int           // Max clients tracked by the rate limiter, 0 is unbounded (default: 100000)
+
+	// Background refresh of hot domains
+	AutoRefreshTopK     int           // Number of most-requested domains kept warm, 0 disables (default: 0)
+	AutoRefreshInterval time.Duration // How often hot domains are checked for refresh (default: 1m)
+	AutoRefreshAhead    time.Duration // Refresh entries this long before they expire (default: 5m)
+	AutoRefreshRate     int           // Max background fetches per second (default: 1)
+
+	// Quality monitoring
+	MinAdvertisersThreshold int // Flag results with fewer advertisers as suspicious, 0 disables (default: 0)
+
+	// Async jobs
+	JobWorkers    int           // Domains analyzed concurrently across all jobs, minimum 1 (default: 4)
+	JobFetchRate  int           // Max job fetches per second across all jobs, 0 is unpaced (default: 10)
+	JobMaxDomains int           // Max domains per job, 0 is unlimited (default: 10000)
+	JobTTL        time.Duration // How long job progress and results are kept, 0 uses CACHE_TTL (default: 24h)
 }
 
 // Load creates a new Config by reading environment variables.
-// If an environment variable is not set or invalid, the default value is used.
-func Load() *Config {
-	return &Config{
-		Port:               getEnv("PORT", "8080"),
-		CacheType:          getEnv("CACHE_TYPE", "memory"),
-		CacheTTL:           getDurationEnv("CACHE_TTL", 1*time.Hour),
-		RateLimitPerSecond: getIntEnv("RATE_LIMIT_PER_SECOND", 10),
-		RedisAddr:          getEnv("REDIS_ADDR", "localhost:6379"),
-		RedisPassword:      getEnv("REDIS_PASSWORD", ""),
-		RedisDB:            getIntEnv("REDIS_DB", 0),
-		FileStoragePath:    getEnv("FILE_STORAGE_PATH", "./cache"),
-		RequestTimeout:     getDurationEnv("REQUEST_TIMEOUT", 10*time.Second),
+// If CONFIG_FILE is set, the YAML or JSON file it points to supplies values for any
+// variable not set in the environment; a file that cannot be read or parsed is an error,
+// as is a metrics flush on shutdown with nowhere to write it.
+// If a value is not set or invalid, the default is used.
+func Load() (*Config, error) {
+	var src source
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		values, err := loadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CONFIG_FILE: %w", err)
+		}
+		src = values
 	}
+
+	cfg := &Config{
+		Port:                src.getEnv("PORT", "8080"),
+		CacheType:           src.getEnv("CACHE_TYPE", "memory"),
+		CacheTTL:            src.getDurationEnv("CACHE_TTL", 1*time.Hour),
+		CacheMode:           src.getEnv("CACHE_MODE", "parsed"),
+		CacheSerialization:  src.getEnv("CACHE_SERIALIZATION", "json"),
+		RateLimitPerSecond:  src.getIntEnv("RATE_LIMIT_PER_SECOND", 10),
+		RateLimitBurst:      src.getIntEnv("RATE_LIMIT_BURST", 0),
+		RedisAddr:           src.getEnv("REDIS_ADDR", "localhost:6379"),
+		RedisPassword:       src.getEnv("REDIS_PASSWORD", ""),
+		RedisDB:             src.getIntEnv("REDIS_DB", 0),
+		RedisMode:           src.getEnv("REDIS_MODE", "single"),
+		RedisSentinelAddrs:  src.getListEnv("REDIS_SENTINEL_ADDRS"),
+		RedisMasterName:     src.getEnv("REDIS_MASTER_NAME", ""),
+		RedisClusterAddrs:   src.getListEnv("REDIS_CLUSTER_ADDRS"),
+		RedisKeyPrefix:      src.getEnv("REDIS_KEY_PREFIX", ""),
+		FileStoragePath:     src.getEnv("FILE_STORAGE_PATH", "./cache"),
+		RequestTimeout:      src.getDurationEnv("REQUEST_TIMEOUT", 10*time.Second),
+		FetchMaxConcurrent:  src.getIntEnv("FETCH_MAX_CONCURRENT", 100),
+		FetchBasicAuth:      src.getListEnv("FETCH_BASIC_AUTH"),
+		FetchDNSCacheTTL:    src.getDurationEnv("FETCH_DNS_CACHE_TTL", 60*time.Second),
+		CommentDirectives:   src.getListEnv("COMMENT_DIRECTIVES"),
+		AccountPrefixGroups: src.getListEnv("ACCOUNT_PREFIX_GROUPS"),
+		MaxAdvertisers:      src.getIntEnv("MAX_ADVERTISERS", 100000),
+		MaxLineLength:       src.getIntEnv("MAX_LINE_LENGTH", 8192),
+		ChangeHistoryTTL:    src.getDurationEnv("CHANGE_HISTORY_TTL", 0),
+		NegativeCacheTTL:    src.getDurationEnv("NEGATIVE_CACHE_TTL", 5*time.Minute),
+		NormalizeWWW:        src.getBoolEnv("NORMALIZE_WWW", false),
+		AdminToken:          src.getEnv("ADMIN_TOKEN", ""),
+		StrictJSON:          src.getBoolEnv("STRICT_JSON", true),
+		TimestampPrecision:  src.getEnv("TIMESTAMP_PRECISION", "seconds"),
+		IncludeRawHash:      src.getBoolEnv("INCLUDE_RAW_HASH", false),
+
+		FetchMaxIdleConns:        src.getIntEnv("FETCH_MAX_IDLE_CONNS", 100),
+		FetchMaxIdleConnsPerHost: src.getIntEnv("FETCH_MAX_IDLE_CONNS_PER_HOST", 10),
+		FetchIdleConnTimeout:     src.getDurationEnv("FETCH_IDLE_CONN_TIMEOUT", 90*time.Second),
+
+		FetchMaxDomainLabels: src.getIntEnv("FETCH_MAX_DOMAIN_LABELS", 10),
+		FetchMaxURLLength:    src.getIntEnv("FETCH_MAX_URL_LENGTH", 2048),
+
+		FetchCircuitFailureThreshold: src.getIntEnv("FETCH_CIRCUIT_FAILURE_THRESHOLD", 5),
+		FetchCircuitWindow:           src.getDurationEnv("FETCH_CIRCUIT_WINDOW", 1*time.Minute),
+		FetchCircuitCooldown:         src.getDurationEnv("FETCH_CIRCUIT_COOLDOWN", 30*time.Second),
+
+		FetchAllowedDomains: src.getListEnv("FETCH_ALLOWED_DOMAINS"),
+		FetchAllowedTLDs:    src.getListEnv("FETCH_ALLOWED_TLDS"),
+
+		FetchSameDomainRedirectsOnly: src.getBoolEnv("FETCH_SAME_DOMAIN_REDIRECTS_ONLY", false),
+		FetchRedirectAllowedDomains:  src.getListEnv("FETCH_REDIRECT_ALLOWED_DOMAINS"),
+
+		FetchHTTPFallback:  src.getEnv("FETCH_HTTP_FALLBACK", "transport"),
+		FetchMaxAttempts:   src.getIntEnv("FETCH_MAX_ATTEMPTS", 3),
+		FetchNoWWWFallback: src.getBoolEnv("FETCH_NO_WWW_FALLBACK", false),
+
+		FetchRace:        src.getBoolEnv("FETCH_RACE", false),
+		FetchRaceStagger: src.getDurationEnv("FETCH_RACE_STAGGER", 250*time.Millisecond),
+
+		FetchClientCert:      src.getEnv("FETCH_CLIENT_CERT", ""),
+		FetchClientKey:       src.getEnv("FETCH_CLIENT_KEY", ""),
+		FetchCACert:          src.getEnv("FETCH_CA_CERT", ""),
+		FetchMinTLSVersion:   src.getEnv("FETCH_MIN_TLS_VERSION", "1.2"),
+		FetchClientCertHosts: src.getListEnv("FETCH_CLIENT_CERT_HOSTS"),
+
+		FetchProxyURL: src.getEnv("FETCH_PROXY_URL", ""),
+
+		FetchInsecureSkipVerifyHosts: src.getListEnv("FETCH_INSECURE_SKIP_VERIFY_HOSTS"),
+
+		MaxInflightRequests:    src.getIntEnv("MAX_INFLIGHT_REQUESTS", 1000),
+		MaxConcurrentPerClient: src.getIntEnv("MAX_CONCURRENT_PER_CLIENT", 20),
+		BatchWorkers:           src.getIntEnv("BATCH_WORKERS", 32),
+
+		BatchStreamMaxDomains: src.getIntEnv("BATCH_STREAM_MAX_DOMAINS", 500),
+		BatchMaxBodyBytes:     src.getIntEnv("BATCH_MAX_BODY_BYTES", 1<<20),
+		BatchStreamTimeout:    src.getDurationEnv("BATCH_STREAM_TIMEOUT", 5*time.Minute),
+
+		CacheEmptyResults:   src.getBoolEnv("CACHE_EMPTY_RESULTS", false),
+		EmptyResultCacheTTL: src.getDurationEnv("EMPTY_RESULT_CACHE_TTL", 5*time.Minute),
+
+		ServeStaleOnError: src.getBoolEnv("SERVE_STALE_ON_ERROR", false),
+		StaleGracePeriod:  src.getDurationEnv("STALE_GRACE_PERIOD", 24*time.Hour),
+
+		SeedDir: src.getEnv("SEED_DIR", ""),
+
+		HealthCacheTTL: src.getDurationEnv("HEALTH_CACHE_TTL", 5*time.Second),
+
+		SlowLogSize:      src.getIntEnv("SLOW_LOG_SIZE", 20),
+		SlowLogThreshold: src.getDurationEnv("SLOW_LOG_THRESHOLD", time.Second),
+		SlowLogWindow:    src.getDurationEnv("SLOW_LOG_WINDOW", time.Hour),
+
+		CacheVerifyTimeout: src.getDurationEnv("CACHE_VERIFY_TIMEOUT", 2*time.Minute),
+
+		ShutdownDrainDelay:      src.getDurationEnv("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+		MetricsFlushOnShutdown:  src.getBoolEnv("METRICS_FLUSH_ON_SHUTDOWN", false),
+		MetricsFlushDestination: src.getEnv("METRICS_FLUSH_DESTINATION", ""),
+
+		TracingEnabled: src.getBoolEnv("TRACING_ENABLED", false),
+
+		ResponseCompressionLevel: src.getIntEnv("RESPONSE_COMPRESSION_LEVEL", 6),
+
+		VaryHeaders: src.getListEnv("VARY_HEADERS"),
+
+		CORSAllowedMethods: src.getListEnv("CORS_ALLOWED_METHODS"),
+		CORSAllowedHeaders: src.getListEnv("CORS_ALLOWED_HEADERS"),
+		CORSMaxAge:         src.getDurationEnv("CORS_MAX_AGE", 24*time.Hour),
+
+		MemoryCleanupInterval:    src.getDurationEnv("MEMORY_CLEANUP_INTERVAL", 5*time.Minute),
+		RateLimitCleanupInterval: src.getDurationEnv("RATELIMIT_CLEANUP_INTERVAL", 1*time.Minute),
+		RateLimitClientTTL:       src.getDurationEnv("RATELIMIT_CLIENT_TTL", 5*time.Minute),
+		RateLimitMaxClients:      src.getIntEnv("RATELIMIT_MAX_CLIENTS", 100000),
+
+		AutoRefreshTopK:     src.getIntEnv("AUTO_REFRESH_TOP_K", 0),
+		AutoRefreshInterval: src.getDurationEnv("AUTO_REFRESH_INTERVAL", 1*time.Minute),
+		AutoRefreshAhead:    src.getDurationEnv("AUTO_REFRESH_AHEAD", 5*time.Minute),
+		AutoRefreshRate:     src.getIntEnv("AUTO_REFRESH_RATE", 1),
+
+		MinAdvertisersThreshold: src.getIntEnv("MIN_ADVERTISERS_THRESHOLD", 0),
+
+		JobWorkers:    src.getIntEnv("JOB_WORKERS", 4),
+		JobFetchRate:  src.getIntEnv("JOB_FETCH_RATE", 10),
+		JobMaxDomains: src.getIntEnv("JOB_MAX_DOMAINS", 10000),
+		JobTTL:        src.getDurationEnv("JOB_TTL", 24*time.Hour),
+	}
+	if cfg.MetricsFlushOnShutdown && cfg.MetricsFlushDestination == "" {
+		return nil, fmt.Errorf("METRICS_FLUSH_ON_SHUTDOWN requires a METRICS_FLUSH_DESTINATION")
+	}
+	return cfg, nil
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+func (src source) getEnv(key, defaultValue string) string {
+	if value := src.lookup(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
 
-func getIntEnv(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
+func (src source) getIntEnv(key string, defaultValue int) int {
+	if value := src.lookup(key); value != "" {
 		if intValue, err := strconv.Atoi(value); err == nil {
 			return intValue
 		}
@@ -54,11 +323,31 @@ func getIntEnv(key string, defaultValue int) int {
 	return defaultValue
 }
 
-func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
-	if value := os.Getenv(key); value != "" {
+func (src source) getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	if value := src.lookup(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
 			return duration
 		}
 	}
 	return defaultValue
 }
+
+func (src source) getBoolEnv(key string, defaultValue bool) bool {
+	if value := src.lookup(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// getListEnv splits a comma-separated environment variable into trimmed, non-empty values.
+func (src source) getListEnv(key string) []string {
+	var values []string
+	for _, part := range strings.Split(src.lookup(key), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile reads a YAML or JSON config file into a map keyed by environment variable name.
+// Keys are the lowercase env names (e.g. "cache_ttl: 2h" sets CACHE_TTL). Values are kept
+// as strings so they go through the same parsing as env vars; lists are joined with commas.
+// Files ending in .json are parsed as JSON, everything else as YAML.
+func loadFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make(map[string]interface{})
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &raw)
+	} else {
+		err = yaml.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		values[strings.ToUpper(key)] = stringifyValue(value)
+	}
+	return values, nil
+}
+
+func stringifyValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		// JSON numbers all decode as float64; %v would turn 10000000 into "1e+07"
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, stringifyValue(item))
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// source holds settings read from CONFIG_FILE, keyed by environment variable name; nil
+// without one. Environment variables take precedence over these values.
+type source map[string]string
+
+// lookup returns the value for key from the environment, falling back to the config file.
+func (src source) lookup(key string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return src[key]
+}
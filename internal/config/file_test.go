@@ -0,0 +1,151 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad_YAMLFileOnly(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+
+	path := writeConfigFile(t, "config.yaml", `
+port: 9090
+cache_type: file
+cache_ttl: 30m
+normalize_www: true
+redis_sentinel_addrs:
+  - sentinel-1:26379
+  - sentinel-2:26379
+`)
+	os.Setenv("CONFIG_FILE", path)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Port != "9090" {
+		t.Errorf("Port = %v, want 9090", cfg.Port)
+	}
+	if cfg.CacheType != "file" {
+		t.Errorf("CacheType = %v, want file", cfg.CacheType)
+	}
+	if cfg.CacheTTL != 30*time.Minute {
+		t.Errorf("CacheTTL = %v, want 30m", cfg.CacheTTL)
+	}
+	if !cfg.NormalizeWWW {
+		t.Error("NormalizeWWW = false, want true")
+	}
+	want := []string{"sentinel-1:26379", "sentinel-2:26379"}
+	if !reflect.DeepEqual(cfg.RedisSentinelAddrs, want) {
+		t.Errorf("RedisSentinelAddrs = %v, want %v", cfg.RedisSentinelAddrs, want)
+	}
+
+	// Values absent from the file keep their defaults
+	if cfg.RateLimitPerSecond != 10 {
+		t.Errorf("RateLimitPerSecond = %v, want default 10", cfg.RateLimitPerSecond)
+	}
+}
+
+func TestLoad_JSONFileOnly(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+
+	path := writeConfigFile(t, "config.json", `{"port": "7000", "rate_limit_per_second": 25, "batch_max_body_bytes": 10000000, "max_advertisers": 1e6}`)
+	os.Setenv("CONFIG_FILE", path)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Port != "7000" {
+		t.Errorf("Port = %v, want 7000", cfg.Port)
+	}
+	if cfg.RateLimitPerSecond != 25 {
+		t.Errorf("RateLimitPerSecond = %v, want 25", cfg.RateLimitPerSecond)
+	}
+	// Large numbers are not formatted in exponent notation, which would fail to parse as ints
+	if cfg.BatchMaxBodyBytes != 10000000 {
+		t.Errorf("BatchMaxBodyBytes = %v, want 10000000", cfg.BatchMaxBodyBytes)
+	}
+	if cfg.MaxAdvertisers != 1000000 {
+		t.Errorf("MaxAdvertisers = %v, want 1000000", cfg.MaxAdvertisers)
+	}
+}
+
+func TestLoad_EnvOverridesFile(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+
+	path := writeConfigFile(t, "config.yaml", "port: 9090\ncache_type: file\n")
+	os.Setenv("CONFIG_FILE", path)
+	os.Setenv("PORT", "6000")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Port != "6000" {
+		t.Errorf("Port = %v, want env value 6000", cfg.Port)
+	}
+	if cfg.CacheType != "file" {
+		t.Errorf("CacheType = %v, want file value 'file'", cfg.CacheType)
+	}
+}
+
+func TestLoad_EnvOnly(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+
+	os.Setenv("PORT", "6000")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Port != "6000" {
+		t.Errorf("Port = %v, want 6000", cfg.Port)
+	}
+	if cfg.CacheType != "memory" {
+		t.Errorf("CacheType = %v, want default memory", cfg.CacheType)
+	}
+}
+
+func TestLoad_InvalidFile(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"missing file", filepath.Join(t.TempDir(), "missing.yaml")},
+		{"malformed file", writeConfigFile(t, "bad.json", "{not json")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("CONFIG_FILE", tt.path)
+
+			if cfg, err := Load(); err == nil {
+				t.Errorf("Load() = %+v, want an error for the unusable config file", cfg)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// redacted replaces a secret that is set, so a summary still shows whether it is configured.
+const redacted = "[REDACTED]"
+
+// secretFields redacts the settings that hold credentials. Add new secret settings here,
+// or Summary will expose them.
+var secretFields = map[string]func(value interface{}) interface{}{
+	"RedisPassword":           redactString,
+	"AdminToken":              redactString,
+	"FetchBasicAuth":          redactBasicAuth,
+	"FetchProxyURL":           redactURL,
+	"MetricsFlushDestination": redactURL,
+}
+
+// Summary returns every setting keyed by its Config field name, for diagnostics such as
+// /api/snapshot. Durations are rendered like "1h0m0s" and credentials are redacted.
+func (c *Config) Summary() map[string]interface{} {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	summary := make(map[string]interface{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i).Interface()
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+		if redact, ok := secretFields[field.Name]; ok {
+			value = redact(value)
+		}
+		summary[field.Name] = value
+	}
+	return summary
+}
+
+func redactString(value interface{}) interface{} {
+	if value.(string) == "" {
+		return ""
+	}
+	return redacted
+}
+
+// redactBasicAuth keeps the domain of each domain=user:pass entry.
+func redactBasicAuth(value interface{}) interface{} {
+	entries := value.([]string)
+	out := make([]string, len(entries))
+	for i, entry := range entries {
+		domain, _, _ := strings.Cut(entry, "=")
+		out[i] = domain + "=" + redacted
+	}
+	return out
+}
+
+// redactURL keeps a URL's scheme, host and path (or a file path as is) but replaces any
+// userinfo and query string, where push endpoints often take a token.
+func redactURL(value interface{}) interface{} {
+	raw := value.(string)
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = redacted
+	}
+	u.Fragment, u.RawFragment = "", ""
+	if u.User == nil {
+		return u.String()
+	}
+	u.User = nil
+	return strings.Replace(u.String(), "://", "://"+redacted+"@", 1)
+}
@@ -3,26 +3,43 @@
 package ratelimit
 
 import (
+	"container/list"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // RateLimiter implements a token bucket rate limiting algorithm with per-client tracking.
+// Each client's bucket holds up to burst tokens and refills continuously at limit per second,
+// so a client can spend a burst in a spike while its sustained rate stays at the limit.
 // It is safe for concurrent use and automatically cleans up inactive clients.
 type RateLimiter struct {
-	limit    int
-	window   time.Duration
-	clients  map[string]*clientBucket
-	mu       sync.RWMutex
-	cleanupT *time.Ticker
+	limit      int
+	burst      int           // Bucket capacity, 0 means limit
+	clientTTL  time.Duration // Inactivity period after which a client is forgotten
+	maxClients int           // Cap on tracked clients, 0 means unbounded
+	clients    map[string]*clientBucket
+	recent     *list.List // Client IDs, most recently seen first, for evicting at maxClients
+	mu         sync.RWMutex
+	cleanupT   *time.Ticker
+
+	cleanupInterval time.Duration
+	lastCleanup     atomic.Int64 // UnixNano of the last completed sweep, or of creation
 }
 
+// Default cleanup settings used by NewRateLimiter.
+const (
+	DefaultCleanupInterval = 1 * time.Minute
+	DefaultClientTTL       = 5 * time.Minute
+)
+
 // clientBucket represents a token bucket for a single client.
-// Each client has their own bucket with a fixed number of tokens that refill over time.
+// Each client has their own bucket of tokens that refill over time.
 type clientBucket struct {
-	tokens    int
-	lastReset time.Time
+	tokens    float64       // Fractional, as tokens refill continuously
+	lastReset time.Time     // When tokens were last refilled, i.e. the client's last request
+	elem      *list.Element // Position in RateLimiter.recent, guarded by RateLimiter.mu
 	mu        sync.Mutex
 }
 
@@ -30,13 +47,30 @@ type clientBucket struct {
 // It starts a background goroutine that cleans up inactive clients every minute.
 // Clients that have been inactive for more than 5 minutes are removed.
 func NewRateLimiter(limitPerSecond int) *RateLimiter {
+	return NewRateLimiterWithCleanup(limitPerSecond, DefaultCleanupInterval, DefaultClientTTL)
+}
+
+// NewRateLimiterWithCleanup creates a new RateLimiter with custom cleanup settings.
+// Inactive clients are swept every cleanupInterval and removed once idle for longer than clientTTL.
+// Non-positive values fall back to DefaultCleanupInterval and DefaultClientTTL.
+func NewRateLimiterWithCleanup(limitPerSecond int, cleanupInterval, clientTTL time.Duration) *RateLimiter {
+	if cleanupInterval <= 0 {
+		cleanupInterval = DefaultCleanupInterval
+	}
+	if clientTTL <= 0 {
+		clientTTL = DefaultClientTTL
+	}
+
 	rl := &RateLimiter{
-		limit:   limitPerSecond,
-		window:  time.Second,
-		clients: make(map[string]*clientBucket),
+		limit:     limitPerSecond,
+		clientTTL: clientTTL,
+		clients:   make(map[string]*clientBucket),
+		recent:    list.New(),
 	}
 
-	rl.cleanupT = time.NewTicker(1 * time.Minute)
+	rl.cleanupT = time.NewTicker(cleanupInterval)
+	rl.cleanupInterval = cleanupInterval
+	rl.lastCleanup.Store(time.Now().UnixNano())
 	go rl.cleanup()
 
 	return rl
@@ -44,18 +78,28 @@ func NewRateLimiter(limitPerSecond int) *RateLimiter {
 
 // Allow checks if a request from the given client should be allowed based on the rate limit.
 // Returns true if the client has available tokens, false otherwise.
-// Uses token bucket algorithm - tokens refill every second up to the limit.
+// Uses token bucket algorithm - tokens refill at the limit per second, up to the burst.
 func (rl *RateLimiter) Allow(clientID string) bool {
 	// Fixed race condition: was using RLock first, but multiple goroutines could create
 	// duplicate buckets. Now use write lock from start.
 	// TODO: Could optimize with sync.Map but current approach is simpler
 	rl.mu.Lock()
+	burst := float64(rl.burst)
+	if rl.burst <= 0 {
+		burst = float64(rl.limit)
+	}
 	bucket, exists := rl.clients[clientID]
-	if !exists {
+	if exists {
+		rl.recent.MoveToFront(bucket.elem)
+	} else {
+		if rl.maxClients > 0 && len(rl.clients) >= rl.maxClients {
+			rl.evictOldest()
+		}
 		bucket = &clientBucket{
-			tokens:    rl.limit,
+			tokens:    burst,
 			lastReset: time.Now(),
 		}
+		bucket.elem = rl.recent.PushFront(clientID)
 		rl.clients[clientID] = bucket
 	}
 	rl.mu.Unlock()
@@ -64,12 +108,11 @@ func (rl *RateLimiter) Allow(clientID string) bool {
 	defer bucket.mu.Unlock()
 
 	now := time.Now()
-	if now.Sub(bucket.lastReset) >= rl.window {
-		bucket.tokens = rl.limit
-		bucket.lastReset = now
-	}
+	refilled := bucket.tokens + now.Sub(bucket.lastReset).Seconds()*float64(rl.limit)
+	bucket.tokens = min(refilled, burst)
+	bucket.lastReset = now
 
-	if bucket.tokens > 0 {
+	if bucket.tokens >= 1 {
 		bucket.tokens--
 		return true
 	}
@@ -77,10 +120,60 @@ func (rl *RateLimiter) Allow(clientID string) bool {
 	return false
 }
 
+// SetBurst sets how many requests a client may make in a spike: its bucket holds up to burst
+// tokens, still refilled at the per-second limit. A burst of 0 (the default) equals the limit.
+// Existing clients keep their current tokens, capped at the new burst on their next request.
+func (rl *RateLimiter) SetBurst(burst int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.burst = burst
+}
+
+// SetMaxClients caps the number of clients tracked at once, bounding memory even when a
+// flood of distinct IPs arrives faster than cleanup runs. When the cap is reached, the
+// least recently seen client is forgotten to make room, so it starts over with a full
+// bucket if it returns. A max of 0 (the default) leaves the number of clients unbounded.
+func (rl *RateLimiter) SetMaxClients(max int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.maxClients = max
+	for max > 0 && len(rl.clients) > max {
+		rl.evictOldest()
+	}
+}
+
+// evictOldest forgets the least recently seen client. Callers must hold rl.mu for writing.
+func (rl *RateLimiter) evictOldest() {
+	for oldest := rl.recent.Back(); oldest != nil; oldest = rl.recent.Back() {
+		rl.recent.Remove(oldest)
+		clientID := oldest.Value.(string)
+		if bucket, ok := rl.clients[clientID]; ok && bucket.elem == oldest {
+			delete(rl.clients, clientID)
+			return
+		}
+	}
+}
+
+// LastCleanup returns when the last inactive-client sweep completed, or when the limiter was
+// created if none has yet. Sweeps that panic are not counted, so a broken cleanup shows up as stale.
+func (rl *RateLimiter) LastCleanup() time.Time {
+	return time.Unix(0, rl.lastCleanup.Load())
+}
+
+// CleanupInterval returns how often inactive clients are swept.
+func (rl *RateLimiter) CleanupInterval() time.Duration {
+	return rl.cleanupInterval
+}
+
+// cleanup removes inactive clients on every cleanup tick. If the goroutine itself
+// panics, it is restarted so sweeping never silently stops.
 func (rl *RateLimiter) cleanup() {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Printf("PANIC in RateLimiter cleanup goroutine: %v", r)
+			log.Printf("PANIC in RateLimiter cleanup goroutine, restarting: %v", r)
+			go rl.cleanup()
 		}
 	}()
 
@@ -98,7 +191,7 @@ func (rl *RateLimiter) cleanup() {
 			rl.mu.RLock() // Use read lock first
 			for clientID, bucket := range rl.clients {
 				bucket.mu.Lock()
-				if now.Sub(bucket.lastReset) > 5*time.Minute {
+				if now.Sub(bucket.lastReset) > rl.clientTTL {
 					toDelete = append(toDelete, clientID)
 				}
 				bucket.mu.Unlock()
@@ -109,12 +202,16 @@ func (rl *RateLimiter) cleanup() {
 			if len(toDelete) > 0 {
 				rl.mu.Lock()
 				for _, clientID := range toDelete {
-					delete(rl.clients, clientID)
+					if bucket, ok := rl.clients[clientID]; ok {
+						rl.recent.Remove(bucket.elem)
+						delete(rl.clients, clientID)
+					}
 				}
 				rl.mu.Unlock()
 
 				log.Printf("RateLimiter cleanup: removed %d inactive clients", len(toDelete))
 			}
+			rl.lastCleanup.Store(time.Now().UnixNano())
 		}()
 	}
 }
@@ -389,3 +389,174 @@ func TestRateLimiter_CleanupPartial(t *testing.T) {
 		}
 	}
 }
+
+func TestRateLimiter_CustomCleanup(t *testing.T) {
+	rl := NewRateLimiterWithCleanup(10, 20*time.Millisecond, 30*time.Millisecond)
+	defer rl.Stop()
+
+	rl.Allow("client-1")
+
+	// Wait past the client TTL plus a few cleanup ticks
+	time.Sleep(150 * time.Millisecond)
+
+	rl.mu.RLock()
+	count := len(rl.clients)
+	rl.mu.RUnlock()
+
+	if count != 0 {
+		t.Errorf("Expected inactive client to be removed, got %d clients", count)
+	}
+}
+
+func TestRateLimiter_LastCleanup(t *testing.T) {
+	rl := NewRateLimiterWithCleanup(10, 20*time.Millisecond, time.Minute)
+	defer rl.Stop()
+
+	created := rl.LastCleanup()
+	if rl.CleanupInterval() != 20*time.Millisecond {
+		t.Errorf("Expected cleanup interval 20ms, got %v", rl.CleanupInterval())
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if !rl.LastCleanup().After(created) {
+		t.Error("Expected LastCleanup to advance after background sweeps")
+	}
+}
+
+func TestNewRateLimiterWithCleanup_Defaults(t *testing.T) {
+	rl := NewRateLimiterWithCleanup(10, 0, 0)
+	defer rl.Stop()
+
+	if rl.clientTTL != DefaultClientTTL {
+		t.Errorf("Expected default client TTL %v, got %v", DefaultClientTTL, rl.clientTTL)
+	}
+}
+
+func TestRateLimiter_MaxClients(t *testing.T) {
+	rl := NewRateLimiter(1)
+	defer rl.Stop()
+	rl.SetMaxClients(2)
+
+	rl.Allow("client-a")
+	rl.Allow("client-b")
+	rl.Allow("client-a") // client-a is now the most recently seen
+
+	// A third client evicts the least recently seen one (client-b)
+	rl.Allow("client-c")
+
+	rl.mu.RLock()
+	_, hasA := rl.clients["client-a"]
+	_, hasB := rl.clients["client-b"]
+	_, hasC := rl.clients["client-c"]
+	count := len(rl.clients)
+	rl.mu.RUnlock()
+
+	if count != 2 {
+		t.Errorf("Expected 2 tracked clients, got %d", count)
+	}
+	if !hasA || hasB || !hasC {
+		t.Errorf("Expected client-b to be evicted, got a=%v b=%v c=%v", hasA, hasB, hasC)
+	}
+
+	// client-a keeps its exhausted bucket; the evicted client-b starts over
+	if rl.Allow("client-a") {
+		t.Error("Expected client-a to remain rate limited")
+	}
+	if !rl.Allow("client-b") {
+		t.Error("Expected evicted client-b to get a fresh bucket")
+	}
+}
+
+func TestRateLimiter_SetMaxClientsShrinks(t *testing.T) {
+	rl := NewRateLimiter(10)
+	defer rl.Stop()
+
+	for i := 0; i < 10; i++ {
+		rl.Allow(fmt.Sprintf("client-%d", i))
+	}
+	rl.SetMaxClients(3)
+
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	if len(rl.clients) != 3 || rl.recent.Len() != 3 {
+		t.Errorf("Expected 3 tracked clients, got %d (list %d)", len(rl.clients), rl.recent.Len())
+	}
+	for i := 7; i < 10; i++ {
+		if _, ok := rl.clients[fmt.Sprintf("client-%d", i)]; !ok {
+			t.Errorf("Expected most recent client-%d to be kept", i)
+		}
+	}
+}
+
+func TestRateLimiter_MaxClientsUnbounded(t *testing.T) {
+	rl := NewRateLimiter(10)
+	defer rl.Stop()
+	rl.SetMaxClients(0)
+
+	for i := 0; i < 100; i++ {
+		rl.Allow(fmt.Sprintf("client-%d", i))
+	}
+
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	if len(rl.clients) != 100 {
+		t.Errorf("Expected 100 tracked clients, got %d", len(rl.clients))
+	}
+}
+
+func TestRateLimiter_Burst(t *testing.T) {
+	rl := NewRateLimiter(2)
+	defer rl.Stop()
+	rl.SetBurst(10)
+
+	clientID := "bursty-client"
+
+	// A spike can spend the whole burst at once, well above the per-second limit
+	for i := 0; i < 10; i++ {
+		if !rl.Allow(clientID) {
+			t.Fatalf("Request %d of the burst should be allowed", i+1)
+		}
+	}
+	if rl.Allow(clientID) {
+		t.Error("Request 11 should be blocked once the burst is spent")
+	}
+
+	// Tokens come back at the limit, 2 per second, not all at once
+	time.Sleep(600 * time.Millisecond)
+	if !rl.Allow(clientID) {
+		t.Error("Expected one token to have refilled after 600ms")
+	}
+	if rl.Allow(clientID) {
+		t.Error("Expected only one token to have refilled after 600ms")
+	}
+
+	// However long the client is idle, the bucket never holds more than the burst
+	rl.mu.RLock()
+	bucket := rl.clients[clientID]
+	rl.mu.RUnlock()
+	bucket.mu.Lock()
+	bucket.lastReset = time.Now().Add(-time.Hour)
+	bucket.mu.Unlock()
+
+	allowed := 0
+	for rl.Allow(clientID) {
+		allowed++
+	}
+	if allowed != 10 {
+		t.Errorf("Expected a full bucket of 10 after idling, got %d", allowed)
+	}
+}
+
+func TestRateLimiter_BurstDefaultsToLimit(t *testing.T) {
+	rl := NewRateLimiter(3)
+	defer rl.Stop()
+	rl.SetBurst(0)
+
+	allowed := 0
+	for rl.Allow("client") {
+		allowed++
+	}
+	if allowed != 3 {
+		t.Errorf("Expected a burst equal to the limit (3), got %d", allowed)
+	}
+}
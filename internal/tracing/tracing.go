@@ -0,0 +1,113 @@
+// Package tracing instruments requests and outbound fetches with OpenTelemetry spans, taken
+// from the global tracer provider (see otel.SetTracerProvider), and correlates log lines with
+// them. Until a provider is installed the global one is a no-op: spans are not recorded,
+// logs gain no attributes and no traceparent is sent anywhere.
+package tracing
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header is the W3C Trace Context request header.
+const Header = "traceparent"
+
+// instrumentationName identifies this service's tracer to the provider.
+const instrumentationName = "adstxt-api"
+
+// propagator reads and writes traceparent headers.
+var propagator = propagation.TraceContext{}
+
+func tracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(instrumentationName)
+}
+
+// StartServer starts the span for an inbound request, continuing the caller's trace from its
+// traceparent header or starting a new one. End it with End.
+func StartServer(r *http.Request) (context.Context, trace.Span) {
+	ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	return tracer().Start(ctx, r.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+		))
+}
+
+// StartClient starts the span for an outbound GET of url, as a child of the span in ctx.
+// End it with End.
+func StartClient(ctx context.Context, url string) (context.Context, trace.Span) {
+	return tracer().Start(ctx, http.MethodGet,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", http.MethodGet),
+			attribute.String("url.full", url),
+		))
+}
+
+// End records the response status (0 if none was received) and err on span and ends it.
+// Server errors and client-side failures mark the span as failed.
+func End(span trace.Span, status int, err error) {
+	if status != 0 {
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+	}
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case status >= 500:
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
+
+// localSpan returns the span context of the span this service started in ctx. A remote span
+// context, extracted from a caller while no tracer provider is installed, does not count:
+// the no-op provider passes it through unchanged rather than starting a span of its own.
+func localSpan(ctx context.Context) (trace.SpanContext, bool) {
+	sc := trace.SpanContextFromContext(ctx)
+	return sc, sc.IsValid() && !sc.IsRemote()
+}
+
+// Inject sets the traceparent header on an outbound request from the span in ctx, making
+// that span the request's parent. It does nothing unless this service started that span, so
+// without a tracer provider a caller's trace is never passed on to third-party hosts.
+func Inject(ctx context.Context, header http.Header) {
+	if _, ok := localSpan(ctx); ok {
+		propagator.Inject(ctx, propagation.HeaderCarrier(header))
+	}
+}
+
+// LogHandler adds trace_id and span_id attributes to records logged with a context that
+// carries a span this service started, i.e. through the slog *Context methods. Other records
+// pass through as is.
+type LogHandler struct {
+	slog.Handler
+}
+
+// NewLogHandler wraps next with trace correlation.
+func NewLogHandler(next slog.Handler) *LogHandler {
+	return &LogHandler{Handler: next}
+}
+
+func (h *LogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if sc, ok := localSpan(ctx); ok {
+		record.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{Handler: h.Handler.WithGroup(name)}
+}
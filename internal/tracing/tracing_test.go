@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// installRecorder installs a recording tracer provider for the rest of the test.
+func installRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+	return recorder
+}
+
+// requestFromCaller returns an inbound request carrying the incoming traceparent.
+func requestFromCaller() *http.Request {
+	req := httptest.NewRequest("GET", "/api/analyze", nil)
+	req.Header.Set(Header, incoming)
+	return req
+}
+
+func TestStartServer(t *testing.T) {
+	recorder := installRecorder(t)
+
+	ctx, span := StartServer(requestFromCaller())
+	sc := span.SpanContext()
+	if got := sc.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID = %s, want the caller's", got)
+	}
+	if got := sc.SpanID().String(); got == "00f067aa0ba902b7" {
+		t.Error("span ID is the caller's, want a child span")
+	}
+	if _, ok := localSpan(ctx); !ok {
+		t.Error("request context does not carry the new span")
+	}
+	End(span, http.StatusServiceUnavailable, nil)
+
+	_, client := StartClient(ctx, "https://example.com/ads.txt")
+	End(client, 0, errors.New("connection refused"))
+
+	ended := recorder.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("%d spans ended, want 2", len(ended))
+	}
+	server, fetch := ended[0], ended[1]
+	if server.Parent().SpanID().String() != "00f067aa0ba902b7" || server.Status().Code != codes.Error {
+		t.Errorf("server span parent = %s, status = %v, want the caller's span and an error", server.Parent().SpanID(), server.Status())
+	}
+	if !hasAttribute(server.Attributes(), attribute.Int("http.response.status_code", http.StatusServiceUnavailable)) {
+		t.Errorf("server span attributes = %v, want the response status", server.Attributes())
+	}
+	if fetch.Parent().SpanID() != sc.SpanID() || fetch.Status().Code != codes.Error || len(fetch.Events()) == 0 {
+		t.Errorf("fetch span parent = %s, status = %v, events = %v, want the request span and the recorded error", fetch.Parent().SpanID(), fetch.Status(), fetch.Events())
+	}
+}
+
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, kv := range attrs {
+		if kv == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestInject(t *testing.T) {
+	// Without a tracer provider the caller's traceparent is not passed on
+	ctx, span := StartServer(requestFromCaller())
+	header := http.Header{}
+	Inject(ctx, header)
+	End(span, http.StatusOK, nil)
+	if got := header.Get(Header); got != "" {
+		t.Errorf("Inject() without a tracer provider set traceparent %q", got)
+	}
+	Inject(context.Background(), header)
+	if got := header.Get(Header); got != "" {
+		t.Errorf("Inject() without a span set traceparent %q", got)
+	}
+
+	installRecorder(t)
+	ctx, span = StartServer(requestFromCaller())
+	defer span.End()
+	Inject(ctx, header)
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + span.SpanContext().SpanID().String() + "-01"
+	if got := header.Get(Header); got != want {
+		t.Errorf("traceparent = %q, want %q", got, want)
+	}
+}
+
+func TestLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(&buf, nil))).With(slog.String("component", "test"))
+
+	remote, remoteSpan := StartServer(requestFromCaller()) // No tracer provider yet
+	logger.InfoContext(remote, "caller's span only")
+	remoteSpan.End()
+	installRecorder(t)
+	ctx, span := StartServer(requestFromCaller())
+	defer span.End()
+	logger.InfoContext(ctx, "with span")
+	logger.Info("without span")
+
+	dec := json.NewDecoder(&buf)
+	var withRemote, withSpan, withoutSpan map[string]any
+	for _, record := range []*map[string]any{&withRemote, &withSpan, &withoutSpan} {
+		if err := dec.Decode(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sc := span.SpanContext()
+	if withSpan["trace_id"] != sc.TraceID().String() || withSpan["span_id"] != sc.SpanID().String() {
+		t.Errorf("record = %v, want trace_id %s and span_id %s", withSpan, sc.TraceID(), sc.SpanID())
+	}
+	if withSpan["component"] != "test" {
+		t.Errorf("record lost attributes added with With: %v", withSpan)
+	}
+	if _, ok := withRemote["trace_id"]; ok {
+		t.Errorf("record without a tracer provider got trace_id: %v", withRemote)
+	}
+	if _, ok := withoutSpan["trace_id"]; ok {
+		t.Errorf("record without a span got trace_id: %v", withoutSpan)
+	}
+}